@@ -12,7 +12,7 @@
 //
 // You should have received a copy of the GNU General Public License along
 // with this program; if not, see <http://www.gnu.org/licenses/>.
-package cmd
+package cmd_box
 
 import (
 	"fmt"
@@ -27,13 +27,16 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func newExecCommand(cfg *config.LuetConfig) *cobra.Command {
+// NewExecCommand returns the hidden exec command used by the luet
+// and luet-build binaries to run a command inside a rootfs.
+func NewExecCommand(cfg *config.LuetConfig) *cobra.Command {
 	var execCmd = &cobra.Command{
 		Use:   "exec --rootfs /path [command]",
 		Short: "Execute a command in the rootfs context",
 		Long:  `Uses unshare technique and pivot root to execute a command inside a folder containing a valid rootfs`,
 		PreRun: func(cmd *cobra.Command, args []string) {
 		},
+		// Used by the native backend to run the build steps.
 		// If you change this, look at pkg/box/exec that runs this command and adapt
 		Run: func(cmd *cobra.Command, args []string) {
 
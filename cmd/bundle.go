@@ -0,0 +1,27 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	cmd_bundle "github.com/geaaru/luet/cmd/bundle"
+	cfg "github.com/geaaru/luet/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+func newBundleCommand(config *cfg.LuetConfig) *cobra.Command {
+
+	var ans = &cobra.Command{
+		Use:   "bundle [command] [OPTIONS]",
+		Short: "Manage offline bundles for air-gapped installs",
+	}
+
+	ans.AddCommand(
+		cmd_bundle.NewBundleCreateCommand(config),
+		cmd_bundle.NewBundleInstallCommand(config),
+	)
+
+	return ans
+}
@@ -0,0 +1,82 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_bundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	helpers "github.com/geaaru/luet/cmd/helpers"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	installer "github.com/geaaru/luet/pkg/v2/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func NewBundleCreateCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "create <pkg1> <pkg2> ...",
+		Short: "Create a bundle with the packages and their dependencies.",
+		Long: `Create a self-contained bundle with the selected packages, all the
+required dependencies and the metadata of the repositories:
+
+	$ luet bundle create -o /tmp/mybundle.tar utils/busybox utils/yq
+
+The bundle could be installed on a system without network access
+with the command:
+
+	$ luet bundle install /tmp/mybundle.tar
+`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				fmt.Println("Missing arguments.")
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			output, _ := cmd.Flags().GetString("output")
+			name, _ := cmd.Flags().GetString("name")
+			ignoreMasks, _ := cmd.Flags().GetBool("ignore-masks")
+
+			if name == "" {
+				name = strings.Split(filepath.Base(output), ".")[0]
+			}
+
+			packs := []*pkg.DefaultPackage{}
+			for _, a := range args {
+				pack, err := helpers.ParsePackageStr(config, a)
+				if err != nil {
+					Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+				packs = append(packs, pack)
+			}
+
+			aManager := installer.NewArtifactsManager(config)
+			defer aManager.Close()
+
+			opts := &installer.BundleOpts{
+				Name:        name,
+				IgnoreMasks: ignoreMasks,
+			}
+
+			err := aManager.CreateBundle(opts, output, packs...)
+			if err != nil {
+				Fatal("Error: " + err.Error())
+			}
+		},
+	}
+
+	flags := ans.Flags()
+	flags.StringP("output", "o", "luet-bundle.tar", "Path of the bundle file to create.")
+	flags.String("name", "",
+		"Name of the temporary repository of the bundle. Default is the bundle filename.")
+	flags.Bool("ignore-masks", false, "Ignore packages masked.")
+
+	return ans
+}
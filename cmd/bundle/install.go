@@ -0,0 +1,121 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_bundle
+
+import (
+	"fmt"
+	"os"
+
+	helpers "github.com/geaaru/luet/cmd/helpers"
+	"github.com/geaaru/luet/cmd/util"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/subsets"
+	installer "github.com/geaaru/luet/pkg/v2/installer"
+
+	. "github.com/logrusorgru/aurora"
+	"github.com/spf13/cobra"
+)
+
+func NewBundleInstallCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "install <bundle-file> [<pkg1> <pkg2> ...]",
+		Short: "Install packages from a bundle.",
+		Long: `Install the packages of a bundle without network access.
+
+The bundle is registered as a temporary disk repository and all the
+others repositories are disabled during the installation:
+
+	$ luet bundle install -y /tmp/mybundle.tar
+
+By default are installed the packages used to create the bundle.
+It's possible to install only a part of the packages of the bundle:
+
+	$ luet bundle install -y /tmp/mybundle.tar utils/yq
+`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				fmt.Println("Missing bundle file.")
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			force, _ := cmd.Flags().GetBool("force")
+			yes, _ := cmd.Flags().GetBool("yes")
+			pretend, _ := cmd.Flags().GetBool("pretend")
+			skipCheckSystem, _ := cmd.Flags().GetBool("skip-check-system")
+			preserveSystem, _ := cmd.Flags().GetBool("preserve-system-essentials")
+			skipFinalizers, _ := cmd.Flags().GetBool("skip-finalizers")
+			skipHooks, _ := cmd.Flags().GetBool("skip-hooks")
+			finalizerEnvs, _ := cmd.Flags().GetStringArray("finalizer-env")
+
+			InfoC(fmt.Sprintf(":rocket:%s %s",
+				Bold(Blue("Luet")), Bold(Blue(util.Version()))))
+
+			packs := []*pkg.DefaultPackage{}
+			for _, a := range args[1:] {
+				pack, err := helpers.ParsePackageStr(nil, a)
+				if err != nil {
+					Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+				packs = append(packs, pack)
+			}
+
+			// Load config protect configs
+			installer.LoadConfigProtectConfs(config)
+			// Load subsets defintions
+			subsets.LoadSubsetsDefintions(config)
+			// Load subsets config
+			subsets.LoadSubsetsConfig(config)
+
+			// Load finalizer runtime environments
+			err := util.SetCliFinalizerEnvs(finalizerEnvs)
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			aManager := installer.NewArtifactsManager(config)
+			defer aManager.Close()
+
+			opts := &installer.InstallOpts{
+				Force:                       force,
+				PreserveSystemEssentialData: preserveSystem,
+				Ask:                         !yes,
+				SkipFinalizers:              skipFinalizers,
+				SkipHooks:                   skipHooks,
+				Pretend:                     pretend,
+				CheckSystemFiles:            !skipCheckSystem,
+				// The bundle contains the packages resolved on creation.
+				IgnoreMasks: true,
+			}
+
+			err = aManager.InstallBundle(opts, config.GetSystem().Rootfs,
+				args[0], packs...)
+			if err != nil {
+				Fatal("Error: " + err.Error())
+			}
+
+			InfoC(fmt.Sprintf(":confetti_ball:%s",
+				Bold(Blue("All done."))))
+		},
+	}
+
+	flags := ans.Flags()
+	flags.Bool("force", false, "Skip errors and keep going (potentially harmful)")
+	flags.BoolP("yes", "y", false, "Don't ask questions")
+	flags.BoolP("pretend", "p", false,
+		"simply display what *would* have been installed if --pretend weren't used")
+	flags.Bool("skip-check-system", false, "Skip conflicts check with existing rootfs.")
+	flags.Bool("preserve-system-essentials", true, "Preserve system luet files")
+	flags.Bool("skip-finalizers", false,
+		"Skip the execution of the finalizers.")
+	flags.Bool("skip-hooks", false,
+		"Skip the execution of the transaction hooks.")
+	flags.StringArray("finalizer-env", []string{},
+		"Set finalizer environment in the format key=value.")
+
+	return ans
+}
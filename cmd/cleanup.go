@@ -20,8 +20,10 @@ import (
 	"github.com/geaaru/luet/cmd/util"
 	cfg "github.com/geaaru/luet/pkg/config"
 	. "github.com/geaaru/luet/pkg/logger"
+	"github.com/geaaru/luet/pkg/v2/cache"
 	installer "github.com/geaaru/luet/pkg/v2/installer"
 
+	units "github.com/docker/go-units"
 	"github.com/spf13/cobra"
 )
 
@@ -30,7 +32,20 @@ func newCleanupCommand(config *cfg.LuetConfig) *cobra.Command {
 	var ans = &cobra.Command{
 		Use:   "cleanup",
 		Short: "Clean packages cache.",
-		Long:  `remove downloaded packages tarballs and clean cache directory`,
+		Long: `remove downloaded packages tarballs and clean cache directory
+
+	$ luet cleanup
+
+Remove all the cached packages.
+
+	$ luet cleanup --keep-installed --older-than 30d
+
+Remove the cached packages not installed and not used in the last 30 days.
+
+	$ luet cleanup --max-size 5G
+
+Remove the cached packages less recently used until the cache is under 5G.
+`,
 		PreRun: func(cmd *cobra.Command, args []string) {
 			util.BindSystemFlags(cmd)
 		},
@@ -38,11 +53,43 @@ func newCleanupCommand(config *cfg.LuetConfig) *cobra.Command {
 			util.SetSystemConfig()
 
 			purge, _ := cmd.Flags().GetBool("purge-repos")
+			keepInstalled, _ := cmd.Flags().GetBool("keep-installed")
+			olderThan, _ := cmd.Flags().GetString("older-than")
+			maxSize, _ := cmd.Flags().GetString("max-size")
+			pretend, _ := cmd.Flags().GetBool("pretend")
 
 			aManager := installer.NewArtifactsManager(config)
 			defer aManager.Close()
 
-			err := aManager.CleanLocalPackagesCache()
+			opts := &cache.GCOpts{
+				Pretend: pretend,
+			}
+
+			if olderThan != "" {
+				d, err := cache.ParseDuration(olderThan)
+				if err != nil {
+					Fatal("Invalid older-than value:", err.Error())
+				}
+				opts.OlderThan = d
+			}
+
+			if maxSize != "" {
+				size, err := units.RAMInBytes(maxSize)
+				if err != nil {
+					Fatal("Invalid max-size value:", err.Error())
+				}
+				opts.MaxSize = size
+			}
+
+			if keepInstalled {
+				keep, err := aManager.KeepInstalledPackages()
+				if err != nil {
+					Fatal(err.Error())
+				}
+				opts.Keep = keep
+			}
+
+			_, err := aManager.GCPackagesCache(opts)
 			if err != nil {
 				Fatal(err.Error())
 			}
@@ -61,6 +108,14 @@ func newCleanupCommand(config *cfg.LuetConfig) *cobra.Command {
 	ans.Flags().String("system-engine", "", "System DB engine")
 	ans.Flags().Bool("purge-repos", false,
 		"Remove all repos files. This impacts on searching packages too.")
+	ans.Flags().Bool("keep-installed", false,
+		"Preserve the cached packages of the installed versions.")
+	ans.Flags().String("older-than", "",
+		"Remove only the cached packages not used from the specified duration (ex. 30d, 12h).")
+	ans.Flags().String("max-size", "",
+		"Remove the cached packages less recently used until the cache size is under the limit (ex. 10G).")
+	ans.Flags().Bool("pretend", false,
+		"Show the cached packages to remove without removing them.")
 
 	return ans
 }
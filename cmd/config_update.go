@@ -0,0 +1,214 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	config "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/v2/configprotect"
+	installer "github.com/geaaru/luet/pkg/v2/installer"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func getConfigProtectDirs(cfg *config.LuetConfig) []string {
+	confDirs := []string{}
+	for _, c := range cfg.GetConfigProtectConfFiles() {
+		confDirs = append(confDirs, c.Directories...)
+	}
+
+	annotations := []string{}
+	for _, p := range cfg.GetSystemDB().World() {
+		dir, ok := p.GetAnnotationByKey(string(pkg.ConfigProtectAnnnotation)).(string)
+		if ok {
+			annotations = append(annotations, dir)
+		}
+	}
+
+	return configprotect.GetProtectDirs(confDirs, annotations)
+}
+
+func askConfigUpdateAction(hasPristine bool) string {
+	var input string
+
+	msg := "[k]eep current, [r]eplace with new, "
+	if hasPristine {
+		msg += "[m]erge, "
+	}
+	msg += "[d]iff, [s]kip, [q]uit: "
+
+	Msg("info", true, false, msg)
+	_, err := fmt.Scanln(&input)
+	if err != nil {
+		return "s"
+	}
+	return strings.ToLower(input)
+}
+
+func processConfigUpdate(u *configprotect.ConfigUpdater, p *configprotect.PendingFile) (bool, error) {
+	diff, err := u.Diff(p)
+	if err != nil {
+		return false, err
+	}
+	fmt.Println(diff)
+
+	for {
+		current, _, pristine, err := u.ReadFiles(p)
+		if err != nil {
+			return false, err
+		}
+
+		switch askConfigUpdateAction(pristine != nil) {
+		case "k":
+			return false, u.Keep(p)
+		case "r":
+			return false, u.Replace(p)
+		case "d":
+			fmt.Println(diff)
+		case "m":
+			if pristine == nil {
+				continue
+			}
+			merged, conflicts, err := u.Merge3(p)
+			if err != nil {
+				return false, err
+			}
+			if conflicts {
+				fmt.Println(string(merged))
+				Warning(fmt.Sprintf(
+					"The merge of %s has conflicts. Choose another action.",
+					p.Path))
+				continue
+			}
+			fmt.Println(configprotect.UnifiedDiff(
+				current, merged, p.Path, p.Path+" (merged)"))
+			if Ask() {
+				return false, u.WriteMerged(p, merged)
+			}
+		case "s":
+			return false, nil
+		case "q":
+			return true, nil
+		}
+	}
+}
+
+func newConfigUpdateCommand(cfg *config.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "config-update [OPTIONS]",
+		Short: "Process the pending updates of the protected files.",
+		Long: `When a protected file is modified on the system, the new version
+shipped by the package is installed as ._cfgNNNN_<name> file.
+
+List the pending updates:
+
+	$ luet config-update --list
+
+Review the pending updates with the choice to keep the current file,
+to replace it with the new version or to merge the changes:
+
+	$ luet config-update
+
+Resolve only the updates that don't require a choice (the current file
+is not modified, the new version is not changed or the merge is without
+conflicts). The other updates are left pending:
+
+	$ luet config-update --auto-merge-trivial
+`,
+		Aliases: []string{"cu"},
+		Run: func(cmd *cobra.Command, args []string) {
+			list, _ := cmd.Flags().GetBool("list")
+			out, _ := cmd.Flags().GetString("output")
+			autoMerge, _ := cmd.Flags().GetBool("auto-merge-trivial")
+
+			// Load config protect configs
+			installer.LoadConfigProtectConfs(cfg)
+
+			rootfs, err := cfg.GetSystem().GetRootFsAbs()
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			u := configprotect.NewConfigUpdater(rootfs, cfg.GetSystem().DatabasePath)
+			pending, err := u.Scan(getConfigProtectDirs(cfg))
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			if list {
+				switch out {
+				case "json":
+					data, err := json.Marshal(pending)
+					if err != nil {
+						Fatal("Error on marshal files", err.Error())
+					}
+					fmt.Println(string(data))
+				case "yaml":
+					data, err := yaml.Marshal(pending)
+					if err != nil {
+						Fatal("Error on marshal files", err.Error())
+					}
+					fmt.Println(string(data))
+				default:
+					for _, p := range pending {
+						fmt.Println(fmt.Sprintf("%s (%d)", p.Path, len(p.Candidates)))
+					}
+				}
+				return
+			}
+
+			if len(pending) == 0 {
+				InfoC(":smiling_face_with_sunglasses:No pending configuration files.")
+				return
+			}
+
+			left := 0
+			for _, p := range pending {
+				if autoMerge {
+					action, err := u.AutoMergeTrivial(p)
+					if err != nil {
+						Fatal(err.Error())
+					}
+					if action == "" {
+						Warning(fmt.Sprintf("%s requires a manual merge.", p.Path))
+						left++
+					} else {
+						InfoC(fmt.Sprintf(":check_mark:%s # %s", p.Path, action))
+					}
+					continue
+				}
+
+				InfoC(fmt.Sprintf(":pencil:%s (%s)", p.Path, p.GetCandidate()))
+				quit, err := processConfigUpdate(u, p)
+				if err != nil {
+					Fatal(err.Error())
+				}
+				if quit {
+					os.Exit(0)
+				}
+			}
+
+			if left > 0 {
+				InfoC(fmt.Sprintf("%d configuration files still pending.", left))
+			}
+		},
+	}
+
+	flags := ans.Flags()
+	flags.Bool("list", false, "List the pending configuration files.")
+	flags.StringP("output", "o", "terminal",
+		"Output format of the list ( Defaults: terminal, available: json,yaml )")
+	flags.Bool("auto-merge-trivial", false,
+		"Resolve only the trivial updates without ask questions.")
+
+	return ans
+}
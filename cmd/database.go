@@ -37,6 +37,7 @@ func newDatabaseCommand(cfg *config.LuetConfig) *cobra.Command {
 		NewDatabaseCreateCommand(cfg),
 		NewDatabaseGetCommand(cfg),
 		NewDatabaseRemoveCommand(cfg),
+		NewDatabaseMarkCommand(cfg),
 		NewDatabaseReindexCommand(cfg),
 	)
 
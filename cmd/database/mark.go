@@ -0,0 +1,74 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_database
+
+import (
+	"fmt"
+
+	helpers "github.com/geaaru/luet/cmd/helpers"
+	"github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	installer "github.com/geaaru/luet/pkg/v2/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func NewDatabaseMarkCommand(cfg *config.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "mark [package1] [package2] ...",
+		Short: "Mark installed packages as explicitly installed or as dependencies",
+		Long: `Changes the install reason of the installed packages:
+
+		$ luet database mark --explicit foo/bar
+
+		$ luet database mark --dep foo/baz
+
+The packages marked as dependencies are removed by uninstall --deep
+when no other packages require them.
+`,
+		Args: cobra.MinimumNArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			explicit, _ := cmd.Flags().GetBool("explicit")
+			dep, _ := cmd.Flags().GetBool("dep")
+			if explicit == dep {
+				Fatal("One of the flags --explicit or --dep is mandatory.")
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			explicit, _ := cmd.Flags().GetBool("explicit")
+
+			aManager := installer.NewArtifactsManager(cfg)
+			defer aManager.Close()
+
+			packs := []*pkg.DefaultPackage{}
+			for _, a := range args {
+				pack, err := helpers.ParsePackageStr(cfg, a)
+				if err != nil {
+					Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+				packs = append(packs, pack)
+			}
+
+			err := aManager.MarkPackages(explicit, packs...)
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			reason := pkg.InstallReasonDep
+			if explicit {
+				reason = pkg.InstallReasonExplicit
+			}
+			InfoC(fmt.Sprintf(":check_mark:%d packages marked as %s.",
+				len(packs), reason))
+		},
+	}
+
+	ans.Flags().Bool("explicit", false, "Mark the packages as explicitly installed.")
+	ans.Flags().Bool("dep", false, "Mark the packages as dependencies.")
+
+	return ans
+}
@@ -54,6 +54,9 @@ This commands takes multiple packages as arguments and prunes their entries from
 						Fatal("Failed removing files for ", a, ": ", err.Error())
 					}
 				}
+				if err := aManager.Database.RemovePackageFilesMeta(pack); err != nil {
+					Warning("Failed removing files metadata for ", a, ": ", err.Error())
+				}
 				if err := aManager.Database.RemovePackageFinalizer(pack); err != nil {
 					Warning("Failed removing finalizer for ", a, ": ", err.Error())
 				}
@@ -0,0 +1,42 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	cmd_hold "github.com/geaaru/luet/cmd/hold"
+	cfg "github.com/geaaru/luet/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+func newHoldCommand(config *cfg.LuetConfig) *cobra.Command {
+
+	var ans = &cobra.Command{
+		Use:   "hold [command] [OPTIONS]",
+		Short: "Manage the packages held on upgrade",
+		Long: `Manage the packages held on upgrade.
+
+A held package is immovable: luet upgrade doesn't upgrade,
+downgrade or replace it. With a version selector the package
+is upgraded only to the versions that match the selector.
+
+	$ luet hold add sys-libs/glibc
+
+	$ luet hold add app-emulation/lxd@<5.0
+
+	$ luet hold list
+
+	$ luet hold remove sys-libs/glibc
+`,
+	}
+
+	ans.AddCommand(
+		cmd_hold.NewHoldAddCommand(config),
+		cmd_hold.NewHoldRemoveCommand(config),
+		cmd_hold.NewHoldListCommand(config),
+	)
+
+	return ans
+}
@@ -0,0 +1,49 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_hold
+
+import (
+	"fmt"
+
+	helpers "github.com/geaaru/luet/cmd/helpers"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	"github.com/geaaru/luet/pkg/v2/hold"
+
+	"github.com/spf13/cobra"
+)
+
+func NewHoldAddCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "add <pkg1>[@selector] ... <pkgN>[@selector]",
+		Short: "Hold one or more packages.",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			m := hold.NewPackagesHoldManager(config)
+			err := m.Load()
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			for _, a := range args {
+				p, err := helpers.ParsePackageStr(config, a)
+				if err != nil {
+					Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+
+				h := hold.NewPackageHold(p)
+				m.Add(h)
+				InfoC(fmt.Sprintf(":pushpin:Package %s held.", h.String()))
+			}
+
+			err = m.Save()
+			if err != nil {
+				Fatal("Error on write holds file: " + err.Error())
+			}
+		},
+	}
+
+	return ans
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_hold
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	"github.com/geaaru/luet/pkg/v2/hold"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func NewHoldListCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:     "list [OPTIONS]",
+		Short:   "List the packages held.",
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+			quiet, _ := cmd.Flags().GetBool("quiet")
+
+			m := hold.NewPackagesHoldManager(config)
+			err := m.Load()
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			switch out {
+			case "json":
+				data, err := json.Marshal(&hold.PackagesHoldFile{Holds: m.Holds})
+				if err != nil {
+					Fatal("Error on marshal holds", err.Error())
+				}
+				fmt.Println(string(data))
+			case "yaml":
+				data, err := yaml.Marshal(&hold.PackagesHoldFile{Holds: m.Holds})
+				if err != nil {
+					Fatal("Error on marshal holds", err.Error())
+				}
+				fmt.Println(string(data))
+			default:
+				if quiet {
+					for _, h := range m.Holds {
+						fmt.Println(h.String())
+					}
+				} else if len(m.Holds) == 0 {
+					fmt.Println("No packages held.")
+				} else {
+					InfoC(":pushpin:Packages held:")
+					for _, h := range m.Holds {
+						selector := "immovable"
+						if !h.IsImmovable() {
+							selector = h.Selector
+						}
+						InfoC(fmt.Sprintf(" * %-50s %s", h.Package, selector))
+					}
+				}
+			}
+		},
+	}
+
+	flags := ans.Flags()
+	flags.BoolP("quiet", "q", false, "Show only the holds strings.")
+	flags.StringP("output", "o", "terminal",
+		"Output format ( Defaults: terminal, available: json,yaml )")
+
+	return ans
+}
@@ -0,0 +1,52 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_hold
+
+import (
+	"fmt"
+
+	helpers "github.com/geaaru/luet/cmd/helpers"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	"github.com/geaaru/luet/pkg/v2/hold"
+
+	"github.com/spf13/cobra"
+)
+
+func NewHoldRemoveCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:     "remove <pkg1> ... <pkgN>",
+		Short:   "Remove the hold of one or more packages.",
+		Aliases: []string{"rm"},
+		Args:    cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			m := hold.NewPackagesHoldManager(config)
+			err := m.Load()
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			for _, a := range args {
+				p, err := helpers.ParsePackageStr(config, a)
+				if err != nil {
+					Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+
+				if m.Remove(p.PackageName()) {
+					InfoC(fmt.Sprintf(":pushpin:Hold of %s removed.", p.PackageName()))
+				} else {
+					Warning(fmt.Sprintf("Package %s is not held.", p.PackageName()))
+				}
+			}
+
+			err = m.Save()
+			if err != nil {
+				Fatal("Error on write holds file: " + err.Error())
+			}
+		},
+	}
+
+	return ans
+}
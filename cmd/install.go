@@ -68,8 +68,10 @@ To force install a package:
 			downloadOnly, _ := cmd.Flags().GetBool("download-only")
 			finalizerEnvs, _ := cmd.Flags().GetStringArray("finalizer-env")
 			skipFinalizers, _ := cmd.Flags().GetBool("skip-finalizers")
+			skipHooks, _ := cmd.Flags().GetBool("skip-hooks")
 			syncRepos, _ := cmd.Flags().GetBool("sync-repos")
 			ignoreMasks, _ := cmd.Flags().GetBool("ignore-masks")
+			explain, _ := cmd.Flags().GetBool("explain")
 			showInstallOrder, _ := cmd.Flags().GetBool("show-install-order")
 			purge, _ := cmd.Flags().GetBool("purge-repos")
 			cleanup, _ := cmd.Flags().GetBool("cleanup")
@@ -120,10 +122,12 @@ To force install a package:
 				PreserveSystemEssentialData: preserveSystem,
 				Ask:                         !yes,
 				SkipFinalizers:              skipFinalizers,
+				SkipHooks:                   skipHooks,
 				Pretend:                     pretend,
 				DownloadOnly:                downloadOnly,
 				CheckSystemFiles:            !skipCheckSystem,
 				IgnoreMasks:                 ignoreMasks,
+				Explain:                     explain,
 				ShowInstallOrder:            showInstallOrder,
 			}
 
@@ -172,9 +176,13 @@ To force install a package:
 		"Overwrite exiting directories permissions.")
 	flags.Bool("skip-finalizers", false,
 		"Skip the execution of the finalizers.")
+	flags.Bool("skip-hooks", false,
+		"Skip the execution of the transaction hooks.")
 	flags.Bool("sync-repos", false,
 		"Sync repositories before install. Note: If there are in memory repositories then the sync is done always.")
 	flags.Bool("ignore-masks", false, "Ignore packages masked.")
+	flags.Bool("explain", false,
+		"Show the decisions of the solver about the candidates considered and rejected.")
 	flags.Bool("show-install-order", false,
 		"In additional of the package to install, show the installation order and exit.")
 	ans.Flags().Bool("cleanup", false, "Cleanup local packages cache.")
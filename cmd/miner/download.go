@@ -15,7 +15,6 @@ import (
 	installer "github.com/geaaru/luet/pkg/v2/installer"
 	wagon "github.com/geaaru/luet/pkg/v2/repository"
 	"github.com/geaaru/luet/pkg/v2/repository/mask"
-	"github.com/logrusorgru/aurora"
 
 	"github.com/spf13/cobra"
 )
@@ -91,29 +90,15 @@ func NewDownload(config *cfg.LuetConfig) *cobra.Command {
 			fail := false
 
 			artifacts := *artifactsRef
-			ndownloads := len(artifacts)
-			for idx, a := range artifacts {
-
-				msg := fmt.Sprintf(
-					"[%3d of %3d] %-65s - %-15s",
-					aurora.Bold(aurora.BrightMagenta(idx+1)),
-					aurora.Bold(aurora.BrightMagenta(ndownloads)),
-					fmt.Sprintf("%s::%s", a.GetPackage().PackageName(),
-						a.GetPackage().Repository,
-					),
-					a.GetPackage().GetVersion())
-
-				err = aManager.DownloadPackage(a, r, msg)
-				if err != nil {
-					fail = true
-					fmt.Println(fmt.Sprintf(
-						"Error on download artifact %s: %s",
-						a.Runtime.HumanReadableString(),
-						err.Error()))
-					Error(fmt.Sprintf(":package:%s # download failed :fire:", msg))
-				} else {
-					Info(fmt.Sprintf(":package:%s # downloaded :check_mark:", msg))
-				}
+			tasks := []*installer.DownloadTask{}
+			for _, a := range artifacts {
+				tasks = append(tasks, installer.NewDownloadTask(a, r))
+			}
+
+			err = aManager.DownloadPackages(tasks)
+			if err != nil {
+				fail = true
+				Error(err.Error())
 			}
 
 			if len(artifacts) == 0 {
@@ -162,8 +162,18 @@ func NewInstallPackage(config *cfg.LuetConfig) *cobra.Command {
 						"Error on register artifact %s: %s",
 						a.Runtime.HumanReadableString(),
 						err.Error()))
-				} else if !skipFinalizers {
-					toFinalize = append(toFinalize, a)
+				} else {
+					err = aManager.RegisterPackageFilesMeta(a.GetPackage(), a.Files,
+						a.FilesMeta)
+					if err != nil {
+						Warning(fmt.Sprintf(
+							"Error on register files metadata of %s: %s",
+							a.Runtime.HumanReadableString(),
+							err.Error()))
+					}
+					if !skipFinalizers {
+						toFinalize = append(toFinalize, a)
+					}
 				}
 
 			}
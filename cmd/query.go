@@ -24,6 +24,8 @@ func newQueryCommand(config *cfg.LuetConfig) *cobra.Command {
 		NewQueryFilesCommand(config),
 		NewQueryBelongsCommand(config),
 		NewQueryOrphansCommand(config),
+		NewQueryVerifyCommand(config),
+		NewQuerySBOMCommand(config),
 	)
 
 	return ans
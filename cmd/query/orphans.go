@@ -27,12 +27,18 @@ func NewQueryOrphansCommand(config *cfg.LuetConfig) *cobra.Command {
 available in the configured and/or enabled repositories.
 
 This operation could require a bit of time.
+
+With --deps are showed the packages installed as
+dependencies that are no more required by other packages:
+
+	$ luet query orphans --deps
 `,
 		Aliases: []string{"o"},
 		Run: func(cmd *cobra.Command, args []string) {
 			out, _ := cmd.Flags().GetString("output")
 			quiet, _ := cmd.Flags().GetBool("quiet")
 			verbose, _ := cmd.Flags().GetBool("verbose")
+			deps, _ := cmd.Flags().GetBool("deps")
 
 			solveropts := &solver.SolverOpts{
 				IgnoreConflicts: true,
@@ -55,7 +61,13 @@ This operation could require a bit of time.
 				Spinner(3)
 			}
 
-			orphans, err := (*s).Orphans()
+			var orphans *[]*pkg.DefaultPackage
+			var err error
+			if deps {
+				orphans, err = (*s).UnneededDeps()
+			} else {
+				orphans, err = (*s).Orphans()
+			}
 			if enableSpinner {
 				SpinnerStop()
 			}
@@ -95,6 +107,8 @@ This operation could require a bit of time.
 
 	flags.Bool("verbose", true, "Show messages.")
 	flags.Bool("quiet", false, "show output as list without version")
+	flags.Bool("deps", false,
+		"Show the dependencies no more required by other packages.")
 	flags.StringP("output", "o", "terminal",
 		"Output format ( Defaults: terminal, available: json,yaml )")
 	return ans
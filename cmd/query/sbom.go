@@ -0,0 +1,81 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_query
+
+import (
+	"fmt"
+	"os"
+
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	"github.com/geaaru/luet/pkg/sbom"
+	installer "github.com/geaaru/luet/pkg/v2/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func NewQuerySBOMCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "sbom [OPTIONS]",
+		Short: "Generate the SBOM of the installed packages.",
+		Long: `Generate the software bill of materials of the packages
+installed in the system from the system database.
+
+	$ luet query sbom
+
+	$ luet query sbom --format cyclonedx-json --file system.cdx.json
+
+The creation time honors the SOURCE_DATE_EPOCH variable.
+`,
+		Args: cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			format, _ := cmd.Flags().GetString("format")
+			if format != sbom.FormatSPDX && format != sbom.FormatCycloneDX {
+				Fatal(fmt.Sprintf("Invalid format %s (available: %s, %s)",
+					format, sbom.FormatSPDX, sbom.FormatCycloneDX))
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			format, _ := cmd.Flags().GetString("format")
+			file, _ := cmd.Flags().GetString("file")
+			name, _ := cmd.Flags().GetString("name")
+
+			if name == "" {
+				name, _ = os.Hostname()
+			}
+
+			aManager := installer.NewArtifactsManager(config)
+			defer aManager.Close()
+
+			doc, err := aManager.GenerateSBOM(name)
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			data, err := doc.Marshal(format)
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			if file != "" {
+				err = os.WriteFile(file, data, 0644)
+				if err != nil {
+					Fatal("Error on write file " + file + ": " + err.Error())
+				}
+				InfoC(fmt.Sprintf(":page_facing_up: SBOM of %d packages written to %s.",
+					len(doc.Packages), file))
+			} else {
+				fmt.Println(string(data))
+			}
+		},
+	}
+
+	flags := ans.Flags()
+	flags.String("format", sbom.FormatSPDX,
+		"Format of the SBOM ( Defaults: spdx-json, available: cyclonedx-json )")
+	flags.String("file", "", "Write the SBOM to the file instead of stdout.")
+	flags.String("name", "", "Name of the SBOM document ( Defaults: hostname ).")
+	return ans
+}
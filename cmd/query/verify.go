@@ -0,0 +1,128 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_query
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	helpers "github.com/geaaru/luet/cmd/helpers"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	installer "github.com/geaaru/luet/pkg/v2/installer"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func NewQueryVerifyCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "verify [OPTIONS] [pkg1] ... [pkgN]",
+		Short: "Verify the files of the installed packages.",
+		Long: `Compare the files of the installed packages with the
+metadata (sha256, mode, owner, size) stored on install.
+
+	$ luet query verify
+
+	$ luet query verify sys-apps/shadow -o json
+
+For every file changed is showed a line in the rpm -V format:
+
+	S file size differs
+	M mode differs (permissions and file type)
+	5 sha256 digest differs
+	L symlink target differs
+	U user ownership differs
+	G group ownership differs
+
+The config protected files are excluded by default and
+marked with "c" when --include-config is used.
+
+The command exits with 1 when a file is changed or missing.
+`,
+		Aliases: []string{"v"},
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+			includeConfig, _ := cmd.Flags().GetBool("include-config")
+
+			packs := []*pkg.DefaultPackage{}
+			for _, a := range args {
+				p, err := helpers.ParsePackageStr(config, a)
+				if err != nil {
+					Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+				packs = append(packs, p)
+			}
+
+			// Load config protect configs
+			installer.LoadConfigProtectConfs(config)
+
+			aManager := installer.NewArtifactsManager(config)
+			defer aManager.Close()
+
+			opts := &installer.VerifyOpts{
+				IncludeConfig: includeConfig,
+			}
+
+			reports, err := aManager.VerifyPackages(opts,
+				config.GetSystem().Rootfs, packs...)
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			changed := false
+			for _, r := range reports {
+				if len(r.Files) > 0 {
+					changed = true
+					break
+				}
+			}
+
+			switch out {
+			case "json":
+				data, err := json.Marshal(reports)
+				if err != nil {
+					Fatal("Error on marshal reports", err.Error())
+				}
+				fmt.Println(string(data))
+			case "yaml":
+				data, err := yaml.Marshal(reports)
+				if err != nil {
+					Fatal("Error on marshal reports", err.Error())
+				}
+				fmt.Println(string(data))
+			default:
+				for _, r := range reports {
+					if r.NoMetadata {
+						Warning(fmt.Sprintf(
+							"%s: integrity metadata not available (reinstall the package).",
+							r.Package))
+						continue
+					}
+					for _, f := range r.Files {
+						attr := " "
+						if f.Config {
+							attr = "c"
+						}
+						fmt.Println(fmt.Sprintf("%-7s %s /%s (%s)",
+							f.Flags(), attr, f.Path, r.Package))
+					}
+				}
+			}
+
+			if changed {
+				os.Exit(1)
+			}
+		},
+	}
+
+	flags := ans.Flags()
+	flags.Bool("include-config", false, "Verify also the config protected files.")
+	flags.StringP("output", "o", "terminal",
+		"Output format ( Defaults: terminal, available: json,yaml )")
+	return ans
+}
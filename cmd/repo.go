@@ -23,6 +23,8 @@ func newRepoCommand(config *cfg.LuetConfig) *cobra.Command {
 		NewRepoUpdateCommand(config),
 		NewRepoEnableCommand(config),
 		NewRepoDisableCommand(config),
+		NewRepoMirrorsCommand(config),
+		NewRepoDiffCommand(config),
 	)
 
 	return ans
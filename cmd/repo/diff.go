@@ -0,0 +1,83 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_repo
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func NewRepoDiffCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "diff <repo> [OPTIONS]",
+		Short: "Show the packages changes between the revisions of a repository.",
+		Long: `Show the packages added, removed and updated between the
+current revision of a repository and a previous revision synced.
+
+# Show the changes of the last update:
+$> luet repo diff repo1
+
+# Show the changes from the revision 10:
+$> luet repo diff repo1 --from-rev 10
+`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+			fromRev, _ := cmd.Flags().GetInt("from-rev")
+
+			repo, err := config.GetSystemRepository(args[0])
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			repobasedir := config.GetSystem().GetRepoDatabaseDirPath(repo.Name)
+			r := wagon.NewWagonRepository(repo)
+			if !r.HasLocalWagonIdentity(repobasedir) {
+				Fatal(fmt.Sprintf("The repository %s must be synced.", repo.Name))
+			}
+			err = r.ReadWagonIdentify(repobasedir)
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			changelog, err := r.GetChangelog(repobasedir, fromRev)
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			switch out {
+			case "json":
+				data, err := json.Marshal(changelog)
+				if err != nil {
+					Fatal("Error on marshal changelog", err.Error())
+				}
+				fmt.Println(string(data))
+			case "yaml":
+				data, err := yaml.Marshal(changelog)
+				if err != nil {
+					Fatal("Error on marshal changelog", err.Error())
+				}
+				fmt.Println(string(data))
+			default:
+				wagon.ShowChangelog(changelog)
+			}
+		},
+	}
+
+	flags := ans.Flags()
+	flags.Int("from-rev", 0,
+		"Revision used as base of the changes. Default is the previous revision synced.")
+	flags.StringP("output", "o", "terminal",
+		"Output format ( Defaults: terminal, available: json,yaml )")
+
+	return ans
+}
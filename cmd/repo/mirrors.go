@@ -0,0 +1,110 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	"github.com/geaaru/luet/pkg/v2/repository/client"
+
+	units "github.com/docker/go-units"
+	. "github.com/logrusorgru/aurora"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func NewRepoMirrorsCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "mirrors <repo> [OPTIONS]",
+		Short: "Show the ranking of the mirrors of a repository.",
+		Long: `Show the ranking of the repository URLs based on the health
+data collected on downloads.
+
+$> luet repo mirrors macaroni-funtoo
+
+$> luet repo mirrors macaroni-funtoo -o json
+
+$> luet repo mirrors macaroni-funtoo --reset
+`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+			reset, _ := cmd.Flags().GetBool("reset")
+
+			repo, err := config.GetSystemRepository(args[0])
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			if reset {
+				f := filepath.Join(
+					config.GetSystem().GetRepoDatabaseDirPath(repo.Name),
+					client.MirrorsHealthFile,
+				)
+				if err := os.RemoveAll(f); err != nil {
+					Fatal("Error on remove mirrors health file: " + err.Error())
+				}
+				InfoC(fmt.Sprintf(":broom:Mirrors health data of %s reset.",
+					repo.Name))
+				return
+			}
+
+			ranking := client.GetMirrorsHealth(repo).GetRanking(repo.Urls)
+
+			switch out {
+			case "json":
+				data, err := json.Marshal(ranking)
+				if err != nil {
+					Fatal("Error on marshal mirrors", err.Error())
+				}
+				fmt.Println(string(data))
+			case "yaml":
+				data, err := yaml.Marshal(ranking)
+				if err != nil {
+					Fatal("Error on marshal mirrors", err.Error())
+				}
+				fmt.Println(string(data))
+			default:
+				fmt.Println(Bold(BrightGreen(repo.Name)))
+				for idx, m := range ranking {
+					var state string
+					if m.ConsecutiveFailures > 0 {
+						state = Bold(Red("degraded")).String()
+					} else if m.Successes > 0 {
+						state = Bold(Green("healthy")).String()
+					} else {
+						state = Bold(Yellow("unknown")).String()
+					}
+
+					fmt.Println(fmt.Sprintf("  %2d. %s [%s]",
+						idx+1, Bold(Cyan(m.Url)), state))
+					fmt.Println(fmt.Sprintf(
+						"      Throughput %10s/s - Successes %5d - Failures %5d (%d consecutive)",
+						units.BytesSize(float64(m.Throughput)), m.Successes, m.Failures, m.ConsecutiveFailures,
+					))
+					if m.LastError != "" {
+						tsec, _ := strconv.ParseInt(m.LastErrorTime, 10, 64)
+						fmt.Println(fmt.Sprintf("      Last error (%s): %s",
+							time.Unix(tsec, 0).String(), Red(m.LastError)))
+					}
+				}
+			}
+		},
+	}
+
+	flags := ans.Flags()
+	flags.Bool("reset", false, "Remove the mirrors health data of the repository.")
+	flags.StringP("output", "o", "terminal",
+		"Output format ( Defaults: terminal, available: json,yaml )")
+
+	return ans
+}
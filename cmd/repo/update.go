@@ -36,6 +36,9 @@ $> luet repo update
 
 # Update only repo1 and repo2
 $> luet repo update repo1 repo2
+
+# Show again the changes of the last update of repo1
+$> luet repo diff repo1
 `,
 		Aliases: []string{"up"},
 		PreRun: func(cmd *cobra.Command, args []string) {
@@ -43,10 +46,12 @@ $> luet repo update repo1 repo2
 		Run: func(cmd *cobra.Command, args []string) {
 			ignore, _ := cmd.Flags().GetBool("ignore-errors")
 			force, _ := cmd.Flags().GetBool("force")
+			noChangelog, _ := cmd.Flags().GetBool("no-changelog")
 
 			opts := &wagon.SyncOpts{
-				Force:        force,
-				IgnoreErrors: ignore,
+				Force:         force,
+				IgnoreErrors:  ignore,
+				ShowChangelog: !noChangelog,
 			}
 
 			rails := wagon.NewWagonsRails(config)
@@ -61,6 +66,8 @@ $> luet repo update repo1 repo2
 	ans.Flags().BoolP("ignore-errors", "i", false,
 		"Ignore errors on sync repositories.")
 	ans.Flags().BoolP("force", "f", false, "Force resync.")
+	ans.Flags().Bool("no-changelog", false,
+		"Don't show the packages changes of the repositories updated.")
 
 	return ans
 }
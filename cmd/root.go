@@ -13,6 +13,7 @@ import (
 	"runtime"
 	"strings"
 
+	cmd_box "github.com/geaaru/luet/cmd/box"
 	util "github.com/geaaru/luet/cmd/util"
 	config "github.com/geaaru/luet/pkg/config"
 	helpers "github.com/geaaru/luet/pkg/helpers"
@@ -237,9 +238,12 @@ func initCommand(rootCmd *cobra.Command, cfg *config.LuetConfig) {
 	// Add main commands
 	rootCmd.AddCommand(
 		newBoxCommand(cfg),
+		newBundleCommand(cfg),
 		newConfigCommand(cfg),
+		newConfigUpdateCommand(cfg),
 		newDatabaseCommand(cfg),
-		newExecCommand(cfg),
+		cmd_box.NewExecCommand(cfg),
+		newHoldCommand(cfg),
 		newRepoCommand(cfg),
 		newUpgradeCommand(cfg),
 		newSearchCommand(cfg),
@@ -249,6 +253,8 @@ func initCommand(rootCmd *cobra.Command, cfg *config.LuetConfig) {
 		newMinerCommand(cfg),
 		newUninstallCommand(cfg),
 		newInstallCommand(cfg),
+		newTransactionCommand(cfg),
+		newWhyCommand(cfg),
 	)
 
 }
@@ -216,9 +216,13 @@ func newSearchCommand(config *cfg.LuetConfig) *cobra.Command {
 					table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
 					table.SetCenterSeparator("|")
 					table.SetAlignment(tablewriter.ALIGN_LEFT)
-					table.SetHeader([]string{
+					header := []string{
 						"Package", "Version", "Repository",
-					})
+					}
+					if installed {
+						header = append(header, "Reason")
+					}
+					table.SetHeader(header)
 					table.SetAutoWrapText(false)
 
 					if artifactView {
@@ -233,11 +237,15 @@ func newSearchCommand(config *cfg.LuetConfig) *cobra.Command {
 						}
 					} else {
 						for _, s := range *res {
-							table.Append([]string{
+							row := []string{
 								fmt.Sprintf("%s/%s", s.Category, s.Name),
 								s.Version,
 								s.Repository,
-							})
+							}
+							if installed {
+								row = append(row, s.InstallReason)
+							}
+							table.Append(row)
 						}
 					}
 
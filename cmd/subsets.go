@@ -22,6 +22,7 @@ func newSubsetsCommand(config *cfg.LuetConfig) *cobra.Command {
 		cmd_subsets.NewSubsetsListCommand(config),
 		cmd_subsets.NewSubsetsEnableCommand(config),
 		cmd_subsets.NewSubsetsDisableCommand(config),
+		cmd_subsets.NewSubsetsApplyCommand(config),
 	)
 
 	return ans
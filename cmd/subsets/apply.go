@@ -0,0 +1,104 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_subsets
+
+import (
+	helpers "github.com/geaaru/luet/cmd/helpers"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/subsets"
+	installer "github.com/geaaru/luet/pkg/v2/installer"
+
+	"github.com/spf13/cobra"
+)
+
+func applySubsets(config *cfg.LuetConfig, opts *installer.SubsetsApplyOpts,
+	packs []*pkg.DefaultPackage) {
+
+	// Load config protect configs
+	installer.LoadConfigProtectConfs(config)
+	// Load subsets defintions
+	subsets.LoadSubsetsDefintions(config)
+
+	aManager := installer.NewArtifactsManager(config)
+	defer aManager.Close()
+
+	if err := aManager.ApplySubsets(opts, config.GetSystem().Rootfs,
+		packs...); err != nil {
+		Fatal("Error: " + err.Error())
+	}
+}
+
+// reloadSubsetsConfig reloads the enabled subsets after the
+// update of the subsets config files.
+func reloadSubsetsConfig(config *cfg.LuetConfig, enabled []string) {
+	config.Subsets.Enabled = enabled
+	subsets.LoadSubsetsConfig(config)
+}
+
+func addApplyFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.Bool("apply", false,
+		"Apply the subsets changes to the installed packages.")
+	flags.BoolP("pretend", "p", false,
+		"Simply display the changes without apply them (with --apply).")
+	flags.Bool("force", false, "Skip errors and keep going (potentially harmful)")
+	flags.BoolP("yes", "y", false, "Don't ask questions")
+}
+
+func getApplyOpts(cmd *cobra.Command) *installer.SubsetsApplyOpts {
+	pretend, _ := cmd.Flags().GetBool("pretend")
+	force, _ := cmd.Flags().GetBool("force")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	return &installer.SubsetsApplyOpts{
+		Pretend: pretend,
+		Force:   force,
+		Ask:     !yes,
+	}
+}
+
+func NewSubsetsApplyCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "apply [OPTIONS] [pkg1] ... [pkgN]",
+		Short: "Apply the enabled subsets to the installed packages.",
+		Long: `Extract the files of the enabled subsets and remove the files
+of the disabled subsets of the installed packages with subsets rules.
+
+	$> luet subsets apply
+
+	$> luet subsets apply --pretend
+
+	$> luet subsets apply sys-devel/gcc
+
+The new files are extracted from the packages available
+in the cache or downloaded again from the repositories.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			packs := []*pkg.DefaultPackage{}
+			for _, a := range args {
+				p, err := helpers.ParsePackageStr(config, a)
+				if err != nil {
+					Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+				packs = append(packs, p)
+			}
+
+			// Load subsets config
+			subsets.LoadSubsetsConfig(config)
+
+			applySubsets(config, getApplyOpts(cmd), packs)
+		},
+	}
+
+	flags := ans.Flags()
+	flags.BoolP("pretend", "p", false,
+		"Simply display the changes without apply them.")
+	flags.Bool("force", false, "Skip errors and keep going (potentially harmful)")
+	flags.BoolP("yes", "y", false, "Don't ask questions")
+
+	return ans
+}
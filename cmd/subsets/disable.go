@@ -12,6 +12,7 @@ import (
 	cfg "github.com/geaaru/luet/pkg/config"
 	helpers "github.com/geaaru/luet/pkg/helpers/file"
 	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
 	"github.com/geaaru/luet/pkg/subsets"
 
 	"github.com/spf13/cobra"
@@ -27,6 +28,8 @@ func NewSubsetsDisableCommand(config *cfg.LuetConfig) *cobra.Command {
 
 	$> luet subsets disable -f my devel portage mysubset
 
+	$> luet subsets disable --apply devel
+
 The filename is used to write/update the file under the first
 directory defined on subsets_confdir option (for example /etc/luet/subsets.conf.d/my.yml else main.yml is used).
 `,
@@ -37,6 +40,10 @@ directory defined on subsets_confdir option (for example /etc/luet/subsets.conf.
 			subsetsDisabled := []string{}
 
 			filename, _ := cmd.Flags().GetString("file")
+			apply, _ := cmd.Flags().GetBool("apply")
+
+			// Store the subsets enabled on luet.yaml
+			enabled := append([]string{}, config.Subsets.Enabled...)
 
 			// Load subsets config
 			subsets.LoadSubsetsConfig(config)
@@ -97,11 +104,17 @@ directory defined on subsets_confdir option (for example /etc/luet/subsets.conf.
 				InfoC("No subsets to disable.")
 			}
 
+			if apply {
+				reloadSubsetsConfig(config, enabled)
+				applySubsets(config, getApplyOpts(cmd), []*pkg.DefaultPackage{})
+			}
+
 		},
 	}
 
 	ans.Flags().StringP("file", "f", "",
 		"Define the filename without extension where enable the subsets.")
+	addApplyFlags(ans)
 
 	return ans
 }
@@ -0,0 +1,39 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	. "github.com/geaaru/luet/cmd/transaction"
+	cfg "github.com/geaaru/luet/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+func newTransactionCommand(config *cfg.LuetConfig) *cobra.Command {
+
+	var ans = &cobra.Command{
+		Use:     "transaction [command] [OPTIONS]",
+		Short:   "Manage install/upgrade transactions journal.",
+		Aliases: []string{"tx"},
+		Long: `Every install/upgrade operation is registered on a journal
+under the system database path. An interrupted transaction could be
+reverted or resumed.
+
+	$ luet transaction list
+
+	$ luet transaction rollback <id>
+
+	$ luet transaction resume <id>
+`,
+	}
+
+	ans.AddCommand(
+		NewTransactionListCommand(config),
+		NewTransactionRollbackCommand(config),
+		NewTransactionResumeCommand(config),
+	)
+
+	return ans
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_transaction
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/geaaru/luet/cmd/util"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	installer "github.com/geaaru/luet/pkg/v2/installer"
+
+	. "github.com/logrusorgru/aurora"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func NewTransactionListCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:     "list [OPTIONS]",
+		Short:   "List the transactions registered on journal.",
+		Aliases: []string{"l"},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			util.BindSystemFlags(cmd)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			util.SetSystemConfig()
+
+			out, _ := cmd.Flags().GetString("output")
+			pending, _ := cmd.Flags().GetBool("pending")
+
+			aManager := installer.NewArtifactsManager(config)
+			defer aManager.Close()
+
+			var list []*installer.Transaction
+			var err error
+
+			if pending {
+				list, err = aManager.GetPendingTransactions()
+			} else {
+				list, err = aManager.GetTransactions()
+			}
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			switch out {
+			case "json":
+				data, err := json.Marshal(list)
+				if err != nil {
+					Fatal("Error on marshal transactions", err.Error())
+				}
+				fmt.Println(string(data))
+			case "yaml":
+				data, err := yaml.Marshal(list)
+				if err != nil {
+					Fatal("Error on marshal transactions", err.Error())
+				}
+				fmt.Println(string(data))
+			default:
+				if len(list) == 0 {
+					InfoC("No transactions found.")
+					return
+				}
+
+				for _, t := range list {
+					tsec, _ := strconv.ParseInt(t.Created, 10, 64)
+
+					var state string
+					switch t.State {
+					case installer.TransactionCompleted:
+						state = Bold(Green(t.State)).String()
+					case installer.TransactionRolledBack:
+						state = Bold(Yellow(t.State)).String()
+					default:
+						state = Bold(Red(t.State)).String()
+					}
+
+					fmt.Println(fmt.Sprintf("%s %-8s %-12s %s - %d/%d operations done",
+						Bold(Blue(t.Id)),
+						t.Command,
+						state,
+						time.Unix(tsec, 0).String(),
+						len(t.GetOperations(installer.TxOpDone)),
+						len(t.Operations),
+					))
+				}
+			}
+		},
+	}
+
+	flags := ans.Flags()
+	flags.String("system-dbpath", "", "System db path")
+	flags.String("system-target", "", "System rootpath")
+	flags.String("system-engine", "", "System DB engine")
+	flags.Bool("pending", false, "Show only interrupted transactions.")
+	flags.StringP("output", "o", "terminal",
+		"Output format ( Defaults: terminal, available: json,yaml )")
+
+	return ans
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_transaction
+
+import (
+	"fmt"
+
+	"github.com/geaaru/luet/cmd/util"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	"github.com/geaaru/luet/pkg/subsets"
+	installer "github.com/geaaru/luet/pkg/v2/installer"
+
+	. "github.com/logrusorgru/aurora"
+	"github.com/spf13/cobra"
+)
+
+func NewTransactionResumeCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "resume <id>",
+		Short: "Resume an interrupted transaction.",
+		Long: `Execute the operations not completed of an interrupted
+transaction. The artifacts must be available in the packages cache.
+
+	$ luet transaction resume 20230412101010-aBcDeF
+`,
+		Args: cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			util.BindSystemFlags(cmd)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			util.SetSystemConfig()
+
+			force, _ := cmd.Flags().GetBool("force")
+			yes, _ := cmd.Flags().GetBool("yes")
+			preserveSystem, _ := cmd.Flags().GetBool("preserve-system-essentials")
+			skipFinalizers, _ := cmd.Flags().GetBool("skip-finalizers")
+			skipHooks, _ := cmd.Flags().GetBool("skip-hooks")
+			finalizerEnvs, _ := cmd.Flags().GetStringArray("finalizer-env")
+
+			// Load config protect configs
+			installer.LoadConfigProtectConfs(config)
+			// Load subsets defintions
+			subsets.LoadSubsetsDefintions(config)
+			// Load subsets config
+			subsets.LoadSubsetsConfig(config)
+
+			// Load finalizer runtime environments
+			err := util.SetCliFinalizerEnvs(finalizerEnvs)
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			aManager := installer.NewArtifactsManager(config)
+			defer aManager.Close()
+
+			t, err := aManager.GetTransaction(args[0])
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			InfoC(fmt.Sprintf(":play_button:Resume transaction %s (%s): %d of %d operations done.",
+				Bold(Blue(t.Id)), t.Command,
+				len(t.GetOperations(installer.TxOpDone)),
+				len(t.Operations),
+			))
+
+			if !yes && !Ask() {
+				Fatal("Resume cancelled by user.")
+			}
+
+			opts := &installer.InstallOpts{
+				Force:                       force,
+				PreserveSystemEssentialData: preserveSystem,
+				SkipFinalizers:              skipFinalizers,
+				SkipHooks:                   skipHooks,
+			}
+
+			err = aManager.ResumeTransaction(t, opts)
+			if err != nil {
+				Fatal("Error: " + err.Error())
+			}
+
+			InfoC(fmt.Sprintf(":confetti_ball:%s",
+				Bold(Blue("All done."))))
+		},
+	}
+
+	flags := ans.Flags()
+	flags.String("system-dbpath", "", "System db path")
+	flags.String("system-target", "", "System rootpath")
+	flags.String("system-engine", "", "System DB engine")
+	flags.Bool("force", false, "Skip errors and keep going (potentially harmful)")
+	flags.BoolP("yes", "y", false, "Don't ask questions")
+	flags.Bool("preserve-system-essentials", true, "Preserve system luet files")
+	flags.Bool("skip-finalizers", false,
+		"Skip the execution of the finalizers.")
+	flags.Bool("skip-hooks", false,
+		"Skip the execution of the transaction hooks.")
+	flags.StringArray("finalizer-env", []string{},
+		"Set finalizer environment in the format key=value.")
+
+	return ans
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_transaction
+
+import (
+	"fmt"
+
+	"github.com/geaaru/luet/cmd/util"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	installer "github.com/geaaru/luet/pkg/v2/installer"
+
+	. "github.com/logrusorgru/aurora"
+	"github.com/spf13/cobra"
+)
+
+func NewTransactionRollbackCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "rollback <id>",
+		Short: "Revert an interrupted transaction.",
+		Long: `Revert the operations executed by an interrupted transaction
+in the reverse order and restore the files displaced.
+
+	$ luet transaction rollback 20230412101010-aBcDeF
+`,
+		Args: cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			util.BindSystemFlags(cmd)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			util.SetSystemConfig()
+
+			force, _ := cmd.Flags().GetBool("force")
+			yes, _ := cmd.Flags().GetBool("yes")
+
+			aManager := installer.NewArtifactsManager(config)
+			defer aManager.Close()
+
+			t, err := aManager.GetTransaction(args[0])
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			InfoC(fmt.Sprintf(":back:Rollback transaction %s (%s) with %d operations done.",
+				Bold(Blue(t.Id)), t.Command,
+				len(t.GetOperations(installer.TxOpDone))+
+					len(t.GetOperations(installer.TxOpRunning)),
+			))
+
+			if !yes && !Ask() {
+				Fatal("Rollback cancelled by user.")
+			}
+
+			err = aManager.RollbackTransaction(t, force)
+			if err != nil {
+				Fatal("Error: " + err.Error())
+			}
+
+			InfoC(fmt.Sprintf(":confetti_ball:%s",
+				Bold(Blue("All done."))))
+		},
+	}
+
+	flags := ans.Flags()
+	flags.String("system-dbpath", "", "System db path")
+	flags.String("system-target", "", "System rootpath")
+	flags.String("system-engine", "", "System DB engine")
+	flags.Bool("force", false, "Skip errors and keep going (potentially harmful)")
+	flags.BoolP("yes", "y", false, "Don't ask questions")
+
+	return ans
+}
@@ -32,6 +32,11 @@ Remove one or more packages without dependencies
 
 	$ luet uninstall cat/foo1 ... --nodeps
 
+Remove one or more packages and the dependencies that become orphans
+and that aren't required by other packages
+
+	$ luet uninstall cat/foo1 ... --deep
+
 Remove one or more packages and skip errors
 
 	$ luet uninstall cat/foo1 ... --force
@@ -63,10 +68,12 @@ of the finalizers.
 
 			force := config.Viper.GetBool("force")
 			nodeps, _ := cmd.Flags().GetBool("nodeps")
+			deep, _ := cmd.Flags().GetBool("deep")
 			yes := config.Viper.GetBool("yes")
 			keepProtected, _ := cmd.Flags().GetBool("keep-protected-files")
 			preserveSystem, _ := cmd.Flags().GetBool("preserve-system-essentials")
 			skipFinalizers, _ := cmd.Flags().GetBool("skip-finalizers")
+			skipHooks, _ := cmd.Flags().GetBool("skip-hooks")
 			finalizerEnvs, _ := cmd.Flags().GetStringArray("finalizer-env")
 
 			config.ConfigProtectSkip = !keepProtected
@@ -93,6 +100,8 @@ of the finalizers.
 				PreserveSystemEssentialData: preserveSystem,
 				Ask:                         !yes,
 				SkipFinalizers:              skipFinalizers,
+				SkipHooks:                   skipHooks,
+				Deep:                        deep,
 			}
 
 			if err := aManager.Uninstall(opts, config.GetSystem().Rootfs,
@@ -107,6 +116,8 @@ of the finalizers.
 
 	flags.Bool("nodeps", false, "Don't consider package dependencies (harmful! overrides checkconflicts and full!)")
 	flags.Bool("force", false, "Force uninstall")
+	flags.Bool("deep", false,
+		"Remove also the dependencies that become orphans.")
 	flags.BoolP("yes", "y", false, "Don't ask questions")
 	flags.BoolP("keep-protected-files", "k", false, "Keep package protected files around")
 	flags.Bool("preserve-system-essentials", true, "Preserve system luet files")
@@ -114,6 +125,8 @@ of the finalizers.
 		"Set finalizer environment in the format key=value.")
 	ans.Flags().Bool("skip-finalizers", false,
 		"Skip the execution of the finalizers.")
+	ans.Flags().Bool("skip-hooks", false,
+		"Skip the execution of the transaction hooks.")
 
 	config.Viper.BindPFlag("nodeps", flags.Lookup("nodeps"))
 	config.Viper.BindPFlag("force", flags.Lookup("force"))
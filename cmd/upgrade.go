@@ -45,13 +45,17 @@ func newUpgradeCommand(config *cfg.LuetConfig) *cobra.Command {
 			//yes, _ := cmd.Flags().GetBool("yes")
 			skipCheckSystem, _ := cmd.Flags().GetBool("skip-check-system")
 			downloadOnly, _ := cmd.Flags().GetBool("download-only")
+			skipDeltas, _ := cmd.Flags().GetBool("skip-deltas")
 			pretend, _ := cmd.Flags().GetBool("pretend")
 			ignoreConflicts, _ := cmd.Flags().GetBool("ignore-conflicts")
 			preserveSystem, _ := cmd.Flags().GetBool("preserve-system-essentials")
 			finalizerEnvs, _ := cmd.Flags().GetStringArray("finalizer-env")
 			skipFinalizers, _ := cmd.Flags().GetBool("skip-finalizers")
+			skipHooks, _ := cmd.Flags().GetBool("skip-hooks")
 			syncRepos, _ := cmd.Flags().GetBool("sync-repos")
 			ignoreMasks, _ := cmd.Flags().GetBool("ignore-masks")
+			explain, _ := cmd.Flags().GetBool("explain")
+			ignoreHolds, _ := cmd.Flags().GetBool("ignore-holds")
 			showUpgradeOrder, _ := cmd.Flags().GetBool("show-upgrade-order")
 			deep, _ := cmd.Flags().GetBool("deep")
 			purge, _ := cmd.Flags().GetBool("purge-repos")
@@ -95,10 +99,14 @@ func newUpgradeCommand(config *cfg.LuetConfig) *cobra.Command {
 				PreserveSystemEssentialData: preserveSystem,
 				Ask:                         !yes,
 				SkipFinalizers:              skipFinalizers,
+				SkipHooks:                   skipHooks,
 				Pretend:                     pretend,
 				DownloadOnly:                downloadOnly,
+				SkipDeltas:                  skipDeltas,
 				CheckSystemFiles:            !skipCheckSystem,
 				IgnoreMasks:                 ignoreMasks,
+				Explain:                     explain,
+				IgnoreHolds:                 ignoreHolds,
 				ShowInstallOrder:            showUpgradeOrder,
 				Deep:                        deep,
 			}
@@ -136,11 +144,17 @@ func newUpgradeCommand(config *cfg.LuetConfig) *cobra.Command {
 	flags.BoolP("yes", "y", false, "Don't ask questions")
 	flags.Bool("deep", false, "Deep analyzing with downgrade.")
 	flags.Bool("download-only", false, "Download only")
+	flags.Bool("skip-deltas", false, "Always download the complete packages instead of the deltas.")
 	flags.Bool("skip-finalizers", false,
 		"Skip the execution of the finalizers.")
+	flags.Bool("skip-hooks", false,
+		"Skip the execution of the transaction hooks.")
 	flags.Bool("sync-repos", false,
 		"Sync repositories before upgrade. Note: If there are in memory repositories then the sync is done always.")
 	flags.Bool("ignore-masks", false, "Ignore packages masked.")
+	flags.Bool("explain", false,
+		"Show the decisions of the solver about the candidates considered and rejected.")
+	flags.Bool("ignore-holds", false, "Ignore packages held.")
 	flags.Bool("show-upgrade-order", false,
 		"In additional of the package to upgrade show the installation order and exit.")
 	flags.Bool("cleanup", false, "Cleanup local packages cache.")
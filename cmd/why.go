@@ -0,0 +1,128 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	helpers "github.com/geaaru/luet/cmd/helpers"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	solver "github.com/geaaru/luet/pkg/v2/solver"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+type whyChain struct {
+	Packages []string `json:"packages" yaml:"packages"`
+	// Reason is the install reason of the first package of the chain.
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+type whyResult struct {
+	Package string      `json:"package" yaml:"package"`
+	Chains  []*whyChain `json:"chains" yaml:"chains"`
+}
+
+func newWhyCommand(config *cfg.LuetConfig) *cobra.Command {
+
+	var ans = &cobra.Command{
+		Use:   "why <pkg1> ... <pkgN>",
+		Short: "Show why an installed package is installed.",
+		Long: `Show the chains of the installed packages that require the
+package in input up to the packages installed explicitly by the user.
+
+	$ luet why sys-libs/zlib
+
+	$ luet why zlib -o json
+
+The packages installed without the install reason are considered
+as selected by the user and are shown with the unknown reason.
+Use luet database mark to set the install reason.
+`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+
+			s := solver.NewSolver(config, solver.NewSolverOpts())
+			s.SetDatabase(config.GetSystemDB())
+
+			results := []*whyResult{}
+			for _, a := range args {
+				p, err := helpers.ParsePackageStr(config, a)
+				if err != nil {
+					Fatal("Invalid package string ", a, ": ", err.Error())
+				}
+
+				chains, err := s.Why(p)
+				if err != nil {
+					Fatal(err.Error())
+				}
+
+				r := &whyResult{
+					Package: p.PackageName(),
+					Chains:  []*whyChain{},
+				}
+				for _, chain := range chains {
+					c := &whyChain{
+						Packages: []string{},
+						Reason:   chain[0].GetInstallReason(),
+					}
+					if c.Reason == "" {
+						c.Reason = "unknown reason"
+					}
+					for _, cp := range chain {
+						c.Packages = append(c.Packages, cp.HumanReadableString())
+					}
+					r.Chains = append(r.Chains, c)
+				}
+				results = append(results, r)
+			}
+
+			switch out {
+			case "json":
+				data, err := json.Marshal(results)
+				if err != nil {
+					Fatal("Error on marshal results", err.Error())
+				}
+				fmt.Println(string(data))
+			case "yaml":
+				data, err := yaml.Marshal(results)
+				if err != nil {
+					Fatal("Error on marshal results", err.Error())
+				}
+				fmt.Println(string(data))
+			default:
+				for _, r := range results {
+					if len(r.Chains) == 0 {
+						fmt.Println(fmt.Sprintf(
+							"%s: not required by packages selected by the user.", r.Package))
+						continue
+					}
+
+					fmt.Println(fmt.Sprintf("%s:", r.Package))
+					for _, c := range r.Chains {
+						if len(c.Packages) == 1 {
+							fmt.Println(fmt.Sprintf("  %s (%s)",
+								c.Packages[0], c.Reason))
+							continue
+						}
+						fmt.Println(fmt.Sprintf("  %s (%s) -> %s",
+							c.Packages[0], c.Reason,
+							strings.Join(c.Packages[1:], " -> ")))
+					}
+				}
+			}
+		},
+	}
+
+	ans.Flags().StringP("output", "o", "terminal",
+		"Output format ( Defaults: terminal, available: json,yaml )")
+
+	return ans
+}
@@ -8,6 +8,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	helpers "github.com/geaaru/luet/cmd/helpers"
 	bhelpers "github.com/geaaru/luet/luet-build/cmd/helpers"
@@ -20,9 +23,11 @@ import (
 	cfg "github.com/geaaru/luet/pkg/config"
 	. "github.com/geaaru/luet/pkg/logger"
 	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/sbom"
 	tree "github.com/geaaru/luet/pkg/tree"
 
 	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -77,6 +82,11 @@ func newBuildCommand(config *cfg.LuetConfig) *cobra.Command {
 	Build packages specifying multiple definition trees:
 
 		$ luet build --tree overlay/path --tree overlay/path2 utils/yq ...
+
+	Build packages twice and check that the artifacts are identical
+	(without SOURCE_DATE_EPOCH the start time of the build is used):
+
+		$ SOURCE_DATE_EPOCH=1672531200 luet build --verify-reproducible utils/yq
 	`, PreRun: func(cmd *cobra.Command, args []string) {
 			config.Viper.BindPFlag("tree", cmd.Flags().Lookup("tree"))
 			config.Viper.BindPFlag("destination", cmd.Flags().Lookup("destination"))
@@ -95,6 +105,12 @@ func newBuildCommand(config *cfg.LuetConfig) *cobra.Command {
 			config.Viper.BindPFlag("pull", cmd.Flags().Lookup("pull"))
 			config.Viper.BindPFlag("wait", cmd.Flags().Lookup("wait"))
 			config.Viper.BindPFlag("keep-images", cmd.Flags().Lookup("keep-images"))
+			config.Viper.BindPFlag("system.build_cache_path", cmd.Flags().Lookup("cache-dir"))
+
+			sbomFormat, _ := cmd.Flags().GetString("sbom-format")
+			if err := sbom.ValidateFormat(sbomFormat); err != nil {
+				Fatal(err.Error())
+			}
 
 			bhelpers.BindSolverFlags(cmd)
 
@@ -112,6 +128,7 @@ func newBuildCommand(config *cfg.LuetConfig) *cobra.Command {
 			revdeps := config.Viper.GetBool("revdeps")
 			all := config.Viper.GetBool("all")
 			compressionType := config.Viper.GetString("compression")
+			sbomFormat, _ := cmd.Flags().GetString("sbom-format")
 			imageRepository := config.Viper.GetString("image-repository")
 			values := bhelpers.ValuesFlags()
 			wait := config.Viper.GetBool("wait")
@@ -123,6 +140,7 @@ func newBuildCommand(config *cfg.LuetConfig) *cobra.Command {
 			onlyTarget, _ := cmd.Flags().GetBool("only-target-package")
 			full, _ := cmd.Flags().GetBool("full")
 			rebuild, _ := cmd.Flags().GetBool("rebuild")
+			verify, _ := cmd.Flags().GetBool("verify-reproducible")
 
 			var results Results
 			backendArgs := config.Viper.GetStringSlice("backend-args")
@@ -165,8 +183,7 @@ func newBuildCommand(config *cfg.LuetConfig) *cobra.Command {
 
 			Debug("Solver", opts.CompactString())
 
-			luetCompiler := compiler.NewLuetCompiler(compilerBackend,
-				generalRecipe.GetDatabase(),
+			compilerOpts := []options.Option{
 				options.NoDeps(nodeps),
 				options.WithBackendType(backendType),
 				options.PushImages(push),
@@ -183,7 +200,12 @@ func newBuildCommand(config *cfg.LuetConfig) *cobra.Command {
 				options.BackendArgs(backendArgs),
 				options.Concurrency(concurrency),
 				options.WithCompressionType(compression.Implementation(compressionType)),
-			)
+				options.WithCacheDir(config.Viper.GetString("system.build_cache_path")),
+				options.WithSBOMFormat(sbomFormat),
+			}
+
+			luetCompiler := compiler.NewLuetCompiler(compilerBackend,
+				generalRecipe.GetDatabase(), compilerOpts...)
 
 			if full {
 				specs, err := luetCompiler.FromDatabase(generalRecipe.GetDatabase(), true, dst)
@@ -224,6 +246,12 @@ func newBuildCommand(config *cfg.LuetConfig) *cobra.Command {
 				}
 			}
 
+			if verify && !pretend {
+				// Both the builds must clamp the mtime of the files
+				// to the same time.
+				helpers.CheckErr(pinSourceDateEpoch())
+			}
+
 			var artifact []*artifact.PackageArtifact
 			var errs []error
 			if revdeps {
@@ -293,6 +321,21 @@ func newBuildCommand(config *cfg.LuetConfig) *cobra.Command {
 			for _, a := range artifact {
 				Info("Artifact generated:", a.Path)
 			}
+
+			if verify && !pretend && len(artifact) > 0 {
+				// The second build doesn't use the build cache and
+				// doesn't reuse the images of the first build.
+				compilerOpts = append(compilerOpts,
+					options.WithCacheDir(""),
+					options.Rebuild(true),
+					options.PushImages(false),
+				)
+				verifyCompiler := compiler.NewLuetCompiler(compilerBackend,
+					generalRecipe.GetDatabase(), compilerOpts...)
+
+				helpers.CheckErr(verifyReproducible(config, verifyCompiler,
+					compilerSpecs, artifact, privileged, revdeps))
+			}
 		},
 	}
 
@@ -304,7 +347,7 @@ func newBuildCommand(config *cfg.LuetConfig) *cobra.Command {
 	flags := buildCmd.Flags()
 
 	flags.StringSliceP("tree", "t", []string{path}, "Path of the tree to use.")
-	flags.String("backend", "docker", "backend used (docker,img)")
+	flags.String("backend", "docker", "backend used (docker,dockerv2,dockerv3,img,native,podman,buildah)")
 	flags.Bool("privileged", true, "Privileged (Keep permissions)")
 	flags.Bool("revdeps", false, "Build with revdeps")
 	flags.Bool("all", false, "Build all specfiles in the tree")
@@ -332,8 +375,114 @@ func newBuildCommand(config *cfg.LuetConfig) *cobra.Command {
 	flags.Bool("rebuild", false, "To combine with --pull. Allows to rebuild the target package even if an image is available, against a local values file")
 	flags.Bool("pretend", false, "Just print what packages will be compiled")
 	flags.StringArrayP("pull-repository", "p", []string{}, "A list of repositories to pull the cache from")
+	flags.String("cache-dir", "", "Directory of the local build cache (default system.build_cache_path)")
+	flags.String("sbom-format", sbom.FormatSPDX,
+		"Format of the SBOM written next to the metadata files (spdx-json, cyclonedx-json, none)")
+	flags.Bool("verify-reproducible", false,
+		"Build the packages twice and report the differences between the artifacts")
 
 	flags.StringP("output", "o", "terminal", "Output format ( Defaults: terminal, available: json,yaml )")
 
 	return buildCmd
 }
+
+// pinSourceDateEpoch sets SOURCE_DATE_EPOCH to the current time when
+// it's not defined. The source_date_epoch of the specs overrides it.
+func pinSourceDateEpoch() error {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if _, err := strconv.ParseInt(epoch, 10, 64); err != nil {
+			return fmt.Errorf("Invalid SOURCE_DATE_EPOCH %s: it must be a unix timestamp", epoch)
+		}
+		return nil
+	}
+
+	epoch := strconv.FormatInt(time.Now().Unix(), 10)
+	Info(":clock1: SOURCE_DATE_EPOCH not defined, using", epoch, "for both the builds")
+	return os.Setenv("SOURCE_DATE_EPOCH", epoch)
+}
+
+// verifyReproducible builds again the specs in a temporary directory and
+// reports the differences between the new artifacts and the artifacts
+// of the first build.
+func verifyReproducible(config *cfg.LuetConfig, c *compiler.LuetCompiler,
+	specs *compilerspec.LuetCompilationspecs, artifacts []*artifact.PackageArtifact,
+	privileged, revdeps bool) error {
+
+	tmpdir, err := config.GetSystem().TempDir("verify-reproducible")
+	if err != nil {
+		return errors.Wrap(err, "Error on create temporary directory")
+	}
+	defer os.RemoveAll(tmpdir)
+
+	verifySpecs := compilerspec.NewLuetCompilationspecs()
+	for _, s := range specs.All() {
+		spec, err := c.FromPackage(s.GetPackage())
+		if err != nil {
+			return err
+		}
+		spec.SetOutputPath(tmpdir)
+		verifySpecs.Add(spec)
+	}
+
+	Info(":repeat: Building again the packages to verify the artifacts")
+
+	var verifyArtifacts []*artifact.PackageArtifact
+	var errs []error
+	if revdeps {
+		verifyArtifacts, errs = c.CompileWithReverseDeps(privileged, verifySpecs)
+	} else {
+		verifyArtifacts, errs = c.CompileParallel(privileged, verifySpecs)
+	}
+	if len(errs) != 0 {
+		for _, e := range errs {
+			Error("Error: " + e.Error())
+		}
+		return errors.New("Second build failed")
+	}
+
+	verifyMap := make(map[string]*artifact.PackageArtifact, len(verifyArtifacts))
+	for _, a := range verifyArtifacts {
+		verifyMap[filepath.Base(a.Path)] = a
+	}
+
+	failed := 0
+	for _, a := range artifacts {
+		name := filepath.Base(a.Path)
+		b, ok := verifyMap[name]
+		if !ok {
+			Warning(":x:", name, "not generated by the second build")
+			failed++
+			continue
+		}
+
+		if err := a.Hash(); err != nil {
+			return err
+		}
+		if err := b.Hash(); err != nil {
+			return err
+		}
+		if a.Checksums.Compare(b.Checksums) == nil {
+			Info(":white_check_mark:", name, "is reproducible")
+			continue
+		}
+
+		failed++
+		diffs, err := artifact.CompareArtifacts(a, b)
+		if err != nil {
+			return err
+		}
+		if len(diffs) == 0 {
+			Warning(":x:", name, "differs only for the order of the entries or the compression")
+			continue
+		}
+		Warning(":x:", name, "is not reproducible:")
+		for _, d := range diffs {
+			Warning("  ", d.Name+":", strings.Join(d.Differences, ", "))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d artifacts not reproducible", failed, len(artifacts))
+	}
+	return nil
+}
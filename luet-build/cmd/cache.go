@@ -0,0 +1,32 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	. "github.com/geaaru/luet/luet-build/cmd/cache"
+	cfg "github.com/geaaru/luet/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+func newCacheCommand(config *cfg.LuetConfig) *cobra.Command {
+
+	var cacheGroupCmd = &cobra.Command{
+		Use:   "cache [command] [OPTIONS]",
+		Short: "Manage the local build cache",
+	}
+
+	cacheGroupCmd.PersistentFlags().String("cache-dir", "",
+		"Directory of the build cache (default system.build_cache_path)")
+
+	cacheGroupCmd.AddCommand(
+		NewCacheListCommand(config),
+		NewCachePruneCommand(config),
+		NewCacheExportCommand(config),
+		NewCacheImportCommand(config),
+	)
+
+	return cacheGroupCmd
+}
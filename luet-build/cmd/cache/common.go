@@ -0,0 +1,33 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_cache
+
+import (
+	"github.com/geaaru/luet/pkg/compiler/cache"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+
+	"github.com/spf13/cobra"
+)
+
+// buildCache returns the cache of the --cache-dir flag or of the
+// system.build_cache_path option.
+func buildCache(cmd *cobra.Command, config *cfg.LuetConfig) *cache.BuildCache {
+	dir, _ := cmd.Flags().GetString("cache-dir")
+	if dir == "" {
+		dir = config.Viper.GetString("system.build_cache_path")
+	}
+	if dir == "" {
+		Fatal("No build cache directory defined. Use --cache-dir or system.build_cache_path.")
+	}
+	return cache.NewBuildCache(dir)
+}
+
+func shortKey(key string) string {
+	if len(key) > 12 {
+		return key[0:12]
+	}
+	return key
+}
@@ -0,0 +1,40 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_cache
+
+import (
+	"fmt"
+
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCacheExportCommand(config *cfg.LuetConfig) *cobra.Command {
+
+	var ans = &cobra.Command{
+		Use:   "export <file.tar.gz> [OPTIONS]",
+		Short: "Export the entries of the build cache to an archive.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			pkgs, _ := cmd.Flags().GetStringArray("package")
+
+			c := buildCache(cmd, config)
+			entries, err := c.Export(args[0], pkgs)
+			if err != nil {
+				Fatal("Error on export build cache: " + err.Error())
+			}
+
+			InfoC(fmt.Sprintf(":tada: Exported %d entries to %s.", len(entries), args[0]))
+		},
+	}
+
+	flags := ans.Flags()
+	flags.StringArray("package", []string{},
+		"Export only the entries of the package (category/name, category/name-version or key).")
+
+	return ans
+}
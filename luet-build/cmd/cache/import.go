@@ -0,0 +1,43 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_cache
+
+import (
+	"fmt"
+
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCacheImportCommand(config *cfg.LuetConfig) *cobra.Command {
+
+	var ans = &cobra.Command{
+		Use:   "import <file.tar.gz> [OPTIONS]",
+		Short: "Import the entries of an archive created with export.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			force, _ := cmd.Flags().GetBool("force")
+
+			c := buildCache(cmd, config)
+			entries, err := c.Import(args[0], force)
+			for _, e := range entries {
+				InfoC(fmt.Sprintf(":inbox_tray: Imported %s-%s (%s)",
+					e.Package, e.Version, shortKey(e.Key)))
+			}
+			if err != nil {
+				Fatal("Error on import build cache: " + err.Error())
+			}
+
+			InfoC(fmt.Sprintf(":tada: Imported %d entries.", len(entries)))
+		},
+	}
+
+	flags := ans.Flags()
+	flags.Bool("force", false, "Replace the entries already present.")
+
+	return ans
+}
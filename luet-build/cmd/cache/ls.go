@@ -0,0 +1,100 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/geaaru/luet/pkg/compiler/cache"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+
+	units "github.com/docker/go-units"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+type CacheListResult struct {
+	Entries []*cache.CacheEntry `json:"entries" yaml:"entries"`
+	Size    int64               `json:"size" yaml:"size"`
+}
+
+func NewCacheListCommand(config *cfg.LuetConfig) *cobra.Command {
+
+	var ans = &cobra.Command{
+		Use:     "ls [OPTIONS]",
+		Short:   "List the entries of the build cache.",
+		Aliases: []string{"list"},
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("output")
+
+			c := buildCache(cmd, config)
+			entries, err := c.List()
+			if err != nil {
+				Fatal("Error on read build cache: " + err.Error())
+			}
+
+			res := CacheListResult{Entries: entries}
+			for _, e := range entries {
+				res.Size += e.Size
+			}
+
+			switch out {
+			case "json":
+				data, err := json.Marshal(res)
+				if err != nil {
+					Fatal("Error on marshal entries: " + err.Error())
+				}
+				fmt.Println(string(data))
+			case "yaml":
+				data, err := yaml.Marshal(res)
+				if err != nil {
+					Fatal("Error on marshal entries: " + err.Error())
+				}
+				fmt.Println(string(data))
+			default:
+				if len(entries) == 0 {
+					fmt.Println("No entries in the build cache.")
+					return
+				}
+
+				table := tablewriter.NewWriter(os.Stdout)
+				table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+				table.SetCenterSeparator("|")
+				table.SetAlignment(tablewriter.ALIGN_LEFT)
+				table.SetHeader([]string{
+					"Package", "Version", "Key", "Artifact", "Image", "Size", "Last Used",
+				})
+				table.SetAutoWrapText(false)
+
+				for _, e := range entries {
+					table.Append([]string{
+						e.Package,
+						e.Version,
+						shortKey(e.Key),
+						fmt.Sprintf("%v", e.HasArtifact()),
+						e.Image,
+						units.HumanSize(float64(e.Size)),
+						e.LastUsed,
+					})
+				}
+				table.Render()
+
+				fmt.Println(fmt.Sprintf("\n%d entries, %s.", len(entries),
+					units.HumanSize(float64(res.Size))))
+			}
+		},
+	}
+
+	flags := ans.Flags()
+	flags.StringP("output", "o", "terminal",
+		"Output format ( Defaults: terminal, available: json,yaml )")
+
+	return ans
+}
@@ -0,0 +1,102 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd_cache
+
+import (
+	"fmt"
+
+	"github.com/geaaru/luet/pkg/compiler/cache"
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+
+	units "github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+func NewCachePruneCommand(config *cfg.LuetConfig) *cobra.Command {
+
+	var ans = &cobra.Command{
+		Use:   "prune [OPTIONS]",
+		Short: "Remove entries from the build cache.",
+		Long: `Remove entries from the build cache:
+
+	Remove the entries not used in the last 30 days:
+
+		$ luet-build cache prune --older-than 720h
+
+	Remove the less recently used entries until the cache is less than 20GB:
+
+		$ luet-build cache prune --max-size 20GB
+
+	Remove the entries of a package:
+
+		$ luet-build cache prune --package utils/yq
+
+	Remove the entries of a package not used in the last 30 days:
+
+		$ luet-build cache prune --package utils/yq --older-than 720h
+`,
+		Args: cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			all, _ := cmd.Flags().GetBool("all")
+			olderThan, _ := cmd.Flags().GetDuration("older-than")
+			maxSize, _ := cmd.Flags().GetString("max-size")
+			pkgs, _ := cmd.Flags().GetStringArray("package")
+
+			if !all && olderThan == 0 && maxSize == "" && len(pkgs) == 0 {
+				Fatal("Use at least one of --all, --older-than, --max-size or --package.")
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			all, _ := cmd.Flags().GetBool("all")
+			olderThan, _ := cmd.Flags().GetDuration("older-than")
+			maxSize, _ := cmd.Flags().GetString("max-size")
+			pkgs, _ := cmd.Flags().GetStringArray("package")
+
+			opts := cache.PruneOpts{
+				All:       all,
+				OlderThan: olderThan,
+				Packages:  pkgs,
+			}
+
+			if maxSize != "" {
+				size, err := units.FromHumanSize(maxSize)
+				if err != nil {
+					Fatal("Invalid max size: " + err.Error())
+				}
+				opts.MaxSize = size
+				if opts.MaxSize == 0 {
+					// A cache of 0 bytes means remove all.
+					opts.All = true
+				}
+			}
+
+			c := buildCache(cmd, config)
+			removed, err := c.Prune(opts)
+			for _, e := range removed {
+				InfoC(fmt.Sprintf(":wastebasket: Removed %s-%s (%s)",
+					e.Package, e.Version, shortKey(e.Key)))
+			}
+			if err != nil {
+				Fatal("Error on prune build cache: " + err.Error())
+			}
+
+			freed := int64(0)
+			for _, e := range removed {
+				freed += e.Size
+			}
+			InfoC(fmt.Sprintf(":tada: Removed %d entries, %s freed.",
+				len(removed), units.HumanSize(float64(freed))))
+		},
+	}
+
+	flags := ans.Flags()
+	flags.Bool("all", false, "Remove all the entries.")
+	flags.Duration("older-than", 0, "Remove the entries not used since the duration (e.g. 720h).")
+	flags.String("max-size", "", "Remove the less recently used entries until the cache is less than the size (e.g. 20GB).")
+	flags.StringArray("package", []string{}, "Remove the entries of the package (category/name or category/name-version).")
+
+	return ans
+}
@@ -11,6 +11,7 @@ import (
 	helpers "github.com/geaaru/luet/cmd/helpers"
 	"github.com/geaaru/luet/luet-build/pkg/v2/repository"
 	cfg "github.com/geaaru/luet/pkg/config"
+	"github.com/geaaru/luet/pkg/sbom"
 	"github.com/geaaru/luet/pkg/v2/compiler/types/compression"
 	wagon "github.com/geaaru/luet/pkg/v2/repository"
 
@@ -41,6 +42,19 @@ func newCreateRepoCommand(config *cfg.LuetConfig) *cobra.Command {
 	Create a repository from the metadata description defined in the luet.yaml config file:
 
 		$ luet create-repo --repo repository1
+
+	Sign the repository.yaml with an ed25519 key (see luet-build keygen):
+
+		$ luet create-repo --sign-key /etc/luet/keys/repo.key ...
+
+	Create delta tarballs against the two previous versions of every package:
+
+		$ luet create-repo --deltas 2 ...
+
+	Push the packages and the repository files as OCI artifacts to the
+	registries defined as urls:
+
+		$ luet create-repo --type oci --urls quay.io/org/repo ...
 	`,
 		PreRun: func(cmd *cobra.Command, args []string) {
 			config.Viper.BindPFlag("packages", cmd.Flags().Lookup("packages"))
@@ -61,6 +75,8 @@ func newCreateRepoCommand(config *cfg.LuetConfig) *cobra.Command {
 			config.Viper.BindPFlag("force-push", cmd.Flags().Lookup("force-push"))
 			config.Viper.BindPFlag("push-images", cmd.Flags().Lookup("push-images"))
 			config.Viper.BindPFlag("with-compilertree", cmd.Flags().Lookup("with-compilertree"))
+			config.Viper.BindPFlag("sign-key", cmd.Flags().Lookup("sign-key"))
+			config.Viper.BindPFlag("deltas", cmd.Flags().Lookup("deltas"))
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			var err error
@@ -79,6 +95,8 @@ func newCreateRepoCommand(config *cfg.LuetConfig) *cobra.Command {
 			sourceRepo := config.Viper.GetString("repo")
 			checkPackageTarball := config.Viper.GetBool("check-package-tarball")
 			withCompilerTree := config.Viper.GetBool("with-compilertree")
+			signKey := config.Viper.GetString("sign-key")
+			deltas := config.Viper.GetInt("deltas")
 			//backendType := config.Viper.GetString("backend")
 			//fromRepo, _ := cmd.Flags().GetBool("from-repositories")
 
@@ -95,6 +113,11 @@ func newCreateRepoCommand(config *cfg.LuetConfig) *cobra.Command {
 			opts.CompressionMode = compression.NewCompression(treetype)
 			opts.CheckPackageTarball = checkPackageTarball
 			opts.WithCompilerTree = withCompilerTree
+			opts.SignKeyFile = signKey
+			opts.DeltaVersions = deltas
+			opts.ForcePush = config.Viper.GetBool("force-push")
+			opts.SBOMFormat, _ = cmd.Flags().GetString("sbom-format")
+			helpers.CheckErr(sbom.ValidateFormat(opts.SBOMFormat))
 			if treeName != "" {
 				opts.TreeFilename = treeName
 			}
@@ -129,19 +152,25 @@ func newCreateRepoCommand(config *cfg.LuetConfig) *cobra.Command {
 	flags.String("name", "luet", "Repository name")
 	flags.String("descr", "luet", "Repository description")
 	flags.StringSlice("urls", []string{}, "Repository URLs")
-	flags.String("type", "disk", "Repository type (disk, http, docker)")
+	flags.String("type", "disk", "Repository type (disk, http, docker, oci)")
 	flags.Bool("reset-revision", false, "Reset repository revision.")
 	flags.Bool("check-package-tarball", false, "Validate presence of package tarball.")
 	flags.String("repo", "", "Use repository defined in configuration.")
 	flags.String("backend", "docker", "backend used (docker,img)")
 
-	flags.Bool("force-push", false, "Force overwrite of docker images if already present online")
+	flags.Bool("force-push", false, "Force overwrite of docker images or OCI artifacts if already present online")
 	flags.Bool("push-images", false, "Enable/Disable docker image push for docker repositories")
 	//flags.Bool("from-metadata", false, "Consider metadata files from the packages folder while indexing the new tree")
 
 	flags.Bool("with-compilertree", false, "Create compiler tree tarball.")
 	flags.String("tree-compression", "none", "Compression alg: none (self-autodetect), gzip, zstd")
 	flags.String("tree-filename", wagon.TREE_TARBALL, "Repository tree filename")
+	flags.String("sign-key", "",
+		"Path of the ed25519 private key (PEM) used to sign the repository.yaml.")
+	flags.Int("deltas", 0,
+		"Number of previous versions of every package used to create delta tarballs.")
+	flags.String("sbom-format", sbom.FormatSPDX,
+		"Format of the aggregate SBOM of the repository (spdx-json, cyclonedx-json, none)")
 	//flags.Bool("from-repositories", false, "Consume the user-defined repositories to pull specfiles from")
 
 	return createrepoCmd
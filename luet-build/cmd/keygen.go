@@ -0,0 +1,59 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	cfg "github.com/geaaru/luet/pkg/config"
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	. "github.com/geaaru/luet/pkg/logger"
+	"github.com/geaaru/luet/pkg/v2/repository/keyring"
+
+	"github.com/spf13/cobra"
+)
+
+func newKeygenCommand(config *cfg.LuetConfig) *cobra.Command {
+	var ans = &cobra.Command{
+		Use:   "keygen [OPTIONS]",
+		Short: "Generate the ed25519 keys used to sign repositories.",
+		Long: `Generate a new ed25519 key pair in PEM format:
+
+	$ luet-build keygen --private repo.key --public repo.pub
+
+The private key is used by create-repo (--sign-key) and the public key
+must be installed on clients under the directory
+<repos_keyringdir>/<repository-name>/ (ex. /etc/luet/repos.keyring.d/myrepo/repo.pub).
+`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			privFile, _ := cmd.Flags().GetString("private")
+			pubFile, _ := cmd.Flags().GetString("public")
+
+			if fileHelper.Exists(privFile) {
+				Fatal(fmt.Sprintf("The file %s already exists.", privFile))
+			}
+
+			err := keyring.GenerateKeys(privFile, pubFile)
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			pub, err := keyring.LoadPublicKey(pubFile)
+			if err != nil {
+				Fatal(err.Error())
+			}
+
+			InfoC(fmt.Sprintf(":key:Generated key %s (%s, %s).",
+				keyring.KeyId(pub), privFile, pubFile))
+		},
+	}
+
+	flags := ans.Flags()
+	flags.String("private", "repository.key", "Path of the private key to create.")
+	flags.String("public", "repository.pub", "Path of the public key to create.")
+
+	return ans
+}
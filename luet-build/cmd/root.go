@@ -12,6 +12,7 @@ import (
 	"runtime"
 	"strings"
 
+	cmd_box "github.com/geaaru/luet/cmd/box"
 	config "github.com/geaaru/luet/pkg/config"
 	helpers "github.com/geaaru/luet/pkg/helpers"
 	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
@@ -235,6 +236,9 @@ func initCommand(rootCmd *cobra.Command, cfg *config.LuetConfig) {
 		newServerRepoCommand(cfg),
 		newTreeCommand(cfg),
 		newBuildCommand(cfg),
+		newKeygenCommand(cfg),
+		cmd_box.NewExecCommand(cfg),
+		newCacheCommand(cfg),
 	)
 }
 
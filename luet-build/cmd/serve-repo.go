@@ -5,43 +5,106 @@
 package cmd
 
 import (
+	"fmt"
 	"net/http"
 	"os"
+	"strings"
 
+	server "github.com/geaaru/luet/luet-build/pkg/v2/server"
 	cfg "github.com/geaaru/luet/pkg/config"
 	. "github.com/geaaru/luet/pkg/logger"
 
 	"github.com/spf13/cobra"
 )
 
-type HandlerHttpLogger struct {
-	Config  *cfg.LuetConfig
-	Handler http.Handler
-}
-
-func (h *HandlerHttpLogger) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
-	Debug("Processing ", req.URL)
-	h.Handler.ServeHTTP(resp, req)
-}
-
 func newServerRepoCommand(config *cfg.LuetConfig) *cobra.Command {
 
 	var serverepoCmd = &cobra.Command{
 		Use:   "serve-repo",
 		Short: "Embedded micro-http server",
-		Long:  `Embedded mini http server for serving local repositories`,
+		Long: `Embedded mini http server for serving local repositories.
+
+Serve the directory of a repository:
+
+	$ luet-build serve-repo --dir /srv/repo
+
+Serve multiple repositories under different paths with TLS and
+authentication:
+
+	$ luet-build serve-repo --repo stage3=/srv/stage3 --repo dev=/srv/dev \
+		--tls-cert server.crt --tls-key server.key --token mytoken
+
+The repositories are available at https://<host>:<port>/stage3/ and
+https://<host>:<port>/dev/. The token is checked with the header
+"Authorization: token <val>" or "Authorization: Bearer <val>".
+
+The server exposes the packages of the repositories tree
+with the JSON API:
+
+	GET /_api/v1/repositories
+	GET /_api/v1/repositories/<name>
+	GET /_api/v1/repositories/<name>/packages
+
+and the downloads metrics in the Prometheus format under /metrics.
+`,
 		Run: func(cmd *cobra.Command, args []string) {
 
 			dir := config.Viper.GetString("dir")
 			port := config.Viper.GetString("port")
 			address := config.Viper.GetString("address")
+			repos, _ := cmd.Flags().GetStringArray("repo")
+			tokens, _ := cmd.Flags().GetStringArray("token")
+			basicAuth, _ := cmd.Flags().GetStringArray("basic-auth")
+			tlsCert, _ := cmd.Flags().GetString("tls-cert")
+			tlsKey, _ := cmd.Flags().GetString("tls-key")
+			metricsNoAuth, _ := cmd.Flags().GetBool("metrics-no-auth")
+
+			if (tlsCert == "") != (tlsKey == "") {
+				Fatal("Both --tls-cert and --tls-key are required for TLS.")
+			}
+
+			for _, b := range basicAuth {
+				if !strings.Contains(b, ":") {
+					Fatal("Invalid basic auth credentials. Use the format user:password.")
+				}
+			}
+
+			s := server.NewRepoServer(&server.RepoServerOpts{
+				Tokens:        tokens,
+				BasicAuth:     basicAuth,
+				MetricsNoAuth: metricsNoAuth,
+			})
+
+			if len(repos) == 0 || cmd.Flags().Changed("dir") {
+				if err := s.AddRepository("", server.DefaultPrefix, dir); err != nil {
+					Fatal(err.Error())
+				}
+				Info("Serving", dir, "on path /")
+			}
+
+			for _, r := range repos {
+				fields := strings.SplitN(r, "=", 2)
+				if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+					Fatal(fmt.Sprintf("Invalid repository %s. Use the format <prefix>=<dir>.", r))
+				}
+				if err := s.AddRepository("", fields[0], fields[1]); err != nil {
+					Fatal(err.Error())
+				}
+				Info("Serving", fields[1], "on path", "/"+strings.Trim(fields[0], "/")+"/")
+			}
 
-			handler := &HandlerHttpLogger{Config: config}
-			handler.Handler = http.FileServer(http.Dir(dir))
-			http.Handle("/", handler)
+			srv := &http.Server{
+				Addr:    address + ":" + port,
+				Handler: s,
+			}
 
-			Info("Serving ", dir, " on HTTP port: ", port)
-			Fatal(http.ListenAndServe(address+":"+port, nil))
+			if tlsCert != "" {
+				Info("Listening on HTTPS port:", port)
+				Fatal(srv.ListenAndServeTLS(tlsCert, tlsKey))
+			} else {
+				Info("Listening on HTTP port:", port)
+				Fatal(srv.ListenAndServe())
+			}
 		},
 	}
 
@@ -50,12 +113,22 @@ func newServerRepoCommand(config *cfg.LuetConfig) *cobra.Command {
 		Fatal(err)
 	}
 
-	serverepoCmd.Flags().String("dir", path, "Packages folder (output from build)")
-	serverepoCmd.Flags().String("port", "9090", "Listening port")
-	serverepoCmd.Flags().String("address", "0.0.0.0", "Listening address")
-	config.Viper.BindPFlag("dir", serverepoCmd.Flags().Lookup("dir"))
-	config.Viper.BindPFlag("address", serverepoCmd.Flags().Lookup("address"))
-	config.Viper.BindPFlag("port", serverepoCmd.Flags().Lookup("port"))
+	flags := serverepoCmd.Flags()
+	flags.String("dir", path, "Packages folder (output from build)")
+	flags.String("port", "9090", "Listening port")
+	flags.String("address", "0.0.0.0", "Listening address")
+	flags.StringArray("repo", []string{},
+		"Serve a repository directory under a path prefix (<prefix>=<dir>).")
+	flags.StringArray("token", []string{},
+		"Token accepted for the authentication.")
+	flags.StringArray("basic-auth", []string{},
+		"Basic auth credentials accepted (user:password).")
+	flags.String("tls-cert", "", "TLS certificate file.")
+	flags.String("tls-key", "", "TLS private key file.")
+	flags.Bool("metrics-no-auth", false, "Expose metrics without authentication.")
+	config.Viper.BindPFlag("dir", flags.Lookup("dir"))
+	config.Viper.BindPFlag("address", flags.Lookup("address"))
+	config.Viper.BindPFlag("port", flags.Lookup("port"))
 
 	return serverepoCmd
 }
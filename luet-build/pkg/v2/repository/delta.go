@@ -0,0 +1,150 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	. "github.com/geaaru/luet/pkg/logger"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	"github.com/geaaru/luet/pkg/v2/compiler/types/compression"
+)
+
+// getPackageTarball returns the path of the package tarball related
+// to the metadata file in input or an empty string if it isn't present.
+func getPackageTarball(opts *WagonFactoryOpts, metaFile string) (string, compression.Implementation) {
+	prefix := filepath.Join(opts.PackagesDir,
+		strings.TrimSuffix(metaFile, ".metadata.yaml")+".package.tar")
+
+	for _, c := range []compression.Implementation{
+		compression.Zstandard, compression.GZip, compression.None,
+	} {
+		if fileHelper.Exists(prefix + c.Ext()) {
+			return prefix + c.Ext(), c
+		}
+	}
+
+	return "", compression.None
+}
+
+// loadArtifactsVersions reads the metadata files of the packages
+// directory and maps the artifacts with a package tarball
+// for package name. The artifacts are sorted by version from the
+// newer to the older.
+func (w *WagonFactory) loadArtifactsVersions(opts *WagonFactoryOpts) error {
+	var regexRepo = regexp.MustCompile(`.metadata.yaml$`)
+
+	w.artifactsVersions = make(map[string][]*artifact.PackageArtifact, 0)
+
+	dirEntries, err := os.ReadDir(opts.PackagesDir)
+	if err != nil {
+		return fmt.Errorf("Error on read dir %s: %s",
+			opts.PackagesDir, err.Error())
+	}
+
+	for _, file := range dirEntries {
+		if file.IsDir() || !regexRepo.MatchString(file.Name()) {
+			continue
+		}
+
+		tarball, ctype := getPackageTarball(opts, file.Name())
+		if tarball == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(opts.PackagesDir, file.Name()))
+		if err != nil {
+			return fmt.Errorf("Error on read file %s: %s",
+				file.Name(), err.Error())
+		}
+
+		art, err := artifact.NewPackageArtifactFromYaml(data)
+		if err != nil {
+			return fmt.Errorf("Error on parse file %s: %s",
+				file.Name(), err.Error())
+		}
+		if art.GetPackage() == nil {
+			continue
+		}
+
+		art.CachePath = tarball
+		art.CompressionType = ctype
+
+		name := art.GetPackage().PackageName()
+		w.artifactsVersions[name] = append(w.artifactsVersions[name], art)
+	}
+
+	for name := range w.artifactsVersions {
+		arts := w.artifactsVersions[name]
+		sort.Slice(arts[:], func(i, j int) bool {
+			pi, _ := arts[i].GetPackage().ToGentooPackage()
+			pj, _ := arts[j].GetPackage().ToGentooPackage()
+			ans, _ := pi.GreaterThan(pj)
+			return ans
+		})
+	}
+
+	return nil
+}
+
+// createDeltas creates the delta tarballs of the artifact against
+// the previous versions available in the packages directory.
+func (w *WagonFactory) createDeltas(art *artifact.PackageArtifact,
+	metaFile string, opts *WagonFactoryOpts) error {
+
+	art.Deltas = []*artifact.DeltaArtifact{}
+
+	tarball, ctype := getPackageTarball(opts, metaFile)
+	if tarball == "" {
+		return nil
+	}
+	art.CachePath = tarball
+	art.CompressionType = ctype
+
+	gp, err := art.GetPackage().ToGentooPackage()
+	if err != nil {
+		return err
+	}
+
+	ndeltas := 0
+	for _, base := range w.artifactsVersions[art.GetPackage().PackageName()] {
+		if ndeltas >= opts.DeltaVersions {
+			break
+		}
+
+		gb, err := base.GetPackage().ToGentooPackage()
+		if err != nil {
+			return err
+		}
+		if older, _ := gb.LessThan(gp); !older {
+			continue
+		}
+
+		delta, err := art.CreateDelta(base, opts.PackagesDir)
+		if err != nil {
+			return fmt.Errorf("[%s] Error on create delta from %s: %s",
+				art.GetPackage().HumanReadableString(),
+				base.GetVersion(), err.Error())
+		}
+
+		Debug(fmt.Sprintf("[%s] Created delta %s (%d files reused).",
+			art.GetPackage().HumanReadableString(),
+			delta.Path, len(delta.Unchanged)))
+
+		art.Deltas = append(art.Deltas, delta)
+		ndeltas++
+	}
+
+	// The cache path is not needed on the metadata.
+	art.CachePath = ""
+
+	return nil
+}
@@ -6,6 +6,7 @@ package repository
 
 import (
 	"archive/tar"
+	"crypto/ed25519"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -20,9 +21,11 @@ import (
 	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
 	. "github.com/geaaru/luet/pkg/logger"
 	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/sbom"
 	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
 	"github.com/geaaru/luet/pkg/v2/compiler/types/compression"
 	wagon "github.com/geaaru/luet/pkg/v2/repository"
+	"github.com/geaaru/luet/pkg/v2/repository/keyring"
 	"github.com/geaaru/luet/pkg/v2/tree"
 
 	tarf "github.com/geaaru/tar-formers/pkg/executor"
@@ -57,6 +60,22 @@ type WagonFactoryOpts struct {
 	//	PushImage bool
 
 	TreeFilename string
+
+	// Path of the ed25519 private key used to sign
+	// the repository.yaml file.
+	SignKeyFile string
+
+	// Number of previous versions of a package used
+	// to create delta tarballs. Zero disables deltas.
+	DeltaVersions int
+
+	// Push the packages already present on the
+	// registry of an OCI repository.
+	ForcePush bool
+
+	// Format of the aggregate SBOM of the repository.
+	// Empty or none disables the SBOM.
+	SBOMFormat string
 }
 
 type WagonFactory struct {
@@ -64,7 +83,10 @@ type WagonFactory struct {
 	Repository *cfg.LuetRepository
 	Provides   *wagon.WagonProvides
 
-	mutex *sync.Mutex
+	mutex             *sync.Mutex
+	artifactsVersions map[string][]*artifact.PackageArtifact
+	ociPackages       []*ociPackage
+	sbomPackages      []*sbom.Package
 }
 
 func NewWagonFactoryOpts() *WagonFactoryOpts {
@@ -77,6 +99,10 @@ func NewWagonFactoryOpts() *WagonFactoryOpts {
 		WithCompilerTree:    false,
 		CompressionMode:     compression.Zstandard,
 		TreeFilename:        wagon.TREE_TARBALL,
+		SignKeyFile:         "",
+		DeltaVersions:       0,
+		ForcePush:           false,
+		SBOMFormat:          sbom.FormatNone,
 	}
 }
 
@@ -229,6 +255,19 @@ func (w *WagonFactory) createPackage(f string, idx *[]*tree.TreeIdx,
 
 	w.mutex.Unlock()
 
+	if opts.DeltaVersions > 0 {
+		err = w.createDeltas(art, f, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.isOciRepository() {
+		w.addOciPackage(art, f, opts)
+	}
+
+	w.addSBOMPackage(art)
+
 	metaJsonFile := filepath.Join(treePkgdir, "metadata.json")
 	err = art.WriteMetadataJson(metaJsonFile)
 	if err != nil {
@@ -268,6 +307,13 @@ func (w *WagonFactory) createTreeFs(idx *[]*tree.TreeIdx,
 			err.Error())
 	}
 
+	if opts.DeltaVersions > 0 {
+		err = w.loadArtifactsVersions(opts)
+		if err != nil {
+			return err
+		}
+	}
+
 	for _, file := range dirEntries {
 		if file.IsDir() {
 			continue
@@ -542,6 +588,9 @@ func (w *WagonFactory) BumpRevision(treePaths []string, opts *WagonFactoryOpts)
 
 	Debug("Using temporary tree path:", treefsDir)
 
+	w.ociPackages = []*ociPackage{}
+	w.sbomPackages = []*sbom.Package{}
+
 	// Create treefs filesystem
 	err = w.createTreeFs(&idx, opts, treefsDir)
 	if err != nil {
@@ -591,9 +640,42 @@ func (w *WagonFactory) BumpRevision(treePaths []string, opts *WagonFactoryOpts)
 		return err
 	}
 
+	if opts.SignKeyFile != "" {
+		// Create the detached signature repository.yaml.sig
+		priv, err := keyring.LoadPrivateKey(opts.SignKeyFile)
+		if err != nil {
+			return errors.Wrap(err, "Error on load sign key")
+		}
+
+		sigFile, err := keyring.SignFile(identifyFilePath, priv)
+		if err != nil {
+			return errors.Wrap(err, "Error on sign "+identifyFilePath)
+		}
+
+		InfoC(fmt.Sprintf(":lock:Repository: %s signed with key %s (%s).",
+			aurora.Bold(aurora.Green(wIdentity.GetName())).String(),
+			keyring.KeyId(priv.Public().(ed25519.PublicKey)),
+			filepath.Base(sigFile)))
+	} else {
+		// Drop a previous signature that will be not valid anymore.
+		sigFile := filepath.Join(opts.OutputDir, wagon.REPOSITORY_SIGFILE)
+		if fileHelper.Exists(sigFile) {
+			os.Remove(sigFile)
+		}
+	}
+
+	err = w.writeSBOM(opts)
+	if err != nil {
+		return err
+	}
+
 	if opts.LegacyMode {
 
 	}
 
+	if w.isOciRepository() {
+		return w.pushOci(opts, wIdentity)
+	}
+
 	return nil
 }
@@ -0,0 +1,127 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+
+	. "github.com/geaaru/luet/pkg/logger"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+	"github.com/geaaru/luet/pkg/v2/repository/oci"
+
+	"github.com/pkg/errors"
+)
+
+// ociPackage contains the blobs of a package pushed
+// as an OCI artifact with the tag of the package.
+type ociPackage struct {
+	Package string
+	Tag     string
+	Blobs   []*oci.Blob
+}
+
+func (w *WagonFactory) isOciRepository() bool {
+	return w.Repository.Type == wagon.OciRepositoryType
+}
+
+// addOciPackage registers the package tarball, the deltas and
+// the metadata file of the artifact to push.
+func (w *WagonFactory) addOciPackage(art *artifact.PackageArtifact,
+	metaFile string, opts *WagonFactoryOpts) {
+
+	tarball, ctype := getPackageTarball(opts, metaFile)
+	if tarball == "" {
+		Warning(fmt.Sprintf("[%s] Package tarball not found. Not pushed.",
+			art.GetPackage().HumanReadableString()))
+		return
+	}
+
+	p := &ociPackage{
+		Package: art.GetPackage().HumanReadableString(),
+		Tag:     art.GetPackage().ImageID(),
+		Blobs: []*oci.Blob{
+			oci.NewBlob(tarball, oci.TarballMediaType(oci.PackageMediaType, ctype)),
+			oci.NewBlob(filepath.Join(opts.PackagesDir, metaFile), oci.MetadataMediaType),
+		},
+	}
+
+	for _, d := range art.Deltas {
+		p.Blobs = append(p.Blobs, oci.NewBlob(
+			filepath.Join(opts.PackagesDir, d.Path),
+			oci.TarballMediaType(oci.DeltaMediaType, d.CompressionType)))
+	}
+
+	w.mutex.Lock()
+	w.ociPackages = append(w.ociPackages, p)
+	w.mutex.Unlock()
+}
+
+// pushOci pushes the packages and the repository files to the
+// registries defined as urls of the repository. The repository.yaml
+// is pushed as last file to publish the new revision only when
+// all files are available.
+func (w *WagonFactory) pushOci(opts *WagonFactoryOpts, identity *wagon.WagonIdentity) error {
+	if len(w.Repository.Urls) == 0 {
+		return errors.New("No registry urls defined for the repository")
+	}
+
+	ropts := oci.RemoteOptions(w.Repository.Authentication)
+
+	repoFiles := []string{}
+	for _, key := range []string{
+		wagon.REPOFILE_TREEV2_KEY, wagon.REPOFILE_COMPILER_TREE_KEY,
+	} {
+		if doc, ok := identity.RepositoryFiles[key]; ok {
+			repoFiles = append(repoFiles, doc.GetFileName())
+		}
+	}
+	if opts.SignKeyFile != "" {
+		repoFiles = append(repoFiles, wagon.REPOSITORY_SIGFILE)
+	}
+	repoFiles = append(repoFiles, wagon.REPOSITORY_SPECFILE)
+
+	for _, uri := range w.Repository.Urls {
+		InfoC(fmt.Sprintf(":rocket:Pushing %d packages to %s...",
+			len(w.ociPackages), uri))
+
+		for _, p := range w.ociPackages {
+			ref, err := oci.ParseReference(uri, p.Tag)
+			if err != nil {
+				return err
+			}
+
+			if !opts.ForcePush && oci.Exists(ref, ropts...) {
+				Debug(fmt.Sprintf("[%s] Already present on %s. Skipped.",
+					p.Package, ref.String()))
+				continue
+			}
+
+			err = oci.Push(ref, p.Blobs, ropts...)
+			if err != nil {
+				return errors.Wrapf(err, "Error on push package %s", p.Package)
+			}
+			Debug(fmt.Sprintf("[%s] Pushed to %s.", p.Package, ref.String()))
+		}
+
+		for _, f := range repoFiles {
+			ref, err := oci.ParseReference(uri, f)
+			if err != nil {
+				return err
+			}
+
+			err = oci.Push(ref, []*oci.Blob{
+				oci.NewBlob(filepath.Join(opts.OutputDir, f), oci.RepositoryFileMediaType),
+			}, ropts...)
+			if err != nil {
+				return errors.Wrapf(err, "Error on push file %s", f)
+			}
+			Debug(fmt.Sprintf("Pushed %s to %s.", f, ref.String()))
+		}
+	}
+
+	return nil
+}
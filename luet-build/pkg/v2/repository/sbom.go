@@ -0,0 +1,51 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+
+	. "github.com/geaaru/luet/pkg/logger"
+	"github.com/geaaru/luet/pkg/sbom"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+)
+
+const REPOSITORY_SBOM_PREFIX = "repository"
+
+func (w *WagonFactory) addSBOMPackage(art *artifact.PackageArtifact) {
+	sp := sbom.NewPackage(art.GetPackage(), art.Files)
+	sp.Sha256 = art.Checksums[string(artifact.SHA256)]
+	sp.Repository = w.Repository.Name
+
+	w.mutex.Lock()
+	w.sbomPackages = append(w.sbomPackages, sp)
+	w.mutex.Unlock()
+}
+
+// writeSBOM writes the aggregate SBOM of the packages of
+// the repository in the output directory.
+func (w *WagonFactory) writeSBOM(opts *WagonFactoryOpts) error {
+	if opts.SBOMFormat == "" || opts.SBOMFormat == sbom.FormatNone {
+		return nil
+	}
+
+	doc := sbom.NewDocument(w.Repository.Name)
+	for _, p := range w.sbomPackages {
+		doc.AddPackage(p)
+	}
+
+	sbomFile := filepath.Join(opts.OutputDir,
+		REPOSITORY_SBOM_PREFIX+sbom.FileSuffix(opts.SBOMFormat))
+	err := doc.WriteFile(sbomFile, opts.SBOMFormat)
+	if err != nil {
+		return fmt.Errorf("Error on write SBOM %s: %s", sbomFile, err.Error())
+	}
+
+	InfoC(fmt.Sprintf(":page_facing_up:Repository: %s SBOM %s with %d packages.",
+		w.Repository.Name, filepath.Base(sbomFile), len(w.sbomPackages)))
+
+	return nil
+}
@@ -0,0 +1,280 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package server
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cfg "github.com/geaaru/luet/pkg/config"
+	"github.com/geaaru/luet/pkg/v2/compiler/types/compression"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+	version "github.com/geaaru/luet/pkg/versioner"
+
+	zstd "github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+type RepositoryInfo struct {
+	Name       string `json:"name" yaml:"name"`
+	Prefix     string `json:"prefix" yaml:"prefix"`
+	Revision   int    `json:"revision" yaml:"revision"`
+	LastUpdate string `json:"last_update,omitempty" yaml:"last_update,omitempty"`
+	Packages   int    `json:"packages" yaml:"packages"`
+}
+
+type PackageVersions struct {
+	Category string   `json:"category" yaml:"category"`
+	Name     string   `json:"name" yaml:"name"`
+	Versions []string `json:"versions" yaml:"versions"`
+}
+
+// treeIndex maintains the list of the packages of the repository
+// tree. The tree tarball is parsed again only when the
+// repository.yaml file is updated.
+type treeIndex struct {
+	mutex    sync.Mutex
+	dir      string
+	mtime    time.Time
+	identity *wagon.WagonIdentity
+	packages []*PackageVersions
+}
+
+func newTreeIndex(dir string) *treeIndex {
+	return &treeIndex{dir: dir}
+}
+
+func (t *treeIndex) load() (*wagon.WagonIdentity, []*PackageVersions, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	specfile := filepath.Join(t.dir, wagon.REPOSITORY_SPECFILE)
+	st, err := os.Stat(specfile)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Repository file not found")
+	}
+
+	if t.identity != nil && st.ModTime().Equal(t.mtime) {
+		return t.identity, t.packages, nil
+	}
+
+	identity := wagon.NewWagonIdentify(&cfg.LuetRepository{})
+	err = identity.Load(specfile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := wagon.REPOFILE_TREEV2_KEY
+	if !identity.HasDocument(key) {
+		key = wagon.REPOFILE_TREE_KEY
+	}
+	doc, ok := identity.RepositoryFiles[key]
+	if !ok {
+		return nil, nil, errors.New("No tree available on repository")
+	}
+
+	packages, err := readTreeTarball(
+		filepath.Join(t.dir, doc.GetFileName()), doc.GetCompressionType())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t.identity = identity
+	t.packages = packages
+	t.mtime = st.ModTime()
+
+	return t.identity, t.packages, nil
+}
+
+// readTreeTarball returns the packages and the versions available
+// from the paths <category>/<name>/<version>/<file> of the tree.
+func readTreeTarball(file string, c compression.Implementation) ([]*PackageVersions, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot open "+file)
+	}
+	defer f.Close()
+
+	var r io.Reader
+	switch c {
+	case compression.Zstandard:
+		d, err := zstd.NewReader(bufio.NewReader(f))
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		r = d
+	case compression.GZip:
+		d, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		r = d
+	default:
+		r = bufio.NewReader(f)
+	}
+
+	pmap := make(map[string]*PackageVersions, 0)
+	vmap := make(map[string]bool, 0)
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Error on read tree tarball")
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		base := path.Base(h.Name)
+		if base != "metadata.json" && base != "definition.yaml" {
+			continue
+		}
+
+		fields := strings.Split(strings.TrimPrefix(path.Clean(h.Name), "./"), "/")
+		if len(fields) < 4 {
+			continue
+		}
+		cat := fields[len(fields)-4]
+		name := fields[len(fields)-3]
+		ver := fields[len(fields)-2]
+
+		pkey := cat + "/" + name
+		p, ok := pmap[pkey]
+		if !ok {
+			p = &PackageVersions{Category: cat, Name: name, Versions: []string{}}
+			pmap[pkey] = p
+		}
+		if _, present := vmap[pkey+"@"+ver]; !present {
+			vmap[pkey+"@"+ver] = true
+			p.Versions = append(p.Versions, ver)
+		}
+	}
+
+	v := version.DefaultVersioner()
+	ans := []*PackageVersions{}
+	for _, p := range pmap {
+		p.Versions = v.Sort(p.Versions)
+		ans = append(ans, p)
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		if ans[i].Category == ans[j].Category {
+			return ans[i].Name < ans[j].Name
+		}
+		return ans[i].Category < ans[j].Category
+	})
+
+	return ans, nil
+}
+
+func writeJson(resp http.ResponseWriter, code int, obj interface{}) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(code)
+	resp.Write(data)
+}
+
+func writeJsonError(resp http.ResponseWriter, code int, msg string) {
+	writeJson(resp, code, map[string]string{"error": msg})
+}
+
+// serveApi exposes:
+//
+//	GET /_api/v1/repositories
+//	GET /_api/v1/repositories/<name>
+//	GET /_api/v1/repositories/<name>/packages
+func (s *RepoServer) serveApi(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeJsonError(resp, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	fields := strings.Split(
+		strings.Trim(strings.TrimPrefix(req.URL.Path, ApiPrefix), "/"), "/")
+
+	if fields[0] != "repositories" || len(fields) > 3 {
+		writeJsonError(resp, http.StatusNotFound, "Resource not found")
+		return
+	}
+
+	if len(fields) == 1 {
+		ans := []*RepositoryInfo{}
+		for _, r := range s.sortedRepositories() {
+			info, _, err := r.Info()
+			if err != nil {
+				// The repository is not yet created.
+				info = &RepositoryInfo{Name: r.Name, Prefix: r.Prefix}
+			}
+			ans = append(ans, info)
+		}
+		writeJson(resp, http.StatusOK, ans)
+		return
+	}
+
+	repo := s.GetRepository(fields[1])
+	if repo == nil {
+		writeJsonError(resp, http.StatusNotFound,
+			fmt.Sprintf("Repository %s not found", fields[1]))
+		return
+	}
+
+	info, packages, err := repo.Info()
+	if err != nil {
+		writeJsonError(resp, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if len(fields) == 2 {
+		writeJson(resp, http.StatusOK, info)
+	} else if fields[2] == "packages" {
+		writeJson(resp, http.StatusOK, packages)
+	} else {
+		writeJsonError(resp, http.StatusNotFound, "Resource not found")
+	}
+}
+
+func (s *RepoServer) sortedRepositories() []*ServedRepository {
+	ans := make([]*ServedRepository, len(s.Repositories))
+	copy(ans, s.Repositories)
+	sort.Slice(ans, func(i, j int) bool { return ans[i].Name < ans[j].Name })
+	return ans
+}
+
+// Info returns the repository information and the packages
+// available in the repository tree.
+func (r *ServedRepository) Info() (*RepositoryInfo, []*PackageVersions, error) {
+	identity, packages, err := r.index.load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &RepositoryInfo{
+		Name:       r.Name,
+		Prefix:     r.Prefix,
+		Revision:   identity.GetRevision(),
+		LastUpdate: identity.GetLastUpdate(),
+		Packages:   len(packages),
+	}, packages, nil
+}
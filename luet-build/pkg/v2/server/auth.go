@@ -0,0 +1,66 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+func (s *RepoServer) authEnabled() bool {
+	return len(s.Opts.Tokens) > 0 || len(s.Opts.BasicAuth) > 0
+}
+
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// authorized checks the Authorization header sent by the HttpClient
+// of the repositories: "token <val>" or "Basic <val>". The header
+// "Bearer <val>" is accepted too.
+func (s *RepoServer) authorized(req *http.Request) bool {
+	if !s.authEnabled() {
+		return true
+	}
+
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		return false
+	}
+
+	if user, pass, ok := req.BasicAuth(); ok {
+		for _, cred := range s.Opts.BasicAuth {
+			if secureCompare(cred, user+":"+pass) {
+				return true
+			}
+		}
+		return false
+	}
+
+	fields := strings.SplitN(header, " ", 2)
+	if len(fields) != 2 {
+		return false
+	}
+	scheme := strings.ToLower(fields[0])
+	if scheme != "token" && scheme != "bearer" {
+		return false
+	}
+
+	for _, t := range s.Opts.Tokens {
+		if secureCompare(t, strings.TrimSpace(fields[1])) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *RepoServer) unauthorized(resp http.ResponseWriter) {
+	if len(s.Opts.BasicAuth) > 0 {
+		resp.Header().Set("WWW-Authenticate", `Basic realm="luet"`)
+	}
+	http.Error(resp, "Unauthorized", http.StatusUnauthorized)
+}
@@ -0,0 +1,126 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type downloadKey struct {
+	Repo string
+	File string
+}
+
+type requestKey struct {
+	Repo string
+	Code int
+}
+
+// Metrics collects the counters of the served files exposed
+// in the Prometheus text format.
+type Metrics struct {
+	mutex     sync.Mutex
+	downloads map[downloadKey]int64
+	bytes     map[string]int64
+	requests  map[requestKey]int64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		downloads: make(map[downloadKey]int64, 0),
+		bytes:     make(map[string]int64, 0),
+		requests:  make(map[requestKey]int64, 0),
+	}
+}
+
+// AddRequest registers a request of a repository. The file is
+// counted as download only on success responses.
+func (m *Metrics) AddRequest(repo, file string, code int, nbytes int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.requests[requestKey{Repo: repo, Code: code}]++
+	m.bytes[repo] += nbytes
+	if file != "" && (code == http.StatusOK || code == http.StatusPartialContent) {
+		m.downloads[downloadKey{Repo: repo, File: file}]++
+	}
+}
+
+func (m *Metrics) GetDownloads(repo, file string) int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.downloads[downloadKey{Repo: repo, File: file}]
+}
+
+func escapeLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `"`, `\"`)
+	return strings.ReplaceAll(v, "\n", `\n`)
+}
+
+func (m *Metrics) String() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var b strings.Builder
+
+	b.WriteString("# HELP luet_repo_downloads_total Number of downloads of the repository files.\n")
+	b.WriteString("# TYPE luet_repo_downloads_total counter\n")
+	dkeys := []downloadKey{}
+	for k := range m.downloads {
+		dkeys = append(dkeys, k)
+	}
+	sort.Slice(dkeys, func(i, j int) bool {
+		if dkeys[i].Repo == dkeys[j].Repo {
+			return dkeys[i].File < dkeys[j].File
+		}
+		return dkeys[i].Repo < dkeys[j].Repo
+	})
+	for _, k := range dkeys {
+		b.WriteString(fmt.Sprintf("luet_repo_downloads_total{repository=\"%s\",file=\"%s\"} %d\n",
+			escapeLabel(k.Repo), escapeLabel(k.File), m.downloads[k]))
+	}
+
+	b.WriteString("# HELP luet_repo_sent_bytes_total Number of bytes sent for repository.\n")
+	b.WriteString("# TYPE luet_repo_sent_bytes_total counter\n")
+	repos := []string{}
+	for r := range m.bytes {
+		repos = append(repos, r)
+	}
+	sort.Strings(repos)
+	for _, r := range repos {
+		b.WriteString(fmt.Sprintf("luet_repo_sent_bytes_total{repository=\"%s\"} %d\n",
+			escapeLabel(r), m.bytes[r]))
+	}
+
+	b.WriteString("# HELP luet_repo_requests_total Number of requests for repository and status code.\n")
+	b.WriteString("# TYPE luet_repo_requests_total counter\n")
+	rkeys := []requestKey{}
+	for k := range m.requests {
+		rkeys = append(rkeys, k)
+	}
+	sort.Slice(rkeys, func(i, j int) bool {
+		if rkeys[i].Repo == rkeys[j].Repo {
+			return rkeys[i].Code < rkeys[j].Code
+		}
+		return rkeys[i].Repo < rkeys[j].Repo
+	})
+	for _, k := range rkeys {
+		b.WriteString(fmt.Sprintf("luet_repo_requests_total{repository=\"%s\",code=\"%s\"} %d\n",
+			escapeLabel(k.Repo), strconv.Itoa(k.Code), m.requests[k]))
+	}
+
+	return b.String()
+}
+
+func (m *Metrics) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	resp.Write([]byte(m.String()))
+}
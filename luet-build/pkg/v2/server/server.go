@@ -0,0 +1,222 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "github.com/geaaru/luet/pkg/logger"
+)
+
+const (
+	ApiPrefix     = "/_api/v1/"
+	MetricsPath   = "/metrics"
+	DefaultPrefix = "/"
+)
+
+type RepoServerOpts struct {
+	// Tokens accepted with the header Authorization: token <val>
+	// or Authorization: Bearer <val>.
+	Tokens []string
+	// Basic auth credentials in the format user:password.
+	BasicAuth []string
+	// Expose metrics without authentication.
+	MetricsNoAuth bool
+}
+
+// ServedRepository is a repository directory served under a path prefix.
+type ServedRepository struct {
+	Name   string `json:"name" yaml:"name"`
+	Prefix string `json:"prefix" yaml:"prefix"`
+	Dir    string `json:"-" yaml:"-"`
+
+	fileServer http.Handler
+	index      *treeIndex
+}
+
+type RepoServer struct {
+	Opts         *RepoServerOpts
+	Repositories []*ServedRepository
+	Metrics      *Metrics
+}
+
+func NewRepoServer(opts *RepoServerOpts) *RepoServer {
+	if opts == nil {
+		opts = &RepoServerOpts{}
+	}
+	return &RepoServer{
+		Opts:         opts,
+		Repositories: []*ServedRepository{},
+		Metrics:      NewMetrics(),
+	}
+}
+
+// AddRepository registers the directory dir under the path prefix.
+func (s *RepoServer) AddRepository(name, prefix, dir string) error {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	prefix = path.Clean("/" + prefix)
+	if prefix != "/" {
+		prefix += "/"
+	}
+
+	if strings.HasPrefix(prefix, ApiPrefix) || prefix == MetricsPath+"/" {
+		return fmt.Errorf("Prefix %s is reserved", prefix)
+	}
+
+	st, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("Invalid directory %s: %s", dir, err.Error())
+	}
+	if !st.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	if name == "" {
+		name = strings.Trim(prefix, "/")
+		if name == "" {
+			name = "default"
+		}
+	}
+
+	for _, r := range s.Repositories {
+		if r.Prefix == prefix {
+			return fmt.Errorf("Prefix %s already used by %s", prefix, r.Name)
+		}
+		if r.Name == name {
+			return fmt.Errorf("Repository %s already present", name)
+		}
+	}
+
+	s.Repositories = append(s.Repositories, &ServedRepository{
+		Name:       name,
+		Prefix:     prefix,
+		Dir:        dir,
+		fileServer: http.FileServer(http.Dir(dir)),
+		index:      newTreeIndex(dir),
+	})
+
+	// Longest prefix first.
+	sort.Slice(s.Repositories, func(i, j int) bool {
+		return len(s.Repositories[i].Prefix) > len(s.Repositories[j].Prefix)
+	})
+
+	return nil
+}
+
+func (s *RepoServer) GetRepository(name string) *ServedRepository {
+	for _, r := range s.Repositories {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *RepoServer) matchRepository(p string) *ServedRepository {
+	for _, r := range s.Repositories {
+		if r.Prefix == "/" || strings.HasPrefix(p, r.Prefix) || p+"/" == r.Prefix {
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *RepoServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	Debug("Processing ", req.Method, req.URL)
+
+	if req.URL.Path == MetricsPath {
+		if !s.Opts.MetricsNoAuth && !s.authorized(req) {
+			s.unauthorized(resp)
+			return
+		}
+		s.Metrics.ServeHTTP(resp, req)
+		return
+	}
+
+	if !s.authorized(req) {
+		s.unauthorized(resp)
+		return
+	}
+
+	if strings.HasPrefix(req.URL.Path, ApiPrefix) {
+		s.serveApi(resp, req)
+		return
+	}
+
+	repo := s.matchRepository(req.URL.Path)
+	if repo == nil {
+		http.NotFound(resp, req)
+		return
+	}
+
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		resp.Header().Set("Allow", "GET, HEAD")
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.serveFile(repo, resp, req)
+}
+
+func (s *RepoServer) serveFile(repo *ServedRepository, resp http.ResponseWriter, req *http.Request) {
+	rpath := strings.TrimPrefix(req.URL.Path, strings.TrimSuffix(repo.Prefix, "/"))
+	if rpath == "" {
+		// Permit the redirect of the directory to the path with
+		// the final slash.
+		http.Redirect(resp, req, repo.Prefix, http.StatusMovedPermanently)
+		return
+	}
+	rpath = path.Clean("/" + rpath)
+
+	file := filepath.Join(repo.Dir, filepath.FromSlash(rpath))
+	st, err := os.Stat(file)
+	if err == nil && !st.IsDir() {
+		// http.ServeContent used by the FileServer handles
+		// the If-None-Match and If-Range headers only when
+		// the ETag is set.
+		resp.Header().Set("ETag", etag(st))
+	}
+
+	r2 := req.Clone(req.Context())
+	r2.URL.Path = rpath
+
+	w := &responseRecorder{ResponseWriter: resp, status: http.StatusOK}
+	repo.fileServer.ServeHTTP(w, r2)
+
+	if err == nil && !st.IsDir() {
+		s.Metrics.AddRequest(repo.Name, path.Base(rpath), w.status, w.bytes)
+	} else {
+		s.Metrics.AddRequest(repo.Name, "", w.status, w.bytes)
+	}
+}
+
+func etag(st os.FileInfo) string {
+	return fmt.Sprintf("\"%x-%x\"", st.ModTime().UnixNano(), st.Size())
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
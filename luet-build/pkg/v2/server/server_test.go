@@ -0,0 +1,200 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package server_test
+
+import (
+	"archive/tar"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/geaaru/luet/luet-build/pkg/v2/server"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const repoSpec = `name: test
+revision: 3
+last_update: "1700000000"
+repo_files:
+  treev2:
+    filename: tree.tar
+    compressiontype: none
+`
+
+func createRepo(dir string) {
+	err := os.WriteFile(filepath.Join(dir, "repository.yaml"), []byte(repoSpec), 0644)
+	Expect(err).ToNot(HaveOccurred())
+	err = os.WriteFile(filepath.Join(dir, "a-test-1.0.package.tar.zst"),
+		[]byte("0123456789"), 0644)
+	Expect(err).ToNot(HaveOccurred())
+
+	f, err := os.Create(filepath.Join(dir, "tree.tar"))
+	Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, name := range []string{
+		"test/a/1.0/metadata.json",
+		"test/a/1.10/metadata.json",
+		"test/a/1.2/metadata.json",
+		"test/b/0.1/metadata.json",
+	} {
+		err = tw.WriteHeader(&tar.Header{
+			Name: name, Mode: 0644, Size: 2, Typeflag: tar.TypeReg,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = tw.Write([]byte("{}"))
+		Expect(err).ToNot(HaveOccurred())
+	}
+	Expect(tw.Close()).ToNot(HaveOccurred())
+}
+
+func doRequest(s http.Handler, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+var _ = Describe("Server", func() {
+
+	var tmpdir string
+	var s *RepoServer
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "server")
+		Expect(err).ToNot(HaveOccurred())
+		createRepo(tmpdir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	Context("Files", func() {
+
+		BeforeEach(func() {
+			s = NewRepoServer(nil)
+			Expect(s.AddRepository("", "stage3", tmpdir)).ToNot(HaveOccurred())
+		})
+
+		It("Serves files under the prefix", func() {
+			w := doRequest(s, "/stage3/a-test-1.0.package.tar.zst", nil)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(Equal("0123456789"))
+			Expect(w.Header().Get("ETag")).ToNot(BeEmpty())
+
+			w = doRequest(s, "/a-test-1.0.package.tar.zst", nil)
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("Handles Range and ETag", func() {
+			w := doRequest(s, "/stage3/a-test-1.0.package.tar.zst",
+				map[string]string{"Range": "bytes=2-5"})
+			Expect(w.Code).To(Equal(http.StatusPartialContent))
+			Expect(w.Body.String()).To(Equal("2345"))
+
+			etag := w.Header().Get("ETag")
+			w = doRequest(s, "/stage3/a-test-1.0.package.tar.zst",
+				map[string]string{"If-None-Match": etag})
+			Expect(w.Code).To(Equal(http.StatusNotModified))
+
+			Expect(s.Metrics.GetDownloads("stage3", "a-test-1.0.package.tar.zst")).To(Equal(int64(1)))
+		})
+
+		It("Rejects a duplicate prefix", func() {
+			Expect(s.AddRepository("other", "/stage3/", tmpdir)).To(HaveOccurred())
+		})
+
+		It("Exposes metrics", func() {
+			doRequest(s, "/stage3/a-test-1.0.package.tar.zst", nil)
+			w := doRequest(s, "/metrics", nil)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring(
+				`luet_repo_downloads_total{repository="stage3",file="a-test-1.0.package.tar.zst"} 1`))
+		})
+	})
+
+	Context("Authentication", func() {
+
+		BeforeEach(func() {
+			s = NewRepoServer(&RepoServerOpts{
+				Tokens:    []string{"secret"},
+				BasicAuth: []string{"user:pass"},
+			})
+			Expect(s.AddRepository("", "/", tmpdir)).ToNot(HaveOccurred())
+		})
+
+		It("Rejects requests without credentials", func() {
+			w := doRequest(s, "/repository.yaml", nil)
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+			w = doRequest(s, "/repository.yaml",
+				map[string]string{"Authorization": "token wrong"})
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("Accepts the headers of the http client", func() {
+			w := doRequest(s, "/repository.yaml",
+				map[string]string{"Authorization": "token secret"})
+			Expect(w.Code).To(Equal(http.StatusOK))
+			w = doRequest(s, "/repository.yaml",
+				map[string]string{"Authorization": "Bearer secret"})
+			Expect(w.Code).To(Equal(http.StatusOK))
+			w = doRequest(s, "/repository.yaml",
+				map[string]string{
+					"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass")),
+				})
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("API", func() {
+
+		BeforeEach(func() {
+			s = NewRepoServer(nil)
+			Expect(s.AddRepository("", "dev", tmpdir)).ToNot(HaveOccurred())
+		})
+
+		It("Lists repositories", func() {
+			w := doRequest(s, "/_api/v1/repositories", nil)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			ans := []*RepositoryInfo{}
+			Expect(json.Unmarshal(w.Body.Bytes(), &ans)).ToNot(HaveOccurred())
+			Expect(len(ans)).To(Equal(1))
+			Expect(ans[0].Name).To(Equal("dev"))
+			Expect(ans[0].Prefix).To(Equal("/dev/"))
+			Expect(ans[0].Revision).To(Equal(3))
+			Expect(ans[0].Packages).To(Equal(2))
+		})
+
+		It("Lists packages and versions", func() {
+			w := doRequest(s, "/_api/v1/repositories/dev/packages", nil)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			ans := []*PackageVersions{}
+			data, _ := io.ReadAll(w.Body)
+			Expect(json.Unmarshal(data, &ans)).ToNot(HaveOccurred())
+			Expect(len(ans)).To(Equal(2))
+			Expect(ans[0].Name).To(Equal("a"))
+			Expect(strings.Join(ans[0].Versions, " ")).To(Equal("1.0 1.2 1.10"))
+			Expect(ans[1].Name).To(Equal("b"))
+		})
+
+		It("Returns not found for unknown repositories", func() {
+			w := doRequest(s, "/_api/v1/repositories/foo/packages", nil)
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -41,6 +41,8 @@ type DefaultBox struct {
 	Args                  []string
 	HostMounts            []string
 	Stdin, Stdout, Stderr bool
+	// Share the network namespace of the host.
+	HostNetwork bool
 }
 
 func NewBox(cmd string, args, hostmounts, env []string, rootfs string, stdin, stdout, stderr bool) Box {
@@ -155,13 +157,17 @@ func (b *DefaultBox) Run() error {
 	if b.Stdout {
 		cmd.Stdout = os.Stdout
 	}
+	cloneFlags := syscall.CLONE_NEWNS |
+		syscall.CLONE_NEWUTS |
+		syscall.CLONE_NEWIPC |
+		syscall.CLONE_NEWPID |
+		syscall.CLONE_NEWUSER
+	if !b.HostNetwork {
+		cloneFlags |= syscall.CLONE_NEWNET
+	}
+
 	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Cloneflags: syscall.CLONE_NEWNS |
-			syscall.CLONE_NEWUTS |
-			syscall.CLONE_NEWIPC |
-			syscall.CLONE_NEWPID |
-			syscall.CLONE_NEWNET |
-			syscall.CLONE_NEWUSER,
+		Cloneflags: uintptr(cloneFlags),
 		UidMappings: []syscall.SysProcIDMap{
 			{
 				ContainerID: 0,
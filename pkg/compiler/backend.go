@@ -28,6 +28,12 @@ func NewBackend(s string) (CompilerBackend, error) {
 		compilerBackend = backend.NewDockerv2Backend()
 	case backend.Dockerv3Backend:
 		compilerBackend = backend.NewDockerv3Backend()
+	case backend.NativeBackend:
+		compilerBackend = backend.NewNativeBackend()
+	case backend.PodmanBackend:
+		compilerBackend = backend.NewPodmanBackend()
+	case backend.BuildahBackend:
+		compilerBackend = backend.NewBuildahBackend()
 	default:
 		return nil, errors.New("invalid backend. Unsupported")
 	}
@@ -51,6 +57,13 @@ type CompilerBackend interface {
 	ImageExists(string) bool
 }
 
+// ImageLoader is implemented by the backends that could load the
+// images of an archive created by ExportImage. It's used to restore
+// the images from the build cache.
+type ImageLoader interface {
+	LoadImage(path string) error
+}
+
 // GenerateChanges generates changes between two images using a backend by leveraging export/extractrootfs methods
 // example of json return: [
 //
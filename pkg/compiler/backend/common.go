@@ -30,6 +30,9 @@ const (
 	DockerBackend   = "docker"
 	Dockerv2Backend = "dockerv2"
 	Dockerv3Backend = "dockerv3"
+	NativeBackend   = "native"
+	PodmanBackend   = "podman"
+	BuildahBackend  = "buildah"
 )
 
 func imageAvailable(image string) bool {
@@ -0,0 +1,895 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package backend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/geaaru/luet/pkg/box"
+	"github.com/geaaru/luet/pkg/config"
+	"github.com/geaaru/luet/pkg/helpers"
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	. "github.com/geaaru/luet/pkg/logger"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	tarf "github.com/geaaru/tar-formers/pkg/executor"
+	tarf_specs "github.com/geaaru/tar-formers/pkg/specs"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Directory under the database path where are stored
+	// the images of the native backend.
+	NativeImagesDir = "native-images"
+
+	nativeImageMeta   = "image.json"
+	nativeImageRootfs = "rootfs"
+	nativeDefaultPath = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+// Native is a daemonless backend. The images are stored as rootfs
+// directories and the RUN steps of the Dockerfiles are executed inside
+// the user and mount namespaces of pkg/box. The box re-executes the
+// current binary with the hidden command exec.
+type Native struct {
+	// Directory where are stored the images. If empty it's used
+	// the directory native-images under the database path.
+	ImagesDir string
+	// Share the network of the host with the RUN steps.
+	HostNetwork bool
+}
+
+// nativeImage contains the configuration of the image
+// used by the RUN steps.
+type nativeImage struct {
+	Name       string   `json:"name"`
+	Env        []string `json:"env,omitempty"`
+	WorkingDir string   `json:"workdir,omitempty"`
+}
+
+func NewNativeBackend() *Native {
+	return &Native{
+		HostNetwork: true,
+	}
+}
+
+func (n *Native) getImagesDir() string {
+	if n.ImagesDir == "" {
+		n.ImagesDir = filepath.Join(config.LuetCfg.GetSystem().Rootfs,
+			config.LuetCfg.GetSystem().DatabasePath, NativeImagesDir)
+	}
+	return n.ImagesDir
+}
+
+// imageDir returns the directory of the image. The name is normalized
+// to use the same directory for test/pkg and index.docker.io/test/pkg:latest.
+func (n *Native) imageDir(image string) string {
+	key := image
+	if ref, err := name.ParseReference(image); err == nil {
+		key = ref.Name()
+	}
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(n.getImagesDir(), hex.EncodeToString(h[:]))
+}
+
+func (n *Native) imageRootfs(image string) string {
+	return filepath.Join(n.imageDir(image), nativeImageRootfs)
+}
+
+func (n *Native) loadImage(image string) (*nativeImage, error) {
+	data, err := os.ReadFile(filepath.Join(n.imageDir(image), nativeImageMeta))
+	if err != nil {
+		return nil, errors.Wrap(err, "Image "+image+" not available")
+	}
+
+	ans := &nativeImage{}
+	err = json.Unmarshal(data, ans)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid configuration of the image "+image)
+	}
+	return ans, nil
+}
+
+// prepareStaging returns a new directory where the image
+// is created before commit it.
+func (n *Native) prepareStaging(image, suffix string) (string, error) {
+	staging := n.imageDir(image) + suffix
+	err := os.RemoveAll(staging)
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(filepath.Join(staging, nativeImageRootfs), 0755)
+	if err != nil {
+		return "", errors.Wrap(err, "Error on create image directory")
+	}
+	return staging, nil
+}
+
+// commitImage writes the configuration of the image and it
+// replaces the previous image with the staging directory.
+func (n *Native) commitImage(staging string, img *nativeImage) error {
+	data, err := json.Marshal(img)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filepath.Join(staging, nativeImageMeta), data, 0644)
+	if err != nil {
+		return err
+	}
+
+	dir := n.imageDir(img.Name)
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return errors.Wrap(err, "Error on remove previous image "+img.Name)
+	}
+
+	return os.Rename(staging, dir)
+}
+
+func (n *Native) ensureImage(image string) error {
+	if n.ImageExists(image) {
+		return nil
+	}
+	return n.DownloadImage(Options{ImageName: image})
+}
+
+func (n *Native) createTarFormers() *tarf.TarFormers {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	cfg := tarf_specs.NewConfig(config.LuetCfg.Viper)
+	cfg.GetGeneral().Debug = config.LuetCfg.GetGeneral().Debug
+	cfg.GetLogging().Level = config.LuetCfg.GetLogging().Level
+
+	return tarf.NewTarFormers(cfg)
+}
+
+func (n *Native) DownloadImage(opts Options) error {
+	image := opts.ImageName
+
+	Debug(":seedling: Downloading image " + image)
+
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return errors.Wrap(err, "Invalid image "+image)
+	}
+
+	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return errors.Wrap(err, "Failed downloading image "+image)
+	}
+
+	Spinner(22)
+	defer SpinnerStop()
+
+	err = n.importImage(image, img)
+	if err != nil {
+		return err
+	}
+
+	Info(":seedling: Image " + image + " downloaded")
+	return nil
+}
+
+// importImage unpacks the image in the store of the backend.
+func (n *Native) importImage(image string, img v1.Image) error {
+	cfgFile, err := img.ConfigFile()
+	if err != nil {
+		return errors.Wrap(err, "Failed reading config of the image "+image)
+	}
+
+	staging, err := n.prepareStaging(image, ".pull")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	// The layers are flattened with the whiteouts already applied.
+	reader := mutate.Extract(img)
+	defer reader.Close()
+
+	spec := tarf_specs.NewSpecFile()
+	spec.SameOwner = os.Geteuid() == 0
+	spec.EnableMutex = true
+	spec.OverwritePerms = true
+	spec.IgnoreRegexes = []string{}
+	spec.IgnoreFiles = []string{}
+
+	tarformers := n.createTarFormers()
+	tarformers.SetReader(reader)
+
+	err = tarformers.RunTask(spec, filepath.Join(staging, nativeImageRootfs))
+	if err != nil {
+		return errors.Wrap(err, "Failed extracting image "+image)
+	}
+
+	return n.commitImage(staging, &nativeImage{
+		Name:       image,
+		Env:        cfgFile.Config.Env,
+		WorkingDir: cfgFile.Config.WorkingDir,
+	})
+}
+
+// LoadImage imports the images of an archive in the docker-archive
+// format, like the archives created by ExportImage.
+func (n *Native) LoadImage(path string) error {
+	manifest, err := tarball.LoadManifest(func() (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+	if err != nil {
+		return errors.Wrap(err, "Invalid image archive "+path)
+	}
+
+	for _, m := range manifest {
+		for _, t := range m.RepoTags {
+			tag, err := name.NewTag(t)
+			if err != nil {
+				return errors.Wrap(err, "Invalid image "+t)
+			}
+
+			img, err := tarball.ImageFromPath(path, &tag)
+			if err != nil {
+				return errors.Wrap(err, "Failed reading image "+t)
+			}
+
+			err = n.importImage(t, img)
+			if err != nil {
+				return err
+			}
+			Info(":seedling: Image " + t + " loaded")
+		}
+	}
+
+	return nil
+}
+
+func (n *Native) BuildImage(opts Options) error {
+	image := opts.ImageName
+
+	dockerfile := opts.DockerFileName
+	if !filepath.IsAbs(dockerfile) {
+		dockerfile = filepath.Join(opts.SourcePath, dockerfile)
+	}
+	contextDir := opts.Context
+	if contextDir == "" {
+		contextDir = "."
+	}
+	if !filepath.IsAbs(contextDir) {
+		contextDir = filepath.Join(opts.SourcePath, contextDir)
+	}
+
+	f, err := os.Open(dockerfile)
+	if err != nil {
+		return errors.Wrap(err, "Failed reading Dockerfile")
+	}
+	instructions, err := ParseDockerfile(f)
+	f.Close()
+	if err != nil {
+		return errors.Wrap(err, "Failed parsing "+dockerfile)
+	}
+
+	Info(":seedling: Building image " + image)
+
+	staging, err := n.prepareStaging(image, ".build")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	b := &nativeBuild{
+		backend:    n,
+		rootfs:     filepath.Join(staging, nativeImageRootfs),
+		contextDir: contextDir,
+		img:        &nativeImage{Name: image},
+	}
+
+	for idx, i := range instructions {
+		if i.Command == "FROM" && idx > 0 {
+			return errors.New("Multi-stage builds are not supported by the native backend")
+		}
+		Debug(fmt.Sprintf(":seedling: [%s] %s %s", image, i.Command, i.Raw))
+		err = b.apply(i)
+		if err != nil {
+			return errors.Wrapf(err, "Failed building image %s on step %s %s",
+				image, i.Command, i.Raw)
+		}
+	}
+
+	err = n.commitImage(staging, b.img)
+	if err != nil {
+		return err
+	}
+
+	Info(":seedling: Building image " + image + " done")
+	return nil
+}
+
+// nativeBuild maintains the state of the image in build.
+type nativeBuild struct {
+	backend    *Native
+	rootfs     string
+	contextDir string
+	img        *nativeImage
+}
+
+func (b *nativeBuild) workdir() string {
+	if b.img.WorkingDir == "" {
+		return "/"
+	}
+	return b.img.WorkingDir
+}
+
+func (b *nativeBuild) resolve(p string) string {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(b.workdir(), p)
+	}
+	return p
+}
+
+func (b *nativeBuild) apply(i *DockerfileInstruction) error {
+	switch i.Command {
+	case "FROM":
+		base := i.Args[0]
+		if base == "scratch" {
+			return nil
+		}
+		if err := b.backend.ensureImage(base); err != nil {
+			return err
+		}
+		baseImg, err := b.backend.loadImage(base)
+		if err != nil {
+			return err
+		}
+		b.img.Env = append([]string{}, baseImg.Env...)
+		b.img.WorkingDir = baseImg.WorkingDir
+		return copyTree(b.backend.imageRootfs(base), b.rootfs, "/", true)
+
+	case "ENV":
+		// The variables are expanded with the values available
+		// before the instruction.
+		env := b.envMap()
+		for _, kv := range i.Args {
+			b.setEnv(expandEnv(kv, env))
+		}
+
+	case "WORKDIR":
+		b.img.WorkingDir = b.resolve(i.Args[0])
+		dir, err := securejoin.SecureJoin(b.rootfs, b.img.WorkingDir)
+		if err != nil {
+			return err
+		}
+		return os.MkdirAll(dir, 0755)
+
+	case "COPY", "ADD":
+		return b.copy(i)
+
+	case "RUN":
+		return b.run(i)
+	}
+
+	return nil
+}
+
+func (b *nativeBuild) envMap() map[string]string {
+	ans := make(map[string]string, len(b.img.Env))
+	for _, e := range b.img.Env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) == 2 {
+			ans[kv[0]] = kv[1]
+		}
+	}
+	return ans
+}
+
+// expandEnv replaces $VAR and ${VAR} in the value of the
+// variable. The undefined variables are replaced with an
+// empty string.
+func expandEnv(kv string, env map[string]string) string {
+	v := strings.SplitN(kv, "=", 2)
+	if len(v) != 2 {
+		return kv
+	}
+	return v[0] + "=" + os.Expand(v[1], func(k string) string {
+		return env[k]
+	})
+}
+
+func (b *nativeBuild) setEnv(kv string) {
+	key := strings.SplitN(kv, "=", 2)[0]
+	for idx, e := range b.img.Env {
+		if strings.HasPrefix(e, key+"=") {
+			b.img.Env[idx] = kv
+			return
+		}
+	}
+	b.img.Env = append(b.img.Env, kv)
+}
+
+func (b *nativeBuild) copy(i *DockerfileInstruction) error {
+	srcRoot := b.contextDir
+	if from, ok := i.Flags["from"]; ok {
+		if err := b.backend.ensureImage(from); err != nil {
+			return err
+		}
+		srcRoot = b.backend.imageRootfs(from)
+	}
+
+	sources := i.Args[:len(i.Args)-1]
+	dest := i.Args[len(i.Args)-1]
+	toDir := strings.HasSuffix(dest, "/") || len(sources) > 1
+	dest = b.resolve(dest)
+
+	if !toDir {
+		if target, err := securejoin.SecureJoin(b.rootfs, dest); err == nil {
+			if isDir, _ := fileHelper.IsDirectory(target); isDir {
+				toDir = true
+			}
+		}
+	}
+
+	for _, s := range sources {
+		if i.Command == "ADD" && (strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")) {
+			if err := b.download(s, dest, toDir); err != nil {
+				return err
+			}
+			continue
+		}
+
+		src, err := securejoin.SecureJoin(srcRoot, s)
+		if err != nil {
+			return err
+		}
+		matches, err := filepath.Glob(src)
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("%s not found", s)
+		}
+
+		for _, m := range matches {
+			st, err := os.Lstat(m)
+			if err != nil {
+				return err
+			}
+
+			target := dest
+			if !st.IsDir() && toDir {
+				target = filepath.Join(dest, filepath.Base(m))
+			}
+
+			// The owner of the files of the build context is not preserved.
+			err = copyTree(m, b.rootfs, target, i.Flags["from"] != "")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *nativeBuild) download(url, dest string, toDir bool) error {
+	if toDir {
+		dest = filepath.Join(dest, filepath.Base(url))
+	}
+	target, err := securejoin.SecureJoin(b.rootfs, dest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed downloading %s: %s", url, resp.Status)
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, resp.Body)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (b *nativeBuild) run(i *DockerfileInstruction) error {
+	env := append([]string{}, b.img.Env...)
+	hasPath := false
+	for _, e := range env {
+		if strings.HasPrefix(e, "PATH=") {
+			hasPath = true
+			break
+		}
+	}
+	if !hasPath {
+		env = append(env, nativeDefaultPath)
+	}
+
+	// The box doesn't support the working directory.
+	args := append([]string{
+		"-c", `cd "$1" && shift && exec "$@"`, "sh", b.workdir(),
+	}, i.Args...)
+
+	bx := &box.DefaultBox{
+		Cmd:         "/bin/sh",
+		Args:        args,
+		Root:        b.rootfs,
+		Env:         env,
+		HostMounts:  []string{},
+		Stdout:      config.LuetCfg.GetGeneral().ShowBuildOutput,
+		Stderr:      true,
+		HostNetwork: b.backend.HostNetwork,
+	}
+
+	return bx.Run()
+}
+
+// copyTree copies src to the path dst of the root directory.
+// The symlinks of the destination are resolved inside the root.
+// The directory src is merged with the existing directory.
+// The regular files are cloned when the filesystem supports
+// the copy-on-write (btrfs, xfs) and so the rootfs of the base
+// image isn't duplicated on the builds.
+func copyTree(src, root, dst string, keepOwner bool) error {
+	keepOwner = keepOwner && os.Geteuid() == 0
+
+	type dirTimes struct {
+		path string
+		info os.FileInfo
+	}
+	dirs := []dirTimes{}
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target, err := securejoin.SecureJoin(root, filepath.Join(dst, rel))
+		if err != nil {
+			return err
+		}
+
+		mode := info.Mode()
+		switch {
+		case mode.IsDir():
+			if st, err := os.Lstat(target); err == nil && !st.IsDir() {
+				os.Remove(target)
+			}
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			// Permissions are applied at the end to permit
+			// the copy of read-only directories.
+			dirs = append(dirs, dirTimes{path: target, info: info})
+			return nil
+
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			os.RemoveAll(target)
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+
+		case mode.IsRegular():
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			os.RemoveAll(target)
+			if err := copyRegularFile(path, target, mode); err != nil {
+				return err
+			}
+			if err := os.Chtimes(target, info.ModTime(), info.ModTime()); err != nil {
+				return err
+			}
+
+		default:
+			// Devices, fifos and sockets are created only by root.
+			if os.Geteuid() != 0 {
+				Debug("Skipping special file", path)
+				return nil
+			}
+			st, ok := info.Sys().(*syscall.Stat_t)
+			if !ok {
+				return nil
+			}
+			os.RemoveAll(target)
+			if err := syscall.Mknod(target, st.Mode, int(st.Rdev)); err != nil {
+				return errors.Wrap(err, "Failed creating special file "+target)
+			}
+		}
+
+		if keepOwner {
+			if st, ok := info.Sys().(*syscall.Stat_t); ok {
+				if err := os.Lchown(target, int(st.Uid), int(st.Gid)); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for idx := len(dirs) - 1; idx >= 0; idx-- {
+		d := dirs[idx]
+		if keepOwner {
+			if st, ok := d.info.Sys().(*syscall.Stat_t); ok {
+				if err := os.Lchown(d.path, int(st.Uid), int(st.Gid)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := os.Chmod(d.path, d.info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+			return err
+		}
+		if err := os.Chtimes(d.path, d.info.ModTime(), d.info.ModTime()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyRegularFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	// The data blocks are shared with src until they are modified.
+	// Without the support of the filesystem the file is copied.
+	if err = unix.IoctlFileClone(int(out.Fd()), int(in.Fd())); err != nil {
+		_, err = io.Copy(out, in)
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+
+	// Restore the setuid/setgid bits dropped by umask and OpenFile.
+	return os.Chmod(dst, mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+}
+
+// ExtractRootfs copies the rootfs of the image to the destination.
+// With PackageDir only the directory of the package is copied.
+func (n *Native) ExtractRootfs(opts Options, keepPerms bool) error {
+	image := opts.ImageName
+
+	if err := n.ensureImage(image); err != nil {
+		return errors.Wrap(err, "failed pulling image "+image+" during extraction")
+	}
+
+	src := n.imageRootfs(image)
+	dst := "/"
+	if opts.PackageDir != "" {
+		p, err := securejoin.SecureJoin(src, opts.PackageDir)
+		if err != nil {
+			return err
+		}
+		src = p
+		dst = opts.PackageDir
+	}
+
+	if err := os.MkdirAll(opts.Destination, 0755); err != nil {
+		return err
+	}
+
+	Debug(":seedling: Extracting image " + image)
+	err := copyTree(src, opts.Destination, dst, keepPerms)
+	if err != nil {
+		return errors.Wrap(err, "Failed extracting image "+image)
+	}
+	Debug(":seedling: Image " + image + " extracted")
+
+	return nil
+}
+
+// v1Image returns the image with a single layer with the rootfs.
+// The returned function removes the temporary layer file.
+func (n *Native) v1Image(image string) (v1.Image, func(), error) {
+	img, err := n.loadImage(image)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpdir, err := config.LuetCfg.GetSystem().TempDir("native-layer")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmpdir) }
+
+	layerFile := filepath.Join(tmpdir, "layer.tar")
+	err = helpers.Tar(n.imageRootfs(image), layerFile)
+	if err != nil {
+		cleanup()
+		return nil, nil, errors.Wrap(err, "Failed creating layer of "+image)
+	}
+
+	layer, err := tarball.LayerFromFile(layerFile)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	ans, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	ans, err = mutate.Config(ans, v1.Config{
+		Env:        img.Env,
+		WorkingDir: img.WorkingDir,
+	})
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	return ans, cleanup, nil
+}
+
+// ExportImage writes the image as a tarball in the
+// docker save format.
+func (n *Native) ExportImage(opts Options) error {
+	image := opts.ImageName
+
+	tag, err := name.NewTag(image)
+	if err != nil {
+		return errors.Wrap(err, "Invalid image "+image)
+	}
+
+	img, cleanup, err := n.v1Image(image)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	Debug(":seedling: Saving image " + image)
+	err = tarball.WriteToFile(opts.Destination, tag, img)
+	if err != nil {
+		return errors.Wrap(err, "Failed exporting image "+image)
+	}
+	Info(":seedling: Image " + image + " saved")
+
+	return nil
+}
+
+func (n *Native) ImageDefinitionToTar(opts Options) error {
+	if err := n.BuildImage(opts); err != nil {
+		return errors.Wrap(err, "Failed building image")
+	}
+	if err := n.ExportImage(opts); err != nil {
+		return errors.Wrap(err, "Failed exporting image")
+	}
+	if err := n.RemoveImage(opts); err != nil {
+		return errors.Wrap(err, "Failed removing image")
+	}
+	return nil
+}
+
+func (n *Native) RemoveImage(opts Options) error {
+	err := os.RemoveAll(n.imageDir(opts.ImageName))
+	if err != nil {
+		return errors.Wrap(err, "Failed removing image "+opts.ImageName)
+	}
+	Info(":seedling: Image " + opts.ImageName + " removed")
+	return nil
+}
+
+func (n *Native) CopyImage(src, dst string) error {
+	Debug(":seedling: Tagging image", src, dst)
+
+	img, err := n.loadImage(src)
+	if err != nil {
+		return err
+	}
+
+	staging, err := n.prepareStaging(dst, ".copy")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	err = copyTree(n.imageRootfs(src), filepath.Join(staging, nativeImageRootfs), "/", true)
+	if err != nil {
+		return errors.Wrap(err, "Failed tagging image "+dst)
+	}
+
+	err = n.commitImage(staging, &nativeImage{
+		Name:       dst,
+		Env:        img.Env,
+		WorkingDir: img.WorkingDir,
+	})
+	if err != nil {
+		return err
+	}
+
+	Info(":seedling: Image " + dst + " tagged")
+	return nil
+}
+
+func (n *Native) Push(opts Options) error {
+	image := opts.ImageName
+
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return errors.Wrap(err, "Invalid image "+image)
+	}
+
+	img, cleanup, err := n.v1Image(image)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	err = remote.Write(ref, img, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return errors.Wrap(err, "Failed pushing image "+image)
+	}
+	Info(":seedling: Pushed image:", image)
+
+	return nil
+}
+
+func (*Native) ImageAvailable(image string) bool {
+	return imageAvailable(image)
+}
+
+// ImageExists check if the given image is available locally
+func (n *Native) ImageExists(image string) bool {
+	return fileHelper.Exists(filepath.Join(n.imageDir(image), nativeImageMeta))
+}
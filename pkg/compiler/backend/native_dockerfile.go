@@ -0,0 +1,185 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package backend
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DockerfileInstruction is an instruction of the Dockerfiles
+// generated by the compilation specs.
+type DockerfileInstruction struct {
+	Command string
+	Flags   map[string]string
+	Args    []string
+	// The original arguments of the instruction (used by RUN).
+	Raw string
+}
+
+// ParseDockerfile parses the subset of the Dockerfile syntax used
+// by the compiler: FROM, COPY, ADD, ENV, WORKDIR and RUN.
+func ParseDockerfile(r io.Reader) ([]*DockerfileInstruction, error) {
+	ans := []*DockerfileInstruction{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	line := ""
+	nline := 0
+	for scanner.Scan() {
+		nline++
+		l := strings.TrimSpace(scanner.Text())
+
+		if line == "" && (l == "" || strings.HasPrefix(l, "#")) {
+			continue
+		}
+
+		if strings.HasSuffix(l, "\\") {
+			line += strings.TrimSpace(strings.TrimSuffix(l, "\\")) + " "
+			continue
+		}
+		line += l
+
+		i, err := parseInstruction(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", nline, err.Error())
+		}
+		ans = append(ans, i)
+		line = ""
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if line != "" {
+		return nil, fmt.Errorf("line %d: unterminated instruction", nline)
+	}
+
+	if len(ans) == 0 || ans[0].Command != "FROM" {
+		return nil, fmt.Errorf("the first instruction must be FROM")
+	}
+
+	return ans, nil
+}
+
+func parseInstruction(line string) (*DockerfileInstruction, error) {
+	fields := strings.SplitN(line, " ", 2)
+	ans := &DockerfileInstruction{
+		Command: strings.ToUpper(fields[0]),
+		Flags:   make(map[string]string, 0),
+		Args:    []string{},
+	}
+	if len(fields) > 1 {
+		ans.Raw = strings.TrimSpace(fields[1])
+	}
+
+	switch ans.Command {
+	case "RUN":
+		if strings.HasPrefix(ans.Raw, "[") {
+			// Exec form
+			err := json.Unmarshal([]byte(ans.Raw), &ans.Args)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RUN exec form: %s", err.Error())
+			}
+		} else {
+			ans.Args = []string{"/bin/sh", "-c", ans.Raw}
+		}
+
+	case "ENV":
+		words, err := splitWords(ans.Raw)
+		if err != nil {
+			return nil, err
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("ENV without arguments")
+		}
+		if !strings.Contains(words[0], "=") {
+			// Legacy form: ENV key value
+			kv := strings.SplitN(ans.Raw, " ", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid ENV %s", ans.Raw)
+			}
+			ans.Args = []string{kv[0] + "=" + strings.TrimSpace(kv[1])}
+		} else {
+			ans.Args = words
+		}
+
+	case "FROM", "WORKDIR", "COPY", "ADD":
+		words, err := splitWords(ans.Raw)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range words {
+			if strings.HasPrefix(w, "--") && len(ans.Args) == 0 {
+				kv := strings.SplitN(strings.TrimPrefix(w, "--"), "=", 2)
+				if len(kv) == 2 {
+					ans.Flags[kv[0]] = kv[1]
+				} else {
+					ans.Flags[kv[0]] = ""
+				}
+				continue
+			}
+			ans.Args = append(ans.Args, w)
+		}
+
+		if len(ans.Args) == 0 {
+			return nil, fmt.Errorf("%s without arguments", ans.Command)
+		}
+		if (ans.Command == "COPY" || ans.Command == "ADD") && len(ans.Args) < 2 {
+			return nil, fmt.Errorf("%s requires a source and a destination", ans.Command)
+		}
+
+	default:
+		return nil, fmt.Errorf("instruction %s not supported", ans.Command)
+	}
+
+	return ans, nil
+}
+
+// splitWords splits the string by spaces honoring the single and
+// the double quotes. The quotes are removed.
+func splitWords(s string) ([]string, error) {
+	ans := []string{}
+	var quote rune
+	word := strings.Builder{}
+	inWord := false
+
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t':
+			if inWord {
+				ans = append(ans, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in: %s", s)
+	}
+	if inWord {
+		ans = append(ans, word.String())
+	}
+
+	return ans, nil
+}
@@ -0,0 +1,293 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package backend_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	cmd_box "github.com/geaaru/luet/cmd/box"
+	. "github.com/geaaru/luet/pkg/compiler"
+	"github.com/geaaru/luet/pkg/compiler/backend"
+	. "github.com/geaaru/luet/pkg/compiler/backend"
+	"github.com/geaaru/luet/pkg/config"
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func init() {
+	// The box of the RUN steps executes the test binary
+	// with the hidden command exec.
+	if len(os.Args) > 1 && os.Args[1] == "exec" {
+		c := cmd_box.NewExecCommand(config.LuetCfg)
+		c.SetArgs(os.Args[2:])
+		if err := c.Execute(); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+}
+
+// userNamespacesAvailable checks if the current user could
+// create the namespaces used by the box.
+func userNamespacesAvailable() bool {
+	c := exec.Command("/bin/sh", "-c", "true")
+	c.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getuid(), Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getgid(), Size: 1},
+		},
+	}
+	return c.Run() == nil
+}
+
+// copyWithLibs copies the binary and the shared libraries
+// reported by ldd to the rootfs.
+func copyWithLibs(bin, rootfs, target string) {
+	files := map[string]string{bin: target}
+
+	out, err := exec.Command("ldd", bin).Output()
+	if err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			for _, f := range strings.Fields(line) {
+				if filepath.IsAbs(f) {
+					files[f] = f
+				}
+			}
+		}
+	}
+
+	for src, dst := range files {
+		real, err := filepath.EvalSymlinks(src)
+		Expect(err).ToNot(HaveOccurred())
+		data, err := os.ReadFile(real)
+		Expect(err).ToNot(HaveOccurred())
+		dst = filepath.Join(rootfs, dst)
+		Expect(os.MkdirAll(filepath.Dir(dst), 0755)).ToNot(HaveOccurred())
+		Expect(os.WriteFile(dst, data, 0755)).ToNot(HaveOccurred())
+	}
+}
+
+var _ = Describe("Native backend", func() {
+	Context("Dockerfile parser", func() {
+		It("Parses the instructions generated by the compiler", func() {
+			instructions, err := ParseDockerfile(strings.NewReader(`
+FROM luet/base
+COPY . /luetbuild
+WORKDIR /luetbuild
+ENV PACKAGE_NAME=enman
+ENV FOO="bar baz" A=b
+COPY --from=luet/other /src /dst
+RUN echo foo > /test && \
+  echo bar > /test2
+RUN ["/bin/echo", "foo"]`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(instructions)).To(Equal(8))
+
+			Expect(instructions[0].Command).To(Equal("FROM"))
+			Expect(instructions[0].Args).To(Equal([]string{"luet/base"}))
+			Expect(instructions[1].Args).To(Equal([]string{".", "/luetbuild"}))
+			Expect(instructions[3].Args).To(Equal([]string{"PACKAGE_NAME=enman"}))
+			Expect(instructions[4].Args).To(Equal([]string{"FOO=bar baz", "A=b"}))
+			Expect(instructions[5].Flags).To(Equal(map[string]string{"from": "luet/other"}))
+			Expect(instructions[5].Args).To(Equal([]string{"/src", "/dst"}))
+			Expect(instructions[6].Args).To(Equal([]string{
+				"/bin/sh", "-c", "echo foo > /test && echo bar > /test2",
+			}))
+			Expect(instructions[7].Args).To(Equal([]string{"/bin/echo", "foo"}))
+		})
+
+		It("Fails without FROM", func() {
+			_, err := ParseDockerfile(strings.NewReader("RUN echo foo\n"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Fails with unsupported instructions", func() {
+			_, err := ParseDockerfile(strings.NewReader("FROM foo\nHEALTHCHECK NONE\n"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Images without RUN steps", func() {
+		var tmpdir string
+		var b *Native
+
+		BeforeEach(func() {
+			var err error
+			tmpdir, err = os.MkdirTemp("", "native")
+			Expect(err).ToNot(HaveOccurred())
+
+			b = NewNativeBackend()
+			b.ImagesDir = filepath.Join(tmpdir, "images")
+
+			ctx := filepath.Join(tmpdir, "ctx")
+			Expect(os.MkdirAll(filepath.Join(ctx, "etc"), 0755)).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(ctx, "etc", "os-release"),
+				[]byte("NAME=test\n"), 0644)).ToNot(HaveOccurred())
+			Expect(os.Symlink("os-release", filepath.Join(ctx, "etc", "release"))).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(ctx, "output1"),
+				[]byte("foo\n"), 0644)).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(ctx, "output2"),
+				[]byte("foobar\n"), 0644)).ToNot(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(ctx, "base.dockerfile"), []byte(`
+FROM scratch
+COPY etc /etc
+ENV PATH=/bin
+ENV ROOT=/luet
+ENV DIR=${ROOT}/build SAME=$ROOT EMPTY=$UNSET
+WORKDIR /luetbuild
+COPY output1 .`), 0644)).ToNot(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(ctx, "pkg.dockerfile"), []byte(`
+FROM test/base
+COPY output2 /luetbuild/
+COPY --from=test/base /etc/os-release /usr/share/os-release`), 0644)).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpdir)
+		})
+
+		It("Builds, extracts and exports the images", func() {
+			baseOpts := backend.Options{
+				ImageName:      "test/base",
+				SourcePath:     filepath.Join(tmpdir, "ctx"),
+				DockerFileName: "base.dockerfile",
+			}
+			pkgOpts := backend.Options{
+				ImageName:      "test/pkg",
+				SourcePath:     filepath.Join(tmpdir, "ctx"),
+				DockerFileName: "pkg.dockerfile",
+			}
+
+			Expect(b.ImageExists("test/base")).To(BeFalse())
+			Expect(b.BuildImage(baseOpts)).ToNot(HaveOccurred())
+			Expect(b.ImageExists("test/base")).To(BeTrue())
+			Expect(b.BuildImage(pkgOpts)).ToNot(HaveOccurred())
+
+			rootfs := filepath.Join(tmpdir, "rootfs")
+			Expect(b.ExtractRootfs(backend.Options{
+				ImageName: "test/pkg", Destination: rootfs,
+			}, false)).ToNot(HaveOccurred())
+
+			Expect(fileHelper.Read(filepath.Join(rootfs, "luetbuild", "output1"))).To(Equal("foo\n"))
+			Expect(fileHelper.Read(filepath.Join(rootfs, "luetbuild", "output2"))).To(Equal("foobar\n"))
+			Expect(fileHelper.Read(filepath.Join(rootfs, "usr", "share", "os-release"))).To(Equal("NAME=test\n"))
+			Expect(os.Readlink(filepath.Join(rootfs, "etc", "release"))).To(Equal("os-release"))
+
+			diffs, err := GenerateChanges(b, baseOpts, pkgOpts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(diffs)).To(Equal(1))
+			Expect(len(diffs[0].Diffs.Additions)).To(Equal(2))
+			Expect(diffs[0].Diffs.Additions[0].Name).To(Equal("/luetbuild/output2"))
+			Expect(diffs[0].Diffs.Additions[1].Name).To(Equal("/usr/share/os-release"))
+			Expect(len(diffs[0].Diffs.Deletions)).To(Equal(0))
+
+			Expect(b.CopyImage("test/pkg", "test/pkg:copy")).ToNot(HaveOccurred())
+			Expect(b.ImageExists("test/pkg:copy")).To(BeTrue())
+
+			tarFile := filepath.Join(tmpdir, "pkg.tar")
+			Expect(b.ExportImage(backend.Options{
+				ImageName: "test/pkg", Destination: tarFile,
+			})).ToNot(HaveOccurred())
+
+			img, err := tarball.ImageFromPath(tarFile, nil)
+			Expect(err).ToNot(HaveOccurred())
+			cfg, err := img.ConfigFile()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Config.Env).To(Equal([]string{
+				"PATH=/bin", "ROOT=/luet", "DIR=/luet/build",
+				"SAME=/luet", "EMPTY=",
+			}))
+			Expect(cfg.Config.WorkingDir).To(Equal("/luetbuild"))
+
+			Expect(b.RemoveImage(pkgOpts)).ToNot(HaveOccurred())
+			Expect(b.ImageExists("test/pkg")).To(BeFalse())
+
+			Expect(b.LoadImage(tarFile)).ToNot(HaveOccurred())
+			Expect(b.ImageExists("test/pkg")).To(BeTrue())
+			Expect(b.ExtractRootfs(backend.Options{
+				ImageName: "test/pkg", Destination: filepath.Join(tmpdir, "loaded"),
+			}, false)).ToNot(HaveOccurred())
+			Expect(fileHelper.Read(filepath.Join(tmpdir, "loaded", "luetbuild", "output2"))).To(Equal("foobar\n"))
+		})
+
+		It("Extracts only the package dir", func() {
+			Expect(b.BuildImage(backend.Options{
+				ImageName:      "test/base",
+				SourcePath:     filepath.Join(tmpdir, "ctx"),
+				DockerFileName: "base.dockerfile",
+			})).ToNot(HaveOccurred())
+
+			rootfs := filepath.Join(tmpdir, "rootfs")
+			Expect(b.ExtractRootfs(backend.Options{
+				ImageName: "test/base", Destination: rootfs, PackageDir: "/etc",
+			}, false)).ToNot(HaveOccurred())
+
+			Expect(fileHelper.Exists(filepath.Join(rootfs, "etc", "os-release"))).To(BeTrue())
+			Expect(fileHelper.Exists(filepath.Join(rootfs, "luetbuild"))).To(BeFalse())
+		})
+	})
+	Context("Images with RUN steps", func() {
+		var tmpdir string
+		var b *Native
+
+		BeforeEach(func() {
+			if !userNamespacesAvailable() {
+				Skip("User namespaces not available")
+			}
+			if _, err := exec.LookPath("ldd"); err != nil {
+				Skip("ldd not available")
+			}
+
+			var err error
+			tmpdir, err = os.MkdirTemp("", "native")
+			Expect(err).ToNot(HaveOccurred())
+
+			b = NewNativeBackend()
+			b.ImagesDir = filepath.Join(tmpdir, "images")
+
+			ctx := filepath.Join(tmpdir, "ctx")
+			copyWithLibs("/bin/sh", filepath.Join(ctx, "root"), "/bin/sh")
+
+			Expect(os.WriteFile(filepath.Join(ctx, "run.dockerfile"), []byte(`
+FROM scratch
+COPY root /
+ENV OUT=/luetbuild/out
+WORKDIR /luetbuild
+RUN echo "$OUT $PWD" > $OUT`), 0644)).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpdir)
+		})
+
+		It("Runs the steps in the rootfs of the image", func() {
+			Expect(b.BuildImage(backend.Options{
+				ImageName:      "test/run",
+				SourcePath:     filepath.Join(tmpdir, "ctx"),
+				DockerFileName: "run.dockerfile",
+			})).ToNot(HaveOccurred())
+
+			rootfs := filepath.Join(tmpdir, "rootfs")
+			Expect(b.ExtractRootfs(backend.Options{
+				ImageName: "test/run", Destination: rootfs, PackageDir: "/luetbuild",
+			}, false)).ToNot(HaveOccurred())
+
+			Expect(fileHelper.Read(filepath.Join(rootfs, "luetbuild", "out"))).To(
+				Equal("/luetbuild/out /luetbuild\n"))
+		})
+	})
+})
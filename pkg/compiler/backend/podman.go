@@ -0,0 +1,339 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package backend
+
+import (
+	b64 "encoding/base64"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+
+	tarf "github.com/geaaru/tar-formers/pkg/executor"
+	tarf_specs "github.com/geaaru/tar-formers/pkg/specs"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+)
+
+// Podman is the backend for the Podman and Buildah CLIs. Both
+// support rootless builds without a daemon.
+type Podman struct {
+	// The CLI executed: podman, buildah or the path of the binary.
+	Command string
+	// Credentials used on push. The keys are the same of the
+	// repository auth map: username, password, auth and identitytoken.
+	// If empty are used the credentials of the repository with
+	// the url that matches with the image.
+	Auth map[string]string
+}
+
+func NewPodmanBackend() *Podman {
+	return &Podman{Command: "podman"}
+}
+
+func NewBuildahBackend() *Podman {
+	return &Podman{Command: "buildah"}
+}
+
+func (p *Podman) isBuildah() bool {
+	return filepath.Base(p.Command) == "buildah"
+}
+
+func (p *Podman) BuildImage(opts Options) error {
+	name := opts.ImageName
+
+	buildarg := genBuildCommand(opts)
+	if p.isBuildah() {
+		// build is available only on buildah >= 1.16
+		buildarg[0] = "bud"
+	}
+
+	Info(":ship: Building image " + name)
+	cmd := exec.Command(p.Command, buildarg...)
+	cmd.Dir = opts.SourcePath
+	err := runCommand(cmd)
+	if err != nil {
+		return err
+	}
+
+	Info(":ship: Building image " + name + " done")
+	return nil
+}
+
+func (p *Podman) CopyImage(src, dst string) error {
+	Debug(":ship: Tagging image:", src, "->", dst)
+	out, err := exec.Command(p.Command, "tag", src, dst).CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, "Failed tagging image: "+string(out))
+	}
+	Info(":ship: Tagged image:", src, "->", dst)
+	return nil
+}
+
+func (p *Podman) DownloadImage(opts Options) error {
+	name := opts.ImageName
+
+	Debug(":ship: Downloading image " + name)
+
+	Spinner(22)
+	defer SpinnerStop()
+
+	out, err := exec.Command(p.Command, "pull", name).CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, "Failed pulling image: "+string(out))
+	}
+
+	Info(":ship: Downloaded image:", name)
+	return nil
+}
+
+func (p *Podman) ImageExists(imagename string) bool {
+	buildarg := []string{"image", "exists", imagename}
+	if p.isBuildah() {
+		buildarg = []string{"inspect", "--type", "image", imagename}
+	}
+
+	Debug(":ship: Checking existance of image: " + imagename)
+	out, err := exec.Command(p.Command, buildarg...).CombinedOutput()
+	if err != nil {
+		Debug("Image not present")
+		Debug(string(out))
+		return false
+	}
+	return true
+}
+
+func (*Podman) ImageAvailable(imagename string) bool {
+	return imageAvailable(imagename)
+}
+
+func (p *Podman) RemoveImage(opts Options) error {
+	name := opts.ImageName
+	out, err := exec.Command(p.Command, "rmi", name).CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, "Failed removing image: "+string(out))
+	}
+	Info(":ship: Removed image:", name)
+	return nil
+}
+
+// LoadImage loads the images of an archive created by ExportImage.
+func (p *Podman) LoadImage(path string) error {
+	buildarg := []string{"load", "-i", path}
+	if p.isBuildah() {
+		buildarg = []string{"pull", "docker-archive:" + path}
+	}
+	out, err := exec.Command(p.Command, buildarg...).CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, "Failed loading image: "+string(out))
+	}
+	Info(":ship: Loaded image archive:", path)
+	return nil
+}
+
+// repositoryAuth returns the auth map of the first repository
+// with an url that matches with the image.
+func repositoryAuth(image string) map[string]string {
+	for _, r := range config.LuetCfg.SystemRepositories {
+		if len(r.Authentication) == 0 {
+			continue
+		}
+		for _, u := range r.Urls {
+			u = strings.TrimSuffix(u, "/")
+			if u != "" && (image == u || strings.HasPrefix(image, u+":") ||
+				strings.HasPrefix(image, u+"/")) {
+				return r.Authentication
+			}
+		}
+	}
+	return nil
+}
+
+// writeAuthFile writes the credentials of the image registry in the
+// format of the containers-auth.json file. It returns an empty
+// string if there aren't credentials.
+func (p *Podman) writeAuthFile(image string) (string, error) {
+	auth := p.Auth
+	if len(auth) == 0 {
+		auth = repositoryAuth(image)
+	}
+	if len(auth) == 0 {
+		return "", nil
+	}
+
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", errors.Wrap(err, "Invalid image "+image)
+	}
+
+	entry := map[string]string{}
+	if auth["username"] != "" {
+		entry["auth"] = b64.StdEncoding.EncodeToString(
+			[]byte(auth["username"] + ":" + auth["password"]))
+	} else if auth["auth"] != "" {
+		entry["auth"] = auth["auth"]
+	}
+	if auth["identitytoken"] != "" {
+		entry["identitytoken"] = auth["identitytoken"]
+	}
+	if len(entry) == 0 {
+		return "", errors.New("No supported credentials for the registry " +
+			ref.Context().RegistryStr())
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			ref.Context().RegistryStr(): entry,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	f, err := config.LuetCfg.GetSystem().TempFile("podman-auth")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	err = f.Chmod(0600)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+func (p *Podman) Push(opts Options) error {
+	name := opts.ImageName
+	pusharg := []string{"push"}
+
+	authFile, err := p.writeAuthFile(name)
+	if err != nil {
+		return err
+	}
+	if authFile != "" {
+		defer os.Remove(authFile)
+		pusharg = append(pusharg, "--authfile", authFile)
+	}
+	pusharg = append(pusharg, name)
+
+	Spinner(22)
+	defer SpinnerStop()
+
+	out, err := exec.Command(p.Command, pusharg...).CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, "Failed pushing image: "+string(out))
+	}
+	Info(":ship: Pushed image:", name)
+
+	return nil
+}
+
+func (p *Podman) ImageDefinitionToTar(opts Options) error {
+	if err := p.BuildImage(opts); err != nil {
+		return errors.Wrap(err, "Failed building image")
+	}
+	if err := p.ExportImage(opts); err != nil {
+		return errors.Wrap(err, "Failed exporting image")
+	}
+	if err := p.RemoveImage(opts); err != nil {
+		return errors.Wrap(err, "Failed removing image")
+	}
+	return nil
+}
+
+// ExportImage saves the image in the docker-archive format.
+func (p *Podman) ExportImage(opts Options) error {
+	name := opts.ImageName
+	path := opts.Destination
+
+	buildarg := []string{"image", "save", "--format", "docker-archive", "-o", path, name}
+	if p.isBuildah() {
+		buildarg = []string{"push", name, "docker-archive:" + path + ":" + name}
+	}
+	Debug(":ship: Saving image " + name)
+
+	Spinner(22)
+	defer SpinnerStop()
+
+	out, err := exec.Command(p.Command, buildarg...).CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, "Failed exporting image: "+string(out))
+	}
+
+	Debug(":ship: Exported image:", name)
+	return nil
+}
+
+func (p *Podman) createTarFormers() *tarf.TarFormers {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	cfg := tarf_specs.NewConfig(config.LuetCfg.Viper)
+	cfg.GetGeneral().Debug = config.LuetCfg.GetGeneral().Debug
+	cfg.GetLogging().Level = config.LuetCfg.GetLogging().Level
+
+	return tarf.NewTarFormers(cfg)
+}
+
+// ExtractRootfs saves the image and it unpacks the layers
+// with the whiteouts applied in the destination.
+func (p *Podman) ExtractRootfs(opts Options, keepPerms bool) error {
+	name := opts.ImageName
+	dst := opts.Destination
+
+	if !p.ImageExists(name) {
+		if err := p.DownloadImage(opts); err != nil {
+			return errors.Wrap(err, "failed pulling image "+name+" during extraction")
+		}
+	}
+
+	tempexport, err := config.LuetCfg.GetSystem().TempDir("podman-export")
+	if err != nil {
+		return errors.Wrap(err, "Error met while creating tempdir for rootfs")
+	}
+	defer os.RemoveAll(tempexport) // clean up
+
+	imageExport := filepath.Join(tempexport, "image.tar")
+	if err := p.ExportImage(Options{ImageName: name, Destination: imageExport}); err != nil {
+		return errors.Wrap(err, "failed while extracting rootfs for "+name)
+	}
+
+	img, err := tarball.ImageFromPath(imageExport, nil)
+	if err != nil {
+		return errors.Wrap(err, "Error met while reading image archive")
+	}
+
+	reader := mutate.Extract(img)
+	defer reader.Close()
+
+	spec := tarf_specs.NewSpecFile()
+	spec.SameOwner = keepPerms
+	spec.EnableMutex = true
+	spec.OverwritePerms = true
+	spec.IgnoreRegexes = []string{}
+	spec.IgnoreFiles = []string{}
+
+	tarformers := p.createTarFormers()
+	tarformers.SetReader(reader)
+
+	err = tarformers.RunTask(spec, dst)
+	if err != nil {
+		return errors.Wrap(err, "Error met while unpacking rootfs")
+	}
+
+	return nil
+}
@@ -0,0 +1,238 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package backend_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/geaaru/luet/pkg/compiler/backend"
+	. "github.com/geaaru/luet/pkg/compiler/backend"
+	config "github.com/geaaru/luet/pkg/config"
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// The shim logs the arguments and it simulates the commands
+// used by the backend. The image archive saved is the file
+// image.tar of the shim directory.
+const fakeCliShim = `#!/bin/sh
+dir=$(dirname "$0")
+echo "$@" >> "$dir/calls.log"
+case "$1" in
+  image)
+    if [ "$2" = "exists" ]; then
+      [ -e "$dir/exists" ] && exit 0
+      exit 1
+    fi
+    # image save --format docker-archive -o <path> <image>
+    cp "$dir/image.tar" "$6"
+    ;;
+  inspect)
+    [ -e "$dir/exists" ] && exit 0
+    exit 1
+    ;;
+  push)
+    if [ "$2" = "--authfile" ]; then
+      cp "$3" "$dir/auth.json"
+    fi
+    case "$3" in
+      docker-archive:*)
+        dst=${3#docker-archive:}
+        cp "$dir/image.tar" "${dst%%:*}"
+        ;;
+    esac
+    ;;
+  pull)
+    touch "$dir/exists"
+    ;;
+  rmi)
+    rm -f "$dir/exists"
+    ;;
+esac
+exit 0
+`
+
+func writeFakeCli(dir, cli string) string {
+	path := filepath.Join(dir, cli)
+	Expect(os.WriteFile(path, []byte(fakeCliShim), 0755)).ToNot(HaveOccurred())
+	return path
+}
+
+func writeImageArchive(file, image string, files map[string]string) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for f, content := range files {
+		Expect(tw.WriteHeader(&tar.Header{
+			Name: f, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg,
+		})).ToNot(HaveOccurred())
+		_, err := tw.Write([]byte(content))
+		Expect(err).ToNot(HaveOccurred())
+	}
+	Expect(tw.Close()).ToNot(HaveOccurred())
+
+	data := buf.Bytes()
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	Expect(err).ToNot(HaveOccurred())
+
+	tag, err := name.NewTag(image)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(tarball.WriteToFile(file, tag, img)).ToNot(HaveOccurred())
+}
+
+func readCalls(dir string) []string {
+	data, err := os.ReadFile(filepath.Join(dir, "calls.log"))
+	Expect(err).ToNot(HaveOccurred())
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+var _ = Describe("Podman backend", func() {
+	var tmpdir string
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "podman")
+		Expect(err).ToNot(HaveOccurred())
+
+		writeImageArchive(filepath.Join(tmpdir, "image.tar"), "luet/base:latest",
+			map[string]string{
+				"luetbuild/output1": "foo\n",
+				"etc/os-release":    "NAME=test\n",
+			})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	Context("With the podman CLI", func() {
+		var b *Podman
+
+		BeforeEach(func() {
+			b = &Podman{Command: writeFakeCli(tmpdir, "podman")}
+		})
+
+		It("Builds, exports and removes the image", func() {
+			dst := filepath.Join(tmpdir, "output.tar")
+			Expect(b.ImageDefinitionToTar(backend.Options{
+				ImageName:      "luet/base",
+				SourcePath:     tmpdir,
+				DockerFileName: "Dockerfile",
+				Destination:    dst,
+			})).ToNot(HaveOccurred())
+
+			Expect(fileHelper.Exists(dst)).To(BeTrue())
+			Expect(readCalls(tmpdir)).To(Equal([]string{
+				"build -f Dockerfile -t luet/base .",
+				"image save --format docker-archive -o " + dst + " luet/base",
+				"rmi luet/base",
+			}))
+		})
+
+		It("Pulls the image missing and extracts the rootfs", func() {
+			Expect(b.ImageExists("luet/base")).To(BeFalse())
+
+			rootfs := filepath.Join(tmpdir, "rootfs")
+			Expect(os.MkdirAll(rootfs, 0755)).ToNot(HaveOccurred())
+			Expect(b.ExtractRootfs(backend.Options{
+				ImageName: "luet/base", Destination: rootfs,
+			}, false)).ToNot(HaveOccurred())
+
+			Expect(fileHelper.Read(filepath.Join(rootfs, "luetbuild", "output1"))).To(Equal("foo\n"))
+			Expect(fileHelper.Read(filepath.Join(rootfs, "etc", "os-release"))).To(Equal("NAME=test\n"))
+
+			calls := readCalls(tmpdir)
+			Expect(calls[0]).To(Equal("image exists luet/base"))
+			Expect(calls[1]).To(Equal("image exists luet/base"))
+			Expect(calls[2]).To(Equal("pull luet/base"))
+			Expect(calls[3]).To(HavePrefix("image save --format docker-archive -o "))
+
+			Expect(b.ImageExists("luet/base")).To(BeTrue())
+			Expect(b.CopyImage("luet/base", "luet/base:copy")).ToNot(HaveOccurred())
+			Expect(readCalls(tmpdir)).To(ContainElement("tag luet/base luet/base:copy"))
+		})
+
+		It("Pushes with the credentials of the repository", func() {
+			config.LuetCfg.SystemRepositories = []config.LuetRepository{
+				{
+					Name: "cache",
+					Type: "docker",
+					Urls: []string{"quay.io/geaaru/luet-cache"},
+					Authentication: map[string]string{
+						"username": "user",
+						"password": "pass",
+					},
+				},
+			}
+			defer func() { config.LuetCfg.SystemRepositories = []config.LuetRepository{} }()
+
+			Expect(b.Push(backend.Options{
+				ImageName: "quay.io/geaaru/luet-cache:foo",
+			})).ToNot(HaveOccurred())
+
+			calls := readCalls(tmpdir)
+			Expect(calls[0]).To(HavePrefix("push --authfile "))
+			Expect(calls[0]).To(HaveSuffix(" quay.io/geaaru/luet-cache:foo"))
+			// The auth file is removed after the push.
+			Expect(fileHelper.Exists(strings.Split(calls[0], " ")[2])).To(BeFalse())
+
+			data, err := os.ReadFile(filepath.Join(tmpdir, "auth.json"))
+			Expect(err).ToNot(HaveOccurred())
+			auths := map[string]map[string]map[string]string{}
+			Expect(json.Unmarshal(data, &auths)).ToNot(HaveOccurred())
+			Expect(auths["auths"]["quay.io"]["auth"]).To(Equal("dXNlcjpwYXNz"))
+		})
+
+		It("Pushes without credentials", func() {
+			Expect(b.Push(backend.Options{ImageName: "luet/base"})).ToNot(HaveOccurred())
+			Expect(readCalls(tmpdir)).To(Equal([]string{"push luet/base"}))
+		})
+	})
+
+	Context("With the buildah CLI", func() {
+		var b *Podman
+
+		BeforeEach(func() {
+			b = &Podman{Command: writeFakeCli(tmpdir, "buildah")}
+		})
+
+		It("Uses the buildah commands", func() {
+			Expect(b.BuildImage(backend.Options{
+				ImageName:      "luet/base",
+				SourcePath:     tmpdir,
+				DockerFileName: "Dockerfile",
+			})).ToNot(HaveOccurred())
+			Expect(b.ImageExists("luet/base")).To(BeFalse())
+
+			rootfs := filepath.Join(tmpdir, "rootfs")
+			Expect(os.MkdirAll(rootfs, 0755)).ToNot(HaveOccurred())
+			Expect(b.ExtractRootfs(backend.Options{
+				ImageName: "luet/base", Destination: rootfs,
+			}, false)).ToNot(HaveOccurred())
+			Expect(fileHelper.Read(filepath.Join(rootfs, "luetbuild", "output1"))).To(Equal("foo\n"))
+
+			calls := readCalls(tmpdir)
+			Expect(calls[0]).To(Equal("bud -f Dockerfile -t luet/base ."))
+			Expect(calls[1]).To(Equal("inspect --type image luet/base"))
+			Expect(calls).To(ContainElement(HavePrefix("push luet/base docker-archive:")))
+		})
+	})
+})
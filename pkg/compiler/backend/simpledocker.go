@@ -136,6 +136,16 @@ func (*SimpleDocker) RemoveImage(opts Options) error {
 	return nil
 }
 
+// LoadImage loads the images of an archive created by ExportImage.
+func (*SimpleDocker) LoadImage(path string) error {
+	out, err := exec.Command("docker", "load", "-i", path).CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, "Failed loading image: "+string(out))
+	}
+	Info(":whale: Loaded image archive:", path)
+	return nil
+}
+
 func (*SimpleDocker) Push(opts Options) error {
 	name := opts.ImageName
 	pusharg := []string{"push", name}
@@ -51,6 +51,19 @@ func (*SimpleImg) BuildImage(opts Options) error {
 	return nil
 }
 
+// LoadImage loads the images of an archive created by ExportImage.
+func (*SimpleImg) LoadImage(path string) error {
+	Spinner(22)
+	defer SpinnerStop()
+	out, err := exec.Command("img", "load", "-i", path).CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, "Failed loading image: "+string(out))
+	}
+
+	Info(":tea: Image archive " + path + " loaded")
+	return nil
+}
+
 func (*SimpleImg) RemoveImage(opts Options) error {
 	name := opts.ImageName
 	buildarg := []string{"rm", name}
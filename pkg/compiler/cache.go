@@ -0,0 +1,165 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/geaaru/luet/pkg/compiler/backend"
+	"github.com/geaaru/luet/pkg/compiler/cache"
+	artifact "github.com/geaaru/luet/pkg/compiler/types/artifact"
+	compilerspec "github.com/geaaru/luet/pkg/compiler/types/spec"
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	. "github.com/geaaru/luet/pkg/logger"
+)
+
+// newCacheEntry returns the build cache and the entry of the spec.
+// The compression type and the SOURCE_DATE_EPOCH (of the environment
+// or of the spec) are part of the key because with the same spec
+// they produce a different artifact.
+func (cs *LuetCompiler) newCacheEntry(p *compilerspec.LuetCompilationSpec,
+	packageHash string) (*cache.BuildCache, *cache.CacheEntry) {
+
+	if cs.Options.CacheDir == "" {
+		return nil, nil
+	}
+
+	specHash, err := p.Hash()
+	if err != nil {
+		Debug("Build cache disabled for", p.GetPackage().HumanReadableString(), ":", err.Error())
+		return nil, nil
+	}
+
+	epoch := ""
+	if t := p.GetSourceDateEpoch(); t != nil {
+		epoch = strconv.FormatInt(t.Unix(), 10)
+	}
+
+	c := cache.NewBuildCache(cs.Options.CacheDir)
+	return c, &cache.CacheEntry{
+		Key: cache.Key(specHash, packageHash,
+			string(cs.Options.CompressionType), epoch),
+		Package:     p.GetPackage().GetCategory() + "/" + p.GetPackage().GetName(),
+		Version:     p.GetPackage().GetVersion(),
+		SpecHash:    specHash,
+		PackageHash: packageHash,
+	}
+}
+
+// restoreFromCache restores the package image and the artifact of
+// the spec from the build cache. It returns nil if the build isn't
+// available in the cache or it can't be restored.
+func (cs *LuetCompiler) restoreFromCache(c *cache.BuildCache, e *cache.CacheEntry,
+	p *compilerspec.LuetCompilationSpec, packageImage string,
+	generateArtifact bool) *artifact.PackageArtifact {
+
+	if c == nil || cs.Options.Rebuild {
+		return nil
+	}
+
+	pkgTag := ":package: " + p.GetPackage().HumanReadableString()
+
+	entry, err := c.Get(e.Key)
+	if err != nil {
+		Warning(pkgTag, "Error on read build cache:", err.Error())
+		return nil
+	}
+	if entry == nil || (generateArtifact && !entry.HasArtifact()) {
+		Debug(pkgTag, "Build cache miss for key", e.Key)
+		return nil
+	}
+
+	// The package image is the source image of the packages that
+	// depend on this package.
+	if !cs.Backend.ImageExists(packageImage) {
+		loader, ok := cs.Backend.(ImageLoader)
+		if !ok || !entry.HasImage() {
+			Debug(pkgTag, "Build cache entry without a loadable image")
+			return nil
+		}
+
+		err = loader.LoadImage(filepath.Join(c.EntryDir(entry.Key), cache.ImageFile))
+		if err == nil && entry.Image != packageImage {
+			err = cs.Backend.CopyImage(entry.Image, packageImage)
+		}
+		if err != nil {
+			Warning(pkgTag, "Error on restore image from build cache:", err.Error())
+			return nil
+		}
+	}
+
+	ans := &artifact.PackageArtifact{}
+	if generateArtifact {
+		err = os.MkdirAll(p.GetOutputPath(), 0755)
+		for _, f := range entry.Files {
+			if err != nil {
+				break
+			}
+			err = fileHelper.CopyFile(filepath.Join(c.EntryDir(entry.Key), f), p.Rel(f))
+		}
+		if err == nil {
+			ans, err = LoadArtifactFromYaml(p)
+		}
+		if err != nil {
+			Warning(pkgTag, "Error on restore artifact from build cache:", err.Error())
+			return nil
+		}
+		// The first file is the tarball of the artifact.
+		ans.Path = p.Rel(entry.Files[0])
+
+		if err := cs.writeSBOM(ans); err != nil {
+			Warning(pkgTag, "Error on write SBOM:", err.Error())
+		}
+	}
+
+	if err := c.Touch(entry); err != nil {
+		Debug(pkgTag, "Error on update build cache entry:", err.Error())
+	}
+
+	Info(pkgTag, "   :recycle: restored from build cache")
+	return ans
+}
+
+// storeInCache adds the package image and the artifact (if not nil)
+// to the build cache. The errors are only reported because the
+// build is already completed.
+func (cs *LuetCompiler) storeInCache(c *cache.BuildCache, e *cache.CacheEntry,
+	p *compilerspec.LuetCompilationSpec, packageImage string,
+	a *artifact.PackageArtifact) {
+
+	if c == nil {
+		return
+	}
+
+	files := []string{}
+	if a != nil && a.Path != "" {
+		files = append(files, a.Path, p.Rel(p.GetPackage().GetMetadataFilePath()))
+	}
+
+	var exportImage func(string) error
+	if _, ok := cs.Backend.(ImageLoader); ok && cs.Backend.ImageExists(packageImage) {
+		e.Image = packageImage
+		exportImage = func(dst string) error {
+			return cs.Backend.ExportImage(backend.Options{
+				ImageName:   packageImage,
+				Destination: dst,
+			})
+		}
+	}
+
+	if len(files) == 0 && exportImage == nil {
+		return
+	}
+
+	err := c.Store(e, files, exportImage)
+	if err != nil {
+		Warning(":package: "+p.GetPackage().HumanReadableString(),
+			"Error on store build in cache:", err.Error())
+		return
+	}
+	Debug(":package: "+p.GetPackage().HumanReadableString(), "Stored in build cache with key", e.Key)
+}
@@ -0,0 +1,528 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cache
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// File with the metadata of the cache entry.
+	EntryFile = "entry.yaml"
+	// File with the package image in the docker-archive format.
+	ImageFile = "image.tar"
+
+	stagingPrefix = ".staging-"
+)
+
+// CacheEntry describes the artifact and the package image of a
+// build stored in the cache.
+type CacheEntry struct {
+	Key string `json:"key" yaml:"key"`
+	// Package in the format category/name.
+	Package     string `json:"package" yaml:"package"`
+	Version     string `json:"version" yaml:"version"`
+	SpecHash    string `json:"spec_hash" yaml:"spec_hash"`
+	PackageHash string `json:"package_hash" yaml:"package_hash"`
+	// Name of the package image exported.
+	Image string `json:"image,omitempty" yaml:"image,omitempty"`
+	// Files of the artifact: tarball and metadata.
+	Files    []string `json:"files,omitempty" yaml:"files,omitempty"`
+	Size     int64    `json:"size" yaml:"size"`
+	Created  string   `json:"created" yaml:"created"`
+	LastUsed string   `json:"last_used" yaml:"last_used"`
+}
+
+// BuildCache is a directory with the builds keyed by the hash of the
+// compilation spec and the hash of the dependencies. The entries are
+// written in a staging directory and renamed so the same directory
+// could be shared between build hosts over NFS.
+type BuildCache struct {
+	Dir string
+}
+
+type PruneOpts struct {
+	// Remove all entries.
+	All bool
+	// Remove the entries not used since the duration.
+	OlderThan time.Duration
+	// Remove the entries less recently used until the
+	// cache size is less than MaxSize.
+	MaxSize int64
+	// Remove the entries of the packages in the list
+	// (category/name or category/name-version). With OlderThan
+	// are removed only the entries of the packages not used
+	// since the duration.
+	Packages []string
+}
+
+func NewBuildCache(dir string) *BuildCache {
+	return &BuildCache{Dir: dir}
+}
+
+// Key returns the key of the entry from the hash of the
+// compilation spec, the package hash of the hashtree and the options
+// that change the artifact of the same spec: the compression type
+// and the epoch used to clamp the mtime of the files (empty if
+// not defined).
+func Key(specHash, packageHash, compression, epoch string) string {
+	h := sha256.Sum256([]byte(strings.Join(
+		[]string{specHash, packageHash, compression, epoch}, "-")))
+	return hex.EncodeToString(h[:])
+}
+
+func (e *CacheEntry) HasArtifact() bool {
+	return len(e.Files) > 0
+}
+
+func (e *CacheEntry) HasImage() bool {
+	return e.Image != ""
+}
+
+func (e *CacheEntry) GetLastUsed() time.Time {
+	t, err := time.Parse(time.RFC3339, e.LastUsed)
+	if err != nil {
+		t, _ = time.Parse(time.RFC3339, e.Created)
+	}
+	return t
+}
+
+func (e *CacheEntry) write(dir string) error {
+	data, err := yaml.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	// The entry is replaced atomically to avoid partial
+	// reads from concurrent builds.
+	f, err := os.CreateTemp(dir, "."+EntryFile+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(dir, EntryFile))
+}
+
+func readEntry(dir string) (*CacheEntry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, EntryFile))
+	if err != nil {
+		return nil, err
+	}
+
+	ans := &CacheEntry{}
+	err = yaml.Unmarshal(data, ans)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid cache entry "+dir)
+	}
+	return ans, nil
+}
+
+func (c *BuildCache) EntryDir(key string) string {
+	return filepath.Join(c.Dir, key)
+}
+
+// Get returns the entry with the key or nil if it isn't present.
+func (c *BuildCache) Get(key string) (*CacheEntry, error) {
+	e, err := readEntry(c.EntryDir(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// Touch updates the last use of the entry.
+func (c *BuildCache) Touch(e *CacheEntry) error {
+	e.LastUsed = time.Now().UTC().Format(time.RFC3339)
+	return e.write(c.EntryDir(e.Key))
+}
+
+func (c *BuildCache) newStaging(key string) (string, error) {
+	err := os.MkdirAll(c.Dir, 0755)
+	if err != nil {
+		return "", errors.Wrap(err, "Error on create cache directory")
+	}
+	return os.MkdirTemp(c.Dir, stagingPrefix+key+"-")
+}
+
+// commit replaces the entry with the staging directory.
+func (c *BuildCache) commit(staging, key string) error {
+	dir := c.EntryDir(key)
+
+	if fileHelper.Exists(dir) {
+		trash := staging + ".old"
+		err := os.Rename(dir, trash)
+		if err != nil {
+			return errors.Wrap(err, "Error on replace cache entry "+key)
+		}
+		defer os.RemoveAll(trash)
+	}
+
+	return os.Rename(staging, dir)
+}
+
+// Store adds the entry with the artifact files in input. If the
+// function image is not nil it's called to export the package
+// image in the entry directory.
+func (c *BuildCache) Store(e *CacheEntry, files []string, image func(dst string) error) error {
+	staging, err := c.newStaging(e.Key)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	e.Files = []string{}
+	e.Size = 0
+
+	for _, f := range files {
+		dst := filepath.Join(staging, filepath.Base(f))
+		err = fileHelper.CopyFile(f, dst)
+		if err != nil {
+			return errors.Wrap(err, "Error on copy "+f)
+		}
+		e.Files = append(e.Files, filepath.Base(f))
+		if st, err := os.Stat(dst); err == nil {
+			e.Size += st.Size()
+		}
+	}
+
+	if image != nil {
+		dst := filepath.Join(staging, ImageFile)
+		err = image(dst)
+		if err != nil {
+			return errors.Wrap(err, "Error on export image "+e.Image)
+		}
+		if st, err := os.Stat(dst); err == nil {
+			e.Size += st.Size()
+		}
+	} else {
+		e.Image = ""
+	}
+
+	e.Created = time.Now().UTC().Format(time.RFC3339)
+	e.LastUsed = e.Created
+
+	err = e.write(staging)
+	if err != nil {
+		return err
+	}
+
+	return c.commit(staging, e.Key)
+}
+
+// List returns the entries of the cache sorted by package.
+func (c *BuildCache) List() ([]*CacheEntry, error) {
+	ans := []*CacheEntry{}
+
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ans, nil
+		}
+		return nil, err
+	}
+
+	for _, d := range entries {
+		if !d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		e, err := readEntry(filepath.Join(c.Dir, d.Name()))
+		if err != nil {
+			continue
+		}
+		ans = append(ans, e)
+	}
+
+	sort.Slice(ans, func(i, j int) bool {
+		if ans[i].Package == ans[j].Package {
+			return ans[i].Created < ans[j].Created
+		}
+		return ans[i].Package < ans[j].Package
+	})
+
+	return ans, nil
+}
+
+func (c *BuildCache) Remove(key string) error {
+	return os.RemoveAll(c.EntryDir(key))
+}
+
+// Match returns true if the filter is the key of the entry or the
+// package in the format category/name or category/name-version.
+func (e *CacheEntry) Match(filter string) bool {
+	return e.Key == filter || e.Package == filter ||
+		e.Package+"-"+e.Version == filter
+}
+
+// Prune removes the entries selected by the options and it
+// returns the entries removed.
+func (c *BuildCache) Prune(opts PruneOpts) ([]*CacheEntry, error) {
+	removed := []*CacheEntry{}
+
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort from the less recently used.
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].GetLastUsed().Before(entries[j].GetLastUsed())
+	})
+
+	pkgs := make(map[string]bool, 0)
+	for _, p := range opts.Packages {
+		pkgs[p] = true
+	}
+
+	now := time.Now()
+	size := int64(0)
+	kept := []*CacheEntry{}
+
+	// The filters are combined: an entry is removed only if it
+	// matches all the filters defined.
+	filtered := len(pkgs) > 0 || opts.OlderThan > 0
+	match := func(e *CacheEntry) bool {
+		if len(pkgs) > 0 && !pkgs[e.Package] && !pkgs[e.Package+"-"+e.Version] {
+			return false
+		}
+		if opts.OlderThan > 0 && now.Sub(e.GetLastUsed()) <= opts.OlderThan {
+			return false
+		}
+		return true
+	}
+
+	for _, e := range entries {
+		if opts.All || (filtered && match(e)) {
+			if err := c.Remove(e.Key); err != nil {
+				return removed, err
+			}
+			removed = append(removed, e)
+			continue
+		}
+		size += e.Size
+		kept = append(kept, e)
+	}
+
+	for _, e := range kept {
+		if opts.MaxSize <= 0 || size <= opts.MaxSize {
+			break
+		}
+		if err := c.Remove(e.Key); err != nil {
+			return removed, err
+		}
+		size -= e.Size
+		removed = append(removed, e)
+	}
+
+	// Drop the staging directories of the interrupted builds.
+	if dirs, err := os.ReadDir(c.Dir); err == nil {
+		for _, d := range dirs {
+			if strings.HasPrefix(d.Name(), stagingPrefix) {
+				if info, err := d.Info(); err == nil && now.Sub(info.ModTime()) > time.Hour {
+					os.RemoveAll(filepath.Join(c.Dir, d.Name()))
+				}
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// Export writes the entries in a tar.gz archive. The filters are
+// matched with CacheEntry.Match and without filters all the
+// entries are exported.
+func (c *BuildCache) Export(dst string, filters []string) ([]*CacheEntry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(filters) > 0 {
+		selected := []*CacheEntry{}
+		for _, e := range entries {
+			for _, k := range filters {
+				if e.Match(k) {
+					selected = append(selected, e)
+					break
+				}
+			}
+		}
+		entries = selected
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+
+	for _, e := range entries {
+		files := append([]string{EntryFile}, e.Files...)
+		if e.HasImage() {
+			files = append(files, ImageFile)
+		}
+
+		for _, f := range files {
+			err = addTarFile(tw, filepath.Join(c.EntryDir(e.Key), f), e.Key+"/"+f)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Error on export entry %s", e.Key)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	return entries, out.Close()
+}
+
+func addTarFile(tw *tar.Writer, src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     st.Size(),
+		ModTime:  st.ModTime(),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Import adds the entries of an archive created by Export. The
+// entries already present are skipped unless force is true.
+func (c *BuildCache) Import(src string, force bool) ([]*CacheEntry, error) {
+	imported := []*CacheEntry{}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	gr, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid cache archive "+src)
+	}
+	defer gr.Close()
+
+	err = os.MkdirAll(c.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(c.Dir, stagingPrefix+"import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	keys := []string{}
+	tr := tar.NewReader(gr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Error on read cache archive")
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		fields := strings.Split(h.Name, "/")
+		if len(fields) != 2 || fields[0] == "" || fields[1] == "" ||
+			strings.HasPrefix(fields[0], ".") || strings.HasPrefix(fields[1], ".") {
+			return nil, fmt.Errorf("Invalid file %s in cache archive", h.Name)
+		}
+
+		dir := filepath.Join(staging, fields[0])
+		if !fileHelper.Exists(dir) {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, err
+			}
+			keys = append(keys, fields[0])
+		}
+
+		out, err := os.Create(filepath.Join(dir, fields[1]))
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(out, tr)
+		out.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "Error on extract "+h.Name)
+		}
+	}
+
+	for _, k := range keys {
+		e, err := readEntry(filepath.Join(staging, k))
+		if err != nil || e.Key != k {
+			return imported, fmt.Errorf("Invalid entry %s in cache archive", k)
+		}
+
+		if !force && fileHelper.Exists(filepath.Join(c.EntryDir(k), EntryFile)) {
+			continue
+		}
+
+		if err := c.commit(filepath.Join(staging, k), k); err != nil {
+			return imported, err
+		}
+		imported = append(imported, e)
+	}
+
+	return imported, nil
+}
@@ -0,0 +1,189 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/geaaru/luet/pkg/compiler/cache"
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Build cache", func() {
+	var tmpdir string
+	var c *BuildCache
+	var artifactFiles []string
+
+	store := func(pkg, version, packageHash string, withImage bool) *CacheEntry {
+		e := &CacheEntry{
+			Key:         Key("spec-"+pkg, packageHash, "zstd", ""),
+			Package:     pkg,
+			Version:     version,
+			SpecHash:    "spec-" + pkg,
+			PackageHash: packageHash,
+		}
+		var image func(string) error
+		if withImage {
+			e.Image = "luet/cache:" + packageHash
+			image = func(dst string) error {
+				return os.WriteFile(dst, []byte("image"), 0644)
+			}
+		}
+		Expect(c.Store(e, artifactFiles, image)).ToNot(HaveOccurred())
+		return e
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "cache")
+		Expect(err).ToNot(HaveOccurred())
+
+		c = NewBuildCache(filepath.Join(tmpdir, "cache"))
+
+		artifactFiles = []string{
+			filepath.Join(tmpdir, "foo-1.0.package.tar"),
+			filepath.Join(tmpdir, "foo-1.0.metadata.yaml"),
+		}
+		Expect(os.WriteFile(artifactFiles[0], []byte("tarball"), 0644)).ToNot(HaveOccurred())
+		Expect(os.WriteFile(artifactFiles[1], []byte("metadata"), 0644)).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Changes the key with the compression type and the epoch", func() {
+		key := Key("spec", "hash", "zstd", "")
+		Expect(Key("spec", "hash", "gzip", "")).ToNot(Equal(key))
+		Expect(Key("spec", "hash", "zstd", "1700000000")).ToNot(Equal(key))
+		Expect(Key("spec", "hash", "zstd", "")).To(Equal(key))
+	})
+
+	It("Stores and gets the entries", func() {
+		e, err := c.Get(Key("spec", "hash", "zstd", ""))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e).To(BeNil())
+
+		stored := store("test/foo", "1.0", "hash", true)
+		Expect(stored.Key).To(Equal(Key("spec-test/foo", "hash", "zstd", "")))
+
+		e, err = c.Get(stored.Key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e).ToNot(BeNil())
+		Expect(e.Package).To(Equal("test/foo"))
+		Expect(e.Files).To(Equal([]string{"foo-1.0.package.tar", "foo-1.0.metadata.yaml"}))
+		Expect(e.HasArtifact()).To(BeTrue())
+		Expect(e.HasImage()).To(BeTrue())
+		Expect(e.Size).To(Equal(int64(len("tarball") + len("metadata") + len("image"))))
+		Expect(fileHelper.Read(filepath.Join(c.EntryDir(e.Key), ImageFile))).To(Equal("image"))
+
+		// The entry is replaced.
+		artifactFiles = []string{}
+		store("test/foo", "1.0", "hash", false)
+		e, err = c.Get(stored.Key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e.HasArtifact()).To(BeFalse())
+		Expect(e.HasImage()).To(BeFalse())
+		Expect(fileHelper.Exists(filepath.Join(c.EntryDir(e.Key), ImageFile))).To(BeFalse())
+
+		entries, err := c.List()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(entries)).To(Equal(1))
+	})
+
+	It("Updates the last use of an entry", func() {
+		foo := store("test/foo", "1.0", "hash1", false)
+		foo.LastUsed = time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+
+		Expect(c.Touch(foo)).ToNot(HaveOccurred())
+
+		e, err := c.Get(foo.Key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(time.Since(e.GetLastUsed())).To(BeNumerically("<", time.Hour))
+
+		// The temporary file is renamed.
+		files, err := os.ReadDir(c.EntryDir(foo.Key))
+		Expect(err).ToNot(HaveOccurred())
+		for _, f := range files {
+			Expect(f.Name()).ToNot(HavePrefix("."))
+		}
+	})
+
+	It("Prunes the entries", func() {
+		var removed []*CacheEntry
+		foo := store("test/foo", "1.0", "hash1", false)
+		store("test/bar", "1.0-r1", "hash2", false)
+		store("test/baz", "2.0", "hash3", true)
+
+		// foo is not used in the last two days.
+		foo.LastUsed = time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+		data, err := yaml.Marshal(foo)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(c.EntryDir(foo.Key), EntryFile), data, 0644)).ToNot(HaveOccurred())
+
+		// The filters are combined.
+		removed, err = c.Prune(PruneOpts{
+			OlderThan: 24 * time.Hour,
+			Packages:  []string{"test/bar"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(removed)).To(Equal(0))
+
+		removed, err = c.Prune(PruneOpts{OlderThan: 24 * time.Hour})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(removed)).To(Equal(1))
+		Expect(removed[0].Package).To(Equal("test/foo"))
+
+		removed, err = c.Prune(PruneOpts{Packages: []string{"test/bar-1.0-r1"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(removed)).To(Equal(1))
+		Expect(removed[0].Package).To(Equal("test/bar"))
+
+		removed, err = c.Prune(PruneOpts{MaxSize: 1})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(removed)).To(Equal(1))
+
+		entries, err := c.List()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(entries)).To(Equal(0))
+	})
+
+	It("Exports and imports the entries", func() {
+		foo := store("test/foo", "1.0", "hash1", true)
+		store("test/bar", "1.0", "hash2", false)
+
+		archive := filepath.Join(tmpdir, "cache.tar.gz")
+		exported, err := c.Export(archive, []string{"test/foo"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(exported)).To(Equal(1))
+
+		c2 := NewBuildCache(filepath.Join(tmpdir, "cache2"))
+		imported, err := c2.Import(archive, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(imported)).To(Equal(1))
+		Expect(imported[0].Key).To(Equal(foo.Key))
+
+		e, err := c2.Get(foo.Key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e.Image).To(Equal(foo.Image))
+		Expect(fileHelper.Read(filepath.Join(c2.EntryDir(e.Key), "foo-1.0.package.tar"))).To(Equal("tarball"))
+		Expect(fileHelper.Read(filepath.Join(c2.EntryDir(e.Key), ImageFile))).To(Equal("image"))
+
+		// The entries already present are skipped.
+		imported, err = c2.Import(archive, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(imported)).To(Equal(0))
+
+		imported, err = c2.Import(archive, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(imported)).To(Equal(1))
+	})
+})
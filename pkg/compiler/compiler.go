@@ -259,7 +259,11 @@ func (cs *LuetCompiler) unpackFs(concurrency int, keepPermissions bool, p *compi
 	a := artifact.NewPackageArtifact(tarFile)
 	a.CompressionType = cs.Options.CompressionType
 
-	if err := a.Compress(rootfs, concurrency); err != nil {
+	// Without keepPermissions the files are owned by the user of the build.
+	if err := a.CompressWithOpts(rootfs, concurrency, &helpers.TarOpts{
+		SourceDateEpoch: p.GetSourceDateEpoch(),
+		RootOwnership:   !keepPermissions,
+	}); err != nil {
 		return nil, errors.Wrap(err, "Error met while creating package archive")
 	}
 
@@ -294,7 +298,7 @@ func (cs *LuetCompiler) unpackDelta(concurrency int, keepPermissions bool, p *co
 		ImageName: runnerOpts.ImageName, Destination: rootfs}, keepPermissions); err != nil {
 		return nil, errors.Wrap(err, "Could not extract rootfs")
 	}
-	artifact, err := artifact.ExtractArtifactFromDelta(rootfs, p.Rel(p.GetPackage().GetFingerPrint()+".package.tar"), diffs, concurrency, keepPermissions, p.GetIncludes(), p.GetExcludes(), cs.Options.CompressionType)
+	artifact, err := artifact.ExtractArtifactFromDelta(rootfs, p.Rel(p.GetPackage().GetFingerPrint()+".package.tar"), diffs, concurrency, keepPermissions, p.GetIncludes(), p.GetExcludes(), cs.Options.CompressionType, p.GetSourceDateEpoch())
 	if err != nil {
 		return nil, errors.Wrap(err, "Could not generate deltas")
 	}
@@ -440,7 +444,9 @@ func (cs *LuetCompiler) genArtifact(p *compilerspec.LuetCompilationSpec, builder
 		a := artifact.NewPackageArtifact(fakePackage)
 		a.CompressionType = cs.Options.CompressionType
 
-		if err := a.Compress(rootfs, concurrency); err != nil {
+		if err := a.CompressWithOpts(rootfs, concurrency, &helpers.TarOpts{
+			SourceDateEpoch: p.GetSourceDateEpoch(),
+		}); err != nil {
 			return nil, errors.Wrap(err, "Error met while creating package archive")
 		}
 
@@ -451,6 +457,9 @@ func (cs *LuetCompiler) genArtifact(p *compilerspec.LuetCompilationSpec, builder
 		if err != nil {
 			return a, errors.Wrap(err, "Failed while writing metadata file")
 		}
+		if err := cs.writeSBOM(a); err != nil {
+			return a, errors.Wrap(err, "Failed while writing SBOM file")
+		}
 		Info(pkgTag, "   :white_check_mark: done (empty virtual package)")
 		return a, nil
 	}
@@ -481,6 +490,9 @@ func (cs *LuetCompiler) genArtifact(p *compilerspec.LuetCompilationSpec, builder
 	if err != nil {
 		return a, errors.Wrap(err, "Failed while writing metadata file")
 	}
+	if err := cs.writeSBOM(a); err != nil {
+		return a, errors.Wrap(err, "Failed while writing SBOM file")
+	}
 	Info(pkgTag, "   :white_check_mark: Done")
 
 	return a, nil
@@ -608,6 +620,20 @@ func (cs *LuetCompiler) compileWithImage(image, builderHash string, packageTagHa
 	}
 
 	packageImage := fmt.Sprintf("%s:%s", cs.Options.PushImageRepository, packageTagHash)
+
+	buildCache, cacheEntry := cs.newCacheEntry(p, packageTagHash)
+	if art := cs.restoreFromCache(buildCache, cacheEntry, p, packageImage, generateArtifact); art != nil {
+		// The package image is pushed also when restored from the cache.
+		if cs.Options.Push {
+			Info(":package: "+p.GetPackage().HumanReadableString(),
+				":droplet: pushing image from build cache", packageImage)
+			if err := cs.Backend.Push(backend.Options{ImageName: packageImage}); err != nil {
+				return nil, errors.Wrapf(err, "Could not push image: %s", packageImage)
+			}
+		}
+		return art, nil
+	}
+
 	remoteBuildertaggedImage := fmt.Sprintf("%s:%s", cs.Options.PushImageRepository, builderHash)
 	builderResolved := cs.resolveExistingImageHash(builderHash, p)
 	//generated := false
@@ -662,10 +688,15 @@ func (cs *LuetCompiler) compileWithImage(image, builderHash string, packageTagHa
 	}
 
 	if !generateArtifact {
+		cs.storeInCache(buildCache, cacheEntry, p, packageImage, nil)
 		return &artifact.PackageArtifact{}, nil
 	}
 
-	return cs.genArtifact(p, builderOpts, runnerOpts, concurrency, keepPermissions)
+	a, err := cs.genArtifact(p, builderOpts, runnerOpts, concurrency, keepPermissions)
+	if err == nil {
+		cs.storeInCache(buildCache, cacheEntry, p, packageImage, a)
+	}
+	return a, err
 }
 
 // FromDatabase returns all the available compilation specs from a database. If the minimum flag is returned
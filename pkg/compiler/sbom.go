@@ -0,0 +1,52 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package compiler
+
+import (
+	artifact "github.com/geaaru/luet/pkg/compiler/types/artifact"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/sbom"
+
+	"github.com/pkg/errors"
+)
+
+// SBOMFile returns the path of the SBOM of the artifact.
+func SBOMFile(a *artifact.PackageArtifact, format string) string {
+	return a.CompileSpec.Rel(
+		a.CompileSpec.GetPackage().GetFingerPrint() + sbom.FileSuffix(format))
+}
+
+// writeSBOM writes the bill of materials of the artifact next
+// to the metadata file.
+func (cs *LuetCompiler) writeSBOM(a *artifact.PackageArtifact) error {
+	format := cs.Options.SBOMFormat
+	if format == "" || format == sbom.FormatNone ||
+		a.CompileSpec == nil || a.CompileSpec.GetPackage() == nil {
+		return nil
+	}
+
+	var p pkg.Package = a.CompileSpec.GetPackage()
+	if a.Runtime != nil {
+		p = a.Runtime
+	}
+
+	doc := sbom.NewDocument(p.HumanReadableString())
+	sp := sbom.NewPackage(p, a.Files)
+	sp.Sha256 = a.Checksums[string(artifact.SHA256)]
+
+	checksums, err := a.FileChecksums()
+	if err != nil {
+		return errors.Wrap(err, "Error on generate the checksums of the files of "+a.Path)
+	}
+	for _, f := range sp.Files {
+		if c, ok := checksums[f.Path]; ok {
+			f.Sha1 = c[string(artifact.SHA1)]
+			f.Sha256 = c[string(artifact.SHA256)]
+		}
+	}
+	doc.AddPackage(sp)
+
+	return doc.WriteFile(SBOMFile(a, format), format)
+}
@@ -21,6 +21,7 @@ import (
 	"bufio"
 	"bytes"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -36,6 +37,7 @@ import (
 	//"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	backend "github.com/geaaru/luet/pkg/compiler/backend"
 	compression "github.com/geaaru/luet/pkg/compiler/types/compression"
@@ -243,6 +245,15 @@ func (a *PackageArtifact) GenerateFinalImage(imageName string, b ImageBuilder, k
 // It accepts a source path, which is the content to be archived/compressed
 // and a concurrency parameter.
 func (a *PackageArtifact) Compress(src string, concurrency int) error {
+	return a.CompressWithOpts(src, concurrency, &helpers.TarOpts{
+		SourceDateEpoch: helpers.SourceDateEpoch(),
+	})
+}
+
+// CompressWithOpts creates the reproducible tarball of the artifact
+// from the src directory. The compression settings are fixed to
+// generate the same archive from the same tarball.
+func (a *PackageArtifact) CompressWithOpts(src string, concurrency int, opts *helpers.TarOpts) error {
 	var tarFile string
 
 	cleanup := func() {
@@ -253,7 +264,7 @@ func (a *PackageArtifact) Compress(src string, concurrency int) error {
 	switch a.CompressionType {
 
 	case compression.Zstandard:
-		err := helpers.Tar(src, a.Path)
+		err := helpers.TarReproducible(src, a.Path, opts)
 		if err != nil {
 			return err
 		}
@@ -271,8 +282,12 @@ func (a *PackageArtifact) Compress(src string, concurrency int) error {
 		if err != nil {
 			return err
 		}
+		defer dst.Close()
 
-		enc, err := zstd.NewWriter(dst)
+		enc, err := zstd.NewWriter(dst,
+			zstd.WithEncoderLevel(zstd.SpeedDefault),
+			zstd.WithEncoderCRC(true),
+		)
 		if err != nil {
 			return err
 		}
@@ -291,7 +306,7 @@ func (a *PackageArtifact) Compress(src string, concurrency int) error {
 		a.Path = zstdFile
 		return nil
 	case compression.GZip:
-		err := helpers.Tar(src, a.Path)
+		err := helpers.TarReproducible(src, a.Path, opts)
 		if err != nil {
 			return err
 		}
@@ -310,7 +325,12 @@ func (a *PackageArtifact) Compress(src string, concurrency int) error {
 			return err
 		}
 		// Create gzip writer.
-		w := gzip.NewWriter(dst)
+		// The gzip header is without name and mtime and the blocks
+		// size is fixed: the output doesn't depend on the concurrency.
+		w, err := gzip.NewWriterLevel(dst, gzip.DefaultCompression)
+		if err != nil {
+			return err
+		}
 		w.SetConcurrency(1<<20, concurrency)
 		defer w.Close()
 		defer dst.Close()
@@ -330,7 +350,7 @@ func (a *PackageArtifact) Compress(src string, concurrency int) error {
 
 	// Defaults to tar only (covers when "none" is supplied)
 	default:
-		return helpers.Tar(src, a.getCompressedName())
+		return helpers.TarReproducible(src, a.getCompressedName(), opts)
 	}
 }
 
@@ -563,13 +583,13 @@ func (a *PackageArtifact) Unpack(dst string, enableSubsets bool) error {
 	}
 }
 
-// FileList generates the list of file of a package from the local archive
-func (a *PackageArtifact) FileList() ([]string, error) {
+// walkArchive calls the function for every entry of the local archive.
+func (a *PackageArtifact) walkArchive(f func(*tar.Header, io.Reader) error) error {
 	var tr *tar.Reader
 	archiveDir, err := LuetCfg.GetSystem().TempDir(
 		fmt.Sprintf("%s", filepath.Base(a.Path)))
 	if err != nil {
-		return []string{}, err
+		return err
 	}
 	cleandir := func() {
 		os.RemoveAll(archiveDir)
@@ -581,20 +601,20 @@ func (a *PackageArtifact) FileList() ([]string, error) {
 		archive, err := os.Create(filepath.Join(archiveDir,
 			filepath.Base(a.Path)+".uncompressed"))
 		if err != nil {
-			return []string{}, err
+			return err
 		}
 		defer archive.Close()
 
 		original, err := os.Open(a.Path)
 		if err != nil {
-			return []string{}, errors.Wrap(err, "Cannot open "+a.Path)
+			return errors.Wrap(err, "Cannot open "+a.Path)
 		}
 		defer original.Close()
 
 		bufferedReader := bufio.NewReader(original)
 		r, err := zstd.NewReader(bufferedReader)
 		if err != nil {
-			return []string{}, err
+			return err
 		}
 		defer r.Close()
 		tr = tar.NewReader(r)
@@ -603,20 +623,20 @@ func (a *PackageArtifact) FileList() ([]string, error) {
 		archive, err := os.Create(filepath.Join(archiveDir,
 			filepath.Base(a.Path)+".uncompressed"))
 		if err != nil {
-			return []string{}, err
+			return err
 		}
 		defer archive.Close()
 
 		original, err := os.Open(a.Path)
 		if err != nil {
-			return []string{}, errors.Wrap(err, "Cannot open "+a.Path)
+			return errors.Wrap(err, "Cannot open "+a.Path)
 		}
 		defer original.Close()
 
 		bufferedReader := bufio.NewReader(original)
 		r, err := gzip.NewReader(bufferedReader)
 		if err != nil {
-			return []string{}, err
+			return err
 		}
 		defer r.Close()
 		tr = tar.NewReader(r)
@@ -625,14 +645,13 @@ func (a *PackageArtifact) FileList() ([]string, error) {
 	default:
 		tarFile, err := os.Open(a.Path)
 		if err != nil {
-			return []string{}, errors.Wrap(err, "Could not open package archive")
+			return errors.Wrap(err, "Could not open package archive")
 		}
 		defer tarFile.Close()
 		tr = tar.NewReader(tarFile)
 
 	}
 
-	var files []string
 	// untar each segment
 	for {
 		hdr, err := tr.Next()
@@ -640,22 +659,61 @@ func (a *PackageArtifact) FileList() ([]string, error) {
 			break
 		}
 		if err != nil {
-			return []string{}, err
+			return err
 		}
+		if err := f(hdr, tr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FileList generates the list of file of a package from the local archive
+func (a *PackageArtifact) FileList() ([]string, error) {
+	var files []string
+	err := a.walkArchive(func(hdr *tar.Header, r io.Reader) error {
 		// determine proper file path info
 		finfo := hdr.FileInfo()
-		fileName := hdr.Name
 		if finfo.Mode().IsDir() {
-			continue
+			return nil
 		}
-		files = append(files, fileName)
-
-		// if a dir, create it, then go to next segment
+		files = append(files, hdr.Name)
+		return nil
+	})
+	if err != nil {
+		return []string{}, err
 	}
 
 	return files, nil
 }
 
+// FileChecksums returns the checksums of the regular files of the
+// local archive.
+func (a *PackageArtifact) FileChecksums() (map[string]Checksums, error) {
+	ans := make(map[string]Checksums, 0)
+	err := a.walkArchive(func(hdr *tar.Header, r io.Reader) error {
+		if !hdr.FileInfo().Mode().IsRegular() {
+			return nil
+		}
+		h1 := sha1.New()
+		h256 := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(h1, h256), r); err != nil {
+			return errors.Wrap(err, "Error on read "+hdr.Name)
+		}
+		ans[hdr.Name] = Checksums{
+			string(SHA1):   fmt.Sprintf("%x", h1.Sum(nil)),
+			string(SHA256): fmt.Sprintf("%x", h256.Sum(nil)),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
 type CopyJob struct {
 	Src, Dst string
 	Artifact string
@@ -705,7 +763,7 @@ type ArtifactLayer struct {
 }
 
 // ExtractArtifactFromDelta extracts deltas from ArtifactLayer from an image in tar format
-func ExtractArtifactFromDelta(src, dst string, layers []ArtifactLayer, concurrency int, keepPerms bool, includes []string, excludes []string, t compression.Implementation) (*PackageArtifact, error) {
+func ExtractArtifactFromDelta(src, dst string, layers []ArtifactLayer, concurrency int, keepPerms bool, includes []string, excludes []string, t compression.Implementation, sourceDateEpoch *time.Time) (*PackageArtifact, error) {
 
 	archive, err := LuetCfg.GetSystem().TempDir("archive")
 	if err != nil {
@@ -828,7 +886,11 @@ func ExtractArtifactFromDelta(src, dst string, layers []ArtifactLayer, concurren
 
 	a := NewPackageArtifact(dst)
 	a.CompressionType = t
-	err = a.Compress(archive, concurrency)
+	// Without keepPerms the files are owned by the user of the build.
+	err = a.CompressWithOpts(archive, concurrency, &helpers.TarOpts{
+		SourceDateEpoch: sourceDateEpoch,
+		RootOwnership:   !keepPerms,
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "Error met while creating package archive")
 	}
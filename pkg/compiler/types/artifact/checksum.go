@@ -33,6 +33,7 @@ type HashImplementation string
 
 const (
 	SHA256 HashImplementation = "sha256"
+	SHA1   HashImplementation = "sha1"
 )
 
 type Checksums map[string]string
@@ -0,0 +1,211 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package artifact
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	compression "github.com/geaaru/luet/pkg/compiler/types/compression"
+
+	zstd "github.com/klauspost/compress/zstd"
+	gzip "github.com/klauspost/pgzip"
+	"github.com/pkg/errors"
+)
+
+// ArchiveEntry describes an entry of the tarball of an artifact.
+type ArchiveEntry struct {
+	Name     string
+	Mode     os.FileMode
+	Uid      int
+	Gid      int
+	ModTime  time.Time
+	Size     int64
+	Linkname string
+	Sha256   string
+	Xattrs   map[string]string
+}
+
+// ArchiveDiff describes the differences of an entry between
+// two artifacts.
+type ArchiveDiff struct {
+	Name        string
+	Differences []string
+}
+
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+func (z zstdReadCloser) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+// archiveReader returns the reader of the uncompressed tarball.
+func (a *PackageArtifact) archiveReader() (io.ReadCloser, io.Closer, error) {
+	f, err := os.Open(a.Path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Cannot open "+a.Path)
+	}
+
+	switch a.CompressionType {
+	case compression.Zstandard:
+		r, err := zstd.NewReader(bufio.NewReader(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return zstdReadCloser{r}, f, nil
+	case compression.GZip:
+		r, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return r, f, nil
+	default:
+		return f, f, nil
+	}
+}
+
+// ArchiveEntries returns the entries of the tarball of the artifact
+// in the order of the archive.
+func (a *PackageArtifact) ArchiveEntries() ([]*ArchiveEntry, error) {
+	r, f, err := a.archiveReader()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	defer r.Close()
+
+	ans := []*ArchiveEntry{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Error on read "+a.Path)
+		}
+
+		e := &ArchiveEntry{
+			Name:     hdr.Name,
+			Mode:     hdr.FileInfo().Mode(),
+			Uid:      hdr.Uid,
+			Gid:      hdr.Gid,
+			ModTime:  hdr.ModTime,
+			Size:     hdr.Size,
+			Linkname: hdr.Linkname,
+			Xattrs:   map[string]string{},
+		}
+		for k, v := range hdr.PAXRecords {
+			if strings.HasPrefix(k, "SCHILY.xattr.") {
+				e.Xattrs[strings.TrimPrefix(k, "SCHILY.xattr.")] = v
+			}
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			h := sha256.New()
+			if _, err := io.Copy(h, tr); err != nil {
+				return nil, errors.Wrap(err, "Error on read "+hdr.Name)
+			}
+			e.Sha256 = hex.EncodeToString(h.Sum(nil))
+		}
+
+		ans = append(ans, e)
+	}
+
+	return ans, nil
+}
+
+// Diff returns the differences between the entry and the entry
+// in input with the same name.
+func (e *ArchiveEntry) Diff(o *ArchiveEntry) []string {
+	ans := []string{}
+	if e.Mode != o.Mode {
+		ans = append(ans, fmt.Sprintf("mode %s != %s", e.Mode, o.Mode))
+	}
+	if e.Uid != o.Uid || e.Gid != o.Gid {
+		ans = append(ans, fmt.Sprintf("owner %d:%d != %d:%d", e.Uid, e.Gid, o.Uid, o.Gid))
+	}
+	if !e.ModTime.Equal(o.ModTime) {
+		ans = append(ans, fmt.Sprintf("mtime %s != %s",
+			e.ModTime.UTC().Format(time.RFC3339), o.ModTime.UTC().Format(time.RFC3339)))
+	}
+	if e.Linkname != o.Linkname {
+		ans = append(ans, fmt.Sprintf("link %s != %s", e.Linkname, o.Linkname))
+	}
+	if e.Size != o.Size {
+		ans = append(ans, fmt.Sprintf("size %d != %d", e.Size, o.Size))
+	}
+	if e.Sha256 != o.Sha256 {
+		ans = append(ans, "content")
+	}
+	if fmt.Sprint(e.Xattrs) != fmt.Sprint(o.Xattrs) {
+		ans = append(ans, "extended attributes")
+	}
+	return ans
+}
+
+// CompareArtifacts returns the differences between the tarballs of two
+// artifacts sorted by entry name. The artifacts are identical when the
+// list is empty and the checksums are equal. With no differences and
+// different checksums the entries are stored with a different order
+// or compression.
+func CompareArtifacts(a, b *PackageArtifact) ([]*ArchiveDiff, error) {
+	aEntries, err := a.ArchiveEntries()
+	if err != nil {
+		return nil, err
+	}
+	bEntries, err := b.ArchiveEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	bMap := make(map[string]*ArchiveEntry, len(bEntries))
+	for _, e := range bEntries {
+		bMap[e.Name] = e
+	}
+
+	ans := []*ArchiveDiff{}
+	for _, e := range aEntries {
+		o, ok := bMap[e.Name]
+		if !ok {
+			ans = append(ans, &ArchiveDiff{
+				Name:        e.Name,
+				Differences: []string{"only in " + a.Path},
+			})
+			continue
+		}
+		delete(bMap, e.Name)
+
+		if diffs := e.Diff(o); len(diffs) > 0 {
+			ans = append(ans, &ArchiveDiff{Name: e.Name, Differences: diffs})
+		}
+	}
+
+	for name := range bMap {
+		ans = append(ans, &ArchiveDiff{
+			Name:        name,
+			Differences: []string{"only in " + b.Path},
+		})
+	}
+
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].Name < ans[j].Name
+	})
+
+	return ans, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package artifact_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/geaaru/luet/pkg/compiler/types/artifact"
+	compression "github.com/geaaru/luet/pkg/compiler/types/compression"
+	"github.com/geaaru/luet/pkg/helpers"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reproducible", func() {
+	Context("Compress", func() {
+
+		It("Generates the same artifact and reports the differences", func() {
+			src, err := ioutil.TempDir("", "reproducible-src")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(src)
+
+			out, err := ioutil.TempDir("", "reproducible-out")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(out)
+
+			Expect(os.MkdirAll(filepath.Join(src, "usr", "bin"), 0755)).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(filepath.Join(src, "usr", "bin", "foo"), []byte("foo"), 0755)).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(filepath.Join(src, "bar"), []byte("bar"), 0644)).ToNot(HaveOccurred())
+
+			epoch := time.Unix(1600000000, 0)
+			opts := &helpers.TarOpts{SourceDateEpoch: &epoch}
+
+			compress := func(name string) *PackageArtifact {
+				a := NewPackageArtifact(filepath.Join(out, name))
+				a.CompressionType = compression.GZip
+				Expect(a.CompressWithOpts(src, 2, opts)).ToNot(HaveOccurred())
+				Expect(a.Hash()).ToNot(HaveOccurred())
+				return a
+			}
+
+			a1 := compress("1.tar")
+
+			now := time.Now().Add(time.Hour)
+			Expect(os.Chtimes(filepath.Join(src, "bar"), now, now)).ToNot(HaveOccurred())
+
+			a2 := compress("2.tar")
+			Expect(a1.Path).To(Equal(filepath.Join(out, "1.tar.gz")))
+			Expect(a1.Checksums).To(Equal(a2.Checksums))
+
+			diffs, err := CompareArtifacts(a1, a2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diffs).To(BeEmpty())
+
+			Expect(ioutil.WriteFile(filepath.Join(src, "usr", "bin", "foo"), []byte("foo2"), 0700)).ToNot(HaveOccurred())
+			Expect(os.Remove(filepath.Join(src, "bar"))).ToNot(HaveOccurred())
+
+			a3 := compress("3.tar")
+			Expect(a1.Checksums).ToNot(Equal(a3.Checksums))
+
+			diffs, err = CompareArtifacts(a1, a3)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diffs).To(HaveLen(2))
+			Expect(diffs[0].Name).To(Equal("bar"))
+			Expect(diffs[0].Differences).To(Equal([]string{"only in " + a1.Path}))
+			Expect(diffs[1].Name).To(Equal("usr/bin/foo"))
+			Expect(diffs[1].Differences).To(ContainElements("size 3 != 4", "content"))
+		})
+	})
+})
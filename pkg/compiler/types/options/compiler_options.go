@@ -45,6 +45,13 @@ type Compiler struct {
 
 	// TemplatesFolder. should default to tree/templates
 	TemplatesFolder []string
+
+	// Directory of the build cache. Empty disables the cache.
+	CacheDir string
+
+	// Format of the SBOM written next to the artifacts
+	// metadata. Empty or none disables the SBOM.
+	SBOMFormat string
 }
 
 func NewDefaultCompiler() *Compiler {
@@ -89,6 +96,20 @@ func WithBackendType(r string) func(cfg *Compiler) error {
 	}
 }
 
+func WithCacheDir(r string) func(cfg *Compiler) error {
+	return func(cfg *Compiler) error {
+		cfg.CacheDir = r
+		return nil
+	}
+}
+
+func WithSBOMFormat(f string) func(cfg *Compiler) error {
+	return func(cfg *Compiler) error {
+		cfg.SBOMFormat = f
+		return nil
+	}
+}
+
 func WithTemplateFolder(r []string) func(cfg *Compiler) error {
 	return func(cfg *Compiler) error {
 		cfg.TemplatesFolder = r
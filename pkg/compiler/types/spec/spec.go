@@ -20,8 +20,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"time"
 
 	options "github.com/geaaru/luet/pkg/compiler/types/options"
+	"github.com/geaaru/luet/pkg/helpers"
 	"github.com/mitchellh/hashstructure/v2"
 
 	pkg "github.com/geaaru/luet/pkg/package"
@@ -118,6 +120,10 @@ type LuetCompilationSpec struct {
 	Copy []CopyField `json:"copy" yaml:"copy"`
 
 	RequiresFinalImages bool `json:"requires_final_images" yaml:"requires_final_images"`
+
+	// Unix time used to clamp the mtime of the files of the artifact.
+	// It overrides the SOURCE_DATE_EPOCH environment variable.
+	SourceDateEpoch int64 `json:"source_date_epoch,omitempty" yaml:"source_date_epoch,omitempty"`
 }
 
 // Signature is a portion of the spec that yields a signature for the hash
@@ -245,6 +251,16 @@ func (cs *LuetCompilationSpec) GetRetrieve() []string {
 	return cs.Retrieve
 }
 
+// GetSourceDateEpoch returns the time used to clamp the mtime of the
+// files of the artifact or nil if the files are archived as they are.
+func (cs *LuetCompilationSpec) GetSourceDateEpoch() *time.Time {
+	if cs.SourceDateEpoch > 0 {
+		ans := time.Unix(cs.SourceDateEpoch, 0).UTC()
+		return &ans
+	}
+	return helpers.SourceDateEpoch()
+}
+
 // IsVirtual returns true if the spec is virtual.
 // A spec is virtual if the package is empty, and it has no image source to unpack from.
 func (cs *LuetCompilationSpec) IsVirtual() bool {
@@ -65,6 +65,7 @@ type LuetGeneralConfig struct {
 
 	ClientTimeout    int `yaml:"client_timeout,omitempty" json:"client_timeout,omitempty" mapstructure:"client_timeout,omitempty"`
 	ClientMultiFetch int `yaml:"client_multifetch,omitempty" json:"client_multifetch,omitempty" mapstructure:"client_multifetch,omitempty"`
+	ClientRetries    int `yaml:"client_retries,omitempty" json:"client_retries,omitempty" mapstructure:"client_retries,omitempty"`
 
 	OverwriteDirPerms bool `yaml:"overwrite_dir_perms,omitempty" json:"overwrite_dir_perms,omitempty" mapstructure:"overwrite_dir_perms,omitempty"`
 }
@@ -75,6 +76,9 @@ type LuetSolverOptions struct {
 	Discount       float32 `yaml:"discount,omitempty" json:"discount,omitempty" mapstructure:"discount"`
 	MaxAttempts    int     `yaml:"max_attempts,omitempty" json:"max_attempts,omitempty" mapstructure:"max_attempts"`
 	Implementation string  `yaml:"implementation,omitempty" json:"implementation,omitempty" mapstructure:"implementation"`
+	// Number of candidates evaluated by the backtracking search
+	// of the solver before to give up.
+	BacktrackMaxSteps int `yaml:"backtrack_max_steps,omitempty" json:"backtrack_max_steps,omitempty" mapstructure:"backtrack_max_steps"`
 }
 
 func (opts *LuetSolverOptions) CompactString() string {
@@ -89,6 +93,7 @@ type LuetSystemConfig struct {
 	Rootfs         string `yaml:"rootfs" json:"rootfs" mapstructure:"rootfs"`
 	PkgsCachePath  string `yaml:"pkgs_cache_path" json:"pkgs_cache_path" mapstructure:"pkgs_cache_path"`
 	TmpDirBase     string `yaml:"tmpdir_base" json:"tmpdir_base" mapstructure:"tmpdir_base"`
+	BuildCachePath string `yaml:"build_cache_path,omitempty" json:"build_cache_path,omitempty" mapstructure:"build_cache_path"`
 }
 
 func (s *LuetSystemConfig) SetRootFS(path string) error {
@@ -243,6 +248,7 @@ type LuetConfig struct {
 	TarFlows LuetTarflowsConfig `yaml:"tar_flows,omitempty" mapstructure:"tar_flows,omitempty"`
 
 	RepositoriesConfDir  []string         `yaml:"repos_confdir,omitempty" mapstructure:"repos_confdir"`
+	RepositoriesKeyring  []string         `yaml:"repos_keyringdir,omitempty" mapstructure:"repos_keyringdir"`
 	ConfigProtectConfDir []string         `yaml:"config_protect_confdir,omitempty" mapstructure:"config_protect_confdir"`
 	PackagesMaskDir      []string         `yaml:"packages_maskdir,omitempty" mapstructure:"packages_maskdir,omitempty"`
 	ConfigProtectSkip    bool             `yaml:"config_protect_skip,omitempty" mapstructure:"config_protect_skip"`
@@ -262,6 +268,12 @@ type LuetConfig struct {
 	SubsetsDefinitions *LuetSubsetsDefinition            `yaml:"-" mapstructure:"-"`
 	SubsetsPkgsDefMap  map[string]*LuetSubsetsDefinition `yaml:"-" mapstructure:"-"`
 	SubsetsCatDefMap   map[string]*LuetSubsetsDefinition `yaml:"-" mapstructure:"-"`
+
+	// Directories of the transaction hooks.
+	HooksDir []string `yaml:"hooks_dir,omitempty" mapstructure:"hooks_dir"`
+
+	// File with the packages held on upgrade.
+	HoldsFile string `yaml:"holds_file,omitempty" mapstructure:"holds_file"`
 }
 
 type LuetTarflowsConfig struct {
@@ -321,6 +333,7 @@ func GenDefault(viper *v.Viper) {
 	viper.SetDefault("general.overwrite_dir_perms", false)
 	viper.SetDefault("general.client_timeout", 3600)
 	viper.SetDefault("general.client_multifetch", 2)
+	viper.SetDefault("general.client_retries", 3)
 
 	u, err := user.Current()
 	// os/user doesn't work in from scratch environments
@@ -337,10 +350,13 @@ func GenDefault(viper *v.Viper) {
 	viper.SetDefault("system.pkgs_cache_path", "packages")
 
 	viper.SetDefault("repos_confdir", []string{"/etc/luet/repos.conf.d"})
+	viper.SetDefault("repos_keyringdir", []string{"/etc/luet/repos.keyring.d"})
 	viper.SetDefault("config_protect_confdir", []string{"/etc/luet/config.protect.d"})
 	viper.SetDefault("packages_maskdir", []string{"/etc/luet/mask.d"})
 	viper.SetDefault("subsets_confdir", []string{"/etc/luet/subsets.conf.d"})
 	viper.SetDefault("subsets_defdir", []string{"/etc/luet/subsets.def.d"})
+	viper.SetDefault("hooks_dir", []string{"/etc/luet/hooks.d"})
+	viper.SetDefault("holds_file", "/etc/luet/holds.yml")
 	viper.SetDefault("config_protect_skip", false)
 	// TODO: Set default to false when we are ready for migration.
 	viper.SetDefault("config_from_host", true)
@@ -352,6 +368,7 @@ func GenDefault(viper *v.Viper) {
 	viper.SetDefault("solver.rate", 0.7)
 	viper.SetDefault("solver.discount", 1.0)
 	viper.SetDefault("solver.max_attempts", 9000)
+	viper.SetDefault("solver.backtrack_max_steps", 10000)
 
 	viper.SetDefault("tar_flows.mutex4dir", true)
 	viper.SetDefault("tar_flows.max_openfiles", 100)
@@ -477,6 +494,13 @@ func (c *LuetConfig) GetSystemRepository(name string) (*LuetRepository, error) {
 	return ans, nil
 }
 
+func (c *LuetGeneralConfig) GetClientRetries() int {
+	if c.ClientRetries <= 0 {
+		return 1
+	}
+	return c.ClientRetries
+}
+
 func (c *LuetGeneralConfig) GetSpinnerMs() time.Duration {
 	duration, err := time.ParseDuration(fmt.Sprintf("%dms", c.SpinnerMs))
 	if err != nil {
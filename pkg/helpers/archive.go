@@ -17,8 +17,13 @@
 package helpers
 
 import (
+	"archive/tar"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
 	. "github.com/geaaru/luet/pkg/config"
 
@@ -52,6 +57,131 @@ func Tar(src, dest string) error {
 	return err
 }
 
+// TarOpts are the options of the reproducible tarballs.
+type TarOpts struct {
+	// The mtime of the entries newer than SourceDateEpoch is
+	// clamped to this time.
+	SourceDateEpoch *time.Time
+	// Store all the entries owned by root. It's used when the
+	// ownership of the files isn't the one of the build.
+	RootOwnership bool
+}
+
+// SourceDateEpoch returns the time defined by the SOURCE_DATE_EPOCH
+// environment variable or nil if it's not defined or not valid.
+func SourceDateEpoch() *time.Time {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return nil
+	}
+	sec, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return nil
+	}
+	ans := time.Unix(sec, 0).UTC()
+	return &ans
+}
+
+type tarInode struct {
+	dev, ino uint64
+}
+
+// TarReproducible creates the tarball dest with the content of src.
+// Unlike Tar, the entries are sorted by path, the ownership is stored
+// only with the numeric ids and the mtimes are clamped to the
+// SourceDateEpoch of the options. The same content produces always
+// the same tarball.
+func TarReproducible(src, dest string, opts *TarOpts) error {
+	if opts == nil {
+		opts = &TarOpts{}
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	tw := tar.NewWriter(out)
+	// The first path of every hardlinked inode.
+	inodes := make(map[tarInode]string)
+
+	// filepath.Walk visits the files in lexical order.
+	err = filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if name == "." || fi.Mode()&os.ModeSocket != 0 {
+			return nil
+		}
+
+		link := ""
+		if fi.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		hdr, err := archive.FileInfoHeader(name, fi, link)
+		if err != nil {
+			return err
+		}
+		if err := archive.ReadSecurityXattrToTarHeader(path, hdr); err != nil {
+			return err
+		}
+
+		hdr.Uname = ""
+		hdr.Gname = ""
+		if opts.RootOwnership {
+			hdr.Uid = 0
+			hdr.Gid = 0
+		}
+		if opts.SourceDateEpoch != nil && hdr.ModTime.After(*opts.SourceDateEpoch) {
+			hdr.ModTime = *opts.SourceDateEpoch
+		}
+
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok && !fi.IsDir() && st.Nlink > 1 {
+			inode := tarInode{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+			if first, ok := inodes[inode]; ok {
+				hdr.Typeflag = tar.TypeLink
+				hdr.Linkname = first
+				hdr.Size = 0
+			} else {
+				inodes[inode] = hdr.Name
+			}
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if hdr.Typeflag == tar.TypeReg && hdr.Size > 0 {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(tw, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
 func UntarProtect(src, dst string, sameOwner, overwriteDirPerms bool, protectedFiles []string, modifier tarf.TarFileHandlerFunc) error {
 
 	spec := tarf_specs.NewSpecFile()
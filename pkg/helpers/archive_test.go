@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	tarf "github.com/geaaru/tar-formers/pkg/executor"
 	tarf_specs "github.com/geaaru/tar-formers/pkg/specs"
@@ -129,4 +130,65 @@ var _ = Describe("Helpers Archive", func() {
 			Expect(fileHelper.Exists(filepath.Join(targetDir, "._cfg0001_file-0"))).Should(Equal(true))
 		})
 	})
+
+	Context("Tar Reproducible", func() {
+
+		It("Generates the same tarball with different mtimes", func() {
+
+			archiveSourceDir, err := ioutil.TempDir("", "archive-source")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(archiveSourceDir)
+
+			_, err = prepareUntarSourceDirectory(10, archiveSourceDir, true)
+			Expect(err).ToNot(HaveOccurred())
+
+			tarballDir, err := ioutil.TempDir("", "tarball")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(tarballDir)
+
+			epoch := time.Unix(1600000000, 0)
+			opts := &TarOpts{SourceDateEpoch: &epoch, RootOwnership: true}
+
+			err = TarReproducible(archiveSourceDir, filepath.Join(tarballDir, "1.tar"), opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			now := time.Now()
+			for n := 0; n < 10; n++ {
+				err = os.Chtimes(filepath.Join(archiveSourceDir, fmt.Sprintf("file-%d", n)),
+					now, now.Add(time.Duration(n)*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			err = TarReproducible(archiveSourceDir, filepath.Join(tarballDir, "2.tar"), opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			t1, err := ioutil.ReadFile(filepath.Join(tarballDir, "1.tar"))
+			Expect(err).ToNot(HaveOccurred())
+			t2, err := ioutil.ReadFile(filepath.Join(tarballDir, "2.tar"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t1).To(Equal(t2))
+
+			names := []string{}
+			links := 0
+			tr := tar.NewReader(bytes.NewReader(t1))
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hdr.ModTime.Unix()).To(Equal(epoch.Unix()))
+				Expect(hdr.Uid).To(Equal(0))
+				Expect(hdr.Uname).To(Equal(""))
+				if hdr.Typeflag == tar.TypeLink {
+					links++
+				}
+				names = append(names, hdr.Name)
+			}
+			Expect(names).To(HaveLen(20))
+			Expect(names[0]).To(Equal("file-0"))
+			Expect(names[1]).To(Equal("file-0-link"))
+			Expect(links).To(Equal(10))
+		})
+	})
 })
@@ -22,3 +22,44 @@ const (
 	ConfigProtectAnnnotation AnnotationKey = "config_protect"
 	SubsetsAnnotation        AnnotationKey = "subsets"
 )
+
+const (
+	// InstallReasonAnnotation is the annotation of the installed
+	// packages that tracks if the package is been requested by the
+	// user or installed as dependency of other packages.
+	InstallReasonAnnotation AnnotationKey = "install_reason"
+
+	InstallReasonExplicit = "explicit"
+	InstallReasonDep      = "dep"
+)
+
+// GetInstallReason returns the install reason of an installed package
+// or an empty string for the packages installed by older releases.
+func (p *DefaultPackage) GetInstallReason() string {
+	if v, ok := p.GetAnnotationByKey(string(InstallReasonAnnotation)).(string); ok {
+		return v
+	}
+	return ""
+}
+
+func (p *DefaultPackage) HasInstallReason() bool {
+	return p.GetInstallReason() != ""
+}
+
+// IsExplicit returns true if the package is been installed explicitly
+// by the user.
+func (p *DefaultPackage) IsExplicit() bool {
+	return p.GetInstallReason() == InstallReasonExplicit
+}
+
+func (p *DefaultPackage) SetInstallReason(reason string) {
+	p.AddAnnotation(string(InstallReasonAnnotation), reason)
+}
+
+func (p *DefaultPackage) SetExplicit(explicit bool) {
+	if explicit {
+		p.SetInstallReason(InstallReasonExplicit)
+	} else {
+		p.SetInstallReason(InstallReasonDep)
+	}
+}
@@ -15,6 +15,8 @@
 
 package pkg
 
+import "os"
+
 // Database is a merely simple in-memory db.
 // FIXME: Use a proper structure or delegate to third-party
 type PackageDatabase interface {
@@ -55,6 +57,11 @@ type PackageSet interface {
 	SetPackageFinalizer(*PackageFinalizer) error
 	RemovePackageFinalizer(Package) error
 
+	// Files integrity metadata
+	GetPackageFilesMeta(Package) (*PackageFilesMeta, error)
+	SetPackageFilesMeta(*PackageFilesMeta) error
+	RemovePackageFilesMeta(Package) error
+
 	FindPackageCandidate(p Package) (Package, error)
 	FindPackageLabel(labelKey string) (Packages, error)
 	FindPackageLabelMatch(pattern string) (Packages, error)
@@ -70,6 +77,28 @@ type PackageFile struct {
 	Files              []string
 }
 
+// FileMeta contains the metadata of an installed file used
+// to verify the integrity of the rootfs.
+type FileMeta struct {
+	Path string `json:"path" yaml:"path"`
+	// Sha256 of the content of the regular files.
+	Sha256 string `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+	// Sha1 of the content of the regular files used by the SBOM.
+	Sha1 string      `json:"sha1,omitempty" yaml:"sha1,omitempty"`
+	Mode os.FileMode `json:"mode" yaml:"mode"`
+	Uid  int         `json:"uid" yaml:"uid"`
+	Gid  int         `json:"gid" yaml:"gid"`
+	Size int64       `json:"size" yaml:"size"`
+	// Target of the symlinks.
+	Link string `json:"link,omitempty" yaml:"link,omitempty"`
+}
+
+type PackageFilesMeta struct {
+	ID                 int         `storm:"id,increment"` // primary key with auto increment
+	PackageFingerprint string      `storm:"unique"`
+	Files              []*FileMeta `json:"files" yaml:"files"`
+}
+
 type PackageFinalizer struct {
 	ID                 int      `storm:"id,increment"` // primary key with auto increment
 	PackageFingerprint string   `storm:"unique"`
@@ -35,6 +35,7 @@ import (
 const (
 	boltdbCollFiles     = "files"
 	boltdbCollFinalizer = "finalizers"
+	boltdbCollFilesMeta = "filesmeta"
 )
 
 type BoltDatabase struct {
@@ -92,6 +93,15 @@ func (db *BoltDatabase) RebuildIndexes() error {
 	files := bolt.From(boltdbCollFiles)
 	files.ReIndex(&PackageFile{})
 
+	pfm := &PackageFilesMeta{
+		PackageFingerprint: p.GetFingerPrint(),
+		Files:              []*FileMeta{},
+	}
+	filesMeta := bolt.From(boltdbCollFilesMeta)
+	filesMeta.Save(pfm)
+	filesMeta.DeleteStruct(pfm)
+	filesMeta.ReIndex(&PackageFilesMeta{})
+
 	return nil
 }
 
@@ -251,8 +261,8 @@ func (db *BoltDatabase) GetAllPackages(packages chan Package) error {
 		return err
 	}
 
-	for _, r := range packs {
-		packages <- &r
+	for i := range packs {
+		packages <- &packs[i]
 	}
 
 	return nil
@@ -386,6 +396,50 @@ func (db *BoltDatabase) RemovePackageFinalizer(p Package) error {
 	return finalizer.DeleteStruct(&pf)
 }
 
+func (db *BoltDatabase) GetPackageFilesMeta(p Package) (*PackageFilesMeta, error) {
+	bolt, err := db.open()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error opening boltdb "+db.Path)
+	}
+
+	filesMeta := bolt.From(boltdbCollFilesMeta)
+	var pfm PackageFilesMeta
+	err = filesMeta.One("PackageFingerprint", p.GetFingerPrint(), &pfm)
+	if err != nil {
+		if err.Error() == "not found" {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "While finding files metadata")
+	}
+	return &pfm, nil
+}
+func (db *BoltDatabase) SetPackageFilesMeta(p *PackageFilesMeta) error {
+	bolt, err := db.open()
+	if err != nil {
+		return errors.Wrap(err, "Error opening boltdb "+db.Path)
+	}
+
+	filesMeta := bolt.From(boltdbCollFilesMeta)
+	return filesMeta.Save(p)
+}
+func (db *BoltDatabase) RemovePackageFilesMeta(p Package) error {
+	bolt, err := db.open()
+	if err != nil {
+		return errors.Wrap(err, "Error opening boltdb "+db.Path)
+	}
+
+	filesMeta := bolt.From(boltdbCollFilesMeta)
+	var pfm PackageFilesMeta
+	err = filesMeta.One("PackageFingerprint", p.GetFingerPrint(), &pfm)
+	if err != nil {
+		if err.Error() == "not found" {
+			return nil
+		}
+		return errors.Wrap(err, "While finding files metadata")
+	}
+	return filesMeta.DeleteStruct(&pfm)
+}
+
 func (db *BoltDatabase) GetPackageFiles(p Package) ([]string, error) {
 	bolt, err := db.open()
 	if err != nil {
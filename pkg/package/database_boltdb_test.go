@@ -82,6 +82,36 @@ var _ = Describe("BoltDB Database", func() {
 			Expect(pack[0]).To(Equal(a))
 		})
 
+		It("Stores package files metadata", func() {
+			a := NewPackage("A", "1.0", []*DefaultPackage{}, []*DefaultPackage{})
+			_, err := db.CreatePackage(a)
+			Expect(err).ToNot(HaveOccurred())
+
+			meta, err := db.GetPackageFilesMeta(a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(meta).To(BeNil())
+
+			err = db.SetPackageFilesMeta(&PackageFilesMeta{
+				PackageFingerprint: a.GetFingerPrint(),
+				Files: []*FileMeta{
+					{Path: "foo", Sha256: "abc", Mode: 0644, Size: 3},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			meta, err = db.GetPackageFilesMeta(a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(meta).ToNot(BeNil())
+			Expect(meta.Files).To(HaveLen(1))
+			Expect(meta.Files[0].Sha256).To(Equal("abc"))
+			Expect(meta.Files[0].Mode).To(Equal(os.FileMode(0644)))
+
+			Expect(db.RemovePackageFilesMeta(a)).ToNot(HaveOccurred())
+			meta, err = db.GetPackageFilesMeta(a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(meta).To(BeNil())
+		})
+
 		It("Expands correctly", func() {
 
 			a := NewPackage("A", ">=1.0", []*DefaultPackage{}, []*DefaultPackage{})
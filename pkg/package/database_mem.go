@@ -29,6 +29,7 @@ var DBInMemoryInstance = &InMemoryDatabase{
 	Mutex:             &sync.Mutex{},
 	FileDatabase:      map[string][]string{},
 	FinalizerDatabase: map[string]*PackageFinalizer{},
+	FilesMetaDatabase: map[string]*PackageFilesMeta{},
 	Database:          map[string]string{},
 	CacheNoVersion:    map[string]map[string]interface{}{},
 	ProvidesDatabase:  map[string]map[string]Package{},
@@ -41,6 +42,7 @@ type InMemoryDatabase struct {
 	Database          map[string]string
 	FileDatabase      map[string][]string
 	FinalizerDatabase map[string]*PackageFinalizer
+	FilesMetaDatabase map[string]*PackageFilesMeta
 	CacheNoVersion    map[string]map[string]interface{}
 	ProvidesDatabase  map[string]map[string]Package
 	RevDepsDatabase   map[string]map[string]Package
@@ -51,13 +53,14 @@ func NewInMemoryDatabase(singleton bool) PackageDatabase {
 	// In memoryDB is a singleton
 	if !singleton {
 		return &InMemoryDatabase{
-			Mutex:            &sync.Mutex{},
-			FileDatabase:     map[string][]string{},
-			Database:         map[string]string{},
-			CacheNoVersion:   map[string]map[string]interface{}{},
-			ProvidesDatabase: map[string]map[string]Package{},
-			RevDepsDatabase:  map[string]map[string]Package{},
-			cached:           map[string]interface{}{},
+			Mutex:             &sync.Mutex{},
+			FileDatabase:      map[string][]string{},
+			FilesMetaDatabase: map[string]*PackageFilesMeta{},
+			Database:          map[string]string{},
+			CacheNoVersion:    map[string]map[string]interface{}{},
+			ProvidesDatabase:  map[string]map[string]Package{},
+			RevDepsDatabase:   map[string]map[string]Package{},
+			cached:            map[string]interface{}{},
 		}
 	}
 	return DBInMemoryInstance
@@ -468,6 +471,31 @@ func (db *InMemoryDatabase) RemovePackageFinalizer(p Package) error {
 	return nil
 }
 
+func (db *InMemoryDatabase) GetPackageFilesMeta(p Package) (*PackageFilesMeta, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	pa, ok := db.FilesMetaDatabase[p.GetFingerPrint()]
+	if !ok {
+		return nil, nil
+	}
+	return pa, nil
+}
+
+func (db *InMemoryDatabase) SetPackageFilesMeta(p *PackageFilesMeta) error {
+	db.Lock()
+	defer db.Unlock()
+	db.FilesMetaDatabase[p.PackageFingerprint] = p
+	return nil
+}
+
+func (db *InMemoryDatabase) RemovePackageFilesMeta(p Package) error {
+	db.Lock()
+	defer db.Unlock()
+	delete(db.FilesMetaDatabase, p.GetFingerPrint())
+	return nil
+}
+
 func (db *InMemoryDatabase) GetPackageFiles(p Package) ([]string, error) {
 
 	db.Lock()
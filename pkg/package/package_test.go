@@ -123,6 +123,23 @@ var _ = Describe("Package", func() {
 		})
 	})
 
+	Context("Check install reason", func() {
+		a := NewPackage("A", "1.0", []*DefaultPackage{}, []*DefaultPackage{})
+		b := NewPackage("B", "1.0", []*DefaultPackage{}, []*DefaultPackage{})
+		c := NewPackage("C", "1.0", []*DefaultPackage{}, []*DefaultPackage{})
+		a.SetExplicit(true)
+		b.SetExplicit(false)
+
+		It("Set and get correctly the install reason", func() {
+			Expect(a.HasInstallReason()).To(Equal(true))
+			Expect(a.IsExplicit()).To(Equal(true))
+			Expect(b.GetInstallReason()).To(Equal(InstallReasonDep))
+			Expect(b.IsExplicit()).To(Equal(false))
+			Expect(c.HasInstallReason()).To(Equal(false))
+			Expect(c.IsExplicit()).To(Equal(false))
+		})
+	})
+
 	Context("Check description", func() {
 		a := NewPackage("A", ">=1.0", []*DefaultPackage{}, []*DefaultPackage{})
 		a.SetDescription("Description A")
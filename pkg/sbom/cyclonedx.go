@@ -0,0 +1,175 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/geaaru/luet/pkg/config"
+)
+
+// Structures of the CycloneDX 1.4 JSON format.
+
+type cdxDocument struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     []cdxTool    `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTool struct {
+	Vendor  string `json:"vendor,omitempty"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+// cdxLicense is a license with the SPDX identifier or the name
+// or a SPDX license expression.
+type cdxLicense struct {
+	License    *cdxLicenseName `json:"license,omitempty"`
+	Expression string          `json:"expression,omitempty"`
+}
+
+type cdxLicenseName struct {
+	Id   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type cdxExternalRef struct {
+	Type string `json:"type"`
+	Url  string `json:"url"`
+}
+
+type cdxComponent struct {
+	Type               string           `json:"type"`
+	BomRef             string           `json:"bom-ref,omitempty"`
+	Group              string           `json:"group,omitempty"`
+	Name               string           `json:"name"`
+	Version            string           `json:"version,omitempty"`
+	Description        string           `json:"description,omitempty"`
+	Publisher          string           `json:"publisher,omitempty"`
+	Licenses           []cdxLicense     `json:"licenses,omitempty"`
+	Purl               string           `json:"purl,omitempty"`
+	Hashes             []cdxHash        `json:"hashes,omitempty"`
+	ExternalReferences []cdxExternalRef `json:"externalReferences,omitempty"`
+	Components         []cdxComponent   `json:"components,omitempty"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+func (d *Document) cyclonedx() ([]byte, error) {
+	digest := d.digest()
+
+	doc := cdxDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		// A reproducible UUID from the content of the document.
+		SerialNumber: fmt.Sprintf("urn:uuid:%s-%s-4%s-a%s-%s",
+			digest[0:8], digest[8:12], digest[13:16], digest[17:20], digest[20:32]),
+		Version: 1,
+		Metadata: cdxMetadata{
+			Timestamp: d.Created.Format(time.RFC3339),
+			Tools: []cdxTool{
+				{
+					Vendor:  "Macaroni OS Linux",
+					Name:    "luet",
+					Version: config.LuetVersion,
+				},
+			},
+			Component: cdxComponent{
+				Type: "operating-system",
+				Name: d.Name,
+			},
+		},
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{},
+	}
+
+	names := d.packagesMap()
+
+	for _, p := range d.sortedPackages() {
+		ref := p.Purl()
+		c := cdxComponent{
+			Type:        "library",
+			BomRef:      ref,
+			Group:       p.Category,
+			Name:        p.Name,
+			Version:     p.Version,
+			Description: p.Description,
+			Publisher:   p.Repository,
+			Purl:        ref,
+		}
+		if strings.TrimSpace(p.License) != "" {
+			c.Licenses = []cdxLicense{cdxLicenseFrom(p.License)}
+		}
+		if p.Sha256 != "" {
+			c.Hashes = []cdxHash{{Alg: "SHA-256", Content: p.Sha256}}
+		}
+		for _, u := range p.Uri {
+			c.ExternalReferences = append(c.ExternalReferences,
+				cdxExternalRef{Type: "website", Url: u})
+		}
+		for _, f := range p.Files {
+			fc := cdxComponent{
+				Type: "file",
+				Name: "/" + strings.TrimPrefix(f.Path, "/"),
+			}
+			if f.Sha1 != "" {
+				fc.Hashes = append(fc.Hashes, cdxHash{Alg: "SHA-1", Content: f.Sha1})
+			}
+			if f.Sha256 != "" {
+				fc.Hashes = append(fc.Hashes, cdxHash{Alg: "SHA-256", Content: f.Sha256})
+			}
+			c.Components = append(c.Components, fc)
+		}
+		doc.Components = append(doc.Components, c)
+
+		dep := cdxDependency{Ref: ref, DependsOn: []string{}}
+		for _, rp := range p.dependencies(names) {
+			dep.DependsOn = append(dep.DependsOn, rp.Purl())
+		}
+		doc.Dependencies = append(doc.Dependencies, dep)
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// cdxLicenseFrom returns the license with the SPDX identifier when
+// available, with the original name for a license without identifier
+// or as expression for multiple licenses.
+func cdxLicenseFrom(l string) cdxLicense {
+	expr := newLicenseExpression(l)
+	switch {
+	case expr.IsLicenseId():
+		return cdxLicense{License: &cdxLicenseName{Id: expr.Expression}}
+	case len(expr.Refs) == 1 && expr.Refs[expr.Expression] != "":
+		return cdxLicense{License: &cdxLicenseName{Name: expr.Refs[expr.Expression]}}
+	case len(expr.Refs) > 0 || expr.Expression == spdxNoAssertion:
+		// The expression must contains only SPDX identifiers.
+		return cdxLicense{License: &cdxLicenseName{Name: strings.TrimSpace(l)}}
+	default:
+		return cdxLicense{Expression: expr.Expression}
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package sbom
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SPDX identifiers of the licenses names used by the packages
+// (gentoo and SPDX names). The keys are in lowercase.
+var spdxLicenses = map[string]string{
+	"0bsd":              "0BSD",
+	"afl-2.1":           "AFL-2.1",
+	"afl-3.0":           "AFL-3.0",
+	"agpl-3":            "AGPL-3.0-only",
+	"agpl-3+":           "AGPL-3.0-or-later",
+	"agpl-3.0-only":     "AGPL-3.0-only",
+	"agpl-3.0-or-later": "AGPL-3.0-or-later",
+	"apache-1.1":        "Apache-1.1",
+	"apache-2.0":        "Apache-2.0",
+	"artistic":          "Artistic-1.0-Perl",
+	"artistic-1.0-perl": "Artistic-1.0-Perl",
+	"artistic-2":        "Artistic-2.0",
+	"artistic-2.0":      "Artistic-2.0",
+	"boost-1.0":         "BSL-1.0",
+	"bsd":               "BSD-3-Clause",
+	"bsd-2":             "BSD-2-Clause",
+	"bsd-2-clause":      "BSD-2-Clause",
+	"bsd-3-clause":      "BSD-3-Clause",
+	"bsd-4":             "BSD-4-Clause",
+	"bsd-4-clause":      "BSD-4-Clause",
+	"bsl-1.0":           "BSL-1.0",
+	"bzip2-1.0.6":       "bzip2-1.0.6",
+	"cc-by-4.0":         "CC-BY-4.0",
+	"cc-by-sa-4.0":      "CC-BY-SA-4.0",
+	"cc0-1.0":           "CC0-1.0",
+	"cddl":              "CDDL-1.0",
+	"cddl-1.0":          "CDDL-1.0",
+	"curl":              "curl",
+	"epl-1.0":           "EPL-1.0",
+	"epl-2.0":           "EPL-2.0",
+	"fdl-1.2":           "GFDL-1.2-only",
+	"fdl-1.2+":          "GFDL-1.2-or-later",
+	"fdl-1.3":           "GFDL-1.3-only",
+	"fdl-1.3+":          "GFDL-1.3-or-later",
+	"gpl-1":             "GPL-1.0-only",
+	"gpl-1+":            "GPL-1.0-or-later",
+	"gpl-2":             "GPL-2.0-only",
+	"gpl-2+":            "GPL-2.0-or-later",
+	"gpl-2.0-only":      "GPL-2.0-only",
+	"gpl-2.0-or-later":  "GPL-2.0-or-later",
+	"gpl-3":             "GPL-3.0-only",
+	"gpl-3+":            "GPL-3.0-or-later",
+	"gpl-3.0-only":      "GPL-3.0-only",
+	"gpl-3.0-or-later":  "GPL-3.0-or-later",
+	"hpnd":              "HPND",
+	"icu":               "ICU",
+	"ijg":               "IJG",
+	"isc":               "ISC",
+	"lgpl-2":            "LGPL-2.0-only",
+	"lgpl-2+":           "LGPL-2.0-or-later",
+	"lgpl-2.0-only":     "LGPL-2.0-only",
+	"lgpl-2.0-or-later": "LGPL-2.0-or-later",
+	"lgpl-2.1":          "LGPL-2.1-only",
+	"lgpl-2.1+":         "LGPL-2.1-or-later",
+	"lgpl-2.1-only":     "LGPL-2.1-only",
+	"lgpl-2.1-or-later": "LGPL-2.1-or-later",
+	"lgpl-3":            "LGPL-3.0-only",
+	"lgpl-3+":           "LGPL-3.0-or-later",
+	"lgpl-3.0-only":     "LGPL-3.0-only",
+	"lgpl-3.0-or-later": "LGPL-3.0-or-later",
+	"libpng":            "Libpng",
+	"libpng2":           "libpng-2.0",
+	"mit":               "MIT",
+	"mpl-1.1":           "MPL-1.1",
+	"mpl-2.0":           "MPL-2.0",
+	"ncsa":              "NCSA",
+	"openssl":           "OpenSSL",
+	"psf-2":             "PSF-2.0",
+	"psf-2.0":           "PSF-2.0",
+	"ruby":              "Ruby",
+	"sleepycat":         "Sleepycat",
+	"unicode-dfs-2016":  "Unicode-DFS-2016",
+	"unlicense":         "Unlicense",
+	"vim":               "Vim",
+	"w3c":               "W3C",
+	"wtfpl-2":           "WTFPL",
+	"x11":               "X11",
+	"zlib":              "Zlib",
+	"zpl":               "ZPL-2.1",
+}
+
+var spdxLicenseRefInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// licenseExpression is the license of a package converted to
+// a SPDX license expression.
+type licenseExpression struct {
+	Expression string
+	// LicenseRef identifiers of the licenses without a SPDX
+	// identifier mapped to the original name.
+	Refs map[string]string
+}
+
+// IsLicenseId returns true if the expression is a single
+// SPDX license identifier.
+func (l *licenseExpression) IsLicenseId() bool {
+	return len(l.Refs) == 0 && l.Expression != spdxNoAssertion &&
+		!strings.ContainsAny(l.Expression, " ()+")
+}
+
+// newLicenseExpression converts the license of a package to a SPDX
+// license expression. The license could be a SPDX expression or a
+// list of licenses with the gentoo syntax: the licenses of the list
+// are all applied and || ( ... ) is a choice. The licenses without a
+// SPDX identifier are converted to LicenseRef. An empty license
+// is converted to NOASSERTION.
+func newLicenseExpression(l string) *licenseExpression {
+	ans := &licenseExpression{Refs: make(map[string]string, 0)}
+
+	l = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(l)
+	tokens := strings.Fields(l)
+	pos := 0
+	ans.Expression = ans.parse(tokens, &pos, "AND")
+	if ans.Expression == "" {
+		ans.Expression = spdxNoAssertion
+	}
+
+	return ans
+}
+
+func (l *licenseExpression) parse(tokens []string, pos *int, op string) string {
+	out := []string{}
+	needOp := false
+
+	add := func(s string) {
+		if s == "" {
+			return
+		}
+		if needOp {
+			out = append(out, op)
+		}
+		out = append(out, s)
+		needOp = true
+	}
+
+	for *pos < len(tokens) {
+		t := tokens[*pos]
+		*pos++
+
+		switch u := strings.ToUpper(t); {
+		case t == ")":
+			return l.join(out)
+		case t == "(":
+			add(l.group(tokens, pos, "AND"))
+		case t == "||":
+			if *pos < len(tokens) && tokens[*pos] == "(" {
+				*pos++
+				add(l.group(tokens, pos, "OR"))
+			}
+		case strings.HasSuffix(t, "?"):
+			// USE conditional: the licenses of the group are
+			// considered always applied.
+		case u == "AND" || u == "OR" || u == "WITH":
+			if needOp {
+				out = append(out, u)
+				needOp = false
+			}
+		case len(out) > 0 && out[len(out)-1] == "WITH":
+			// Exception identifier.
+			out = append(out, spdxLicenseRefInvalidChars.ReplaceAllString(t, "-"))
+			needOp = true
+		default:
+			add(l.licenseId(t))
+		}
+	}
+
+	return l.join(out)
+}
+
+func (l *licenseExpression) group(tokens []string, pos *int, op string) string {
+	s := l.parse(tokens, pos, op)
+	if strings.Contains(s, " ") {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// join drops the trailing operators without an operand.
+func (l *licenseExpression) join(out []string) string {
+	for len(out) > 0 {
+		last := out[len(out)-1]
+		if last != "AND" && last != "OR" && last != "WITH" {
+			break
+		}
+		out = out[:len(out)-1]
+	}
+	return strings.Join(out, " ")
+}
+
+func (l *licenseExpression) licenseId(name string) string {
+	if id, ok := spdxLicenses[strings.ToLower(name)]; ok {
+		return id
+	}
+
+	ref := "LicenseRef-" + strings.Trim(
+		spdxLicenseRefInvalidChars.ReplaceAllString(name, "-"), "-")
+	if ref == "LicenseRef-" {
+		return ""
+	}
+	l.Refs[ref] = name
+	return ref
+}
+
+// RefsIds returns the LicenseRef identifiers sorted.
+func (l *licenseExpression) RefsIds() []string {
+	ans := []string{}
+	for id := range l.Refs {
+		ans = append(ans, id)
+	}
+	sort.Strings(ans)
+	return ans
+}
@@ -0,0 +1,218 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/geaaru/luet/pkg/config"
+	"github.com/geaaru/luet/pkg/helpers"
+	pkg "github.com/geaaru/luet/pkg/package"
+)
+
+const (
+	FormatSPDX      = "spdx-json"
+	FormatCycloneDX = "cyclonedx-json"
+	// Disable the generation of the SBOM.
+	FormatNone = "none"
+)
+
+// Package is a component of the bill of materials.
+type Package struct {
+	Category    string
+	Name        string
+	Version     string
+	License     string
+	Description string
+	Uri         []string
+	// Dependencies with the version selectors.
+	Requires []*pkg.DefaultPackage
+	// Files of the package without the leading slash.
+	Files []*File
+	// Sha256 of the package tarball.
+	Sha256 string
+	// Name of the repository of the package.
+	Repository string
+}
+
+type File struct {
+	Path   string
+	Sha1   string
+	Sha256 string
+}
+
+// Document is the bill of materials of a set of packages:
+// an artifact, a repository or an installed system.
+type Document struct {
+	Name     string
+	Created  time.Time
+	Packages []*Package
+}
+
+func NewDocument(name string) *Document {
+	return &Document{
+		Name:     name,
+		Created:  creationTime(),
+		Packages: []*Package{},
+	}
+}
+
+// creationTime honors SOURCE_DATE_EPOCH to permit
+// reproducible documents.
+func creationTime() time.Time {
+	if epoch := helpers.SourceDateEpoch(); epoch != nil {
+		return *epoch
+	}
+	return time.Now().UTC()
+}
+
+// NewPackage returns the component of the package with the
+// files in input.
+func NewPackage(p pkg.Package, files []string) *Package {
+	ans := &Package{
+		Category:    p.GetCategory(),
+		Name:        p.GetName(),
+		Version:     p.GetVersion(),
+		License:     p.GetLicense(),
+		Description: p.GetDescription(),
+		Uri:         p.GetURI(),
+		Requires:    []*pkg.DefaultPackage{},
+		Files:       []*File{},
+	}
+
+	ans.Requires = append(ans.Requires, p.GetRequires()...)
+
+	for _, f := range files {
+		ans.Files = append(ans.Files, &File{Path: f})
+	}
+
+	return ans
+}
+
+func (p *Package) PackageName() string {
+	return fmt.Sprintf("%s/%s", p.Category, p.Name)
+}
+
+// Purl returns the package URL of the package.
+func (p *Package) Purl() string {
+	return fmt.Sprintf("pkg:luet/%s/%s@%s",
+		url.PathEscape(p.Category), url.PathEscape(p.Name),
+		url.PathEscape(p.Version))
+}
+
+func (d *Document) AddPackage(p *Package) {
+	d.Packages = append(d.Packages, p)
+}
+
+func (d *Document) sortedPackages() []*Package {
+	ans := make([]*Package, len(d.Packages))
+	copy(ans, d.Packages)
+	sort.Slice(ans, func(i, j int) bool {
+		if ans[i].PackageName() == ans[j].PackageName() {
+			return ans[i].Version < ans[j].Version
+		}
+		return ans[i].PackageName() < ans[j].PackageName()
+	})
+	return ans
+}
+
+// packagesMap returns the map category/name -> packages of
+// the document used to resolve the dependencies.
+func (d *Document) packagesMap() map[string][]*Package {
+	ans := make(map[string][]*Package, 0)
+	for _, p := range d.Packages {
+		ans[p.PackageName()] = append(ans[p.PackageName()], p)
+	}
+	return ans
+}
+
+// dependencies returns the packages of the document admitted
+// by the requires of the package.
+func (p *Package) dependencies(names map[string][]*Package) []*Package {
+	ans := []*Package{}
+	req := &pkg.DefaultPackage{PackageRequires: p.Requires}
+	visited := make(map[string]bool, 0)
+
+	for _, r := range p.Requires {
+		if visited[r.PackageName()] {
+			continue
+		}
+		visited[r.PackageName()] = true
+
+		for _, dep := range names[r.PackageName()] {
+			admit, err := req.Admit(&pkg.DefaultPackage{
+				Category: dep.Category,
+				Name:     dep.Name,
+				Version:  dep.Version,
+			})
+			if err == nil && admit {
+				ans = append(ans, dep)
+			}
+		}
+	}
+
+	return ans
+}
+
+// digest returns an hash of the content of the document used
+// to generate unique but reproducible identifiers.
+func (d *Document) digest() string {
+	h := sha256.New()
+	h.Write([]byte(d.Name))
+	for _, p := range d.sortedPackages() {
+		h.Write([]byte(p.Purl() + p.Sha256))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func toolName() string {
+	return fmt.Sprintf("luet-%s", config.LuetVersion)
+}
+
+// Marshal returns the document in the format in input.
+func (d *Document) Marshal(format string) ([]byte, error) {
+	switch format {
+	case FormatSPDX:
+		return d.spdx()
+	case FormatCycloneDX:
+		return d.cyclonedx()
+	default:
+		return nil, fmt.Errorf("Invalid SBOM format %s", format)
+	}
+}
+
+func (d *Document) WriteFile(file, format string) error {
+	data, err := d.Marshal(format)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// FileSuffix returns the extension of the files of the format.
+func FileSuffix(format string) string {
+	switch format {
+	case FormatCycloneDX:
+		return ".cdx.json"
+	default:
+		return ".spdx.json"
+	}
+}
+
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatSPDX, FormatCycloneDX, FormatNone:
+		return nil
+	default:
+		return fmt.Errorf("Invalid SBOM format %s (available: %s, %s, %s)",
+			format, FormatSPDX, FormatCycloneDX, FormatNone)
+	}
+}
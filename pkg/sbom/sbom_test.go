@@ -0,0 +1,190 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package sbom_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	pkg "github.com/geaaru/luet/pkg/package"
+	. "github.com/geaaru/luet/pkg/sbom"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	bashSha256 = "5f2b6c2d8e1d7a48d8b0c9b2f3e3a6c4b8f2a1e0d9c8b7a6f5e4d3c2b1a09f8e"
+	fileSha1   = "2fd4e1c67a2d28fced849ee1bb76e7391b93eb12"
+	fileSha256 = "d7a8fbb307d7809469ca9abcb0082e4f8d5651e46d3cdb762d02d0bf37c9e592"
+)
+
+// validateSchema validates the document with the official JSON schema
+// of the testdata directory downloaded by make sbom-schemas.
+func validateSchema(data []byte, schema string) {
+	abs, err := filepath.Abs(filepath.Join("testdata", schema))
+	Expect(err).ToNot(HaveOccurred())
+	if _, err := os.Stat(abs); os.IsNotExist(err) {
+		Skip("Schema " + schema + " not available: run make sbom-schemas")
+	}
+
+	res, err := gojsonschema.Validate(
+		gojsonschema.NewReferenceLoader("file://"+abs),
+		gojsonschema.NewBytesLoader(data))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(res.Errors()).To(BeEmpty())
+	Expect(res.Valid()).To(BeTrue())
+}
+
+var _ = Describe("SBOM", func() {
+	var doc *Document
+
+	BeforeEach(func() {
+		os.Setenv("SOURCE_DATE_EPOCH", "1672531200")
+		defer os.Unsetenv("SOURCE_DATE_EPOCH")
+
+		libc := pkg.NewPackage("glibc", "2.36", []*pkg.DefaultPackage{}, []*pkg.DefaultPackage{})
+		libc.Category = "sys-libs"
+		libc.License = "LGPL-2.1+ BSD HPND"
+		libc.Uri = []string{"https://www.gnu.org/software/libc/"}
+
+		bash := pkg.NewPackage("bash", "5.2", []*pkg.DefaultPackage{
+			{Category: "sys-libs", Name: "glibc", Version: ">=2.30"},
+		}, []*pkg.DefaultPackage{})
+		bash.Category = "app-shells"
+		bash.License = "GPL-3"
+
+		// The glibc version available is not admitted.
+		zsh := pkg.NewPackage("zsh", "5.9", []*pkg.DefaultPackage{
+			{Category: "sys-libs", Name: "glibc", Version: ">=2.40"},
+		}, []*pkg.DefaultPackage{})
+		zsh.Category = "app-shells"
+		zsh.License = "ZSH"
+
+		doc = NewDocument("test")
+		sp := NewPackage(bash, []string{"bin/bash"})
+		sp.Sha256 = bashSha256
+		sp.Files[0].Sha1 = fileSha1
+		sp.Files[0].Sha256 = fileSha256
+		doc.AddPackage(sp)
+		doc.AddPackage(NewPackage(zsh, []string{}))
+		doc.AddPackage(NewPackage(libc, []string{"lib/libc.so.6"}))
+	})
+
+	It("Generates SPDX documents", func() {
+		data, err := doc.Marshal(FormatSPDX)
+		Expect(err).ToNot(HaveOccurred())
+
+		spdx := map[string]interface{}{}
+		Expect(json.Unmarshal(data, &spdx)).ToNot(HaveOccurred())
+		Expect(spdx["spdxVersion"]).To(Equal("SPDX-2.3"))
+		Expect(spdx["creationInfo"].(map[string]interface{})["created"]).To(Equal("2023-01-01T00:00:00Z"))
+
+		packages := spdx["packages"].([]interface{})
+		Expect(len(packages)).To(Equal(3))
+		bash := packages[0].(map[string]interface{})
+		Expect(bash["name"]).To(Equal("app-shells/bash"))
+		Expect(bash["SPDXID"]).To(Equal("SPDXRef-Package-app-shells-bash-5.2"))
+		Expect(bash["licenseDeclared"]).To(Equal("GPL-3.0-only"))
+		Expect(packages[1].(map[string]interface{})["licenseDeclared"]).To(Equal("LicenseRef-ZSH"))
+		glibc := packages[2].(map[string]interface{})
+		Expect(glibc["homepage"]).To(Equal("https://www.gnu.org/software/libc/"))
+		Expect(glibc["licenseDeclared"]).To(Equal("LGPL-2.1-or-later AND BSD-3-Clause AND HPND"))
+
+		Expect(spdx["hasExtractedLicensingInfos"]).To(Equal([]interface{}{
+			map[string]interface{}{
+				"licenseId":     "LicenseRef-ZSH",
+				"name":          "ZSH",
+				"extractedText": "The license ZSH is declared by the packages.",
+			},
+		}))
+
+		Expect(spdx["relationships"]).To(ContainElement(map[string]interface{}{
+			"spdxElementId":      "SPDXRef-Package-app-shells-bash-5.2",
+			"relationshipType":   "DEPENDS_ON",
+			"relatedSpdxElement": "SPDXRef-Package-sys-libs-glibc-2.36",
+		}))
+		Expect(spdx["relationships"]).ToNot(ContainElement(HaveKeyWithValue(
+			"spdxElementId", "SPDXRef-Package-app-shells-zsh-5.9")))
+
+		// Only the files with the SHA1 checksum are available.
+		Expect(spdx["files"]).To(Equal([]interface{}{
+			map[string]interface{}{
+				"SPDXID":   "SPDXRef-File-app-shells-bash-5.2-bin-bash",
+				"fileName": "./bin/bash",
+				"checksums": []interface{}{
+					map[string]interface{}{"algorithm": "SHA1", "checksumValue": fileSha1},
+					map[string]interface{}{"algorithm": "SHA256", "checksumValue": fileSha256},
+				},
+			},
+		}))
+
+		validateSchema(data, "spdx-2.3.schema.json")
+
+		// The output is reproducible.
+		data2, err := doc.Marshal(FormatSPDX)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data2).To(Equal(data))
+	})
+
+	It("Generates CycloneDX documents", func() {
+		data, err := doc.Marshal(FormatCycloneDX)
+		Expect(err).ToNot(HaveOccurred())
+
+		cdx := map[string]interface{}{}
+		Expect(json.Unmarshal(data, &cdx)).ToNot(HaveOccurred())
+		Expect(cdx["bomFormat"]).To(Equal("CycloneDX"))
+		Expect(cdx["serialNumber"]).To(MatchRegexp(
+			`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-a[0-9a-f]{3}-[0-9a-f]{12}$`))
+
+		components := cdx["components"].([]interface{})
+		Expect(len(components)).To(Equal(3))
+		bash := components[0].(map[string]interface{})
+		Expect(bash["purl"]).To(Equal("pkg:luet/app-shells/bash@5.2"))
+		Expect(bash["group"]).To(Equal("app-shells"))
+		Expect(bash["hashes"]).To(Equal([]interface{}{
+			map[string]interface{}{"alg": "SHA-256", "content": bashSha256},
+		}))
+		Expect(bash["licenses"]).To(Equal([]interface{}{
+			map[string]interface{}{"license": map[string]interface{}{"id": "GPL-3.0-only"}},
+		}))
+		Expect(bash["components"]).To(Equal([]interface{}{
+			map[string]interface{}{
+				"type": "file",
+				"name": "/bin/bash",
+				"hashes": []interface{}{
+					map[string]interface{}{"alg": "SHA-1", "content": fileSha1},
+					map[string]interface{}{"alg": "SHA-256", "content": fileSha256},
+				},
+			},
+		}))
+		Expect(components[1].(map[string]interface{})["licenses"]).To(Equal([]interface{}{
+			map[string]interface{}{"license": map[string]interface{}{"name": "ZSH"}},
+		}))
+		Expect(components[2].(map[string]interface{})["licenses"]).To(Equal([]interface{}{
+			map[string]interface{}{"expression": "LGPL-2.1-or-later AND BSD-3-Clause AND HPND"},
+		}))
+
+		Expect(cdx["dependencies"]).To(ContainElement(map[string]interface{}{
+			"ref":       "pkg:luet/app-shells/bash@5.2",
+			"dependsOn": []interface{}{"pkg:luet/sys-libs/glibc@2.36"},
+		}))
+		Expect(cdx["dependencies"]).To(ContainElement(map[string]interface{}{
+			"ref":       "pkg:luet/app-shells/zsh@5.9",
+			"dependsOn": []interface{}{},
+		}))
+
+		validateSchema(data, "bom-1.4.schema.json")
+	})
+
+	It("Validates the formats", func() {
+		Expect(ValidateFormat(FormatSPDX)).ToNot(HaveOccurred())
+		Expect(ValidateFormat(FormatNone)).ToNot(HaveOccurred())
+		Expect(ValidateFormat("spdx-tv")).To(HaveOccurred())
+		Expect(FileSuffix(FormatCycloneDX)).To(Equal(".cdx.json"))
+	})
+})
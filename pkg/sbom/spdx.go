@@ -0,0 +1,194 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Structures of the SPDX 2.3 JSON format.
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Files             []spdxFile         `json:"files,omitempty"`
+	Relationships     []spdxRelationship `json:"relationships"`
+	// Licenses without a SPDX identifier.
+	ExtractedLicensingInfos []spdxExtractedLicense `json:"hasExtractedLicensingInfos,omitempty"`
+}
+
+type spdxExtractedLicense struct {
+	LicenseId     string `json:"licenseId"`
+	Name          string `json:"name"`
+	ExtractedText string `json:"extractedText"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo"`
+	DownloadLocation string            `json:"downloadLocation"`
+	Homepage         string            `json:"homepage,omitempty"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	Description      string            `json:"description,omitempty"`
+	Supplier         string            `json:"supplier,omitempty"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs"`
+}
+
+type spdxFile struct {
+	SPDXID    string         `json:"SPDXID"`
+	FileName  string         `json:"fileName"`
+	Checksums []spdxChecksum `json:"checksums,omitempty"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+const spdxNoAssertion = "NOASSERTION"
+
+var spdxIdInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+func spdxId(prefix, s string) string {
+	return "SPDXRef-" + prefix + "-" + spdxIdInvalidChars.ReplaceAllString(s, "-")
+}
+
+func (d *Document) spdx() ([]byte, error) {
+	doc := spdxDocument{
+		SPDXVersion: "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        d.Name,
+		DocumentNamespace: fmt.Sprintf("https://github.com/geaaru/luet/spdx/%s-%s",
+			spdxIdInvalidChars.ReplaceAllString(d.Name, "-"), d.digest()),
+		CreationInfo: spdxCreationInfo{
+			Created:  d.Created.Format(time.RFC3339),
+			Creators: []string{"Tool: " + toolName()},
+		},
+		Packages:      []spdxPackage{},
+		Files:         []spdxFile{},
+		Relationships: []spdxRelationship{},
+	}
+
+	names := d.packagesMap()
+	licenseRefs := make(map[string]string, 0)
+
+	for _, p := range d.sortedPackages() {
+		id := spdxId("Package", p.PackageName()+"-"+p.Version)
+		license := newLicenseExpression(p.License)
+		for ref, name := range license.Refs {
+			licenseRefs[ref] = name
+		}
+
+		sp := spdxPackage{
+			SPDXID:           id,
+			Name:             p.PackageName(),
+			VersionInfo:      p.Version,
+			DownloadLocation: spdxNoAssertion,
+			FilesAnalyzed:    false,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  license.Expression,
+			CopyrightText:    spdxNoAssertion,
+			Description:      p.Description,
+			ExternalRefs: []spdxExternalRef{
+				{
+					ReferenceCategory: "PACKAGE-MANAGER",
+					ReferenceType:     "purl",
+					ReferenceLocator:  p.Purl(),
+				},
+			},
+		}
+		if len(p.Uri) > 0 {
+			sp.Homepage = p.Uri[0]
+		}
+		if p.Repository != "" {
+			sp.Supplier = "Organization: " + p.Repository
+		}
+		if p.Sha256 != "" {
+			sp.Checksums = []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: p.Sha256}}
+		}
+		doc.Packages = append(doc.Packages, sp)
+
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      doc.SPDXID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: id,
+		})
+
+		for _, dep := range p.dependencies(names) {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{
+				SPDXElementID:      id,
+				RelationshipType:   "DEPENDS_ON",
+				RelatedSPDXElement: spdxId("Package", dep.PackageName()+"-"+dep.Version),
+			})
+		}
+
+		for _, f := range p.Files {
+			// The SHA1 checksum of the files is mandatory.
+			if f.Sha1 == "" {
+				continue
+			}
+			fid := spdxId("File", p.PackageName()+"-"+p.Version+"-"+f.Path)
+			sf := spdxFile{
+				SPDXID:    fid,
+				FileName:  "./" + strings.TrimPrefix(f.Path, "/"),
+				Checksums: []spdxChecksum{{Algorithm: "SHA1", ChecksumValue: f.Sha1}},
+			}
+			if f.Sha256 != "" {
+				sf.Checksums = append(sf.Checksums,
+					spdxChecksum{Algorithm: "SHA256", ChecksumValue: f.Sha256})
+			}
+			doc.Files = append(doc.Files, sf)
+			doc.Relationships = append(doc.Relationships, spdxRelationship{
+				SPDXElementID:      id,
+				RelationshipType:   "CONTAINS",
+				RelatedSPDXElement: fid,
+			})
+		}
+	}
+
+	refs := (&licenseExpression{Refs: licenseRefs}).RefsIds()
+	for _, ref := range refs {
+		doc.ExtractedLicensingInfos = append(doc.ExtractedLicensingInfos,
+			spdxExtractedLicense{
+				LicenseId:     ref,
+				Name:          licenseRefs[ref],
+				ExtractedText: "The license " + licenseRefs[ref] + " is declared by the packages.",
+			})
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
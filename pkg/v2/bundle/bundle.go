@@ -0,0 +1,234 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/geaaru/luet/pkg/config"
+	"github.com/geaaru/luet/pkg/helpers"
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	. "github.com/geaaru/luet/pkg/logger"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	BUNDLE_SPECFILE = "bundle.yaml"
+	BUNDLE_REPODIR  = "repo"
+)
+
+// BundleArtifact describes a package tarball available
+// in the bundle.
+type BundleArtifact struct {
+	Package    string `yaml:"package" json:"package"`
+	Version    string `yaml:"version" json:"version"`
+	Repository string `yaml:"repository" json:"repository"`
+	File       string `yaml:"file" json:"file"`
+	Sha256     string `yaml:"sha256,omitempty" json:"sha256,omitempty"`
+}
+
+// BundleSpec is the content of the bundle.yaml file. The bundle
+// is a tarball with the bundle.yaml file and a directory repo/ that
+// contains a disk repository with the packages tarballs and the
+// tree of the packages.
+type BundleSpec struct {
+	Name      string            `yaml:"name" json:"name"`
+	Created   string            `yaml:"created" json:"created"`
+	Packages  []string          `yaml:"packages" json:"packages"`
+	Artifacts []*BundleArtifact `yaml:"artifacts" json:"artifacts"`
+}
+
+// BundleBuilder prepares the content of a bundle in a work directory.
+type BundleBuilder struct {
+	Spec *BundleSpec
+
+	workDir   string
+	treefsDir string
+	provides  *wagon.WagonProvides
+}
+
+func NewBundleSpec(name string) *BundleSpec {
+	return &BundleSpec{
+		Name:      name,
+		Created:   strconv.FormatInt(time.Now().Unix(), 10),
+		Packages:  []string{},
+		Artifacts: []*BundleArtifact{},
+	}
+}
+
+func NewBundleSpecFromFile(f string) (*BundleSpec, error) {
+	data, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := &BundleSpec{}
+	if err = yaml.Unmarshal(data, ans); err != nil {
+		return nil, errors.Wrap(err, "Error on parse "+f)
+	}
+
+	if ans.Name == "" {
+		return nil, fmt.Errorf("invalid bundle file %s without name", f)
+	}
+
+	return ans, nil
+}
+
+func (s *BundleSpec) Write(f string) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f, data, 0644)
+}
+
+// NewBundleBuilder creates a new builder that uses the work
+// directory in input. The work directory must be empty.
+func NewBundleBuilder(name, workDir string) (*BundleBuilder, error) {
+	ans := &BundleBuilder{
+		Spec:      NewBundleSpec(name),
+		workDir:   workDir,
+		treefsDir: filepath.Join(workDir, "treefs"),
+		provides:  wagon.NewWagonProvides(),
+	}
+
+	for _, d := range []string{
+		ans.treefsDir, filepath.Join(workDir, "bundle", BUNDLE_REPODIR),
+	} {
+		if err := os.MkdirAll(d, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	return ans, nil
+}
+
+func (b *BundleBuilder) getRepoDir() string {
+	return filepath.Join(b.workDir, "bundle", BUNDLE_REPODIR)
+}
+
+// AddArtifact adds the downloaded artifact to the bundle. The tree
+// directory is the directory of the repository tree with the
+// metadata of the package.
+func (b *BundleBuilder) AddArtifact(a *artifact.PackageArtifact, treeDir string) error {
+	p := a.GetPackage()
+	file := path.Base(a.Path)
+
+	err := fileHelper.CopyFile(a.CachePath, filepath.Join(b.getRepoDir(), file))
+	if err != nil {
+		return errors.Wrapf(err, "Error on copy tarball of %s",
+			p.HumanReadableString())
+	}
+
+	pkgDir := filepath.Join(p.GetCategory(), p.GetName(), p.GetVersion())
+	err = fileHelper.CopyDir(filepath.Join(treeDir, pkgDir),
+		filepath.Join(b.treefsDir, pkgDir))
+	if err != nil {
+		return errors.Wrapf(err, "Error on copy metadata of %s",
+			p.HumanReadableString())
+	}
+
+	if p.HasProvides() {
+		for _, prov := range p.GetProvides() {
+			b.provides.Add(prov.PackageName(), p)
+		}
+	}
+
+	b.Spec.Artifacts = append(b.Spec.Artifacts, &BundleArtifact{
+		Package:    p.PackageName(),
+		Version:    p.GetVersion(),
+		Repository: p.Repository,
+		File:       file,
+		Sha256:     a.Checksums[string(artifact.SHA256)],
+	})
+
+	return nil
+}
+
+// Write creates the bundle tarball.
+func (b *BundleBuilder) Write(output string) error {
+	repoDir := b.getRepoDir()
+
+	err := b.provides.WriteProvidesYAML(
+		filepath.Join(b.treefsDir, "provides.yaml"))
+	if err != nil {
+		return err
+	}
+
+	// Create the tree tarball of the repository.
+	treeTarball := filepath.Join(repoDir, wagon.TREE_TARBALL)
+	err = helpers.Tar(b.treefsDir, treeTarball)
+	if err != nil {
+		return errors.Wrap(err, "Error on create tree tarball")
+	}
+
+	sha, err := fileHelper.Sha256Sum(treeTarball)
+	if err != nil {
+		return err
+	}
+	docTree := wagon.NewWagonDocument(wagon.TREE_TARBALL)
+	docTree.Checksums[string(artifact.SHA256)] = sha
+
+	identity := wagon.NewWagonIdentify(
+		config.NewLuetRepository(b.Spec.Name, wagon.DiskRepositoryType,
+			"Offline bundle", []string{}, 1, true, false),
+	)
+	identity.RepositoryFiles[wagon.REPOFILE_TREEV2_KEY] = docTree
+	identity.BumpRevision()
+
+	err = identity.Write(filepath.Join(repoDir, wagon.REPOSITORY_SPECFILE))
+	if err != nil {
+		return err
+	}
+
+	err = b.Spec.Write(filepath.Join(b.workDir, "bundle", BUNDLE_SPECFILE))
+	if err != nil {
+		return err
+	}
+
+	Debug(fmt.Sprintf("Creating bundle %s with %d artifacts.",
+		output, len(b.Spec.Artifacts)))
+
+	// The package tarballs are already compressed.
+	return helpers.Tar(filepath.Join(b.workDir, "bundle"), output)
+}
+
+// Extract unpacks the bundle in the directory in input and returns
+// the bundle specification.
+func Extract(file, dir string) (*BundleSpec, error) {
+	if !fileHelper.Exists(file) {
+		return nil, fmt.Errorf("bundle file %s not found", file)
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	err := helpers.Untar(file, dir, false, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error on unpack bundle "+file)
+	}
+
+	spec, err := NewBundleSpecFromFile(filepath.Join(dir, BUNDLE_SPECFILE))
+	if err != nil {
+		return nil, err
+	}
+
+	if !fileHelper.Exists(filepath.Join(dir, BUNDLE_REPODIR,
+		wagon.REPOSITORY_SPECFILE)) {
+		return nil, fmt.Errorf("bundle %s without repository", file)
+	}
+
+	return spec, nil
+}
@@ -0,0 +1,267 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/geaaru/luet/pkg/config"
+
+	"github.com/gofrs/flock"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	BlobsDir      = "blobs"
+	BlobsAlgo     = "sha256"
+	IndexFile     = "index.yaml"
+	IndexLockFile = ".index.lock"
+)
+
+// PackagesCache is the local cache of the packages tarballs.
+// The tarballs are stored by content as blobs under the path
+// blobs/sha256/<first two chars of the hash>/<hash> and the index
+// file maps the packages of the repositories with the blobs.
+// The cache could be shared between multiple rootfs targets.
+type PackagesCache struct {
+	Dir string
+}
+
+// CacheEntry maps an artifact of a repository to a blob.
+type CacheEntry struct {
+	Repository string `yaml:"repository" json:"repository"`
+	Package    string `yaml:"package" json:"package"`
+	Version    string `yaml:"version" json:"version"`
+	Artifact   string `yaml:"artifact" json:"artifact"`
+	Sha256     string `yaml:"sha256" json:"sha256"`
+	// The sha256 of the artifact of the repository for a tarball
+	// rebuilt from a delta. The content is the same but the blob
+	// differs from the artifact of the repository.
+	Source   string `yaml:"source,omitempty" json:"source,omitempty"`
+	Size     int64  `yaml:"size" json:"size"`
+	Created  string `yaml:"created" json:"created"`
+	LastUsed string `yaml:"last_used" json:"last_used"`
+}
+
+type CacheIndex struct {
+	Entries []*CacheEntry `yaml:"entries" json:"entries"`
+	// The system databases of the rootfs that share the cache.
+	Databases []string `yaml:"databases,omitempty" json:"databases,omitempty"`
+}
+
+func NewPackagesCache(dir string) *PackagesCache {
+	return &PackagesCache{Dir: dir}
+}
+
+// GetPackagesCache returns the packages cache of the system
+// configuration.
+func GetPackagesCache() *PackagesCache {
+	return NewPackagesCache(config.LuetCfg.GetSystem().GetSystemPkgsCacheDirPath())
+}
+
+func (c *PackagesCache) GetBlobsDir() string {
+	return filepath.Join(c.Dir, BlobsDir, BlobsAlgo)
+}
+
+// GetBlobPath returns the path of the blob with the sha256 in input.
+func (c *PackagesCache) GetBlobPath(sha string) string {
+	prefix := sha
+	if len(sha) > 2 {
+		prefix = sha[0:2]
+	}
+	return filepath.Join(c.GetBlobsDir(), prefix, sha)
+}
+
+func (c *PackagesCache) HasBlob(sha string) bool {
+	_, err := os.Stat(c.GetBlobPath(sha))
+	return err == nil
+}
+
+// Publish registers the entry on the index and moves the file in the
+// blobs directory. The entry is indexed before the blob is visible
+// so that a concurrent GC never sees an unreferenced blob.
+// It returns the path of the blob.
+func (c *PackagesCache) Publish(file string, e *CacheEntry) (string, error) {
+	blob := c.GetBlobPath(e.Sha256)
+	if err := os.MkdirAll(filepath.Dir(blob), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	l, err := c.lock()
+	if err != nil {
+		return "", err
+	}
+	defer l.Unlock()
+
+	if info, err := os.Stat(file); err == nil {
+		e.Size = info.Size()
+	}
+
+	idx, err := c.readIndex()
+	if err != nil {
+		return "", err
+	}
+	c.register(idx, e)
+
+	if err = c.writeIndex(idx); err != nil {
+		return "", err
+	}
+
+	if err = os.Rename(file, blob); err != nil {
+		return "", err
+	}
+	return blob, nil
+}
+
+func (c *PackagesCache) lock() (*flock.Flock, error) {
+	if err := os.MkdirAll(c.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	l := flock.New(filepath.Join(c.Dir, IndexLockFile))
+	if err := l.Lock(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (c *PackagesCache) readIndex() (*CacheIndex, error) {
+	ans := &CacheIndex{Entries: []*CacheEntry{}}
+
+	data, err := os.ReadFile(filepath.Join(c.Dir, IndexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ans, nil
+		}
+		return nil, err
+	}
+
+	if err = yaml.Unmarshal(data, ans); err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+func (c *PackagesCache) writeIndex(idx *CacheIndex) error {
+	data, err := yaml.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	f := filepath.Join(c.Dir, IndexFile)
+	if err = os.WriteFile(f+".tmp", data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(f+".tmp", f)
+}
+
+// ReadIndex returns the entries of the cache index.
+func (c *PackagesCache) ReadIndex() (*CacheIndex, error) {
+	l, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+
+	return c.readIndex()
+}
+
+// Register adds or updates the entry of the artifact on the index
+// and updates the last usage time.
+func (c *PackagesCache) Register(e *CacheEntry) error {
+	l, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	idx, err := c.readIndex()
+	if err != nil {
+		return err
+	}
+
+	c.register(idx, e)
+
+	return c.writeIndex(idx)
+}
+
+// GetRebuiltBlob returns the sha256 of the blob rebuilt from a delta
+// for the artifact with the sha256 in input or an empty string.
+func (c *PackagesCache) GetRebuiltBlob(source string) string {
+	idx, err := c.ReadIndex()
+	if err != nil {
+		return ""
+	}
+
+	for _, e := range idx.Entries {
+		if e.Source == source && c.HasBlob(e.Sha256) {
+			return e.Sha256
+		}
+	}
+
+	return ""
+}
+
+// AddDatabase registers the system database of a rootfs that
+// uses the cache.
+func (c *PackagesCache) AddDatabase(path string) error {
+	l, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	idx, err := c.readIndex()
+	if err != nil {
+		return err
+	}
+
+	for _, d := range idx.Databases {
+		if d == path {
+			return nil
+		}
+	}
+	idx.Databases = append(idx.Databases, path)
+
+	return c.writeIndex(idx)
+}
+
+func (c *PackagesCache) register(idx *CacheIndex, e *CacheEntry) {
+	now := fmt.Sprintf("%d", time.Now().Unix())
+	var entry *CacheEntry
+	for _, ie := range idx.Entries {
+		if ie.Repository == e.Repository && ie.Package == e.Package &&
+			ie.Version == e.Version && ie.Artifact == e.Artifact {
+			entry = ie
+			break
+		}
+	}
+
+	if entry == nil {
+		entry = e
+		entry.Created = now
+		idx.Entries = append(idx.Entries, entry)
+	} else {
+		entry.Sha256 = e.Sha256
+		entry.Source = e.Source
+		entry.Size = e.Size
+	}
+	entry.LastUsed = now
+}
+
+func (e *CacheEntry) GetLastUsed() time.Time {
+	tsec, _ := strconv.ParseInt(e.LastUsed, 10, 64)
+	return time.Unix(tsec, 0)
+}
+
+func (e *CacheEntry) String() string {
+	return fmt.Sprintf("%s/%s-%s::%s", filepath.Dir(e.Package),
+		filepath.Base(e.Package), e.Version, e.Repository)
+}
@@ -0,0 +1,210 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/geaaru/luet/pkg/v2/cache"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func addBlob(c *PackagesCache, pkg, version, sha string, size int) {
+	f := filepath.Join(c.Dir, "tmp-"+sha)
+	Expect(os.WriteFile(f, make([]byte, size), 0644)).ToNot(HaveOccurred())
+	_, err := c.Publish(f, &CacheEntry{
+		Repository: "repo1",
+		Package:    pkg,
+		Version:    version,
+		Artifact:   filepath.Base(pkg) + "-" + version + ".package.tar.zst",
+		Sha256:     sha,
+	})
+	Expect(err).ToNot(HaveOccurred())
+}
+
+var _ = Describe("Packages cache", func() {
+
+	var tmpdir string
+	var c *PackagesCache
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "cache")
+		Expect(err).ToNot(HaveOccurred())
+		c = NewPackagesCache(tmpdir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	Context("Blobs", func() {
+		It("Stores the file by sha256", func() {
+			addBlob(c, "app/foo", "1.0", "aabbcc", 10)
+			Expect(c.HasBlob("aabbcc")).To(BeTrue())
+			Expect(c.GetBlobPath("aabbcc")).To(Equal(
+				filepath.Join(tmpdir, "blobs", "sha256", "aa", "aabbcc")))
+
+			idx, err := c.ReadIndex()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(idx.Entries).To(HaveLen(1))
+			Expect(idx.Entries[0].Package).To(Equal("app/foo"))
+			Expect(idx.Entries[0].Size).To(Equal(int64(10)))
+		})
+
+		It("Registers the databases once", func() {
+			Expect(c.AddDatabase("/rootfs1/luet.db")).ToNot(HaveOccurred())
+			Expect(c.AddDatabase("/rootfs2/luet.db")).ToNot(HaveOccurred())
+			Expect(c.AddDatabase("/rootfs1/luet.db")).ToNot(HaveOccurred())
+
+			idx, err := c.ReadIndex()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(idx.Databases).To(Equal([]string{
+				"/rootfs1/luet.db", "/rootfs2/luet.db",
+			}))
+		})
+
+		It("Updates the existing entry", func() {
+			addBlob(c, "app/foo", "1.0", "aabbcc", 10)
+			addBlob(c, "app/foo", "1.0", "aabbcc", 10)
+			idx, err := c.ReadIndex()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(idx.Entries).To(HaveLen(1))
+		})
+	})
+
+	Context("GC", func() {
+		It("Removes everything without policies", func() {
+			addBlob(c, "app/foo", "1.0", "aa01", 10)
+			addBlob(c, "app/bar", "1.0", "bb01", 10)
+			Expect(os.WriteFile(filepath.Join(tmpdir, "old.package.tar"),
+				[]byte("x"), 0644)).ToNot(HaveOccurred())
+
+			res, err := c.GC(&GCOpts{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Entries).To(HaveLen(2))
+			Expect(res.Blobs).To(HaveLen(3))
+			Expect(c.HasBlob("aa01")).To(BeFalse())
+			Expect(c.HasBlob("bb01")).To(BeFalse())
+		})
+
+		It("Preserves the partial downloads and the new files", func() {
+			addBlob(c, "app/foo", "1.0", "aa01", 10)
+			partial := c.GetBlobPath("aa03") + ".part"
+			Expect(os.WriteFile(partial, []byte("x"), 0644)).ToNot(HaveOccurred())
+			// A blob published by a concurrent process not yet indexed.
+			newer := c.GetBlobPath("aa02")
+			Expect(os.WriteFile(newer, []byte("x"), 0644)).ToNot(HaveOccurred())
+			future := time.Now().Add(time.Hour)
+			Expect(os.Chtimes(newer, future, future)).ToNot(HaveOccurred())
+			legacyPartial := filepath.Join(tmpdir, "foo.package.tar.part")
+			Expect(os.WriteFile(legacyPartial, []byte("x"), 0644)).ToNot(HaveOccurred())
+
+			res, err := c.GC(&GCOpts{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Blobs).To(Equal([]string{c.GetBlobPath("aa01")}))
+			Expect(partial).To(BeARegularFile())
+			Expect(newer).To(BeARegularFile())
+			Expect(legacyPartial).To(BeARegularFile())
+			Expect(filepath.Join(tmpdir, IndexFile)).To(BeARegularFile())
+		})
+
+		It("Preserves the legacy files with entries to keep", func() {
+			legacy := filepath.Join(tmpdir, "old.package.tar")
+			Expect(os.WriteFile(legacy, []byte("x"), 0644)).ToNot(HaveOccurred())
+
+			res, err := c.GC(&GCOpts{
+				Keep: func(e *CacheEntry) bool { return true },
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Blobs).To(HaveLen(0))
+			Expect(legacy).To(BeARegularFile())
+
+			res, err = c.GC(&GCOpts{OlderThan: time.Hour})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Blobs).To(HaveLen(0))
+
+			past := time.Now().Add(-2 * time.Hour)
+			Expect(os.Chtimes(legacy, past, past)).ToNot(HaveOccurred())
+			res, err = c.GC(&GCOpts{OlderThan: time.Hour})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Blobs).To(Equal([]string{legacy}))
+			Expect(legacy).ToNot(BeAnExistingFile())
+		})
+
+		It("Preserves the kept entries", func() {
+			addBlob(c, "app/foo", "1.0", "aa01", 10)
+			addBlob(c, "app/bar", "1.0", "bb01", 10)
+
+			res, err := c.GC(&GCOpts{
+				Keep: func(e *CacheEntry) bool { return e.Package == "app/foo" },
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Entries).To(HaveLen(1))
+			Expect(c.HasBlob("aa01")).To(BeTrue())
+			Expect(c.HasBlob("bb01")).To(BeFalse())
+		})
+
+		It("Keeps a blob shared by a preserved entry", func() {
+			addBlob(c, "app/foo", "1.0", "aa01", 10)
+			addBlob(c, "app/foo-alias", "1.0", "aa01", 10)
+
+			res, err := c.GC(&GCOpts{
+				Keep: func(e *CacheEntry) bool { return e.Package == "app/foo" },
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Entries).To(HaveLen(1))
+			Expect(res.Blobs).To(HaveLen(0))
+			Expect(c.HasBlob("aa01")).To(BeTrue())
+		})
+
+		It("Removes the less recently used entries over the max size", func() {
+			addBlob(c, "app/foo", "1.0", "aa01", 10)
+			addBlob(c, "app/bar", "1.0", "bb01", 10)
+			addBlob(c, "app/baz", "1.0", "cc01", 10)
+
+			res, err := c.GC(&GCOpts{MaxSize: 25, Pretend: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Entries).To(HaveLen(1))
+			Expect(res.Blobs).To(HaveLen(1))
+			// Pretend doesn't touch the cache.
+			Expect(c.HasBlob(filepath.Base(res.Blobs[0]))).To(BeTrue())
+
+			res, err = c.GC(&GCOpts{MaxSize: 25})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Entries).To(HaveLen(1))
+			Expect(c.HasBlob(filepath.Base(res.Blobs[0]))).To(BeFalse())
+
+			idx, err := c.ReadIndex()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(idx.Entries).To(HaveLen(2))
+		})
+
+		It("Removes the entries older than the duration", func() {
+			addBlob(c, "app/foo", "1.0", "aa01", 10)
+
+			res, err := c.GC(&GCOpts{OlderThan: time.Hour})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Entries).To(HaveLen(0))
+			Expect(c.HasBlob("aa01")).To(BeTrue())
+		})
+	})
+
+	Context("ParseDuration", func() {
+		It("Supports days", func() {
+			d, err := ParseDuration("30d")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d).To(Equal(30 * 24 * time.Hour))
+
+			d, err = ParseDuration("12h")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d).To(Equal(12 * time.Hour))
+		})
+	})
+})
@@ -0,0 +1,232 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GCOpts defines the policies used to select the entries of the
+// cache to remove. Without OlderThan and MaxSize all the entries not
+// kept are removed.
+type GCOpts struct {
+	// Keep returns true for the entries that must be preserved.
+	Keep func(e *CacheEntry) bool
+	// Remove the entries not used from this duration.
+	OlderThan time.Duration
+	// Remove the entries less recently used until the size
+	// of the blobs is under this limit.
+	MaxSize int64
+	// Only report the blobs that will be removed.
+	Pretend bool
+}
+
+type GCResult struct {
+	Entries []*CacheEntry `yaml:"entries" json:"entries"`
+	Blobs   []string      `yaml:"blobs" json:"blobs"`
+	Size    int64         `yaml:"size" json:"size"`
+}
+
+func (o *GCOpts) HasPolicies() bool {
+	return o.OlderThan > 0 || o.MaxSize > 0
+}
+
+// GC removes the entries of the index selected by the policies
+// and the blobs that are no more referenced by the index.
+// Files of the cache not related to the index, like the tarballs
+// downloaded by older releases, are removed only when there aren't
+// entries to keep, because it's not possible to know the package
+// of them. The partial downloads and the files created after the
+// start of the GC are always preserved.
+func (c *PackagesCache) GC(opts *GCOpts) (*GCResult, error) {
+	start := time.Now()
+	ans := &GCResult{
+		Entries: []*CacheEntry{},
+		Blobs:   []string{},
+	}
+
+	l, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+
+	idx, err := c.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the entries without blob.
+	entries := []*CacheEntry{}
+	for _, e := range idx.Entries {
+		if c.HasBlob(e.Sha256) {
+			entries = append(entries, e)
+		}
+	}
+
+	// Sort the entries by usage from the older.
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].GetLastUsed().Before(entries[j].GetLastUsed())
+	})
+
+	removed := make(map[*CacheEntry]bool, 0)
+	candidates := []*CacheEntry{}
+	for _, e := range entries {
+		if opts.Keep != nil && opts.Keep(e) {
+			continue
+		}
+		candidates = append(candidates, e)
+	}
+
+	if !opts.HasPolicies() {
+		for _, e := range candidates {
+			removed[e] = true
+		}
+	}
+
+	if opts.OlderThan > 0 {
+		limit := time.Now().Add(-opts.OlderThan)
+		for _, e := range candidates {
+			if e.GetLastUsed().Before(limit) {
+				removed[e] = true
+			}
+		}
+	}
+
+	if opts.MaxSize > 0 {
+		// Size of the blobs still referenced.
+		refs := make(map[string]int, 0)
+		var size int64 = 0
+		for _, e := range entries {
+			if removed[e] {
+				continue
+			}
+			if refs[e.Sha256] == 0 {
+				size += e.Size
+			}
+			refs[e.Sha256]++
+		}
+
+		for _, e := range candidates {
+			if size <= opts.MaxSize {
+				break
+			}
+			if removed[e] {
+				continue
+			}
+			removed[e] = true
+			refs[e.Sha256]--
+			if refs[e.Sha256] == 0 {
+				size -= e.Size
+			}
+		}
+	}
+
+	referenced := make(map[string]bool, 0)
+	idx.Entries = []*CacheEntry{}
+	for _, e := range entries {
+		if removed[e] {
+			ans.Entries = append(ans.Entries, e)
+			continue
+		}
+		idx.Entries = append(idx.Entries, e)
+		referenced[e.Sha256] = true
+	}
+
+	// Collect the blobs not referenced.
+	err = filepath.Walk(c.GetBlobsDir(), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		if isTransientFile(info, start) {
+			return nil
+		}
+
+		if !referenced[info.Name()] {
+			ans.Blobs = append(ans.Blobs, p)
+			ans.Size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the files of the legacy cache layout.
+	if opts.Keep == nil {
+		files, err := os.ReadDir(c.Dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, f := range files {
+			if f.IsDir() || f.Name() == IndexFile {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil || isTransientFile(info, start) {
+				continue
+			}
+			if opts.OlderThan > 0 && info.ModTime().After(start.Add(-opts.OlderThan)) {
+				continue
+			}
+			ans.Size += info.Size()
+			ans.Blobs = append(ans.Blobs, filepath.Join(c.Dir, f.Name()))
+		}
+	}
+
+	if opts.Pretend {
+		return ans, nil
+	}
+
+	for _, b := range ans.Blobs {
+		if err := os.Remove(b); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	if err = c.writeIndex(idx); err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+// isTransientFile returns true for the files that could be in use:
+// the downloads in progress, the temporary files and the files
+// written after the start of the GC.
+func isTransientFile(info os.FileInfo, start time.Time) bool {
+	name := info.Name()
+	if strings.HasPrefix(name, ".") || strings.Contains(name, ".part") ||
+		strings.HasSuffix(name, ".tmp") {
+		return true
+	}
+	return info.ModTime().After(start)
+}
+
+// ParseDuration parses a duration string with the support
+// of the days unit (ex. 30d).
+func ParseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -29,7 +29,10 @@ import (
 	. "github.com/geaaru/luet/pkg/logger"
 	pkg "github.com/geaaru/luet/pkg/package"
 	"github.com/geaaru/luet/pkg/solver"
+	"github.com/geaaru/luet/pkg/v2/cache"
 	compression "github.com/geaaru/luet/pkg/v2/compiler/types/compression"
+	"github.com/geaaru/luet/pkg/v2/configprotect"
+	"github.com/geaaru/luet/pkg/v2/integrity"
 
 	tarf "github.com/geaaru/tar-formers/pkg/executor"
 	tarf_specs "github.com/geaaru/tar-formers/pkg/specs"
@@ -55,6 +58,10 @@ type PackageArtifact struct {
 	Files             []string                          `json:"files" yaml:"files"`
 	PackageCacheImage string                            `json:"package_cacheimage,omitempty" yaml:"package_cacheimage,omitempty"`
 	Runtime           *pkg.DefaultPackage               `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+	Deltas            []*DeltaArtifact                  `json:"deltas,omitempty" yaml:"deltas,omitempty"`
+	// FilesMeta contains the metadata of the files of the archive
+	// extracted by the last unpack.
+	FilesMeta []*pkg.FileMeta `json:"-" yaml:"-"`
 }
 
 func (p *PackageArtifact) ShallowCopy() *PackageArtifact {
@@ -113,10 +120,58 @@ func (a *PackageArtifact) GetPackageTreePath(treefs string) string {
 	return ans
 }
 
+// GetCacheFile returns the path of the artifact in the packages cache.
+// The artifacts with a sha256 checksum are stored as blobs of the
+// content addressed cache, the others with the artifact name.
+// Without the blob of the artifact, the blob of the tarball rebuilt
+// from a delta is used if available.
+func (a *PackageArtifact) GetCacheFile() string {
+	if sha, ok := a.Checksums[string(SHA256)]; ok && sha != "" {
+		c := cache.GetPackagesCache()
+		if !c.HasBlob(sha) {
+			if rebuilt := c.GetRebuiltBlob(sha); rebuilt != "" {
+				return c.GetBlobPath(rebuilt)
+			}
+		}
+		return c.GetBlobPath(sha)
+	}
+	return filepath.Join(LuetCfg.GetSystem().GetSystemPkgsCacheDirPath(),
+		path.Base(a.Path))
+}
+
+// GetCacheEntry returns the entry of the packages cache index
+// of the artifact or nil for the artifacts without sha256 checksum.
+func (a *PackageArtifact) GetCacheEntry() *cache.CacheEntry {
+	sha, ok := a.Checksums[string(SHA256)]
+	if !ok || sha == "" || a.GetPackage() == nil {
+		return nil
+	}
+	return &cache.CacheEntry{
+		Repository: a.GetPackage().Repository,
+		Package:    a.GetPackage().PackageName(),
+		Version:    a.GetPackage().GetVersion(),
+		Artifact:   path.Base(a.Path),
+		Sha256:     sha,
+	}
+}
+
+// PublishCacheFile moves the file downloaded in the packages cache
+// and returns the path of the cache file. The blobs are registered
+// on the cache index before being visible to the cleanup.
+func (a *PackageArtifact) PublishCacheFile(file string) (string, error) {
+	if e := a.GetCacheEntry(); e != nil {
+		return cache.GetPackagesCache().Publish(file, e)
+	}
+
+	cacheFile := a.GetCacheFile()
+	if err := os.Rename(file, cacheFile); err != nil {
+		return "", err
+	}
+	return cacheFile, nil
+}
+
 func (a *PackageArtifact) ResolveCachePath() {
-	artifactName := path.Base(a.Path)
-	cacheFile := filepath.Join(LuetCfg.GetSystem().GetSystemPkgsCacheDirPath(), artifactName)
-	a.CachePath = cacheFile
+	a.CachePath = a.GetCacheFile()
 }
 
 func (a *PackageArtifact) Hash() error {
@@ -129,13 +184,33 @@ func (a *PackageArtifact) Verify() error {
 		return err
 	}
 
-	if err := sum.Compare(a.Checksums); err != nil {
+	if err := sum.Compare(a.getCacheChecksums()); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// getCacheChecksums returns the checksums of the cache file. A tarball
+// rebuilt from a delta is a blob with a checksum different from the
+// artifact of the repository.
+func (a *PackageArtifact) getCacheChecksums() Checksums {
+	sha := a.Checksums[string(SHA256)]
+	if sha == "" || a.CachePath == "" {
+		return a.Checksums
+	}
+
+	c := cache.GetPackagesCache()
+	if a.CachePath != c.GetBlobPath(sha) {
+		if rebuilt := c.GetRebuiltBlob(sha); rebuilt != "" &&
+			a.CachePath == c.GetBlobPath(rebuilt) {
+			return Checksums{string(SHA256): rebuilt}
+		}
+	}
+
+	return a.Checksums
+}
+
 func (a *PackageArtifact) GetRepository() string {
 	ans := ""
 
@@ -523,18 +598,20 @@ func tarModifierWrapperFunc(path, dst string, header *tar.Header, content io.Rea
 	differs := (existingHash != "" && existingHash != tarHash) || (err != nil && f != nil && header.Size != f.Size())
 
 	// Check if exists
+	protected := false
 	if fileHelper.Exists(destPath) && differs {
 		for i := 1; i < 1000; i++ {
 			name := filepath.Join(filepath.Join(filepath.Dir(path),
 				fmt.Sprintf("._cfg%04d_%s", i, filepath.Base(path))))
 
-			if fileHelper.Exists(name) {
+			if fileHelper.Exists(filepath.Join(dst, name)) {
 				continue
 			}
 
 			Info(fmt.Sprintf("Found protected file %s. Creating %s.", destPath,
 				filepath.Join(dst, name)))
 			path = name
+			protected = true
 			break
 		}
 	}
@@ -545,6 +622,19 @@ func tarModifierWrapperFunc(path, dst string, header *tar.Header, content io.Rea
 	if err != nil {
 		return err
 	}
+
+	// Store the shipped version used as common ancestor by
+	// luet config-update. The pristine copy of a file with a pending
+	// update is updated when the update is processed.
+	if !protected {
+		err = configprotect.SavePristine(
+			configprotect.GetPristineDir(dst, LuetCfg.GetSystem().DatabasePath),
+			path, buffer.Bytes())
+		if err != nil {
+			Warning(fmt.Sprintf("Error on store pristine copy of %s: %s",
+				destPath, err.Error()))
+		}
+	}
 	if setfileprops {
 		meta := tarf_specs.NewFileMeta(header)
 		return t.SetFileProps(filepath.Join(dst, path), &meta, false)
@@ -588,47 +678,103 @@ func (a *PackageArtifact) Unpack(dst string, enableSubsets bool) error {
 	// Create untar specs
 	spec := a.GetTarFormersSpec(enableSubsets)
 
-	switch a.CompressionType {
-	case compression.Zstandard:
-		original, err := os.Open(a.CachePath)
-		if err != nil {
-			return errors.Wrap(err, "Cannot open "+a.CachePath)
+	return a.unpackWithSpec(dst, protectedFiles, spec)
+}
+
+// UnpackFiles extracts only the files in input of the artifact.
+// The files are without the initial slash like the files of the
+// artifact. The protected files are not processed.
+func (a *PackageArtifact) UnpackFiles(dst string, files []string) error {
+	if !strings.HasPrefix(dst, "/") {
+		return errors.New("destination must be an absolute path")
+	}
+
+	selected := make(map[string]bool, 0)
+	for _, f := range files {
+		selected[f] = true
+	}
+
+	spec := a.GetTarFormersSpec(false)
+	for _, f := range a.Files {
+		if !selected[f] {
+			spec.IgnoreFiles = append(spec.IgnoreFiles, "/"+f)
 		}
-		defer original.Close()
+	}
+
+	return a.unpackWithSpec(dst, []string{}, spec)
+}
 
-		//		bufferedReader := bufio.NewReader(original)
+func (a *PackageArtifact) unpackWithSpec(dst string, protectedFiles []string,
+	spec *tarf_specs.SpecFile) error {
+
+	original, err := os.Open(a.CachePath)
+	if err != nil {
+		return errors.Wrap(err, "Cannot open "+a.CachePath)
+	}
+	defer original.Close()
+
+	var in io.Reader = original
+	switch a.CompressionType {
+	case compression.Zstandard:
 		d, err := zstd.NewReader(original)
 		if err != nil {
 			return err
 		}
 		defer d.Close()
-
-		err = helpers.UntarProtectSpecCompress(dst,
-			protectedFiles, tarModifierWrapperFunc, spec, d)
-		return err
+		in = d
 	case compression.GZip:
-		// Create the uncompressed archive
-		original, err := os.Open(a.CachePath)
-		if err != nil {
-			return errors.Wrap(err, "Cannot open "+a.CachePath)
-		}
-		defer original.Close()
-
 		r, err := gzip.NewReader(original)
 		if err != nil {
 			return err
 		}
 		defer r.Close()
-
-		err = helpers.UntarProtectSpecCompress(dst,
-			protectedFiles, tarModifierWrapperFunc, spec, r)
-		return err
+		in = r
 	// Defaults to tar only (covers when "none" is supplied)
 	default:
-		return helpers.UntarProtect(a.CachePath, dst,
-			LuetCfg.GetGeneral().SameOwner,
-			LuetCfg.GetGeneral().OverwriteDirPerms,
-			protectedFiles, tarModifierWrapperFunc)
+	}
+
+	// The tree and the metadata tarballs are without Runtime.
+	if a.Runtime == nil {
+		return helpers.UntarProtectSpecCompress(dst,
+			protectedFiles, tarModifierWrapperFunc, spec, in)
+	}
+
+	// The metadata of the files are computed from the archive
+	// to avoid reading again the files from the rootfs.
+	mr := integrity.NewTarMetaReader(in, spec.SameOwner, newSkipFunc(spec))
+	err = helpers.UntarProtectSpecCompress(dst,
+		protectedFiles, tarModifierWrapperFunc, spec, mr)
+	meta, merr := mr.Close()
+	if err != nil {
+		return err
+	}
+	if merr != nil {
+		return errors.Wrap(merr, "Error on compute files metadata")
+	}
+	a.FilesMeta = meta
+
+	return nil
+}
+
+// newSkipFunc returns the function that matches the files ignored
+// by the spec.
+func newSkipFunc(spec *tarf_specs.SpecFile) func(string) bool {
+	ignoreFiles := make(map[string]bool, 0)
+	for _, f := range spec.IgnoreFiles {
+		ignoreFiles[f] = true
+	}
+	ignoreRegexes := compileRegexes(spec.IgnoreRegexes)
+
+	return func(file string) bool {
+		if ignoreFiles[file] {
+			return true
+		}
+		for _, r := range ignoreRegexes {
+			if r.MatchString(file) {
+				return true
+			}
+		}
+		return false
 	}
 }
 
@@ -0,0 +1,389 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package artifact
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	"github.com/geaaru/luet/pkg/v2/compiler/types/compression"
+
+	zstd "github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// DeltaArtifact describes a tarball that contains only the entries
+// changed between a previous version of the package and the version
+// of the artifact. The regular files not changed are not included
+// and they are taken from the rootfs where the previous version is
+// installed.
+type DeltaArtifact struct {
+	FromVersion     string                     `json:"from_version" yaml:"from_version"`
+	Path            string                     `json:"path" yaml:"path"`
+	Checksums       Checksums                  `json:"checksums" yaml:"checksums"`
+	CompressionType compression.Implementation `json:"compressiontype" yaml:"compressiontype"`
+	Size            int64                      `json:"size,omitempty" yaml:"size,omitempty"`
+
+	// The regular files reused from the previous version with
+	// their sha256 checksum.
+	Unchanged map[string]string `json:"unchanged,omitempty" yaml:"unchanged,omitempty"`
+}
+
+type deltaEntry struct {
+	Sha256 string
+	Mode   int64
+	Uid    int
+	Gid    int
+}
+
+// GetDelta returns the delta available to upgrade the package
+// from the version in input.
+func (a *PackageArtifact) GetDelta(fromVersion string) *DeltaArtifact {
+	for _, d := range a.Deltas {
+		if d.FromVersion == fromVersion {
+			return d
+		}
+	}
+	return nil
+}
+
+// GetDeltaName returns the name of the delta tarball from the
+// version in input.
+func (a *PackageArtifact) GetDeltaName(fromVersion string) string {
+	name := filepath.Base(a.Path)
+	if idx := strings.Index(name, ".tar"); idx > 0 {
+		name = name[:idx]
+	}
+	return fmt.Sprintf("%s.delta-%s.tar%s", name, fromVersion,
+		compression.Zstandard.Ext())
+}
+
+// ToArtifact returns a PackageArtifact usable to download the delta
+// tarball through the repository clients.
+func (d *DeltaArtifact) ToArtifact(a *PackageArtifact) *PackageArtifact {
+	ans := NewPackageArtifact(d.Path)
+	ans.Checksums = d.Checksums
+	ans.CompressionType = d.CompressionType
+	ans.Runtime = a.Runtime
+	ans.CompileSpec = a.CompileSpec
+	return ans
+}
+
+func newTarReader(file string, t compression.Implementation) (*tar.Reader, func(), error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Cannot open "+file)
+	}
+
+	switch t {
+	case compression.Zstandard:
+		r, err := zstd.NewReader(bufio.NewReader(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return tar.NewReader(r), func() { r.Close(); f.Close() }, nil
+	case compression.GZip:
+		r, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return tar.NewReader(r), func() { r.Close(); f.Close() }, nil
+	default:
+		return tar.NewReader(f), func() { f.Close() }, nil
+	}
+}
+
+// tarballEntries returns the sha256 and the permissions of the
+// regular files of the tarball.
+func tarballEntries(file string, t compression.Implementation) (map[string]*deltaEntry, error) {
+	ans := make(map[string]*deltaEntry, 0)
+
+	tr, closer, err := newTarReader(file, t)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		h := sha256.New()
+		if _, err := io.Copy(h, tr); err != nil {
+			return nil, err
+		}
+
+		ans[hdr.Name] = &deltaEntry{
+			Sha256: fmt.Sprintf("%x", h.Sum(nil)),
+			Mode:   hdr.Mode,
+			Uid:    hdr.Uid,
+			Gid:    hdr.Gid,
+		}
+	}
+
+	return ans, nil
+}
+
+// CreateDelta creates the delta tarball between the tarball of the
+// base artifact and the tarball of the artifact in the directory dstDir.
+// The CachePath of the artifacts must be set with the path of the tarballs.
+func (a *PackageArtifact) CreateDelta(base *PackageArtifact, dstDir string) (*DeltaArtifact, error) {
+	baseEntries, err := tarballEntries(base.CachePath, base.CompressionType)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error on read base tarball "+base.CachePath)
+	}
+
+	newEntries, err := tarballEntries(a.CachePath, a.CompressionType)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error on read tarball "+a.CachePath)
+	}
+
+	ans := &DeltaArtifact{
+		FromVersion:     base.GetVersion(),
+		Path:            a.GetDeltaName(base.GetVersion()),
+		Checksums:       Checksums{},
+		CompressionType: compression.Zstandard,
+		Unchanged:       make(map[string]string, 0),
+	}
+
+	for f, e := range newEntries {
+		if b, ok := baseEntries[f]; ok && b.Sha256 == e.Sha256 &&
+			b.Mode == e.Mode && b.Uid == e.Uid && b.Gid == e.Gid {
+			ans.Unchanged[f] = e.Sha256
+		}
+	}
+	baseEntries = nil
+	newEntries = nil
+
+	deltaFile := filepath.Join(dstDir, ans.Path)
+	out, err := os.Create(deltaFile)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	enc, err := zstd.NewWriter(out)
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(enc)
+
+	tr, closer, err := newTarReader(a.CachePath, a.CompressionType)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := ans.Unchanged[hdr.Name]; ok && hdr.Typeflag == tar.TypeReg {
+			continue
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	out.Close()
+
+	sha, err := fileHelper.Sha256Sum(deltaFile)
+	if err != nil {
+		return nil, err
+	}
+	ans.Checksums[string(SHA256)] = sha
+
+	if info, err := os.Stat(deltaFile); err == nil {
+		ans.Size = info.Size()
+	}
+
+	return ans, nil
+}
+
+// CheckRootfs validates that the files reused by the delta
+// are present in the rootfs with the expected checksum.
+func (d *DeltaArtifact) CheckRootfs(rootfs string) error {
+	for f, sum := range d.Unchanged {
+		sha, err := fileHelper.Sha256Sum(filepath.Join(rootfs, f))
+		if err != nil {
+			return err
+		}
+		if sha != sum {
+			return fmt.Errorf("file %s modified", f)
+		}
+	}
+	return nil
+}
+
+// newCompressWriter returns the writer of the compression type in input.
+func newCompressWriter(w io.Writer, t compression.Implementation) (io.WriteCloser, error) {
+	switch t {
+	case compression.Zstandard:
+		return zstd.NewWriter(w)
+	case compression.GZip:
+		return gzip.NewWriter(w), nil
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// Apply rebuilds the complete package tarball in the file dst
+// with the entries of the delta tarball and the files reused from
+// the rootfs. The directories of the delta are written at first,
+// followed by the reused files and the other entries of the delta.
+// The tarball is compressed with the compression type in input.
+func (d *DeltaArtifact) Apply(deltaTarball, rootfs, dst string,
+	t compression.Implementation) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	enc, err := newCompressWriter(out, t)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(enc)
+
+	copyEntries := func(dirs bool) error {
+		tr, closer, err := newTarReader(deltaTarball, d.CompressionType)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			if (hdr.Typeflag == tar.TypeDir) != dirs {
+				continue
+			}
+
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := copyEntries(true); err != nil {
+		return err
+	}
+
+	files := []string{}
+	for f := range d.Unchanged {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		if err := d.addRootfsFile(tw, rootfs, f); err != nil {
+			return err
+		}
+	}
+
+	if err := copyEntries(false); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func (d *DeltaArtifact) addRootfsFile(tw *tar.Writer, rootfs, f string) error {
+	path := filepath.Join(rootfs, f)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = f
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		hdr.Uid = int(stat.Uid)
+		hdr.Gid = int(stat.Gid)
+	}
+	// Avoid to store the names of the local users/groups.
+	hdr.Uname = ""
+	hdr.Gname = ""
+
+	fd, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	h := sha256.New()
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.MultiWriter(tw, h), fd); err != nil {
+		return err
+	}
+
+	if fmt.Sprintf("%x", h.Sum(nil)) != d.Unchanged[f] {
+		return fmt.Errorf("file %s modified", f)
+	}
+
+	return nil
+}
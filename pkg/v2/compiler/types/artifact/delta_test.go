@@ -0,0 +1,136 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package artifact_test
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+
+	pkg "github.com/geaaru/luet/pkg/package"
+	. "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	"github.com/geaaru/luet/pkg/v2/compiler/types/compression"
+
+	zstd "github.com/klauspost/compress/zstd"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func writeTarball(file string, files map[string]string) {
+	f, err := os.Create(file)
+	Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	Expect(tw.WriteHeader(&tar.Header{
+		Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755,
+	})).ToNot(HaveOccurred())
+	for _, name := range []string{"etc/a", "etc/b", "etc/c"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		Expect(tw.WriteHeader(&tar.Header{
+			Name: name, Typeflag: tar.TypeReg, Mode: 0644,
+			Size: int64(len(content)),
+		})).ToNot(HaveOccurred())
+		_, err = tw.Write([]byte(content))
+		Expect(err).ToNot(HaveOccurred())
+	}
+	Expect(tw.Close()).ToNot(HaveOccurred())
+}
+
+func readZstdTarball(file string) map[string]string {
+	ans := map[string]string{}
+	f, err := os.Open(file)
+	Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+
+	r, err := zstd.NewReader(f)
+	Expect(err).ToNot(HaveOccurred())
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		Expect(err).ToNot(HaveOccurred())
+		data, err := io.ReadAll(tr)
+		Expect(err).ToNot(HaveOccurred())
+		ans[hdr.Name] = string(data)
+	}
+	return ans
+}
+
+var _ = Describe("Delta", func() {
+	Context("Create and apply a delta", func() {
+
+		var tmpdir string
+
+		BeforeEach(func() {
+			var err error
+			tmpdir, err = os.MkdirTemp("", "delta")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpdir)
+		})
+
+		It("Rebuilds the package tarball", func() {
+			base := NewPackageArtifact("foo-1.0.package.tar")
+			base.CachePath = filepath.Join(tmpdir, "foo-1.0.package.tar")
+			base.CompressionType = compression.None
+			base.Runtime = &pkg.DefaultPackage{Category: "app", Name: "foo", Version: "1.0"}
+			writeTarball(base.CachePath, map[string]string{
+				"etc/a": "a", "etc/b": "b",
+			})
+
+			art := NewPackageArtifact("foo-1.1.package.tar")
+			art.CachePath = filepath.Join(tmpdir, "foo-1.1.package.tar")
+			art.CompressionType = compression.None
+			art.Runtime = &pkg.DefaultPackage{Category: "app", Name: "foo", Version: "1.1"}
+			writeTarball(art.CachePath, map[string]string{
+				"etc/a": "a", "etc/b": "b2", "etc/c": "c",
+			})
+
+			delta, err := art.CreateDelta(base, tmpdir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(delta.FromVersion).To(Equal("1.0"))
+			Expect(delta.Path).To(Equal("foo-1.1.package.delta-1.0.tar.zst"))
+			Expect(delta.Unchanged).To(HaveLen(1))
+			Expect(delta.Unchanged).To(HaveKey("etc/a"))
+
+			deltaFile := filepath.Join(tmpdir, delta.Path)
+			Expect(readZstdTarball(deltaFile)).To(Equal(map[string]string{
+				"etc/": "", "etc/b": "b2", "etc/c": "c",
+			}))
+
+			deltaArt := delta.ToArtifact(art)
+			deltaArt.CachePath = deltaFile
+			Expect(deltaArt.Verify()).ToNot(HaveOccurred())
+
+			// Prepare the rootfs with the previous version installed.
+			rootfs := filepath.Join(tmpdir, "rootfs")
+			Expect(os.MkdirAll(filepath.Join(rootfs, "etc"), 0755)).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(rootfs, "etc/a"), []byte("a"), 0644)).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(rootfs, "etc/b"), []byte("b"), 0644)).ToNot(HaveOccurred())
+			Expect(delta.CheckRootfs(rootfs)).ToNot(HaveOccurred())
+
+			rebuilt := filepath.Join(tmpdir, "rebuilt.tar.zst")
+			Expect(delta.Apply(deltaFile, rootfs, rebuilt, compression.Zstandard)).ToNot(HaveOccurred())
+			Expect(readZstdTarball(rebuilt)).To(Equal(map[string]string{
+				"etc/": "", "etc/a": "a", "etc/b": "b2", "etc/c": "c",
+			}))
+
+			// A modified file disables the delta.
+			Expect(os.WriteFile(filepath.Join(rootfs, "etc/a"), []byte("changed"), 0644)).ToNot(HaveOccurred())
+			Expect(delta.CheckRootfs(rootfs)).To(HaveOccurred())
+		})
+	})
+})
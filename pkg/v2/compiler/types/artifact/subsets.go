@@ -150,3 +150,22 @@ func (a *PackageArtifact) unmarshalSubsets() *LuetSubsetsDefinition {
 
 	return ans
 }
+
+// FilterSubsetsFiles returns the files in input that are extracted
+// with the subsets currently enabled.
+func (a *PackageArtifact) FilterSubsetsFiles(files []string) ([]string, error) {
+	ans := []string{}
+
+	spec := a.GetTarFormersSpec(true)
+	if err := spec.Prepare(); err != nil {
+		return ans, err
+	}
+
+	for _, f := range files {
+		if !spec.IsPath2Skip("/" + f) {
+			ans = append(ans, f)
+		}
+	}
+
+	return ans, nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package artifact_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/geaaru/luet/pkg/config"
+	pkg "github.com/geaaru/luet/pkg/package"
+	. "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	"github.com/geaaru/luet/pkg/v2/compiler/types/compression"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Subsets", func() {
+	Context("Apply the enabled subsets", func() {
+
+		var tmpdir string
+		var art *PackageArtifact
+
+		BeforeEach(func() {
+			var err error
+			tmpdir, err = os.MkdirTemp("", "subsets")
+			Expect(err).ToNot(HaveOccurred())
+
+			LuetCfg.SubsetsPkgsDefMap["app/foo"] = &LuetSubsetsDefinition{
+				Definitions: map[string]*LuetSubsetDefinition{
+					"extra": {
+						Name:  "extra",
+						Rules: []string{"^/etc/c$"},
+					},
+				},
+			}
+
+			art = NewPackageArtifact("foo-1.0.package.tar")
+			art.CachePath = filepath.Join(tmpdir, "foo-1.0.package.tar")
+			art.CompressionType = compression.None
+			art.Runtime = &pkg.DefaultPackage{Category: "app", Name: "foo", Version: "1.0"}
+			art.Files = []string{"etc/a", "etc/b", "etc/c"}
+			writeTarball(art.CachePath, map[string]string{
+				"etc/a": "a", "etc/b": "b", "etc/c": "c",
+			})
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpdir)
+			delete(LuetCfg.SubsetsPkgsDefMap, "app/foo")
+			LuetCfg.Subsets.Enabled = []string{}
+		})
+
+		It("Filters the files of the disabled subsets", func() {
+			files, err := art.FilterSubsetsFiles(art.Files)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(Equal([]string{"etc/a", "etc/b"}))
+
+			LuetCfg.Subsets.Enabled = []string{"extra"}
+			files, err = art.FilterSubsetsFiles(art.Files)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(Equal([]string{"etc/a", "etc/b", "etc/c"}))
+		})
+
+		It("Extracts only the selected files", func() {
+			rootfs := filepath.Join(tmpdir, "rootfs")
+			Expect(os.MkdirAll(rootfs, 0755)).ToNot(HaveOccurred())
+
+			Expect(art.UnpackFiles(rootfs, []string{"etc/c"})).ToNot(HaveOccurred())
+			Expect(filepath.Join(rootfs, "etc/c")).To(BeARegularFile())
+			Expect(filepath.Join(rootfs, "etc/a")).ToNot(BeAnExistingFile())
+			Expect(filepath.Join(rootfs, "etc/b")).ToNot(BeAnExistingFile())
+		})
+	})
+})
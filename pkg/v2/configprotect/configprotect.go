@@ -0,0 +1,307 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package configprotect
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	PristineDir = "config-protect"
+)
+
+var regexCandidate = regexp.MustCompile(`^\._cfg([0-9]{4})_(.+)$`)
+
+// PendingFile is a protected file with one or more new versions
+// available as ._cfgNNNN_<name> files.
+type PendingFile struct {
+	// Path of the configuration file relative to the rootfs.
+	Path string `json:"path" yaml:"path"`
+	// Paths of the new versions relative to the rootfs sorted by
+	// index. The last is the newer.
+	Candidates []string `json:"candidates" yaml:"candidates"`
+}
+
+// ConfigUpdater processes the pending configuration files of a
+// rootfs. The pristine directory contains the copy of the last
+// version of the protected files shipped by the packages and it's
+// used as common ancestor on 3-way merge.
+type ConfigUpdater struct {
+	Rootfs      string
+	PristineDir string
+}
+
+// GetPristineDir returns the directory where are stored the
+// pristine copies of the protected files of the rootfs.
+func GetPristineDir(rootfs, dbpath string) string {
+	return filepath.Join(rootfs, dbpath, PristineDir)
+}
+
+// SavePristine stores the pristine copy of the protected file.
+func SavePristine(dir, file string, data []byte) error {
+	p := filepath.Join(dir, file)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0644)
+}
+
+func NewConfigUpdater(rootfs, dbpath string) *ConfigUpdater {
+	return &ConfigUpdater{
+		Rootfs:      rootfs,
+		PristineDir: GetPristineDir(rootfs, dbpath),
+	}
+}
+
+func (p *PendingFile) GetCandidate() string {
+	return p.Candidates[len(p.Candidates)-1]
+}
+
+// Scan returns the pending files available under the directories
+// in input sorted by path.
+func (u *ConfigUpdater) Scan(dirs []string) ([]*PendingFile, error) {
+	ans := []*PendingFile{}
+	m := make(map[string]*PendingFile, 0)
+	visited := make(map[string]bool, 0)
+
+	for _, dir := range dirs {
+		root := filepath.Join(u.Rootfs, dir)
+		if visited[root] {
+			continue
+		}
+		visited[root] = true
+
+		err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			matches := regexCandidate.FindStringSubmatch(info.Name())
+			if matches == nil {
+				return nil
+			}
+
+			rel, err := filepath.Rel(u.Rootfs, p)
+			if err != nil {
+				return err
+			}
+			rel = "/" + rel
+			file := filepath.Join(filepath.Dir(rel), matches[2])
+
+			pf, ok := m[file]
+			if !ok {
+				pf = &PendingFile{Path: file, Candidates: []string{}}
+				m[file] = pf
+				ans = append(ans, pf)
+			}
+			for _, c := range pf.Candidates {
+				if c == rel {
+					return nil
+				}
+			}
+			pf.Candidates = append(pf.Candidates, rel)
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "Error on scan directory "+root)
+		}
+	}
+
+	for _, pf := range ans {
+		// The index has a fixed size.
+		sort.Strings(pf.Candidates)
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].Path < ans[j].Path
+	})
+
+	return ans, nil
+}
+
+func readFile(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil && os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// ReadFiles returns the content of the current file, of the newer
+// candidate and of the pristine copy. The pristine copy is nil for the
+// files installed by older releases.
+func (u *ConfigUpdater) ReadFiles(p *PendingFile) (current, candidate, pristine []byte, err error) {
+	current, err = readFile(filepath.Join(u.Rootfs, p.Path))
+	if err != nil {
+		return
+	}
+	candidate, err = readFile(filepath.Join(u.Rootfs, p.GetCandidate()))
+	if err != nil {
+		return
+	}
+	pristine, err = readFile(filepath.Join(u.PristineDir, p.Path))
+	return
+}
+
+// Diff returns the unified diff between the current file and
+// the newer candidate.
+func (u *ConfigUpdater) Diff(p *PendingFile) (string, error) {
+	current, candidate, _, err := u.ReadFiles(p)
+	if err != nil {
+		return "", err
+	}
+	return UnifiedDiff(current, candidate, p.Path, p.GetCandidate()), nil
+}
+
+// cleanup removes the candidates and updates the pristine copy
+// with the newer candidate.
+func (u *ConfigUpdater) cleanup(p *PendingFile, candidate []byte) error {
+	if err := SavePristine(u.PristineDir, p.Path, candidate); err != nil {
+		return err
+	}
+	for _, c := range p.Candidates {
+		err := os.Remove(filepath.Join(u.Rootfs, c))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Keep preserves the current file and drops the candidates.
+func (u *ConfigUpdater) Keep(p *PendingFile) error {
+	_, candidate, _, err := u.ReadFiles(p)
+	if err != nil {
+		return err
+	}
+	return u.cleanup(p, candidate)
+}
+
+// Replace replaces the current file with the newer candidate.
+func (u *ConfigUpdater) Replace(p *PendingFile) error {
+	_, candidate, _, err := u.ReadFiles(p)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(filepath.Join(u.Rootfs, p.GetCandidate()),
+		filepath.Join(u.Rootfs, p.Path))
+	if err != nil {
+		return err
+	}
+
+	return u.cleanup(p, candidate)
+}
+
+// Merge3 returns the 3-way merge of the current file and of the newer
+// candidate using the pristine copy as common ancestor.
+func (u *ConfigUpdater) Merge3(p *PendingFile) ([]byte, bool, error) {
+	current, candidate, pristine, err := u.ReadFiles(p)
+	if err != nil {
+		return nil, false, err
+	}
+	if pristine == nil {
+		return nil, false, fmt.Errorf(
+			"pristine copy of %s not available", p.Path)
+	}
+
+	merged, conflicts := Merge3(pristine, current, candidate, p.Path, p.GetCandidate())
+	return merged, conflicts, nil
+}
+
+// WriteMerged writes the merged content to the current file and
+// drops the candidates.
+func (u *ConfigUpdater) WriteMerged(p *PendingFile, merged []byte) error {
+	_, candidate, _, err := u.ReadFiles(p)
+	if err != nil {
+		return err
+	}
+
+	f := filepath.Join(u.Rootfs, p.Path)
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(f); err == nil {
+		mode = info.Mode()
+	}
+	if err := os.WriteFile(f+".luet-merge", merged, mode); err != nil {
+		return err
+	}
+	if err := os.Rename(f+".luet-merge", f); err != nil {
+		return err
+	}
+
+	return u.cleanup(p, candidate)
+}
+
+// AutoMergeTrivial resolves the pending file when the user doesn't
+// need to choose:
+//   - the current file is been removed;
+//   - the candidate is equal to the current file;
+//   - the current file is not modified from the pristine copy;
+//   - the candidate is not changed from the pristine copy;
+//   - the 3-way merge is without conflicts.
+//
+// It returns the action executed or an empty string.
+func (u *ConfigUpdater) AutoMergeTrivial(p *PendingFile) (string, error) {
+	current, candidate, pristine, err := u.ReadFiles(p)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case current == nil:
+		return "replace", u.Replace(p)
+	case bytes.Equal(current, candidate):
+		return "keep", u.cleanup(p, candidate)
+	case pristine == nil:
+		return "", nil
+	case bytes.Equal(current, pristine):
+		return "replace", u.Replace(p)
+	case bytes.Equal(candidate, pristine):
+		return "keep", u.cleanup(p, candidate)
+	}
+
+	merged, conflicts := Merge3(pristine, current, candidate, p.Path, p.GetCandidate())
+	if conflicts {
+		return "", nil
+	}
+
+	return "merge", u.WriteMerged(p, merged)
+}
+
+// GetProtectDirs returns the directories to scan from the list of
+// the config protect directories and the annotations of the
+// installed packages.
+func GetProtectDirs(confDirs []string, annotations []string) []string {
+	ans := []string{}
+	m := make(map[string]bool, 0)
+	for _, d := range append(confDirs, annotations...) {
+		if d == "" {
+			continue
+		}
+		if !strings.HasPrefix(d, "/") {
+			d = "/" + d
+		}
+		d = filepath.Clean(d)
+		if !m[d] {
+			m[d] = true
+			ans = append(ans, d)
+		}
+	}
+	sort.Strings(ans)
+	return ans
+}
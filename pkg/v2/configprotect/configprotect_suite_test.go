@@ -0,0 +1,17 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package configprotect_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestConfigProtect(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Config Protect Suite")
+}
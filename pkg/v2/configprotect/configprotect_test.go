@@ -0,0 +1,198 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package configprotect_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/geaaru/luet/pkg/v2/configprotect"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func writeFile(f, content string) {
+	Expect(os.MkdirAll(filepath.Dir(f), 0755)).ToNot(HaveOccurred())
+	Expect(os.WriteFile(f, []byte(content), 0644)).ToNot(HaveOccurred())
+}
+
+func readFile(f string) string {
+	data, err := os.ReadFile(f)
+	Expect(err).ToNot(HaveOccurred())
+	return string(data)
+}
+
+var _ = Describe("Config protect", func() {
+
+	Context("Merge3", func() {
+		base := "a\nb\nc\nd\ne\n"
+
+		It("Merges changes on different lines", func() {
+			merged, conflicts := Merge3([]byte(base),
+				[]byte("a\nB\nc\nd\ne\n"), []byte("a\nb\nc\nd\nE\nf\n"), "ours", "theirs")
+			Expect(conflicts).To(BeFalse())
+			Expect(string(merged)).To(Equal("a\nB\nc\nd\nE\nf\n"))
+		})
+
+		It("Detects conflicts", func() {
+			merged, conflicts := Merge3([]byte(base),
+				[]byte("a\nX\nc\nd\ne\n"), []byte("a\nY\nc\nd\ne\n"), "ours", "theirs")
+			Expect(conflicts).To(BeTrue())
+			Expect(string(merged)).To(Equal(
+				"a\n<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs\nc\nd\ne\n"))
+		})
+
+		It("Accepts the same change on both sides", func() {
+			merged, conflicts := Merge3([]byte(base),
+				[]byte("a\nb\nd\ne\n"), []byte("a\nb\nd\ne\n"), "ours", "theirs")
+			Expect(conflicts).To(BeFalse())
+			Expect(string(merged)).To(Equal("a\nb\nd\ne\n"))
+		})
+
+		It("Merges large files", func() {
+			lines := func(n int, edit func(i int) string) string {
+				var sb strings.Builder
+				for i := 0; i < n; i++ {
+					sb.WriteString(edit(i) + "\n")
+				}
+				return sb.String()
+			}
+			base := lines(5000, func(i int) string { return fmt.Sprintf("%d", i) })
+			ours := lines(5000, func(i int) string {
+				if i%100 == 0 {
+					return fmt.Sprintf("ours %d", i)
+				}
+				return fmt.Sprintf("%d", i)
+			})
+			theirs := lines(5000, func(i int) string {
+				if i%100 == 50 {
+					return fmt.Sprintf("theirs %d", i)
+				}
+				return fmt.Sprintf("%d", i)
+			})
+			expected := lines(5000, func(i int) string {
+				switch i % 100 {
+				case 0:
+					return fmt.Sprintf("ours %d", i)
+				case 50:
+					return fmt.Sprintf("theirs %d", i)
+				}
+				return fmt.Sprintf("%d", i)
+			})
+
+			merged, conflicts := Merge3([]byte(base), []byte(ours), []byte(theirs),
+				"ours", "theirs")
+			Expect(conflicts).To(BeFalse())
+			Expect(string(merged)).To(Equal(expected))
+		})
+	})
+
+	Context("UnifiedDiff", func() {
+		It("Returns an empty diff for equal contents", func() {
+			Expect(UnifiedDiff([]byte("a\n"), []byte("a\n"), "a", "b")).To(Equal(""))
+		})
+
+		It("Creates the hunk with context", func() {
+			Expect(UnifiedDiff(
+				[]byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n"),
+				[]byte("1\n2\n3\n4\nfive\n6\n7\n8\n9\n"), "a", "b"),
+			).To(Equal("--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n"))
+		})
+	})
+
+	Context("ConfigUpdater", func() {
+		var tmpdir string
+		var u *ConfigUpdater
+
+		BeforeEach(func() {
+			var err error
+			tmpdir, err = os.MkdirTemp("", "configprotect")
+			Expect(err).ToNot(HaveOccurred())
+			u = NewConfigUpdater(tmpdir, "/var/luet")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpdir)
+		})
+
+		It("Scans the pending files", func() {
+			writeFile(filepath.Join(tmpdir, "etc/foo/foo.conf"), "a\n")
+			writeFile(filepath.Join(tmpdir, "etc/foo/._cfg0002_foo.conf"), "c\n")
+			writeFile(filepath.Join(tmpdir, "etc/foo/._cfg0001_foo.conf"), "b\n")
+			writeFile(filepath.Join(tmpdir, "etc/bar.conf"), "a\n")
+
+			pending, err := u.Scan([]string{"/etc", "/etc/foo", "/opt"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(pending)).To(Equal(1))
+			Expect(pending[0].Path).To(Equal("/etc/foo/foo.conf"))
+			Expect(pending[0].Candidates).To(Equal([]string{
+				"/etc/foo/._cfg0001_foo.conf", "/etc/foo/._cfg0002_foo.conf",
+			}))
+			Expect(pending[0].GetCandidate()).To(Equal("/etc/foo/._cfg0002_foo.conf"))
+		})
+
+		It("Replaces the files not modified", func() {
+			writeFile(filepath.Join(tmpdir, "etc/foo.conf"), "a\n")
+			writeFile(filepath.Join(u.PristineDir, "etc/foo.conf"), "a\n")
+			writeFile(filepath.Join(tmpdir, "etc/._cfg0001_foo.conf"), "b\n")
+
+			pending, err := u.Scan([]string{"/etc"})
+			Expect(err).ToNot(HaveOccurred())
+			action, err := u.AutoMergeTrivial(pending[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(action).To(Equal("replace"))
+			Expect(readFile(filepath.Join(tmpdir, "etc/foo.conf"))).To(Equal("b\n"))
+			Expect(readFile(filepath.Join(u.PristineDir, "etc/foo.conf"))).To(Equal("b\n"))
+			_, err = os.Stat(filepath.Join(tmpdir, "etc/._cfg0001_foo.conf"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("Merges the files without conflicts", func() {
+			writeFile(filepath.Join(tmpdir, "etc/foo.conf"), "local\nb\nc\n")
+			writeFile(filepath.Join(u.PristineDir, "etc/foo.conf"), "a\nb\nc\n")
+			writeFile(filepath.Join(tmpdir, "etc/._cfg0001_foo.conf"), "a\nb\nnew\n")
+
+			pending, err := u.Scan([]string{"/etc"})
+			Expect(err).ToNot(HaveOccurred())
+			action, err := u.AutoMergeTrivial(pending[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(action).To(Equal("merge"))
+			Expect(readFile(filepath.Join(tmpdir, "etc/foo.conf"))).To(Equal("local\nb\nnew\n"))
+			Expect(readFile(filepath.Join(u.PristineDir, "etc/foo.conf"))).To(Equal("a\nb\nnew\n"))
+		})
+
+		It("Leaves the files with conflicts or without pristine copy", func() {
+			writeFile(filepath.Join(tmpdir, "etc/foo.conf"), "local\n")
+			writeFile(filepath.Join(u.PristineDir, "etc/foo.conf"), "a\n")
+			writeFile(filepath.Join(tmpdir, "etc/._cfg0001_foo.conf"), "new\n")
+			writeFile(filepath.Join(tmpdir, "etc/bar.conf"), "local\n")
+			writeFile(filepath.Join(tmpdir, "etc/._cfg0001_bar.conf"), "new\n")
+
+			pending, err := u.Scan([]string{"/etc"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(pending)).To(Equal(2))
+			for _, p := range pending {
+				action, err := u.AutoMergeTrivial(p)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(action).To(Equal(""))
+			}
+			Expect(readFile(filepath.Join(tmpdir, "etc/foo.conf"))).To(Equal("local\n"))
+		})
+
+		It("Keeps the current file", func() {
+			writeFile(filepath.Join(tmpdir, "etc/foo.conf"), "local\n")
+			writeFile(filepath.Join(tmpdir, "etc/._cfg0001_foo.conf"), "new\n")
+
+			pending, err := u.Scan([]string{"/etc"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(u.Keep(pending[0])).ToNot(HaveOccurred())
+			Expect(readFile(filepath.Join(tmpdir, "etc/foo.conf"))).To(Equal("local\n"))
+			Expect(readFile(filepath.Join(u.PristineDir, "etc/foo.conf"))).To(Equal("new\n"))
+		})
+	})
+})
@@ -0,0 +1,332 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package configprotect
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	diffContext = 3
+
+	opEqual  = ' '
+	opDelete = '-'
+	opInsert = '+'
+)
+
+type diffOp struct {
+	op   byte
+	line string
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return []string{}
+	}
+	ans := strings.SplitAfter(string(data), "\n")
+	if ans[len(ans)-1] == "" {
+		ans = ans[:len(ans)-1]
+	}
+	return ans
+}
+
+// lcsMatch returns for every line of a the index of the matched line
+// of b or -1 using the longest common subsequence. The subsequence is
+// computed with the Hirschberg algorithm that requires linear space.
+func lcsMatch(a, b []string) []int {
+	ans := make([]int, len(a))
+	for i := range ans {
+		ans[i] = -1
+	}
+	hirschberg(a, b, 0, 0, ans)
+	return ans
+}
+
+func hirschberg(a, b []string, offA, offB int, ans []int) {
+	// Match the common prefix and suffix.
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		ans[offA] = offB
+		a, b = a[1:], b[1:]
+		offA++
+		offB++
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		ans[offA+len(a)-1] = offB + len(b) - 1
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	if len(a) == 0 || len(b) == 0 {
+		return
+	}
+	if len(a) == 1 {
+		for j := range b {
+			if a[0] == b[j] {
+				ans[offA] = offB + j
+				break
+			}
+		}
+		return
+	}
+
+	// Split b where the sum of the lengths of the subsequences
+	// of the two halves of a is maximum.
+	mid := len(a) / 2
+	fw := lcsLengths(a[:mid], b)
+	bw := lcsLengthsReverse(a[mid:], b)
+	split, best := 0, -1
+	for j := 0; j <= len(b); j++ {
+		if fw[j]+bw[j] > best {
+			split, best = j, fw[j]+bw[j]
+		}
+	}
+
+	hirschberg(a[:mid], b[:split], offA, offB, ans)
+	hirschberg(a[mid:], b[split:], offA+mid, offB+split, ans)
+}
+
+// lcsLengths returns for every j the length of the longest common
+// subsequence of a and b[:j].
+func lcsLengths(a, b []string) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		for j := 1; j <= len(b); j++ {
+			if a[i] == b[j-1] {
+				cur[j] = prev[j-1] + 1
+			} else if prev[j] >= cur[j-1] {
+				cur[j] = prev[j]
+			} else {
+				cur[j] = cur[j-1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// lcsLengthsReverse returns for every j the length of the longest
+// common subsequence of a and b[j:].
+func lcsLengthsReverse(a, b []string) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				cur[j] = prev[j+1] + 1
+			} else if prev[j] >= cur[j+1] {
+				cur[j] = prev[j]
+			} else {
+				cur[j] = cur[j+1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+func diffLines(a, b []string) []diffOp {
+	ans := []diffOp{}
+	match := lcsMatch(a, b)
+	j := 0
+	for i, line := range a {
+		if match[i] < 0 {
+			ans = append(ans, diffOp{opDelete, line})
+			continue
+		}
+		for ; j < match[i]; j++ {
+			ans = append(ans, diffOp{opInsert, b[j]})
+		}
+		ans = append(ans, diffOp{opEqual, line})
+		j++
+	}
+	for ; j < len(b); j++ {
+		ans = append(ans, diffOp{opInsert, b[j]})
+	}
+	return ans
+}
+
+func writeDiffLine(sb *strings.Builder, op byte, line string) {
+	sb.WriteByte(op)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// UnifiedDiff returns the unified diff between a and b or an empty
+// string if the contents are equal.
+func UnifiedDiff(a, b []byte, nameA, nameB string) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	changed := false
+	for _, o := range ops {
+		if o.op != opEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", nameA, nameB))
+
+	// Line number (from 1) of every operation on a and b.
+	posA := make([]int, len(ops)+1)
+	posB := make([]int, len(ops)+1)
+	la, lb := 1, 1
+	for idx, o := range ops {
+		posA[idx], posB[idx] = la, lb
+		if o.op != opInsert {
+			la++
+		}
+		if o.op != opDelete {
+			lb++
+		}
+	}
+	posA[len(ops)], posB[len(ops)] = la, lb
+
+	idx := 0
+	for idx < len(ops) {
+		if ops[idx].op == opEqual {
+			idx++
+			continue
+		}
+
+		// Create the hunk merging the changes with a distance
+		// less of two times the context.
+		start := idx - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := idx
+		for {
+			for end < len(ops) && ops[end].op != opEqual {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].op == opEqual {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*diffContext {
+				end = next
+				continue
+			}
+			break
+		}
+		stop := end + diffContext
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		countA, countB := 0, 0
+		for _, o := range ops[start:stop] {
+			if o.op != opInsert {
+				countA++
+			}
+			if o.op != opDelete {
+				countB++
+			}
+		}
+		startA, startB := posA[start], posB[start]
+		if countA == 0 {
+			startA--
+		}
+		if countB == 0 {
+			startB--
+		}
+
+		sb.WriteString(fmt.Sprintf("@@ -%s +%s @@\n",
+			hunkRange(startA, countA), hunkRange(startB, countB)))
+		for _, o := range ops[start:stop] {
+			writeDiffLine(&sb, o.op, o.line)
+		}
+
+		idx = stop
+	}
+
+	return sb.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func appendConflictLines(ans []string, lines []string) []string {
+	for _, l := range lines {
+		if !strings.HasSuffix(l, "\n") {
+			l += "\n"
+		}
+		ans = append(ans, l)
+	}
+	return ans
+}
+
+// Merge3 merges the changes of ours and theirs from the common
+// ancestor base. On conflicts, the merged content contains the
+// conflict markers.
+func Merge3(base, ours, theirs []byte, nameOurs, nameTheirs string) ([]byte, bool) {
+	b := splitLines(base)
+	o := splitLines(ours)
+	t := splitLines(theirs)
+
+	mo := lcsMatch(b, o)
+	mt := lcsMatch(b, t)
+
+	ans := []string{}
+	conflicts := false
+	i, j, k := 0, 0, 0
+	for {
+		// Search the next line of base not changed on both sides.
+		m := i
+		for m < len(b) && (mo[m] < 0 || mt[m] < 0) {
+			m++
+		}
+		endO, endT := len(o), len(t)
+		if m < len(b) {
+			endO, endT = mo[m], mt[m]
+		}
+
+		cb, co, ct := b[i:m], o[j:endO], t[k:endT]
+		switch {
+		case equalLines(co, cb):
+			ans = append(ans, ct...)
+		case equalLines(ct, cb), equalLines(co, ct):
+			ans = append(ans, co...)
+		default:
+			conflicts = true
+			ans = append(ans, fmt.Sprintf("<<<<<<< %s\n", nameOurs))
+			ans = appendConflictLines(ans, co)
+			ans = append(ans, "=======\n")
+			ans = appendConflictLines(ans, ct)
+			ans = append(ans, fmt.Sprintf(">>>>>>> %s\n", nameTheirs))
+		}
+
+		if m == len(b) {
+			break
+		}
+		ans = append(ans, b[m])
+		i, j, k = m+1, endO+1, endT+1
+	}
+
+	return []byte(strings.Join(ans, "")), conflicts
+}
@@ -0,0 +1,186 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package hold
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// PackageHold blocks the upgrade of an installed package.
+// Without selector the package is immovable, with a selector
+// the package could be upgraded only to the versions that
+// match the selector.
+type PackageHold struct {
+	Package  string `yaml:"package" json:"package"`
+	Selector string `yaml:"selector,omitempty" json:"selector,omitempty"`
+}
+
+type PackagesHoldFile struct {
+	Holds []*PackageHold `yaml:"holds" json:"holds"`
+}
+
+type PackagesHoldManager struct {
+	Config *cfg.LuetConfig
+
+	Holds    []*PackageHold
+	holdsMap map[string]*PackageHold
+}
+
+// NewPackageHold creates the hold of a package string parsed by
+// the cli. The selector >=0 means that the package is immovable.
+func NewPackageHold(p *pkg.DefaultPackage) *PackageHold {
+	ans := &PackageHold{
+		Package: p.PackageName(),
+	}
+	if p.GetVersion() != ">=0" && p.GetVersion() != "" {
+		ans.Selector = p.GetVersion()
+	}
+	return ans
+}
+
+func (h *PackageHold) IsImmovable() bool { return h.Selector == "" }
+
+func (h *PackageHold) String() string {
+	if h.IsImmovable() {
+		return h.Package
+	}
+	return fmt.Sprintf("%s@%s", h.Package, h.Selector)
+}
+
+// Admit returns true if the package could replace the held package.
+func (h *PackageHold) Admit(p pkg.Package) (bool, error) {
+	if h.IsImmovable() {
+		return false, nil
+	}
+	if p.GetVersion() == h.Selector {
+		return true, nil
+	}
+	return p.VersionMatchSelector(h.Selector, nil)
+}
+
+func NewPackagesHoldManager(c *cfg.LuetConfig) *PackagesHoldManager {
+	return &PackagesHoldManager{
+		Config:   c,
+		Holds:    []*PackageHold{},
+		holdsMap: make(map[string]*PackageHold, 0),
+	}
+}
+
+// GetHoldsFile returns the path of the holds file respecting
+// the rootfs of the system when the config is not from host.
+func (m *PackagesHoldManager) GetHoldsFile() (string, error) {
+	rootfs := ""
+	if !m.Config.ConfigFromHost {
+		var err error
+		rootfs, err = m.Config.GetSystem().GetRootFsAbs()
+		if err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(rootfs, m.Config.HoldsFile), nil
+}
+
+func (m *PackagesHoldManager) Load() error {
+	if m.Config.HoldsFile == "" {
+		return nil
+	}
+
+	file, err := m.GetHoldsFile()
+	if err != nil {
+		return err
+	}
+
+	Debug("Parsing holds file", file, "...")
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "Error on read holds file %s", file)
+	}
+
+	hf := &PackagesHoldFile{}
+	if err = yaml.Unmarshal(content, hf); err != nil {
+		return errors.Wrapf(err, "Error on parse holds file %s", file)
+	}
+
+	for _, h := range hf.Holds {
+		if h.Package == "" {
+			Warning(fmt.Sprintf("Hold without package in file %s. Skipped.", file))
+			continue
+		}
+		m.Add(h)
+	}
+
+	return nil
+}
+
+func (m *PackagesHoldManager) Save() error {
+	if m.Config.HoldsFile == "" {
+		return errors.New("No holds file configured")
+	}
+
+	file, err := m.GetHoldsFile()
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&PackagesHoldFile{Holds: m.Holds})
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, data, 0644)
+}
+
+// Add registers the hold or replaces the selector of
+// an existing hold of the same package.
+func (m *PackagesHoldManager) Add(h *PackageHold) {
+	if old, present := m.holdsMap[h.Package]; present {
+		old.Selector = h.Selector
+		return
+	}
+	m.holdsMap[h.Package] = h
+	m.Holds = append(m.Holds, h)
+	sort.Slice(m.Holds, func(i, j int) bool {
+		return m.Holds[i].Package < m.Holds[j].Package
+	})
+}
+
+func (m *PackagesHoldManager) Remove(pkgname string) bool {
+	if _, present := m.holdsMap[pkgname]; !present {
+		return false
+	}
+	delete(m.holdsMap, pkgname)
+	for idx, h := range m.Holds {
+		if h.Package == pkgname {
+			m.Holds = append(m.Holds[:idx], m.Holds[idx+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (m *PackagesHoldManager) GetHold(pkgname string) *PackageHold {
+	if h, present := m.holdsMap[pkgname]; present {
+		return h
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package hold_test
+
+import (
+	"os"
+
+	cfg "github.com/geaaru/luet/pkg/config"
+	pkg "github.com/geaaru/luet/pkg/package"
+	. "github.com/geaaru/luet/pkg/v2/hold"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hold", func() {
+	Context("Packages admitted by a hold", func() {
+
+		It("Immovable package", func() {
+			h := NewPackageHold(pkg.NewPackageWithCatThin("app", "foo", ">=0"))
+			Expect(h.IsImmovable()).To(BeTrue())
+			Expect(h.String()).To(Equal("app/foo"))
+
+			admit, err := h.Admit(pkg.NewPackageWithCatThin("app", "foo", "1.0"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(admit).To(BeFalse())
+		})
+
+		It("Package with selector", func() {
+			h := NewPackageHold(pkg.NewPackageWithCatThin("app", "foo", "<2.0"))
+			Expect(h.IsImmovable()).To(BeFalse())
+			Expect(h.String()).To(Equal("app/foo@<2.0"))
+
+			admit, err := h.Admit(pkg.NewPackageWithCatThin("app", "foo", "1.5"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(admit).To(BeTrue())
+
+			admit, err = h.Admit(pkg.NewPackageWithCatThin("app", "foo", "2.1"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(admit).To(BeFalse())
+		})
+
+		It("Package with version", func() {
+			h := NewPackageHold(pkg.NewPackageWithCatThin("app", "foo", "1.2"))
+
+			admit, err := h.Admit(pkg.NewPackageWithCatThin("app", "foo", "1.2"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(admit).To(BeTrue())
+
+			admit, err = h.Admit(pkg.NewPackageWithCatThin("app", "foo", "1.3"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(admit).To(BeFalse())
+		})
+	})
+
+	Context("Holds file", func() {
+
+		var rootfs string
+		var config *cfg.LuetConfig
+
+		BeforeEach(func() {
+			var err error
+			rootfs, err = os.MkdirTemp("", "hold")
+			Expect(err).ToNot(HaveOccurred())
+
+			config = cfg.NewLuetConfig(nil)
+			config.GetSystem().Rootfs = rootfs
+			config.ConfigFromHost = false
+			config.HoldsFile = "/etc/luet/holds.yml"
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(rootfs)
+		})
+
+		It("Save and load holds", func() {
+			m := NewPackagesHoldManager(config)
+			Expect(m.Load()).ToNot(HaveOccurred())
+			Expect(m.Holds).To(HaveLen(0))
+
+			m.Add(NewPackageHold(pkg.NewPackageWithCatThin("app", "foo", ">=0")))
+			m.Add(NewPackageHold(pkg.NewPackageWithCatThin("app", "bar", "<2.0")))
+			m.Add(NewPackageHold(pkg.NewPackageWithCatThin("app", "bar", "<3.0")))
+			Expect(m.Save()).ToNot(HaveOccurred())
+
+			file, err := m.GetHoldsFile()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file).To(HavePrefix(rootfs))
+
+			m = NewPackagesHoldManager(config)
+			Expect(m.Load()).ToNot(HaveOccurred())
+			Expect(m.Holds).To(HaveLen(2))
+			Expect(m.Holds[0].Package).To(Equal("app/bar"))
+			Expect(m.GetHold("app/bar").Selector).To(Equal("<3.0"))
+			Expect(m.GetHold("app/foo").IsImmovable()).To(BeTrue())
+
+			Expect(m.Remove("app/foo")).To(BeTrue())
+			Expect(m.Remove("app/foo")).To(BeFalse())
+			Expect(m.GetHold("app/foo")).To(BeNil())
+			Expect(m.Holds).To(HaveLen(1))
+		})
+	})
+})
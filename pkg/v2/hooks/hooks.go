@@ -0,0 +1,302 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package hooks
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	box "github.com/geaaru/luet/pkg/box"
+	. "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	OperationInstall = "install"
+	OperationUpgrade = "upgrade"
+	OperationRemove  = "remove"
+
+	WhenPre  = "pre"
+	WhenPost = "post"
+)
+
+// Hook is a command executed one time for transaction when
+// the transaction touches files that match the paths of the hook.
+type Hook struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Globs of the files that trigger the hook. The * matches
+	// the characters except /, the ** matches every character.
+	Paths []string `json:"paths" yaml:"paths"`
+	// Operations that trigger the hook: install, upgrade, remove.
+	// Empty means all operations.
+	Operations []string `json:"operations,omitempty" yaml:"operations,omitempty"`
+	// When execute the hook: pre or post transaction (default).
+	When     string   `json:"when,omitempty" yaml:"when,omitempty"`
+	Shell    []string `json:"shell,omitempty" yaml:"shell,omitempty"`
+	Commands []string `json:"commands" yaml:"commands"`
+
+	File    string           `json:"-" yaml:"-"`
+	regexes []*regexp.Regexp `json:"-" yaml:"-"`
+}
+
+// Trigger contains the files touched by a package operation.
+type Trigger struct {
+	Package   string
+	Operation string
+	Files     []string
+}
+
+func NewHookFromYaml(data []byte) (*Hook, error) {
+	ans := &Hook{}
+	if err := yaml.Unmarshal(data, ans); err != nil {
+		return nil, err
+	}
+	if err := ans.Prepare(); err != nil {
+		return nil, err
+	}
+	return ans, nil
+}
+
+func globToRegex(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// Prepare validates the hook and compiles the paths globs.
+func (h *Hook) Prepare() error {
+	if h.Name == "" {
+		return errors.New("hook without name")
+	}
+	if len(h.Paths) == 0 {
+		return fmt.Errorf("hook %s without paths", h.Name)
+	}
+	if len(h.Commands) == 0 {
+		return fmt.Errorf("hook %s without commands", h.Name)
+	}
+
+	if h.When == "" {
+		h.When = WhenPost
+	}
+	if h.When != WhenPre && h.When != WhenPost {
+		return fmt.Errorf("hook %s with invalid when %s", h.Name, h.When)
+	}
+
+	for _, o := range h.Operations {
+		if o != OperationInstall && o != OperationUpgrade && o != OperationRemove {
+			return fmt.Errorf("hook %s with invalid operation %s", h.Name, o)
+		}
+	}
+
+	h.regexes = []*regexp.Regexp{}
+	for _, p := range h.Paths {
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+		r, err := regexp.Compile(globToRegex(p))
+		if err != nil {
+			return errors.Wrapf(err, "hook %s with invalid path %s", h.Name, p)
+		}
+		h.regexes = append(h.regexes, r)
+	}
+
+	return nil
+}
+
+func (h *Hook) HasOperation(op string) bool {
+	if len(h.Operations) == 0 {
+		return true
+	}
+	for _, o := range h.Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchFile returns true if the file (with or without the initial
+// slash) matches one of the paths of the hook.
+func (h *Hook) MatchFile(f string) bool {
+	if !strings.HasPrefix(f, "/") {
+		f = "/" + f
+	}
+	for _, r := range h.regexes {
+		if r.MatchString(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the packages of the triggers that match the hook.
+func (h *Hook) Match(triggers []*Trigger) []string {
+	ans := []string{}
+	for _, t := range triggers {
+		if !h.HasOperation(t.Operation) {
+			continue
+		}
+		for _, f := range t.Files {
+			if h.MatchFile(f) {
+				ans = append(ans, t.Package)
+				break
+			}
+		}
+	}
+	return ans
+}
+
+func (h *Hook) getShell() (string, []string) {
+	if len(h.Shell) == 0 {
+		return "sh", []string{"-c"}
+	}
+	return h.Shell[0], h.Shell[1:]
+}
+
+// Run executes the commands of the hook inside the target rootfs.
+func (h *Hook) Run(targetRootfs string, packages []string) error {
+	cmd, args := h.getShell()
+
+	envs := LuetCfg.GetFinalizerEnvs()
+	envs = append(envs,
+		fmt.Sprintf("LUET_VERSION=%s", LuetVersion),
+		fmt.Sprintf("LUET_HOOK_NAME=%s", h.Name),
+		fmt.Sprintf("LUET_HOOK_WHEN=%s", h.When),
+		fmt.Sprintf("LUET_HOOK_PACKAGES=%s", strings.Join(packages, " ")),
+	)
+
+	for _, c := range h.Commands {
+		toRun := append(append([]string{}, args...), c)
+		Debug(fmt.Sprintf(":shell: Executing hook %s on %s: %s %s",
+			h.Name, targetRootfs, cmd, toRun))
+
+		if targetRootfs == string(os.PathSeparator) {
+			command := exec.Command(cmd, toRun...)
+			command.Env = envs
+			out, err := command.CombinedOutput()
+			if err != nil {
+				return errors.Wrap(err, "Failed running command: "+string(out))
+			}
+			if len(out) > 0 {
+				Info(string(out))
+			}
+		} else {
+			b := box.NewBox(cmd, toRun, []string{}, envs, targetRootfs, false, true, true)
+			if err := b.Run(); err != nil {
+				return errors.Wrap(err, "Failed running command: ")
+			}
+		}
+	}
+
+	return nil
+}
+
+// LoadHooks reads the hooks of the hooks directories sorted
+// by filename.
+func LoadHooks(c *LuetConfig) ([]*Hook, error) {
+	var regexHook = regexp.MustCompile(`.yml$|.yaml$`)
+	var err error
+	ans := []*Hook{}
+	rootfs := ""
+
+	// Respect the rootfs param on read hooks
+	if !c.ConfigFromHost {
+		rootfs, err = c.GetSystem().GetRootFsAbs()
+		if err != nil {
+			return ans, err
+		}
+	}
+
+	for _, hdir := range c.HooksDir {
+		hdir = filepath.Join(rootfs, hdir)
+
+		Debug("Parsing Hooks Directory", hdir, "...")
+
+		files, err := os.ReadDir(hdir)
+		if err != nil {
+			Debug("Skip dir", hdir, ":", err.Error())
+			continue
+		}
+
+		names := []string{}
+		for _, file := range files {
+			if file.IsDir() || !regexHook.MatchString(file.Name()) {
+				continue
+			}
+			names = append(names, file.Name())
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			content, err := os.ReadFile(filepath.Join(hdir, name))
+			if err != nil {
+				Warning("On read file", name, ":", err.Error())
+				Warning("File", name, "skipped.")
+				continue
+			}
+
+			h, err := NewHookFromYaml(content)
+			if err != nil {
+				Warning("On parse file", name, ":", err.Error())
+				Warning("File", name, "skipped.")
+				continue
+			}
+			h.File = filepath.Join(hdir, name)
+			ans = append(ans, h)
+		}
+	}
+
+	return ans, nil
+}
+
+// RunHooks executes one time the hooks of the selected phase that
+// match the triggers.
+func RunHooks(hooks []*Hook, when, targetRootfs string, triggers []*Trigger) error {
+	var lastErr error
+
+	for _, h := range hooks {
+		if h.When != when {
+			continue
+		}
+
+		pkgs := h.Match(triggers)
+		if len(pkgs) == 0 {
+			continue
+		}
+
+		InfoC(fmt.Sprintf(":hook:Running %s-transaction hook %s...", when, h.Name))
+		if err := h.Run(targetRootfs, pkgs); err != nil {
+			Error(fmt.Sprintf("Hook %s failed: %s", h.Name, err.Error()))
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package hooks_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/geaaru/luet/pkg/v2/hooks"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hooks", func() {
+	Context("Parse and match hooks", func() {
+
+		It("Matches the paths globs", func() {
+			h, err := NewHookFromYaml([]byte(`
+name: fonts
+paths:
+  - /usr/share/fonts/**
+  - usr/lib/*.so
+commands:
+  - fc-cache
+`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(h.When).To(Equal(WhenPost))
+
+			Expect(h.MatchFile("usr/share/fonts/dejavu/a.ttf")).To(BeTrue())
+			Expect(h.MatchFile("/usr/lib/libfoo.so")).To(BeTrue())
+			Expect(h.MatchFile("usr/lib/x/libfoo.so")).To(BeFalse())
+			Expect(h.MatchFile("usr/share/icons/a.png")).To(BeFalse())
+		})
+
+		It("Filters the operations", func() {
+			h, err := NewHookFromYaml([]byte(`
+name: depmod
+paths:
+  - /lib/modules/**
+operations: [install, upgrade]
+commands:
+  - depmod -a
+`))
+			Expect(err).ToNot(HaveOccurred())
+
+			triggers := []*Trigger{
+				{Package: "a", Operation: OperationInstall, Files: []string{"lib/modules/a.ko"}},
+				{Package: "b", Operation: OperationRemove, Files: []string{"lib/modules/b.ko"}},
+				{Package: "c", Operation: OperationUpgrade, Files: []string{"usr/bin/c"}},
+				{Package: "d", Operation: OperationUpgrade, Files: []string{"lib/modules/d.ko"}},
+			}
+			Expect(h.Match(triggers)).To(Equal([]string{"a", "d"}))
+		})
+
+		It("Rejects invalid hooks", func() {
+			_, err := NewHookFromYaml([]byte("name: foo\ncommands: [ls]\n"))
+			Expect(err).To(HaveOccurred())
+
+			_, err = NewHookFromYaml([]byte(
+				"name: foo\npaths: [/etc]\ncommands: [ls]\nwhen: during\n"))
+			Expect(err).To(HaveOccurred())
+
+			_, err = NewHookFromYaml([]byte(
+				"name: foo\npaths: [/etc]\ncommands: [ls]\noperations: [purge]\n"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Runs the matched hooks one time", func() {
+			tmpdir, err := os.MkdirTemp("", "hooks")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(tmpdir)
+
+			log := filepath.Join(tmpdir, "hook.log")
+			h, err := NewHookFromYaml([]byte(`
+name: ldconfig
+paths:
+  - /usr/lib/**
+commands:
+  - echo "$LUET_HOOK_NAME $LUET_HOOK_PACKAGES" >> ` + log + `
+`))
+			Expect(err).ToNot(HaveOccurred())
+
+			triggers := []*Trigger{
+				{Package: "a", Operation: OperationInstall, Files: []string{"usr/lib/a.so"}},
+				{Package: "b", Operation: OperationInstall, Files: []string{"usr/lib/b.so"}},
+			}
+
+			Expect(RunHooks([]*Hook{h}, WhenPre, "/", triggers)).ToNot(HaveOccurred())
+			Expect(log).ToNot(BeAnExistingFile())
+
+			Expect(RunHooks([]*Hook{h}, WhenPost, "/", triggers)).ToNot(HaveOccurred())
+			data, err := os.ReadFile(log)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal("ldconfig a b\n"))
+		})
+	})
+})
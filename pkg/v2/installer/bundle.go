@@ -0,0 +1,204 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cfg "github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/v2/bundle"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+	solver "github.com/geaaru/luet/pkg/v2/solver"
+
+	"github.com/pkg/errors"
+)
+
+type BundleOpts struct {
+	Name        string
+	IgnoreMasks bool
+	NoDeps      bool
+}
+
+// CreateBundle resolves the packages in input against the enabled
+// repositories and writes the bundle with all the artifacts required
+// to install them on an empty system.
+func (m *ArtifactsManager) CreateBundle(opts *BundleOpts, output string,
+	packs ...*pkg.DefaultPackage) error {
+
+	mapRepos := make(map[string]*wagon.WagonRepository, 0)
+
+	InfoC(":brain:Solving install tree...")
+	Spinner(3)
+
+	solverOpts := &solver.SolverOpts{
+		IgnoreMasks: opts.IgnoreMasks,
+		NoDeps:      opts.NoDeps,
+	}
+
+	// The bundle must be installable on a different system.
+	// I use an empty database to collect all the dependencies.
+	s := solver.NewSolverImplementation("solverv2", m.Config, solverOpts)
+	(*s).SetDatabase(pkg.NewInMemoryDatabase(false))
+	pkgs2Install, _, err := (*s).Install(&packs)
+	SpinnerStop()
+	if err != nil {
+		return err
+	}
+	s = nil
+
+	if len(pkgs2Install.Artifacts) == 0 {
+		return errors.New("No packages to add to the bundle.")
+	}
+
+	InfoC(fmt.Sprintf(":truck:Downloading %d packages...",
+		len(pkgs2Install.Artifacts)))
+	tasks := []*DownloadTask{}
+	for _, art := range pkgs2Install.Artifacts {
+		repoName := art.GetRepository()
+
+		wr, ok := mapRepos[repoName]
+		if !ok {
+			repo, err := m.Config.GetSystemRepository(repoName)
+			if err != nil {
+				return fmt.Errorf("Repository not found for artefact %s",
+					art.GetPackage().HumanReadableString())
+			}
+
+			wr = wagon.NewWagonRepository(repo)
+			err = wr.ReadWagonIdentify(
+				m.Config.GetSystem().GetRepoDatabaseDirPath(repoName))
+			if err != nil {
+				return fmt.Errorf("Error on read repository identity file: " +
+					err.Error())
+			}
+			mapRepos[repoName] = wr
+		}
+
+		tasks = append(tasks, NewDownloadTask(art, wr))
+	}
+
+	err = m.DownloadPackages(tasks)
+	if err != nil {
+		return err
+	}
+
+	workDir, err := m.Config.GetSystem().TempDir("bundle")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	b, err := bundle.NewBundleBuilder(opts.Name, workDir)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range packs {
+		b.Spec.Packages = append(b.Spec.Packages, p.PackageName())
+	}
+
+	reposDir := m.Config.GetSystem().GetSystemReposDirPath()
+	for _, art := range pkgs2Install.Artifacts {
+		wr := mapRepos[art.GetRepository()]
+		err = b.AddArtifact(art, wr.GetTreePath(reposDir))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = b.Write(output)
+	if err != nil {
+		return err
+	}
+
+	InfoC(fmt.Sprintf(":gift:Bundle %s created with %d packages.",
+		output, len(b.Spec.Artifacts)))
+
+	return nil
+}
+
+// InstallBundle registers the bundle as a temporary disk repository
+// and installs the packages in input or the packages used to
+// create the bundle. The others repositories are disabled.
+func (m *ArtifactsManager) InstallBundle(opts *InstallOpts,
+	targetRootfs, bundleFile string, packs ...*pkg.DefaultPackage) error {
+
+	// The disk repository is relative to the rootfs when the
+	// configuration is not from host. The bundle is unpacked in the
+	// repositories database directory to be reachable from the client.
+	reposDir := m.Config.GetSystem().GetSystemReposDirPath()
+	if err := os.MkdirAll(reposDir, os.ModePerm); err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(reposDir, ".bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	spec, err := bundle.Extract(bundleFile, tmpDir)
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.Config.GetSystemRepository(spec.Name); err == nil {
+		return fmt.Errorf(
+			"the bundle name %s is already used by a configured repository",
+			spec.Name)
+	}
+
+	repobasedir := m.Config.GetSystem().GetRepoDatabaseDirPath(spec.Name)
+	defer os.RemoveAll(repobasedir)
+
+	repoDir := filepath.Join(repobasedir, "bundle")
+	err = os.Rename(filepath.Join(tmpDir, bundle.BUNDLE_REPODIR), repoDir)
+	if err != nil {
+		return err
+	}
+
+	repoUrl := repoDir
+	if !m.Config.ConfigFromHost {
+		rootfs, err := m.Config.GetSystem().GetRootFsAbs()
+		if err != nil {
+			return err
+		}
+		repoUrl = "/" + strings.TrimPrefix(
+			strings.TrimPrefix(repoDir, rootfs), "/")
+	}
+
+	for idx := range m.Config.SystemRepositories {
+		m.Config.SystemRepositories[idx].Enable = false
+	}
+
+	m.Config.AddSystemRepository(cfg.NewLuetRepository(
+		spec.Name, wagon.DiskRepositoryType, "Offline bundle",
+		[]string{repoUrl}, 1, true, true,
+	))
+
+	wr := wagon.NewWagonRepository(
+		&m.Config.SystemRepositories[len(m.Config.SystemRepositories)-1])
+	err = wr.Sync(true)
+	if err != nil {
+		return err
+	}
+
+	if len(packs) == 0 {
+		for _, p := range spec.Packages {
+			packs = append(packs, &pkg.DefaultPackage{
+				Category: filepath.Dir(p),
+				Name:     filepath.Base(p),
+				Version:  ">=0",
+				Uri:      make([]string, 0),
+			})
+		}
+	}
+
+	return m.Install(opts, targetRootfs, packs...)
+}
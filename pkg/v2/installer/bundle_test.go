@@ -0,0 +1,93 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer_test
+
+import (
+	"os"
+	"path/filepath"
+
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/v2/bundle"
+	. "github.com/geaaru/luet/pkg/v2/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bundle", func() {
+	var tmpdir string
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "bundle")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Creates a bundle and installs it on an empty system", func() {
+		m := setupTestSystem(filepath.Join(tmpdir, "source"))
+		lib := newTestArtifact(tmpdir, "test", "lib", "1.0", map[string]string{
+			"usr/lib/libfoo.so": "lib",
+		})
+		foo := newTestArtifact(tmpdir, "test", "foo", "1.0", map[string]string{
+			"usr/bin/foo": "foo",
+		})
+		foo.Runtime.PackageRequires = []*pkg.DefaultPackage{
+			{Category: "test", Name: "lib", Version: ">=0"},
+		}
+		writeTestRepository(lib, foo)
+		writeTestTree(lib, foo)
+
+		out := filepath.Join(tmpdir, "offline.tar")
+		Expect(m.CreateBundle(&BundleOpts{Name: "offline"}, out,
+			&pkg.DefaultPackage{Category: "test", Name: "foo", Version: ">=0"},
+		)).ToNot(HaveOccurred())
+
+		spec, err := bundle.Extract(out, filepath.Join(tmpdir, "extract"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(spec.Name).To(Equal("offline"))
+		Expect(spec.Packages).To(Equal([]string{"test/foo"}))
+		Expect(spec.Artifacts).To(HaveLen(2))
+
+		// Install the bundle on a new system without repositories.
+		target := filepath.Join(tmpdir, "target")
+		m2 := setupTestSystem(target)
+		m2.Config.SystemRepositories = nil
+		rootfs := filepath.Join(target, "rootfs")
+
+		Expect(m2.InstallBundle(&InstallOpts{SkipFinalizers: true, SkipHooks: true},
+			rootfs, out)).ToNot(HaveOccurred())
+
+		Expect(filepath.Join(rootfs, "usr/bin/foo")).To(BeARegularFile())
+		Expect(filepath.Join(rootfs, "usr/lib/libfoo.so")).To(BeARegularFile())
+
+		p, err := m2.Database.FindPackage(foo.GetPackage())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.(*pkg.DefaultPackage).IsExplicit()).To(BeTrue())
+		p, err = m2.Database.FindPackage(lib.GetPackage())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.(*pkg.DefaultPackage).IsExplicit()).To(BeFalse())
+
+		// The temporary repository is dropped.
+		Expect(m2.Config.GetSystem().GetSystemReposDirPath() + "/offline/bundle").ToNot(BeADirectory())
+	})
+
+	It("Refuses a bundle with the name of a configured repository", func() {
+		m := setupTestSystem(tmpdir)
+
+		work := filepath.Join(tmpdir, "work")
+		b, err := bundle.NewBundleBuilder(testRepo, work)
+		Expect(err).ToNot(HaveOccurred())
+		out := filepath.Join(tmpdir, "test.tar")
+		Expect(b.Write(out)).ToNot(HaveOccurred())
+
+		err = m.InstallBundle(&InstallOpts{}, filepath.Join(tmpdir, "rootfs"), out)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("already used"))
+	})
+})
@@ -12,40 +12,151 @@ import (
 
 	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
 	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/v2/cache"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+
+	units "github.com/docker/go-units"
 )
 
 func (a *ArtifactsManager) CleanLocalPackagesCache() error {
-	var cleaned int = 0
+	_, err := a.GCPackagesCache(&cache.GCOpts{})
+	return err
+}
+
+// GCPackagesCache removes the packages from the cache selected by the
+// policies in input and the blobs no more referenced.
+func (a *ArtifactsManager) GCPackagesCache(opts *cache.GCOpts) (*cache.GCResult, error) {
+	pkgsCache := cache.NewPackagesCache(
+		a.Config.GetSystem().GetSystemPkgsCacheDirPath())
+
+	res, err := pkgsCache.GC(opts)
+	if err != nil {
+		return nil, fmt.Errorf("Error on cleanup cachedir: %s", err.Error())
+	}
+
+	if a.Config.GetGeneral().Debug {
+		for _, b := range res.Blobs {
+			Info("Removing ", b)
+		}
+	}
+
+	if opts.Pretend {
+		Info(fmt.Sprintf("Cleanup: %d packages and %d files (%s) to remove.",
+			len(res.Entries), len(res.Blobs), units.BytesSize(float64(res.Size))))
+	} else {
+		Info(fmt.Sprintf("Cleaned: %d packages and %d files (%s).",
+			len(res.Entries), len(res.Blobs), units.BytesSize(float64(res.Size))))
+	}
+
+	return res, nil
+}
+
+// KeepInstalledPackages returns the function used by the cache cleanup
+// to preserve the packages installed on the system and on the
+// other rootfs that share the same packages cache.
+func (a *ArtifactsManager) KeepInstalledPackages() (func(e *cache.CacheEntry) bool, error) {
+	a.Setup()
+
+	installed := make(map[string]bool, 0)
+	for _, p := range a.Database.World() {
+		installed[p.PackageName()+"@"+p.GetVersion()] = true
+	}
 
-	// Check if cache dir exists
-	if fileHelper.Exists(a.Config.GetSystem().GetSystemPkgsCacheDirPath()) {
+	pkgsCache := cache.NewPackagesCache(
+		a.Config.GetSystem().GetSystemPkgsCacheDirPath())
+	idx, err := pkgsCache.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
 
-		files, err := ioutil.ReadDir(a.Config.GetSystem().GetSystemPkgsCacheDirPath())
+	for _, dbpath := range idx.Databases {
+		if dbpath == a.getSystemDBPath() || !fileHelper.Exists(dbpath) {
+			continue
+		}
+
+		packs, err := readInstalledPackages(dbpath)
 		if err != nil {
-			return fmt.Errorf("Error on read cachedir: %s", err.Error())
+			return nil, fmt.Errorf("Error on read database %s: %s",
+				dbpath, err.Error())
+		}
+		for _, p := range packs {
+			installed[p.PackageName()+"@"+p.GetVersion()] = true
 		}
+	}
 
-		for _, file := range files {
-			if file.IsDir() {
-				continue
-			}
+	return func(e *cache.CacheEntry) bool {
+		return installed[e.Package+"@"+e.Version]
+	}, nil
+}
 
-			if a.Config.GetGeneral().Debug {
-				Info("Removing ", file.Name())
-			}
+// getSystemDBPath returns the path of the system database or
+// an empty string if the database is not persistent.
+func (a *ArtifactsManager) getSystemDBPath() string {
+	if a.Config.GetSystem().DatabaseEngine != "boltdb" {
+		return ""
+	}
+	return filepath.Join(
+		a.Config.GetSystem().GetSystemRepoDatabaseDirPath(), "luet.db")
+}
 
-			err := os.RemoveAll(
-				filepath.Join(a.Config.GetSystem().GetSystemPkgsCacheDirPath(), file.Name()))
-			if err != nil {
-				return fmt.Errorf("Error on removing %s", file.Name())
-			}
-			cleaned++
+func readInstalledPackages(dbpath string) (pkg.Packages, error) {
+	db := pkg.NewBoltDatabase(dbpath)
+	defer db.Close()
+
+	ans := pkg.Packages{}
+	packages := make(chan pkg.Package)
+	done := make(chan bool)
+	go func() {
+		for p := range packages {
+			ans = append(ans, p)
 		}
+		done <- true
+	}()
+
+	err := db.GetAllPackages(packages)
+	close(packages)
+	<-done
+
+	return ans, err
+}
+
+// registerCacheEntry updates the index of the packages cache
+// with the artifact downloaded.
+func (a *ArtifactsManager) registerCacheEntry(p *artifact.PackageArtifact) {
+	e := p.GetCacheEntry()
+	if e == nil {
+		return
 	}
 
-	Info("Cleaned: ", cleaned, "packages.")
+	pkgsCache := cache.NewPackagesCache(
+		a.Config.GetSystem().GetSystemPkgsCacheDirPath())
+	if !pkgsCache.HasBlob(e.Sha256) {
+		return
+	}
 
-	return nil
+	if info, err := os.Stat(pkgsCache.GetBlobPath(e.Sha256)); err == nil {
+		e.Size = info.Size()
+	}
+
+	err := pkgsCache.Register(e)
+	if err != nil {
+		Warning(fmt.Sprintf("[%s] Error on update packages cache index: %s",
+			p.GetPackage().HumanReadableString(), err.Error()))
+		return
+	}
+	a.addCacheDatabase(pkgsCache)
+}
+
+// addCacheDatabase registers the system database on the index of
+// the packages cache.
+func (a *ArtifactsManager) addCacheDatabase(pkgsCache *cache.PackagesCache) {
+	if a.getSystemDBPath() == "" {
+		return
+	}
+	if err := pkgsCache.AddDatabase(a.getSystemDBPath()); err != nil {
+		Warning("Error on update packages cache index: " + err.Error())
+	}
 }
 
 func (a *ArtifactsManager) PurgeLocalReposCache() error {
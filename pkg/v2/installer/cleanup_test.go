@@ -0,0 +1,84 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/geaaru/luet/pkg/config"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/v2/cache"
+	. "github.com/geaaru/luet/pkg/v2/installer"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cleanup", func() {
+	var tmpdir string
+	var m *ArtifactsManager
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "cleanup")
+		Expect(err).ToNot(HaveOccurred())
+		m = setupTestSystem(tmpdir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Keeps the packages installed on the rootfs sharing the cache", func() {
+		_, err := m.Database.CreatePackage(&pkg.DefaultPackage{
+			Category: "test", Name: "foo", Version: "1.0",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		// The database of another rootfs that uses the same cache.
+		otherDB := filepath.Join(tmpdir, "other", "luet.db")
+		Expect(os.MkdirAll(filepath.Dir(otherDB), 0755)).ToNot(HaveOccurred())
+		db := pkg.NewBoltDatabase(otherDB)
+		_, err = db.CreatePackage(&pkg.DefaultPackage{
+			Category: "test", Name: "bar", Version: "2.0",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(db.Close()).ToNot(HaveOccurred())
+
+		pkgsCache := cache.NewPackagesCache(
+			m.Config.GetSystem().GetSystemPkgsCacheDirPath())
+		Expect(pkgsCache.AddDatabase(otherDB)).ToNot(HaveOccurred())
+		// A rootfs removed doesn't block the cleanup.
+		Expect(pkgsCache.AddDatabase(
+			filepath.Join(tmpdir, "removed", "luet.db"))).ToNot(HaveOccurred())
+
+		keep, err := m.KeepInstalledPackages()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keep(&cache.CacheEntry{Package: "test/foo", Version: "1.0"})).To(BeTrue())
+		Expect(keep(&cache.CacheEntry{Package: "test/bar", Version: "2.0"})).To(BeTrue())
+		Expect(keep(&cache.CacheEntry{Package: "test/bar", Version: "1.0"})).To(BeFalse())
+	})
+
+	It("Indexes the downloaded artifacts", func() {
+		a := newTestArtifact(tmpdir, "test", "foo", "1.0", map[string]string{
+			"usr/bin/foo": "foo",
+		})
+		Expect(os.Remove(a.GetCacheFile())).ToNot(HaveOccurred())
+
+		repo, err := config.LuetCfg.GetSystemRepository(testRepo)
+		Expect(err).ToNot(HaveOccurred())
+		wr := wagon.NewWagonRepository(repo)
+		Expect(m.DownloadPackage(a, wr, "foo")).ToNot(HaveOccurred())
+
+		idx, err := cache.GetPackagesCache().ReadIndex()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(idx.Entries).To(HaveLen(1))
+		Expect(idx.Entries[0].Package).To(Equal("test/foo"))
+		Expect(idx.Entries[0].Repository).To(Equal(testRepo))
+		Expect(idx.Entries[0].Size).To(BeNumerically(">", 0))
+	})
+})
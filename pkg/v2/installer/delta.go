@@ -0,0 +1,104 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	. "github.com/geaaru/luet/pkg/logger"
+	"github.com/geaaru/luet/pkg/v2/cache"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	"github.com/geaaru/luet/pkg/v2/repository/client"
+
+	"github.com/pkg/errors"
+)
+
+// selectDelta returns the delta of the artifact to upgrade from the
+// installed version if available and the complete artifact is not
+// already in the cache.
+func (m *ArtifactsManager) selectDelta(art *artifact.PackageArtifact,
+	p2rmap *artifact.ArtifactsMap) *artifact.DeltaArtifact {
+
+	if len(art.Deltas) == 0 {
+		return nil
+	}
+
+	art.ResolveCachePath()
+	if fileHelper.Exists(art.CachePath) {
+		return nil
+	}
+
+	installed, err := p2rmap.GetArtifactsByKey(art.GetPackage().PackageName())
+	if err != nil {
+		return nil
+	}
+
+	return art.GetDelta(installed[0].GetVersion())
+}
+
+// downloadDelta downloads the delta tarball of the task and rebuilds
+// the complete package tarball in the packages cache with the files
+// of the installed version. The rebuilt tarball is stored as a blob
+// mapped to the checksum of the artifact of the repository: the artifact
+// of the task maintains the path and the checksums of the repository.
+func (m *ArtifactsManager) downloadDelta(t *DownloadTask,
+	progress *client.MultiProgress) error {
+
+	e := t.Artifact.GetCacheEntry()
+	if e == nil {
+		return errors.New("delta not applicable to an artifact without checksum")
+	}
+
+	// The files reused must be unmodified on the rootfs.
+	err := t.Delta.CheckRootfs(t.Rootfs)
+	if err != nil {
+		return errors.Wrap(err, "delta not applicable")
+	}
+
+	da := t.Delta.ToArtifact(t.Artifact)
+	err = m.downloadPackage(da, t.Repository, t.Message+" (delta)", progress)
+	if err != nil {
+		return err
+	}
+
+	rebuiltName := strings.Replace(filepath.Base(da.Path), ".delta-", ".rebuilt-", 1)
+	rebuilt := filepath.Join(
+		m.Config.GetSystem().GetSystemPkgsCacheDirPath(), rebuiltName)
+
+	err = t.Delta.Apply(da.CachePath, t.Rootfs, rebuilt, t.Artifact.CompressionType)
+	if err != nil {
+		os.Remove(rebuilt)
+		return errors.Wrap(err, "error on apply delta")
+	}
+
+	sha, err := fileHelper.Sha256Sum(rebuilt)
+	if err != nil {
+		os.Remove(rebuilt)
+		return err
+	}
+
+	Debug(fmt.Sprintf("[%s] Rebuilt package tarball %s from delta %s.",
+		t.Artifact.GetPackage().HumanReadableString(),
+		rebuiltName, filepath.Base(da.Path)))
+
+	// Move the rebuilt tarball in the blobs of the cache.
+	e.Artifact = rebuiltName
+	e.Source = e.Sha256
+	e.Sha256 = sha
+	pkgsCache := cache.NewPackagesCache(
+		m.Config.GetSystem().GetSystemPkgsCacheDirPath())
+	t.Artifact.CachePath, err = pkgsCache.Publish(rebuilt, e)
+	if err != nil {
+		os.Remove(rebuilt)
+		return err
+	}
+	m.addCacheDatabase(pkgsCache)
+
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/geaaru/luet/pkg/config"
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	"github.com/geaaru/luet/pkg/v2/cache"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	. "github.com/geaaru/luet/pkg/v2/installer"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+	solver "github.com/geaaru/luet/pkg/v2/solver"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Delta", func() {
+	var tmpdir, rootfs string
+	var m *ArtifactsManager
+	var wr *wagon.WagonRepository
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "delta")
+		Expect(err).ToNot(HaveOccurred())
+		m = setupTestSystem(tmpdir)
+		rootfs = filepath.Join(tmpdir, "rootfs")
+
+		repo, err := config.LuetCfg.GetSystemRepository(testRepo)
+		Expect(err).ToNot(HaveOccurred())
+		wr = wagon.NewWagonRepository(repo)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Maintains the artifact of the repository and resumes the upgrade", func() {
+		base := newTestArtifact(tmpdir, "test", "foo", "1.0", map[string]string{
+			"etc/a": "a", "etc/b": "b",
+		})
+		art := newTestArtifact(tmpdir, "test", "foo", "1.1", map[string]string{
+			"etc/a": "a", "etc/b": "b2",
+		})
+		base.CachePath = base.Path
+		art.CachePath = art.Path
+		delta, err := art.CreateDelta(base, filepath.Join(tmpdir, "repo"))
+		Expect(err).ToNot(HaveOccurred())
+		art.Deltas = []*artifact.DeltaArtifact{delta}
+
+		// Only the delta is downloaded.
+		sha := art.Checksums[string(artifact.SHA256)]
+		path := art.Path
+		Expect(os.Remove(cache.GetPackagesCache().GetBlobPath(sha))).ToNot(HaveOccurred())
+		art.CachePath = ""
+
+		// The previous version is installed.
+		Expect(os.MkdirAll(filepath.Join(rootfs, "etc"), 0755)).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(rootfs, "etc/a"), []byte("a"), 0644)).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(rootfs, "etc/b"), []byte("b"), 0644)).ToNot(HaveOccurred())
+		// The rebuilt tarball differs for the mtime of the installed file.
+		mtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		Expect(os.Chtimes(filepath.Join(rootfs, "etc/a"), mtime, mtime)).ToNot(HaveOccurred())
+
+		task := NewDownloadTask(art, wr)
+		task.Delta = delta
+		task.Rootfs = rootfs
+		Expect(m.DownloadPackages([]*DownloadTask{task})).ToNot(HaveOccurred())
+		Expect(task.Error).ToNot(HaveOccurred())
+
+		Expect(art.Path).To(Equal(path))
+		Expect(art.Checksums[string(artifact.SHA256)]).To(Equal(sha))
+		Expect(NewTransactionPackage(art).Sha256).To(Equal(sha))
+
+		// The rebuilt tarball is available through the checksum
+		// of the artifact of the repository.
+		art.ResolveCachePath()
+		Expect(art.CachePath).ToNot(Equal(cache.GetPackagesCache().GetBlobPath(sha)))
+		Expect(fileHelper.Exists(art.CachePath)).To(BeTrue())
+		Expect(art.Verify()).ToNot(HaveOccurred())
+
+		writeTestRepository(art)
+		t, err := m.NewTransaction(TransactionUpgrade, rootfs,
+			&[]*solver.Operation{solver.NewOperation(solver.AddPackage, art)}, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		t, err = m.GetTransaction(t.Id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m.ResumeTransaction(t, &InstallOpts{SkipFinalizers: true, SkipHooks: true})).ToNot(HaveOccurred())
+		Expect(t.State).To(Equal(TransactionCompleted))
+
+		data, err := os.ReadFile(filepath.Join(rootfs, "etc/b"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("b2"))
+	})
+})
@@ -0,0 +1,162 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	. "github.com/geaaru/luet/pkg/logger"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+	"github.com/geaaru/luet/pkg/v2/repository/client"
+	"github.com/logrusorgru/aurora"
+)
+
+// DownloadTask describes an artifact to download from a repository.
+type DownloadTask struct {
+	Artifact   *artifact.PackageArtifact
+	Repository *wagon.WagonRepository
+	Message    string
+	Error      error
+
+	// Delta to try before the download of the complete artifact
+	// and the rootfs where the previous version is installed.
+	Delta  *artifact.DeltaArtifact
+	Rootfs string
+}
+
+// DownloadError aggregates the errors of the failed downloads.
+type DownloadError struct {
+	Failed []*DownloadTask
+	Total  int
+}
+
+type progressClient interface {
+	SetProgress(*client.MultiProgress)
+}
+
+func (e *DownloadError) Error() string {
+	msgs := []string{
+		fmt.Sprintf("%d of %d downloads failed:", len(e.Failed), e.Total),
+	}
+	for _, t := range e.Failed {
+		msgs = append(msgs, fmt.Sprintf("  - %s::%s: %s",
+			t.Artifact.GetPackage().PackageName(),
+			t.Artifact.GetPackage().Repository,
+			t.Error.Error()))
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func NewDownloadTask(a *artifact.PackageArtifact, r *wagon.WagonRepository) *DownloadTask {
+	return &DownloadTask{
+		Artifact:   a,
+		Repository: r,
+	}
+}
+
+// GetDownloadWorkers returns the number of parallel downloads
+// defined by the client_multifetch option.
+func (m *ArtifactsManager) GetDownloadWorkers(ntasks int) int {
+	ans := m.Config.GetGeneral().ClientMultiFetch
+	if ans <= 0 {
+		ans = 1
+	}
+	if ans > ntasks {
+		ans = ntasks
+	}
+	return ans
+}
+
+// DownloadPackages downloads the artifacts of the tasks with a pool
+// of workers sized by the client_multifetch option. All the downloads
+// are executed also on errors and a DownloadError with the list of the
+// failed tasks is returned at the end.
+func (m *ArtifactsManager) DownloadPackages(tasks []*DownloadTask) error {
+	ntasks := len(tasks)
+	if ntasks == 0 {
+		return nil
+	}
+
+	for idx, t := range tasks {
+		if t.Message == "" {
+			t.Message = fmt.Sprintf(
+				"[%3d of %3d] %-65s - %-15s",
+				aurora.Bold(aurora.BrightMagenta(idx+1)),
+				aurora.Bold(aurora.BrightMagenta(ntasks)),
+				fmt.Sprintf("%s::%s", t.Artifact.GetPackage().PackageName(),
+					t.Artifact.GetPackage().Repository,
+				),
+				t.Artifact.GetPackage().GetVersion())
+		}
+	}
+
+	nworkers := m.GetDownloadWorkers(ntasks)
+	Debug(fmt.Sprintf("Downloading %d artifacts with %d workers.",
+		ntasks, nworkers))
+
+	progress := client.NewMultiProgress()
+	progress.Start()
+	defer progress.Stop()
+
+	ch := make(chan *DownloadTask)
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < nworkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range ch {
+				if t.Delta != nil {
+					t.Error = m.downloadDelta(t, progress)
+					if t.Error == nil {
+						progress.Println(Emojize(fmt.Sprintf(
+							":package:%s # delta from %s applied :check_mark:",
+							t.Message, t.Delta.FromVersion)))
+						continue
+					}
+					Debug(fmt.Sprintf("[%s] Delta from %s not used: %s",
+						t.Artifact.GetPackage().HumanReadableString(),
+						t.Delta.FromVersion, t.Error.Error()))
+				}
+
+				t.Error = m.downloadPackage(t.Artifact, t.Repository,
+					t.Message, progress)
+				if t.Error != nil {
+					progress.Println(Emojize(fmt.Sprintf(
+						":package:%s # download failed :fire:", t.Message)))
+				} else {
+					progress.Println(Emojize(fmt.Sprintf(
+						":package:%s # downloaded :check_mark:", t.Message)))
+				}
+			}
+		}()
+	}
+
+	for _, t := range tasks {
+		ch <- t
+	}
+	close(ch)
+	wg.Wait()
+	progress.Stop()
+
+	failed := []*DownloadTask{}
+	for _, t := range tasks {
+		if t.Error != nil {
+			failed = append(failed, t)
+		}
+	}
+
+	if len(failed) > 0 {
+		return &DownloadError{
+			Failed: failed,
+			Total:  ntasks,
+		}
+	}
+
+	return nil
+}
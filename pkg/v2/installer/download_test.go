@@ -0,0 +1,102 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer_test
+
+import (
+	"errors"
+	"os"
+
+	"github.com/geaaru/luet/pkg/config"
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	. "github.com/geaaru/luet/pkg/v2/installer"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Download", func() {
+	var tmpdir string
+	var m *ArtifactsManager
+	var wr *wagon.WagonRepository
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "download")
+		Expect(err).ToNot(HaveOccurred())
+		m = setupTestSystem(tmpdir)
+		m.Config.GetGeneral().ClientMultiFetch = 2
+
+		repo, err := config.LuetCfg.GetSystemRepository(testRepo)
+		Expect(err).ToNot(HaveOccurred())
+		wr = wagon.NewWagonRepository(repo)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	// newRemoteArtifact returns an artifact available only
+	// on the repository.
+	newRemoteArtifact := func(name string) *artifact.PackageArtifact {
+		a := newTestArtifact(tmpdir, "test", name, "1.0", map[string]string{
+			"usr/bin/" + name: name,
+		})
+		Expect(os.Remove(a.GetCacheFile())).ToNot(HaveOccurred())
+		return a
+	}
+
+	It("Limits the workers to the tasks", func() {
+		Expect(m.GetDownloadWorkers(1)).To(Equal(1))
+		Expect(m.GetDownloadWorkers(5)).To(Equal(2))
+
+		m.Config.GetGeneral().ClientMultiFetch = 0
+		Expect(m.GetDownloadWorkers(5)).To(Equal(1))
+	})
+
+	It("Downloads all the artifacts and reports the failures", func() {
+		foo := newRemoteArtifact("foo")
+		bar := newRemoteArtifact("bar")
+		baz := newRemoteArtifact("baz")
+		Expect(os.Remove(baz.Path)).ToNot(HaveOccurred())
+
+		tasks := []*DownloadTask{
+			NewDownloadTask(foo, wr),
+			NewDownloadTask(baz, wr),
+			NewDownloadTask(bar, wr),
+		}
+
+		err := m.DownloadPackages(tasks)
+		Expect(err).To(HaveOccurred())
+
+		var derr *DownloadError
+		Expect(errors.As(err, &derr)).To(BeTrue())
+		Expect(derr.Total).To(Equal(3))
+		Expect(derr.Failed).To(HaveLen(1))
+		Expect(derr.Failed[0].Artifact).To(Equal(baz))
+		Expect(err.Error()).To(ContainSubstring("1 of 3 downloads failed"))
+		Expect(err.Error()).To(ContainSubstring("test/baz::test"))
+
+		Expect(tasks[0].Error).ToNot(HaveOccurred())
+		Expect(tasks[2].Error).ToNot(HaveOccurred())
+		Expect(fileHelper.Exists(foo.GetCacheFile())).To(BeTrue())
+		Expect(fileHelper.Exists(bar.GetCacheFile())).To(BeTrue())
+		Expect(fileHelper.Exists(baz.GetCacheFile())).To(BeFalse())
+	})
+
+	It("Rejects an artifact with a wrong checksum", func() {
+		foo := newRemoteArtifact("foo")
+		Expect(os.WriteFile(foo.Path, []byte("corrupted"), 0644)).ToNot(HaveOccurred())
+
+		err := m.DownloadPackages([]*DownloadTask{NewDownloadTask(foo, wr)})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("integrity check"))
+	})
+
+	It("Accepts an empty list", func() {
+		Expect(m.DownloadPackages([]*DownloadTask{})).ToNot(HaveOccurred())
+	})
+})
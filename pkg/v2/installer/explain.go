@@ -0,0 +1,58 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer
+
+import (
+	"fmt"
+
+	. "github.com/geaaru/luet/pkg/logger"
+	solver "github.com/geaaru/luet/pkg/v2/solver"
+)
+
+// showExplain prints the decisions of the solver grouped by package.
+func (m *ArtifactsManager) showExplain(e *solver.SolverExplain) {
+	if e == nil {
+		return
+	}
+
+	aurora := GetAurora()
+
+	InfoC(":brain:Solver decisions:")
+	if len(e.Entries) == 0 {
+		InfoC("   No decisions recorded.")
+		return
+	}
+
+	for _, pname := range e.GetPackages() {
+		InfoC(fmt.Sprintf(":package:%s", aurora.Bold(pname)))
+
+		for _, entry := range e.GetPackageEntries(pname) {
+			var decision interface{}
+			switch entry.Decision {
+			case solver.ExplainSelected, solver.ExplainBacktrack:
+				decision = aurora.Bold(aurora.Green(fmt.Sprintf("%-10s", entry.Decision)))
+			case solver.ExplainCandidates, solver.ExplainProvides:
+				decision = aurora.Bold(aurora.BrightCyan(fmt.Sprintf("%-10s", entry.Decision)))
+			default:
+				decision = aurora.Bold(aurora.BrightRed(fmt.Sprintf("%-10s", entry.Decision)))
+			}
+
+			msg := fmt.Sprintf("   %s", decision)
+			if entry.Artifact != "" {
+				msg += " " + entry.Artifact
+			}
+			if entry.Reason != "" {
+				if entry.Artifact != "" {
+					msg += ":"
+				}
+				msg += " " + entry.Reason
+			}
+			if entry.RequiredBy != "" {
+				msg += fmt.Sprintf(" (required by %s)", entry.RequiredBy)
+			}
+			InfoC(msg)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer
+
+import (
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/v2/hooks"
+	solver "github.com/geaaru/luet/pkg/v2/solver"
+)
+
+// getOperationsTriggers returns the files touched by the operations
+// of the transaction. The remove of the old version of a package
+// updated is considered as upgrade.
+func (m *ArtifactsManager) getOperationsTriggers(ops *[]*solver.Operation) []*hooks.Trigger {
+	ans := []*hooks.Trigger{}
+
+	updated := make(map[string]bool, 0)
+	for _, op := range *ops {
+		if op.Action == solver.UpdatePackage || op.Action == solver.DowngradePackage {
+			updated[op.Artifact.GetPackage().PackageName()] = true
+		}
+	}
+
+	for _, op := range *ops {
+		p := op.Artifact.GetPackage()
+		t := &hooks.Trigger{
+			Package: p.HumanReadableString(),
+			Files:   op.Artifact.Files,
+		}
+
+		switch op.Action {
+		case solver.AddPackage:
+			t.Operation = hooks.OperationInstall
+		case solver.UpdatePackage, solver.DowngradePackage:
+			t.Operation = hooks.OperationUpgrade
+		case solver.RemovePackage:
+			t.Operation = hooks.OperationRemove
+			if updated[p.PackageName()] {
+				t.Operation = hooks.OperationUpgrade
+			}
+			if len(t.Files) == 0 {
+				t.Files, _ = m.Database.GetPackageFiles(p)
+			}
+		}
+
+		ans = append(ans, t)
+	}
+
+	return ans
+}
+
+// getRemoveTriggers returns the files of the installed packages
+// to remove.
+func (m *ArtifactsManager) getRemoveTriggers(pkgs []*pkg.DefaultPackage) []*hooks.Trigger {
+	ans := []*hooks.Trigger{}
+	for _, p := range pkgs {
+		files, _ := m.Database.GetPackageFiles(p)
+		ans = append(ans, &hooks.Trigger{
+			Package:   p.HumanReadableString(),
+			Operation: hooks.OperationRemove,
+			Files:     files,
+		})
+	}
+	return ans
+}
+
+// runHooks executes the hooks of the phase in input that
+// match the files of the transaction.
+func (m *ArtifactsManager) runHooks(when, targetRootfs string,
+	triggers []*hooks.Trigger) error {
+
+	list, err := hooks.LoadHooks(m.Config)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return nil
+	}
+
+	Debug("Evaluating", len(list), when+"-transaction hooks...")
+
+	return hooks.RunHooks(list, when, targetRootfs, triggers)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/geaaru/luet/pkg/config"
+	"github.com/geaaru/luet/pkg/helpers"
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/v2/cache"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	. "github.com/geaaru/luet/pkg/v2/installer"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+
+	tarf "github.com/geaaru/tar-formers/pkg/executor"
+	tarf_specs "github.com/geaaru/tar-formers/pkg/specs"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+func TestInstaller(t *testing.T) {
+	RegisterFailHandler(Fail)
+	InitAurora()
+
+	cfg := tarf_specs.NewConfig(config.LuetCfg.Viper)
+	tarf.SetDefaultTarFormers(tarf.NewTarFormers(cfg))
+
+	RunSpecs(t, "Installer Suite")
+}
+
+const testRepo = "test"
+
+// setupTestSystem configures a system with the database, the packages
+// cache and the rootfs under a temporary directory and returns the
+// manager with an in-memory database.
+func setupTestSystem(tmpdir string) *ArtifactsManager {
+	cfg := config.LuetCfg
+	cfg.GetGeneral().Concurrency = 1
+	cfg.GetSystem().Rootfs = "/"
+	cfg.GetSystem().DatabasePath = filepath.Join(tmpdir, "db")
+	cfg.GetSystem().PkgsCachePath = filepath.Join(tmpdir, "cache")
+	cfg.SystemRepositories = []config.LuetRepository{
+		{
+			Name:   testRepo,
+			Type:   "disk",
+			Urls:   []string{filepath.Join(tmpdir, "repo")},
+			Enable: true,
+			Cached: true,
+		},
+	}
+
+	Expect(os.MkdirAll(filepath.Join(tmpdir, "rootfs"), 0755)).ToNot(HaveOccurred())
+
+	m := NewArtifactsManager(cfg)
+	m.Database = pkg.NewInMemoryDatabase(false)
+	return m
+}
+
+// newTestArtifact creates the tarball of a package with the files in
+// input and stores it in the packages cache. Without files the tarball
+// is corrupted.
+func newTestArtifact(tmpdir, category, name, version string,
+	files map[string]string) *artifact.PackageArtifact {
+
+	src := filepath.Join(tmpdir, "src", category, name, version)
+	Expect(os.MkdirAll(src, 0755)).ToNot(HaveOccurred())
+
+	a := artifact.NewPackageArtifact(
+		filepath.Join(tmpdir, "repo", name+"-"+category+"-"+version+".package.tar"))
+	a.Runtime = &pkg.DefaultPackage{
+		Category:   category,
+		Name:       name,
+		Version:    version,
+		Repository: testRepo,
+	}
+
+	Expect(os.MkdirAll(filepath.Dir(a.Path), 0755)).ToNot(HaveOccurred())
+	if len(files) > 0 {
+		for f, content := range files {
+			Expect(os.MkdirAll(filepath.Join(src, filepath.Dir(f)), 0755)).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(src, f), []byte(content), 0644)).ToNot(HaveOccurred())
+			a.Files = append(a.Files, f)
+		}
+		Expect(helpers.Tar(src, a.Path)).ToNot(HaveOccurred())
+	} else {
+		Expect(os.WriteFile(a.Path, []byte("not a tarball"), 0644)).ToNot(HaveOccurred())
+	}
+	a.CachePath = a.Path
+	Expect(a.Hash()).ToNot(HaveOccurred())
+	a.CachePath = ""
+
+	blob := cache.GetPackagesCache().GetBlobPath(a.Checksums[string(artifact.SHA256)])
+	Expect(os.MkdirAll(filepath.Dir(blob), 0755)).ToNot(HaveOccurred())
+	Expect(fileHelper.CopyFile(a.Path, blob)).ToNot(HaveOccurred())
+
+	return a
+}
+
+// writeTestRepository writes the local metadata of the test repository
+// with the artifacts in input.
+func writeTestRepository(arts ...*artifact.PackageArtifact) {
+	dir := config.LuetCfg.GetSystem().GetRepoDatabaseDirPath(testRepo)
+	repo, err := config.LuetCfg.GetSystemRepository(testRepo)
+	Expect(err).ToNot(HaveOccurred())
+
+	identity := wagon.NewWagonIdentify(repo)
+	identity.RepositoryFiles[wagon.REPOFILE_META_KEY] = &wagon.WagonDocument{
+		FileName: "repository.meta.yaml.tar",
+	}
+	Expect(identity.Write(filepath.Join(dir, wagon.REPOSITORY_SPECFILE))).ToNot(HaveOccurred())
+
+	data, err := yaml.Marshal(&wagon.StonesCatalog{Index: arts})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(os.MkdirAll(filepath.Join(dir, "metafs"), 0755)).ToNot(HaveOccurred())
+	Expect(os.MkdirAll(filepath.Join(dir, "treefs"), 0755)).ToNot(HaveOccurred())
+	Expect(os.WriteFile(
+		filepath.Join(dir, "metafs", wagon.REPOSITORY_METAFILE), data, 0644,
+	)).ToNot(HaveOccurred())
+}
+
+// writeTestTree writes the tree of the test repository with the
+// definition and the metadata of the artifacts in input.
+func writeTestTree(arts ...*artifact.PackageArtifact) {
+	repo, err := config.LuetCfg.GetSystemRepository(testRepo)
+	Expect(err).ToNot(HaveOccurred())
+	treefs := wagon.NewWagonRepository(repo).GetTreePath(
+		config.LuetCfg.GetSystem().GetSystemReposDirPath())
+
+	for _, a := range arts {
+		p := a.GetPackage()
+		dir := filepath.Join(treefs, p.GetCategory(), p.GetName(), p.GetVersion())
+		Expect(os.MkdirAll(dir, 0755)).ToNot(HaveOccurred())
+
+		data, err := p.Yaml()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dir, "definition.yaml"), data, 0644)).ToNot(HaveOccurred())
+		Expect(a.WriteMetadataYaml(filepath.Join(dir, "metadata.yaml"))).ToNot(HaveOccurred())
+	}
+}
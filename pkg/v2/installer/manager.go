@@ -23,6 +23,7 @@ import (
 	"github.com/geaaru/luet/pkg/tree"
 	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
 	repos "github.com/geaaru/luet/pkg/v2/repository"
+	"github.com/geaaru/luet/pkg/v2/repository/client"
 
 	"github.com/pkg/errors"
 )
@@ -61,11 +62,20 @@ func (m *ArtifactsManager) Close() {
 }
 
 func (m *ArtifactsManager) DownloadPackage(p *artifact.PackageArtifact, r *repos.WagonRepository, msg string) error {
+	return m.downloadPackage(p, r, msg, nil)
+}
+
+func (m *ArtifactsManager) downloadPackage(p *artifact.PackageArtifact,
+	r *repos.WagonRepository, msg string, progress *client.MultiProgress) error {
 	c := r.Client()
 	if c == nil {
 		return errors.New("No client could be generated from repository")
 	}
 
+	if pc, ok := c.(progressClient); ok && progress != nil {
+		pc.SetProgress(progress)
+	}
+
 	err := c.DownloadArtifact(p, msg)
 	if err != nil {
 		return errors.Wrap(err, "Error on download artifact")
@@ -77,6 +87,8 @@ func (m *ArtifactsManager) DownloadPackage(p *artifact.PackageArtifact, r *repos
 			"Artifact integrity check failure for file "+p.CachePath)
 	}
 
+	m.registerCacheEntry(p)
+
 	return nil
 }
 
@@ -302,6 +314,11 @@ func (m *ArtifactsManager) RemovePackage(s *repos.Stone,
 		return errors.Wrap(err, "Failed removing package files from database")
 	}
 
+	err = m.Database.RemovePackageFilesMeta(p)
+	if err != nil && !force {
+		return errors.Wrap(err, "Failed removing package files metadata from database")
+	}
+
 	if !skipFinalizer {
 		pf, err := m.Database.GetPackageFinalizer(p)
 		if err != nil && !force {
@@ -359,6 +376,7 @@ func (m *ArtifactsManager) ReinstallPackage(
 		pkg := s.ToPackage()
 		// With force i reinstall also database files
 		m.Database.RemovePackageFiles(pkg)
+		m.Database.RemovePackageFilesMeta(pkg)
 		m.Database.RemovePackageFinalizer(pkg)
 		m.Database.RemovePackage(pkg)
 
@@ -368,7 +386,12 @@ func (m *ArtifactsManager) ReinstallPackage(
 		}
 	}
 
-	return m.InstallPackage(p, r, targetRootfs)
+	err = m.InstallPackage(p, r, targetRootfs)
+	if err != nil {
+		return err
+	}
+
+	return m.RegisterPackageFilesMeta(p.GetPackage(), p.Files, p.FilesMeta)
 }
 
 func (m *ArtifactsManager) InstallPackage(p *artifact.PackageArtifact, r *repos.WagonRepository, targetRootfs string) error {
@@ -0,0 +1,234 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer
+
+import (
+	"fmt"
+
+	. "github.com/geaaru/luet/pkg/logger"
+	"github.com/geaaru/luet/pkg/v2/hooks"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+	solver "github.com/geaaru/luet/pkg/v2/solver"
+	"github.com/logrusorgru/aurora"
+
+	"github.com/pkg/errors"
+)
+
+// executeOperations executes the sorted operations and registers
+// every step on the transaction journal. The install executes only
+// the new packages and the removals, the upgrade executes also the
+// updates and the downgrades.
+func (m *ArtifactsManager) executeOperations(
+	t *Transaction,
+	installOps *[]*solver.Operation,
+	mapRepos map[string]*wagon.WagonRepository,
+	opts *InstallOpts,
+	targetRootfs string,
+	upgrade bool) error {
+
+	var err error
+	errs := []error{}
+	fail := false
+
+	// The files of the packages to remove are available only
+	// before the execution of the operations.
+	triggers := []*hooks.Trigger{}
+	if !opts.SkipHooks {
+		triggers = m.getOperationsTriggers(installOps)
+	}
+
+	if !opts.SkipHooks && (t == nil || len(t.GetOperations(TxOpDone)) == 0) {
+		err = m.runHooks(hooks.WhenPre, targetRootfs, triggers)
+		if err != nil && !opts.Force {
+			// POST: no operations executed. The transaction is dropped.
+			if t != nil {
+				t.Cleanup()
+			}
+			return errors.Wrap(err, "error on execute pre-transaction hooks")
+		}
+	}
+
+	nOps := len(*installOps)
+	InfoC(fmt.Sprintf(
+		":clinking_beer_mugs:Executing %d packages operations...",
+		nOps,
+	))
+
+	for idx, op := range *installOps {
+
+		if t != nil && t.Operations[idx].Status == TxOpDone {
+			// POST: operation already executed by a previous run.
+			continue
+		}
+
+		if !upgrade && (op.Action == solver.UpdatePackage ||
+			op.Action == solver.DowngradePackage) {
+			continue
+		}
+
+		repos := ""
+		if op.Artifact.GetPackage().Repository != "" {
+			repos = "::" + op.Artifact.GetPackage().Repository
+		}
+
+		msg := fmt.Sprintf(
+			"[%3d of %3d] %-65s - %-15s",
+			aurora.Bold(aurora.BrightMagenta(idx+1)),
+			aurora.Bold(aurora.BrightMagenta(nOps)),
+			fmt.Sprintf("%s%s", op.Artifact.GetPackage().PackageName(),
+				repos,
+			),
+			op.Artifact.GetPackage().GetVersion())
+
+		err = m.beginOperation(t, idx, targetRootfs)
+		if err != nil {
+			m.closeTransaction(t, true)
+			return errors.Wrap(err, "error on update transaction journal")
+		}
+
+		switch op.Action {
+		case solver.RemovePackage:
+			p := op.Artifact.GetPackage()
+
+			stone := &wagon.Stone{
+				Name:        p.GetName(),
+				Category:    p.GetCategory(),
+				Version:     p.GetVersion(),
+				Annotations: p.GetAnnotations(),
+			}
+			err = m.RemovePackage(stone, targetRootfs,
+				opts.PreserveSystemEssentialData,
+				opts.SkipFinalizers,
+				opts.Force,
+			)
+
+			if err != nil {
+				Error(fmt.Sprintf("[%s] Removing failed: %s",
+					stone.HumanReadableString(),
+					err.Error()))
+				fail = true
+				if !opts.Force {
+					m.closeTransaction(t, true)
+					return err
+				} else {
+					errs = append(errs, err)
+				}
+			} else {
+				Info(fmt.Sprintf(":recycle: %s # removed :check_mark:", msg))
+				m.completeOperation(t, idx)
+			}
+
+		case solver.AddPackage, solver.UpdatePackage, solver.DowngradePackage:
+			art := op.Artifact
+			art.ResolveCachePath()
+			r := mapRepos[art.GetRepository()]
+
+			// The new packages are dependencies if not requested
+			// by the user. The updates preserve the install reason
+			// of the replaced package.
+			reasons := map[string]string{}
+			if t != nil {
+				reasons = t.InstallReasons
+			}
+			if reason, ok := reasons[art.GetPackage().PackageName()]; ok {
+				art.GetPackage().SetInstallReason(reason)
+			} else if op.Action == solver.AddPackage {
+				art.GetPackage().SetExplicit(false)
+			}
+
+			err = m.InstallPackage(art, r, targetRootfs)
+			if err != nil {
+				Error(fmt.Sprintf(":package:%s # install failer :fire:", msg))
+				errs = append(errs, fmt.Errorf(
+					"%s::%s - error: %s", art.GetPackage().PackageName(),
+					art.GetPackage().Repository,
+					err.Error()))
+				fail = true
+				// The package isn't registered: on resume the
+				// operation is executed again.
+				continue
+			}
+			Info(fmt.Sprintf(":shortcake:%s # installed :check_mark:", msg))
+
+			err = m.RegisterPackage(art, r, opts.Force)
+			if err != nil {
+				Error(fmt.Sprintf(
+					"Error on register artifact %s: %s",
+					art.GetPackage().HumanReadableString(),
+					err.Error()))
+				fail = true
+				if upgrade {
+					errs = append(errs, fmt.Errorf(
+						"%s::%s - error: %s", art.GetPackage().PackageName(),
+						art.GetPackage().Repository,
+						err.Error()))
+				} else if !opts.Force {
+					m.closeTransaction(t, true)
+					return err
+				} else {
+					errs = append(errs, err)
+				}
+			} else {
+				err = m.RegisterPackageFilesMeta(art.GetPackage(), art.Files, art.FilesMeta)
+				if err != nil {
+					Warning(fmt.Sprintf(
+						"Error on register files metadata of %s: %s",
+						art.GetPackage().HumanReadableString(),
+						err.Error()))
+				}
+				m.completeOperation(t, idx)
+			}
+		}
+	}
+
+	// Run finalizers of the installed packages
+	// sorted for action
+	if !opts.SkipFinalizers && (t == nil || !t.FinalizersDone) {
+		for _, op := range *installOps {
+			if op.Action == solver.RemovePackage ||
+				(!upgrade && op.Action != solver.AddPackage) {
+				continue
+			}
+			// POST: just run finalizer on the new packages.
+			art := op.Artifact
+			r := mapRepos[art.GetRepository()]
+			err = m.ExecuteFinalizer(art, r, true, targetRootfs)
+			if err != nil {
+				fail = true
+			}
+		}
+
+		if t != nil && !fail {
+			t.FinalizersDone = true
+		}
+	}
+
+	// The journal tracks only the packages operations: a failure of
+	// the post-transaction hooks doesn't interrupt the transaction.
+	m.closeTransaction(t, fail)
+
+	if !opts.SkipHooks {
+		err = m.runHooks(hooks.WhenPost, targetRootfs, triggers)
+		if err != nil {
+			fail = true
+			errs = append(errs, errors.Wrap(err,
+				"error on execute post-transaction hooks"))
+		}
+	}
+
+	if fail {
+
+		// Write all errors again
+		if len(errs) > 0 {
+			for _, e := range errs {
+				Error(e.Error())
+			}
+		}
+
+		return errors.New("Something goes wrong.")
+	}
+
+	return nil
+}
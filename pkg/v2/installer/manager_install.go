@@ -28,10 +28,14 @@ type InstallOpts struct {
 	SkipFinalizers              bool
 	Pretend                     bool
 	DownloadOnly                bool
+	SkipDeltas                  bool
 	CheckSystemFiles            bool
 	IgnoreMasks                 bool
+	IgnoreHolds                 bool
+	Explain                     bool
 	ShowInstallOrder            bool
 	Deep                        bool
+	SkipHooks                   bool
 }
 
 func (m *ArtifactsManager) sortPackages2Install(
@@ -121,15 +125,28 @@ func (m *ArtifactsManager) Install(opts *InstallOpts, targetRootfs string,
 	packs ...*pkg.DefaultPackage) error {
 
 	mapRepos := make(map[string]*wagon.WagonRepository, 0)
-	errs := []error{}
 
 	m.Setup()
 
-	err := m.ShowReposRevision()
+	err := m.checkPendingTransactions(opts.Force || opts.Pretend)
 	if err != nil {
 		return err
 	}
 
+	err = m.ShowReposRevision()
+	if err != nil {
+		return err
+	}
+
+	// The packages already installed as dependencies are
+	// now requested by the user.
+	if !opts.Pretend {
+		err = m.markExplicitInstalled(packs...)
+		if err != nil {
+			return err
+		}
+	}
+
 	// TODO: temporary load in memory all installed packages.
 	systemPkgs := m.Database.World()
 
@@ -158,12 +175,17 @@ func (m *ArtifactsManager) Install(opts *InstallOpts, targetRootfs string,
 		IgnoreConflicts: opts.IgnoreConflicts,
 		Force:           opts.Force,
 		NoDeps:          opts.NoDeps,
+		IgnoreMasks:     opts.IgnoreMasks,
+		Explain:         opts.Explain,
 	}
 
 	s := solver.NewSolverImplementation("solverv2", m.Config, solverOpts)
 	(*s).SetDatabase(m.Database)
 	pkgs2Install, pkgs2Remove, err := (*s).Install(pkgsToInstall)
 	SpinnerStop()
+	if opts.Explain {
+		m.showExplain((*s).GetExplain())
+	}
 	if err != nil {
 		return err
 	}
@@ -201,8 +223,8 @@ func (m *ArtifactsManager) Install(opts *InstallOpts, targetRootfs string,
 	fail := false
 	InfoC(fmt.Sprintf(":truck:Downloading %d packages...",
 		len(pkgs2Install.Artifacts)))
-	ndownloads := len(pkgs2Install.Artifacts)
-	for idx, art := range pkgs2Install.Artifacts {
+	tasks := []*DownloadTask{}
+	for _, art := range pkgs2Install.Artifacts {
 		repoName := art.GetRepository()
 
 		if repoName == "" {
@@ -238,29 +260,15 @@ func (m *ArtifactsManager) Install(opts *InstallOpts, targetRootfs string,
 			wr = mapRepos[repoName]
 		}
 
-		msg := fmt.Sprintf(
-			"[%3d of %3d] %-65s - %-15s",
-			aurora.Bold(aurora.BrightMagenta(idx+1)),
-			aurora.Bold(aurora.BrightMagenta(ndownloads)),
-			fmt.Sprintf("%s::%s", art.GetPackage().PackageName(),
-				art.GetPackage().Repository,
-			),
-			art.GetPackage().GetVersion())
+		tasks = append(tasks, NewDownloadTask(art, wr))
+	}
 
-		err = m.DownloadPackage(art, wr, msg)
+	if !fail {
+		err := m.DownloadPackages(tasks)
 		if err != nil {
+			Error(err.Error())
 			fail = true
-			fmt.Println(fmt.Sprintf(
-				"Error on download artifact %s: %s",
-				art.GetPackage().HumanReadableString(),
-				err.Error()))
-			Error(fmt.Sprintf(":package:%s # download failed :fire:",
-				msg))
-		} else {
-			Info(fmt.Sprintf(":package:%s # downloaded :check_mark:",
-				msg))
 		}
-
 	}
 
 	if fail {
@@ -292,122 +300,19 @@ func (m *ArtifactsManager) Install(opts *InstallOpts, targetRootfs string,
 		return nil
 	}
 
-	nOps := len(*installOps)
-	InfoC(fmt.Sprintf(
-		":clinking_beer_mugs:Executing %d packages operations...",
-		nOps,
-	))
-
-	// Step 7. Install the matches packages/Remove packages.
-	for idx, op := range *installOps {
-
-		repos := ""
-		if op.Artifact.GetPackage().Repository != "" {
-			repos = "::" + op.Artifact.GetPackage().Repository
-		}
-
-		msg := fmt.Sprintf(
-			"[%3d of %3d] %-65s - %-15s",
-			aurora.Bold(aurora.BrightMagenta(idx+1)),
-			aurora.Bold(aurora.BrightMagenta(nOps)),
-			fmt.Sprintf("%s%s", op.Artifact.GetPackage().PackageName(),
-				repos,
-			),
-			op.Artifact.GetPackage().GetVersion())
-
-		switch op.Action {
-		case solver.RemovePackage:
-			p := op.Artifact.GetPackage()
-
-			stone := &wagon.Stone{
-				Name:        p.GetName(),
-				Category:    p.GetCategory(),
-				Version:     p.GetVersion(),
-				Annotations: p.GetAnnotations(),
-			}
-			err = m.RemovePackage(stone, targetRootfs,
-				opts.PreserveSystemEssentialData,
-				opts.SkipFinalizers,
-				opts.Force,
-			)
-
-			if err != nil {
-				Error(fmt.Sprintf("[%s] Removing failed: %s",
-					stone.HumanReadableString(),
-					err.Error()))
-				fail = true
-				if !opts.Force {
-					return err
-				} else {
-					errs = append(errs, err)
-				}
-			} else {
-				Info(fmt.Sprintf(":recycle: %s # removed :check_mark:", msg))
-			}
-		case solver.AddPackage:
-			art := op.Artifact
-			art.ResolveCachePath()
-			r := mapRepos[art.GetRepository()]
-
-			err = m.InstallPackage(art, r, targetRootfs)
-			if err != nil {
-				Error(fmt.Sprintf(":package:%s # install failer :fire:", msg))
-				errs = append(errs, fmt.Errorf(
-					"%s::%s - error: %s", art.GetPackage().PackageName(),
-					art.GetPackage().Repository,
-					err.Error()))
-				fail = true
-			} else {
-				Info(fmt.Sprintf(":shortcake:%s # installed :check_mark:", msg))
-			}
-
-			err = m.RegisterPackage(art, r, opts.Force)
-			if err != nil {
-				Error(fmt.Sprintf(
-					"Error on register artifact %s: %s",
-					art.GetPackage().HumanReadableString(),
-					err.Error()))
-				fail = true
-				if !opts.Force {
-					return err
-				} else {
-					errs = append(errs, err)
-				}
-			}
-		}
-
-	}
-
-	// Run finalizers of the installed packages
-	// sorted for action
-	if !opts.SkipFinalizers {
-		for _, op := range *installOps {
-			if op.Action != solver.AddPackage {
-				continue
-			}
-			// POST: just run finalizer on the new packages.
-			art := op.Artifact
-			r := mapRepos[art.GetRepository()]
-			err = m.ExecuteFinalizer(art, r, true, targetRootfs)
-			if err != nil {
-				fail = true
-			}
-		}
+	// Step 7. Install the matches packages/Remove packages
+	//         registering every operation on the transaction journal.
+	reasons := m.getInstallReasons()
+	for _, p := range *pkgsToInstall {
+		reasons[p.PackageName()] = pkg.InstallReasonExplicit
 	}
 
-	if fail {
-
-		// Write all errors again
-		if len(errs) > 0 {
-			for _, e := range errs {
-				Error(e.Error())
-			}
-		}
-
-		return errors.New("Something goes wrong.")
+	t, err := m.NewTransaction(TransactionInstall, targetRootfs, installOps, reasons)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return m.executeOperations(t, installOps, mapRepos, opts, targetRootfs, false)
 }
 
 func (m *ArtifactsManager) _install_s1(
@@ -9,7 +9,9 @@ import (
 
 	. "github.com/geaaru/luet/pkg/logger"
 	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/v2/hooks"
 	repos "github.com/geaaru/luet/pkg/v2/repository"
+	solver "github.com/geaaru/luet/pkg/v2/solver"
 	"github.com/logrusorgru/aurora"
 
 	"github.com/pkg/errors"
@@ -21,11 +23,19 @@ type UninstallOpts struct {
 	PreserveSystemEssentialData bool
 	Ask                         bool
 	SkipFinalizers              bool
+	// Remove also the dependencies that become orphans.
+	Deep      bool
+	SkipHooks bool
 }
 
-func (m *ArtifactsManager) showPkgs2Remove(list *[]*pkg.DefaultPackage) {
+func (m *ArtifactsManager) showPkgs2Remove(list, orphans *[]*pkg.DefaultPackage) {
 	n := len(*list)
 
+	orphansMap := make(map[string]bool, 0)
+	for _, p := range *orphans {
+		orphansMap[p.PackageName()] = true
+	}
+
 	for idx, p := range *list {
 		repos := "::"
 		if p.GetRepository() != "" {
@@ -33,13 +43,20 @@ func (m *ArtifactsManager) showPkgs2Remove(list *[]*pkg.DefaultPackage) {
 		} else {
 			repos = ""
 		}
-		InfoC(fmt.Sprintf(":knife:[%s of %s] [%s] %-61s - %s",
+
+		note := ""
+		if orphansMap[p.PackageName()] {
+			note = aurora.Bold(aurora.BrightCyan(" (orphan)")).String()
+		}
+
+		InfoC(fmt.Sprintf(":knife:[%s of %s] [%s] %-61s - %s%s",
 			aurora.Bold(aurora.BrightMagenta(fmt.Sprintf("%3d", idx+1))),
 			aurora.Bold(aurora.BrightMagenta(fmt.Sprintf("%3d", n))),
 			aurora.Bold(aurora.BrightYellow("D")),
 			aurora.Bold(aurora.BrightYellow(
 				fmt.Sprintf("%s%s", p.PackageName(), repos))),
 			aurora.Bold(aurora.BrightYellow(p.GetVersion())),
+			note,
 		))
 	}
 }
@@ -69,20 +86,11 @@ func (m *ArtifactsManager) Uninstall(opts *UninstallOpts, targetRootfs string, p
 	}
 
 	var pkgs2remove []*pkg.DefaultPackage
+	orphans := []*pkg.DefaultPackage{}
 
 	if len(matchedPkgs) > 0 {
 		if opts.NoDeps {
-
-			m.showPkgs2Remove(&matchedPkgs)
-
-			if opts.Ask {
-				if !Ask() {
-					return errors.New("Packages remove cancelled by user.")
-				}
-			}
-
 			pkgs2remove = matchedPkgs
-
 		} else {
 
 			// TODO: temporary load in memory all installed packages.
@@ -99,17 +107,43 @@ func (m *ArtifactsManager) Uninstall(opts *UninstallOpts, targetRootfs string, p
 				}
 			}
 
-			m.showPkgs2Remove(&task.Matches)
+			pkgs2remove = task.Matches
+		}
 
-			if opts.Ask {
-				if !Ask() {
-					return errors.New("Packages remove cancelled by user.")
-				}
+		if opts.Deep {
+			s := solver.NewSolver(m.Config, &solver.SolverOpts{})
+			s.SetDatabase(m.Database)
+
+			var err error
+			orphans, err = s.OrphansAfterRemove(pkgs2remove)
+			if err != nil {
+				return err
 			}
 
-			pkgs2remove = task.Matches
+			pkgs2remove, err = s.SortPackages2Remove(
+				append(pkgs2remove, orphans...))
+			if err != nil {
+				return err
+			}
 		}
 
+		m.showPkgs2Remove(&pkgs2remove, &orphans)
+
+		if opts.Ask {
+			if !Ask() {
+				return errors.New("Packages remove cancelled by user.")
+			}
+		}
+	}
+
+	triggers := []*hooks.Trigger{}
+	if !opts.SkipHooks && len(pkgs2remove) > 0 {
+		triggers = m.getRemoveTriggers(pkgs2remove)
+
+		err := m.runHooks(hooks.WhenPre, targetRootfs, triggers)
+		if err != nil && !opts.Force {
+			return errors.Wrap(err, "error on execute pre-transaction hooks")
+		}
 	}
 
 	// TODO: parallelize this steps. does we need this?
@@ -159,6 +193,13 @@ func (m *ArtifactsManager) Uninstall(opts *UninstallOpts, targetRootfs string, p
 		}
 	}
 
+	if !opts.SkipHooks && len(pkgs2remove) > 0 {
+		err := m.runHooks(hooks.WhenPost, targetRootfs, triggers)
+		if err != nil {
+			lastErr = err
+		}
+	}
+
 	return lastErr
 }
 
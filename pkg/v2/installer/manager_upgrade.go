@@ -12,7 +12,6 @@ import (
 	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
 	wagon "github.com/geaaru/luet/pkg/v2/repository"
 	solver "github.com/geaaru/luet/pkg/v2/solver"
-	"github.com/logrusorgru/aurora"
 	"github.com/pkg/errors"
 )
 
@@ -237,13 +236,47 @@ func (m *ArtifactsManager) showPackages2Update(
 	m.showPackagesSorted(p2r, p2u, &ops, false)
 }
 
+func (m *ArtifactsManager) showHoldReport(r *solver.HoldReport) {
+	if r == nil || r.IsEmpty() {
+		return
+	}
+
+	aurora := GetAurora()
+
+	if len(r.Skipped) > 0 {
+		InfoC(":pushpin:Updates skipped by holds:")
+		for _, sk := range r.Skipped {
+			InfoC(fmt.Sprintf(":pushpin:  %-61s - %s [%s]",
+				aurora.Bold(aurora.BrightYellow(sk.Package)),
+				aurora.BrightCyan(sk.Available),
+				sk.Hold,
+			))
+		}
+	}
+
+	if len(r.Blocked) > 0 {
+		InfoC(":pushpin:Updates blocked by holds:")
+		for _, b := range r.Blocked {
+			InfoC(fmt.Sprintf(":pushpin:  %-61s - requires a change of %s [%s]",
+				aurora.Bold(aurora.BrightYellow(b.Candidate)),
+				aurora.BrightCyan(b.Package),
+				b.Hold,
+			))
+		}
+	}
+}
+
 func (m *ArtifactsManager) Upgrade(opts *InstallOpts, targetRootfs string) error {
 	mapRepos := make(map[string]*wagon.WagonRepository, 0)
-	errs := []error{}
 
 	m.Setup()
 
-	err := m.ShowReposRevision()
+	err := m.checkPendingTransactions(opts.Force || opts.Pretend)
+	if err != nil {
+		return err
+	}
+
+	err = m.ShowReposRevision()
 	if err != nil {
 		return err
 	}
@@ -256,17 +289,25 @@ func (m *ArtifactsManager) Upgrade(opts *InstallOpts, targetRootfs string) error
 		IgnoreConflicts: opts.IgnoreConflicts,
 		Force:           opts.Force,
 		NoDeps:          opts.NoDeps,
+		IgnoreMasks:     opts.IgnoreMasks,
+		IgnoreHolds:     opts.IgnoreHolds,
 		Deep:            opts.Deep,
+		Explain:         opts.Explain,
 	}
 
 	s := solver.NewSolverImplementation("solverv2", m.Config, solverOpts)
 	(*s).SetDatabase(m.Database)
 	pkgs2Remove, pkgs2Update, pkgs2Install, err := (*s).Upgrade()
 	SpinnerStop()
+	if opts.Explain {
+		m.showExplain((*s).GetExplain())
+	}
 	if err != nil {
 		return err
 	}
 
+	m.showHoldReport((*s).GetHoldReport())
+
 	if len(pkgs2Remove.Artifacts) == 0 &&
 		len(pkgs2Update.Artifacts) == 0 &&
 		len(pkgs2Install.Artifacts) == 0 {
@@ -308,8 +349,13 @@ func (m *ArtifactsManager) Upgrade(opts *InstallOpts, targetRootfs string) error
 		len(pkgs2Install.Artifacts)+len(pkgs2Update.Artifacts)))
 
 	pkgs2Download := append(pkgs2Install.Artifacts, pkgs2Update.Artifacts...)
-	ndownloads := len(pkgs2Download)
-	for idx, art := range pkgs2Download {
+	tasks := []*DownloadTask{}
+	p2rmap := pkgs2Remove.ToMap()
+	updates := make(map[*artifact.PackageArtifact]bool, 0)
+	for _, art := range pkgs2Update.Artifacts {
+		updates[art] = true
+	}
+	for _, art := range pkgs2Download {
 		repoName := art.GetRepository()
 
 		if repoName == "" {
@@ -345,27 +391,20 @@ func (m *ArtifactsManager) Upgrade(opts *InstallOpts, targetRootfs string) error
 			wr = mapRepos[repoName]
 		}
 
-		msg := fmt.Sprintf(
-			"[%3d of %3d] %-65s - %-15s",
-			aurora.Bold(aurora.BrightMagenta(idx+1)),
-			aurora.Bold(aurora.BrightMagenta(ndownloads)),
-			fmt.Sprintf("%s::%s", art.GetPackage().PackageName(),
-				art.GetPackage().Repository,
-			),
-			art.GetPackage().GetVersion())
+		task := NewDownloadTask(art, wr)
+		if _, ok := updates[art]; ok && !opts.SkipDeltas {
+			// Prefer the delta from the installed version if available.
+			task.Delta = m.selectDelta(art, p2rmap)
+			task.Rootfs = targetRootfs
+		}
+		tasks = append(tasks, task)
+	}
 
-		err = m.DownloadPackage(art, wr, msg)
+	if !fail {
+		err := m.DownloadPackages(tasks)
 		if err != nil {
+			Error(err.Error())
 			fail = true
-			fmt.Println(fmt.Sprintf(
-				"Error on download artifact %s: %s",
-				art.GetPackage().HumanReadableString(),
-				err.Error()))
-			Error(fmt.Sprintf(":package:%s # download failed :fire:",
-				msg))
-		} else {
-			Info(fmt.Sprintf(":package:%s # downloaded :check_mark:",
-				msg))
 		}
 	}
 	pkgs2Download = nil
@@ -401,117 +440,13 @@ func (m *ArtifactsManager) Upgrade(opts *InstallOpts, targetRootfs string) error
 		return nil
 	}
 
-	InfoC(fmt.Sprintf(
-		":clinking_beer_mugs:Executing %d packages operations...",
-		len(*installOps)))
-	nOps := len(*installOps)
-
-	for idx, op := range *installOps {
-
-		repos := ""
-		if op.Artifact.GetPackage().Repository != "" {
-			repos = "::" + op.Artifact.GetPackage().Repository
-		}
-
-		msg := fmt.Sprintf(
-			"[%3d of %3d] %-65s - %-15s",
-			aurora.Bold(aurora.BrightMagenta(idx+1)),
-			aurora.Bold(aurora.BrightMagenta(nOps)),
-			fmt.Sprintf("%s%s", op.Artifact.GetPackage().PackageName(),
-				repos,
-			),
-			op.Artifact.GetPackage().GetVersion())
-
-		switch op.Action {
-		case solver.RemovePackage:
-			p := op.Artifact.GetPackage()
-
-			stone := &wagon.Stone{
-				Name:        p.GetName(),
-				Category:    p.GetCategory(),
-				Version:     p.GetVersion(),
-				Annotations: p.GetAnnotations(),
-			}
-			err = m.RemovePackage(stone, targetRootfs,
-				opts.PreserveSystemEssentialData,
-				opts.SkipFinalizers,
-				opts.Force,
-			)
-
-			if err != nil {
-				Error(fmt.Sprintf("[%s] Removing failed: %s",
-					stone.HumanReadableString(),
-					err.Error()))
-				fail = true
-				if !opts.Force {
-					return err
-				} else {
-					errs = append(errs, err)
-				}
-			} else {
-				Info(fmt.Sprintf(":recycle: %s # removed :check_mark:", msg))
-			}
-
-		case solver.AddPackage, solver.UpdatePackage, solver.DowngradePackage:
-			art := op.Artifact
-			art.ResolveCachePath()
-			r := mapRepos[art.GetRepository()]
-
-			err = m.InstallPackage(art, r, targetRootfs)
-			if err != nil {
-				Error(fmt.Sprintf(":package:%s # install failer :fire:", msg))
-				errs = append(errs, fmt.Errorf(
-					"%s::%s - error: %s", art.GetPackage().PackageName(),
-					art.GetPackage().Repository,
-					err.Error()))
-				fail = true
-			} else {
-				Info(fmt.Sprintf(":shortcake:%s # installed :check_mark:", msg))
-			}
-
-			err = m.RegisterPackage(art, r, opts.Force)
-			if err != nil {
-				fail = true
-				Error(fmt.Sprintf(
-					"Error on register artifact %s: %s",
-					art.GetPackage().HumanReadableString(),
-					err.Error()))
-				errs = append(errs, fmt.Errorf(
-					"%s::%s - error: %s", art.GetPackage().PackageName(),
-					art.GetPackage().Repository,
-					err.Error()))
-			}
-		}
-	}
-
-	// Run finalizers of the installed packages
-	// sorted for action
-	if !opts.SkipFinalizers {
-		for _, op := range *installOps {
-			if op.Action == solver.RemovePackage {
-				continue
-			}
-			// POST: just run finalizer on the new packages.
-			art := op.Artifact
-			r := mapRepos[art.GetRepository()]
-			err = m.ExecuteFinalizer(art, r, true, targetRootfs)
-			if err != nil {
-				fail = true
-			}
-		}
-	}
-
-	if fail {
-
-		// Write all errors again
-		if len(errs) > 0 {
-			for _, e := range errs {
-				Error(e.Error())
-			}
-		}
-
-		return errors.New("Something goes wrong.")
+	// Step 7. Install the matches packages/Remove packages
+	//         registering every operation on the transaction journal.
+	t, err := m.NewTransaction(TransactionUpgrade, targetRootfs, installOps,
+		m.getInstallReasons())
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return m.executeOperations(t, installOps, mapRepos, opts, targetRootfs, true)
 }
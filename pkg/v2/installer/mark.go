@@ -0,0 +1,84 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer
+
+import (
+	"fmt"
+
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+
+	"github.com/pkg/errors"
+)
+
+// getInstallReasons returns the install reason of the installed
+// packages mapped by package name. The packages installed by older
+// releases are without install reason and are not present.
+func (m *ArtifactsManager) getInstallReasons() map[string]string {
+	ans := make(map[string]string, 0)
+
+	for _, p := range m.Database.World() {
+		dp := p.(*pkg.DefaultPackage)
+		if dp.HasInstallReason() {
+			ans[dp.PackageName()] = dp.GetInstallReason()
+		}
+	}
+
+	return ans
+}
+
+// MarkPackages sets the installed packages in input as explicitly
+// requested by the user or as dependencies.
+func (m *ArtifactsManager) MarkPackages(explicit bool, packs ...*pkg.DefaultPackage) error {
+	m.Setup()
+
+	for _, p := range packs {
+		installed, err := m.Database.FindPackages(p)
+		if err != nil || len(installed) == 0 {
+			return fmt.Errorf("package %s is not installed", p.HumanReadableString())
+		}
+
+		for _, ip := range installed {
+			dp := ip.(*pkg.DefaultPackage)
+			dp.SetExplicit(explicit)
+
+			if err := m.Database.UpdatePackage(dp); err != nil {
+				return errors.Wrapf(err, "Error on update package %s",
+					dp.HumanReadableString())
+			}
+
+			Debug(fmt.Sprintf("Package %s marked as %s.",
+				dp.HumanReadableString(), dp.GetInstallReason()))
+		}
+	}
+
+	return nil
+}
+
+// markExplicitInstalled marks as explicit the packages in input
+// already installed as dependencies.
+func (m *ArtifactsManager) markExplicitInstalled(packs ...*pkg.DefaultPackage) error {
+	for _, p := range packs {
+		installed, err := m.Database.FindPackages(p)
+		if err != nil || len(installed) == 0 {
+			continue
+		}
+
+		for _, ip := range installed {
+			dp := ip.(*pkg.DefaultPackage)
+			if dp.IsExplicit() {
+				continue
+			}
+
+			Info(fmt.Sprintf("%s marked as explicitly installed.",
+				dp.HumanReadableString()))
+			if err := m.MarkPackages(true, dp); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
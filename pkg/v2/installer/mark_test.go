@@ -0,0 +1,79 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer_test
+
+import (
+	"os"
+	"path/filepath"
+
+	pkg "github.com/geaaru/luet/pkg/package"
+	. "github.com/geaaru/luet/pkg/v2/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Install reason", func() {
+	var tmpdir string
+	var m *ArtifactsManager
+	var foo *pkg.DefaultPackage
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "mark")
+		Expect(err).ToNot(HaveOccurred())
+		m = setupTestSystem(tmpdir)
+		writeTestRepository()
+
+		foo = &pkg.DefaultPackage{
+			Category: "test", Name: "foo", Version: "1.0", Repository: testRepo,
+			Labels: map[string]string{"label": "value"},
+		}
+		foo.SetExplicit(false)
+		_, err = m.Database.CreatePackage(foo)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	getPackage := func() *pkg.DefaultPackage {
+		p, err := m.Database.FindPackage(foo)
+		Expect(err).ToNot(HaveOccurred())
+		return p.(*pkg.DefaultPackage)
+	}
+
+	It("Marks the installed packages", func() {
+		sel := &pkg.DefaultPackage{Category: "test", Name: "foo", Version: ">=0"}
+
+		Expect(m.MarkPackages(true, sel)).ToNot(HaveOccurred())
+		Expect(getPackage().IsExplicit()).To(BeTrue())
+		Expect(getPackage().GetLabels()).To(HaveKeyWithValue("label", "value"))
+
+		Expect(m.MarkPackages(false, sel)).ToNot(HaveOccurred())
+		Expect(getPackage().GetInstallReason()).To(Equal(pkg.InstallReasonDep))
+
+		Expect(m.MarkPackages(true,
+			&pkg.DefaultPackage{Category: "test", Name: "bar", Version: ">=0"},
+		)).To(HaveOccurred())
+	})
+
+	It("Marks as explicit on install a package installed as dependency", func() {
+		err := m.Install(&InstallOpts{SkipHooks: true, SkipFinalizers: true},
+			filepath.Join(tmpdir, "rootfs"),
+			&pkg.DefaultPackage{Category: "test", Name: "foo", Version: ">=0"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(getPackage().IsExplicit()).To(BeTrue())
+	})
+
+	It("Doesn't mark the package with pretend", func() {
+		err := m.Install(&InstallOpts{Pretend: true},
+			filepath.Join(tmpdir, "rootfs"),
+			&pkg.DefaultPackage{Category: "test", Name: "foo", Version: ">=0"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(getPackage().IsExplicit()).To(BeFalse())
+	})
+})
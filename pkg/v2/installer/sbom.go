@@ -0,0 +1,53 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer
+
+import (
+	"github.com/geaaru/luet/pkg/sbom"
+
+	"github.com/pkg/errors"
+)
+
+// GenerateSBOM returns the bill of materials of the packages
+// installed in the system. The digests of the files are the digests
+// stored on install: the files of the packages installed without
+// the integrity metadata are without digests.
+func (m *ArtifactsManager) GenerateSBOM(name string) (*sbom.Document, error) {
+	m.Setup()
+
+	doc := sbom.NewDocument(name)
+
+	for _, p := range m.Database.World() {
+		meta, err := m.Database.GetPackageFilesMeta(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error on read files metadata of %s",
+				p.HumanReadableString())
+		}
+
+		var sp *sbom.Package
+		if meta != nil {
+			sp = sbom.NewPackage(p, []string{})
+			for _, f := range meta.Files {
+				sp.Files = append(sp.Files, &sbom.File{
+					Path:   f.Path,
+					Sha1:   f.Sha1,
+					Sha256: f.Sha256,
+				})
+			}
+		} else {
+			files, err := m.Database.GetPackageFiles(p)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Error on read files of %s",
+					p.HumanReadableString())
+			}
+			sp = sbom.NewPackage(p, files)
+		}
+		sp.Repository = p.GetRepository()
+
+		doc.AddPackage(sp)
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/geaaru/luet/pkg/sbom"
+	. "github.com/geaaru/luet/pkg/v2/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SBOM", func() {
+	var tmpdir, rootfs string
+	var m *ArtifactsManager
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "sbom")
+		Expect(err).ToNot(HaveOccurred())
+		m = setupTestSystem(tmpdir)
+		rootfs = filepath.Join(tmpdir, "rootfs")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Uses the digests stored on install", func() {
+		foo := newTestArtifact(tmpdir, "test", "foo", "1.0", map[string]string{
+			"usr/bin/foo": "foo",
+		})
+		foo.ResolveCachePath()
+		Expect(m.InstallPackage(foo, nil, rootfs)).ToNot(HaveOccurred())
+		Expect(m.RegisterPackage(foo, nil, false)).ToNot(HaveOccurred())
+		Expect(m.RegisterPackageFilesMeta(foo.GetPackage(), foo.Files,
+			foo.FilesMeta)).ToNot(HaveOccurred())
+
+		// Package installed without the integrity metadata.
+		bar := newTestArtifact(tmpdir, "test", "bar", "1.0", map[string]string{
+			"usr/bin/bar": "bar",
+		})
+		bar.ResolveCachePath()
+		Expect(m.InstallPackage(bar, nil, rootfs)).ToNot(HaveOccurred())
+		Expect(m.RegisterPackage(bar, nil, false)).ToNot(HaveOccurred())
+
+		// The files changed after the install don't change the digests.
+		Expect(os.WriteFile(filepath.Join(rootfs, "usr/bin/foo"),
+			[]byte("changed"), 0755)).ToNot(HaveOccurred())
+
+		doc, err := m.GenerateSBOM("test")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(doc.Packages).To(HaveLen(2))
+
+		files := map[string]*sbom.File{}
+		for _, p := range doc.Packages {
+			for _, f := range p.Files {
+				files[f.Path] = f
+			}
+		}
+		Expect(files).To(HaveLen(2))
+		Expect(*files["usr/bin/foo"]).To(Equal(sbom.File{
+			Path:   "usr/bin/foo",
+			Sha1:   "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33",
+			Sha256: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+		}))
+		Expect(*files["usr/bin/bar"]).To(Equal(sbom.File{Path: "usr/bin/bar"}))
+	})
+})
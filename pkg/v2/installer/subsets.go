@@ -0,0 +1,356 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/pkg/errors"
+)
+
+type SubsetsApplyOpts struct {
+	Pretend bool
+	Force   bool
+	Ask     bool
+}
+
+// subsetsChange contains the files to add and to remove of an
+// installed package to respect the enabled subsets.
+type subsetsChange struct {
+	Package *pkg.DefaultPackage
+	// Artifact of the repository used to extract the new files.
+	Artifact *artifact.PackageArtifact
+	// Files of the package with the enabled subsets.
+	Files  []string
+	Add    []string
+	Remove []string
+}
+
+func (m *ArtifactsManager) searchInstalledArtifacts(
+	pkgs []*pkg.DefaultPackage) (map[string]*artifact.PackageArtifact, error) {
+
+	ans := make(map[string]*artifact.PackageArtifact, 0)
+	if len(pkgs) == 0 {
+		return ans, nil
+	}
+
+	searcher := wagon.NewSearcherSimple(m.Config)
+	defer searcher.Close()
+
+	artifacts, err := searcher.SearchArtifacts(&wagon.StonesSearchOpts{
+		Packages:     pkgs,
+		Categories:   []string{},
+		Labels:       []string{},
+		Matches:      []string{},
+		FilesOwner:   []string{},
+		Annotations:  []string{},
+		Hidden:       true,
+		OnlyPackages: true,
+		IgnoreMasks:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range *artifacts {
+		key := a.GetPackage().HumanReadableString()
+		// Prefer the artifact of the repository used on install.
+		if _, present := ans[key]; !present ||
+			a.GetRepository() == ans[key].GetPackage().Repository {
+			ans[key] = a
+		}
+	}
+
+	return ans, nil
+}
+
+func (m *ArtifactsManager) computeSubsetsChanges(targetRootfs string,
+	packs ...*pkg.DefaultPackage) ([]*subsetsChange, error) {
+
+	ans := []*subsetsChange{}
+	filter := make(map[string]bool, 0)
+	for _, p := range packs {
+		filter[p.PackageName()] = true
+	}
+
+	// Retrieve the installed packages with subsets rules.
+	candidates := []*pkg.DefaultPackage{}
+	for _, p := range m.Database.World() {
+		dp := p.(*pkg.DefaultPackage)
+		if len(filter) > 0 && !filter[dp.PackageName()] {
+			continue
+		}
+
+		a := artifact.NewPackageArtifact(dp.GetPath())
+		a.Runtime = dp
+		if len(a.GetSubsets().Definitions) == 0 {
+			continue
+		}
+		candidates = append(candidates, dp)
+	}
+
+	artifacts, err := m.searchInstalledArtifacts(candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []*subsetsChange{}
+	kept := make(map[string]bool, 0)
+	for _, p := range candidates {
+		current, err := m.Database.GetPackageFiles(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error on retrieve files of %s",
+				p.HumanReadableString())
+		}
+
+		// The files of the artifact are the complete list of the files.
+		// Without the artifact the new files are not available.
+		all := current
+		repoArt, available := artifacts[p.HumanReadableString()]
+		if available {
+			all = repoArt.Files
+		} else {
+			Warning(fmt.Sprintf(
+				"[%s] Package not available on repositories. Only the remove of the files is possible.",
+				p.HumanReadableString()))
+		}
+
+		a := artifact.NewPackageArtifact(p.GetPath())
+		a.Runtime = p
+		files, err := a.FilterSubsetsFiles(all)
+		if err != nil {
+			return nil, err
+		}
+
+		c := &subsetsChange{
+			Package:  p,
+			Artifact: repoArt,
+			Files:    files,
+			Add:      []string{},
+			Remove:   []string{},
+		}
+
+		mfiles := make(map[string]bool, 0)
+		for _, f := range files {
+			mfiles[f] = true
+			kept[f] = true
+			if !available {
+				continue
+			}
+			if _, err := os.Lstat(filepath.Join(targetRootfs, f)); err != nil {
+				c.Add = append(c.Add, f)
+			}
+		}
+
+		for _, f := range all {
+			if mfiles[f] {
+				continue
+			}
+			if _, err := os.Lstat(filepath.Join(targetRootfs, f)); err == nil {
+				c.Remove = append(c.Remove, f)
+			}
+		}
+
+		if len(c.Add) == 0 && len(c.Remove) == 0 && len(current) == len(files) {
+			continue
+		}
+
+		changes = append(changes, c)
+	}
+
+	// The files dropped by the subsets are removed only if they
+	// aren't owned by other installed packages.
+	if len(changes) > 0 {
+		mcandidates := make(map[string]bool, 0)
+		for _, p := range candidates {
+			mcandidates[p.HumanReadableString()] = true
+		}
+
+		for _, p := range m.Database.World() {
+			if mcandidates[p.HumanReadableString()] {
+				continue
+			}
+			files, _ := m.Database.GetPackageFiles(p)
+			for _, f := range files {
+				kept[f] = true
+			}
+		}
+
+		for _, c := range changes {
+			remove := []string{}
+			for _, f := range c.Remove {
+				if kept[f] {
+					Debug(fmt.Sprintf("[%s] File %s owned by other packages. Preserved.",
+						c.Package.HumanReadableString(), f))
+					continue
+				}
+				remove = append(remove, f)
+			}
+			c.Remove = remove
+			ans = append(ans, c)
+		}
+	}
+
+	return ans, nil
+}
+
+// ApplySubsets extracts the files of the enabled subsets and removes
+// the files of the disabled subsets of the installed packages
+// in input or of all installed packages with subsets rules.
+func (m *ArtifactsManager) ApplySubsets(opts *SubsetsApplyOpts, targetRootfs string,
+	packs ...*pkg.DefaultPackage) error {
+
+	m.Setup()
+
+	err := m.checkPendingTransactions(opts.Force || opts.Pretend)
+	if err != nil {
+		return err
+	}
+
+	InfoC(":brain:Computing subsets changes...")
+	changes, err := m.computeSubsetsChanges(targetRootfs, packs...)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		InfoC(":smiling_face_with_sunglasses:Installed packages already aligned with the enabled subsets.")
+		return nil
+	}
+
+	for _, c := range changes {
+		InfoC(fmt.Sprintf(":package:%-65s %s %s",
+			c.Package.HumanReadableString(),
+			aurora.Bold(aurora.Green(fmt.Sprintf("+%d", len(c.Add)))),
+			aurora.Bold(aurora.Red(fmt.Sprintf("-%d", len(c.Remove)))),
+		))
+	}
+
+	if opts.Pretend {
+		return nil
+	}
+
+	if opts.Ask && !Ask() {
+		return errors.New("Subsets apply cancelled by user.")
+	}
+
+	// Download the artifacts with new files.
+	mapRepos := make(map[string]*wagon.WagonRepository, 0)
+	tasks := []*DownloadTask{}
+	for _, c := range changes {
+		if len(c.Add) == 0 {
+			continue
+		}
+
+		repoName := c.Artifact.GetRepository()
+		wr, ok := mapRepos[repoName]
+		if !ok {
+			repo, err := m.Config.GetSystemRepository(repoName)
+			if err != nil {
+				return fmt.Errorf("Repository not found for artefact %s",
+					c.Artifact.GetPackage().HumanReadableString())
+			}
+
+			wr = wagon.NewWagonRepository(repo)
+			err = wr.ReadWagonIdentify(
+				m.Config.GetSystem().GetRepoDatabaseDirPath(repoName))
+			if err != nil {
+				return fmt.Errorf("Error on read repository identity file: " +
+					err.Error())
+			}
+			mapRepos[repoName] = wr
+		}
+
+		tasks = append(tasks, NewDownloadTask(c.Artifact, wr))
+	}
+
+	if len(tasks) > 0 {
+		InfoC(fmt.Sprintf(":truck:Downloading %d packages...", len(tasks)))
+		if err := m.DownloadPackages(tasks); err != nil {
+			return err
+		}
+	}
+
+	fail := false
+	for _, c := range changes {
+		p := c.Package
+
+		if len(c.Remove) > 0 {
+			stone := &wagon.Stone{
+				Name:        p.GetName(),
+				Category:    p.GetCategory(),
+				Version:     p.GetVersion(),
+				Annotations: p.GetAnnotations(),
+				Files:       c.Remove,
+			}
+			err = m.removePackageFiles(stone, targetRootfs, true)
+			if err != nil {
+				Error(fmt.Sprintf("[%s] Error on remove files: %s",
+					p.HumanReadableString(), err.Error()))
+				fail = true
+				if !opts.Force {
+					break
+				}
+			}
+		}
+
+		if len(c.Add) > 0 {
+			c.Artifact.ResolveCachePath()
+			err = c.Artifact.UnpackFiles(targetRootfs, c.Add)
+			if err != nil {
+				Error(fmt.Sprintf("[%s] Error on extract files: %s",
+					p.HumanReadableString(), err.Error()))
+				fail = true
+				if !opts.Force {
+					break
+				}
+				continue
+			}
+		}
+
+		// Update the files of the package on database.
+		m.Database.RemovePackageFiles(p)
+		err = m.Database.SetPackageFiles(&pkg.PackageFile{
+			PackageFingerprint: p.GetFingerPrint(),
+			Files:              c.Files,
+		})
+		if err != nil {
+			Error(fmt.Sprintf("[%s] Error on update files: %s",
+				p.HumanReadableString(), err.Error()))
+			fail = true
+			if !opts.Force {
+				break
+			}
+			continue
+		}
+
+		meta := []*pkg.FileMeta{}
+		if c.Artifact != nil && len(c.Add) > 0 {
+			meta = c.Artifact.FilesMeta
+		}
+		err = m.RegisterPackageFilesMeta(p, c.Files, meta)
+		if err != nil {
+			Warning(fmt.Sprintf("[%s] Error on update files metadata: %s",
+				p.HumanReadableString(), err.Error()))
+		}
+
+		Info(fmt.Sprintf(":shortcake:%s # subsets applied :check_mark:",
+			p.HumanReadableString()))
+	}
+
+	if fail {
+		return errors.New("Something goes wrong.")
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/geaaru/luet/pkg/config"
+	pkg "github.com/geaaru/luet/pkg/package"
+	. "github.com/geaaru/luet/pkg/v2/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Subsets", func() {
+	var tmpdir, rootfs string
+	var m *ArtifactsManager
+
+	registerPackage := func(p *pkg.DefaultPackage, files ...string) {
+		_, err := m.Database.CreatePackage(p)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m.Database.SetPackageFiles(&pkg.PackageFile{
+			PackageFingerprint: p.GetFingerPrint(),
+			Files:              files,
+		})).ToNot(HaveOccurred())
+
+		for _, f := range files {
+			Expect(os.MkdirAll(filepath.Join(rootfs, filepath.Dir(f)), 0755)).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(rootfs, f), []byte(f), 0644)).ToNot(HaveOccurred())
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "subsets")
+		Expect(err).ToNot(HaveOccurred())
+		m = setupTestSystem(tmpdir)
+		rootfs = filepath.Join(tmpdir, "rootfs")
+		writeTestRepository()
+
+		config.LuetCfg.SubsetsPkgsDefMap["test/foo"] = &config.LuetSubsetsDefinition{
+			Definitions: map[string]*config.LuetSubsetDefinition{
+				"docs": {
+					Name:  "docs",
+					Rules: []string{"^/usr/share/doc/.*"},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+		delete(config.LuetCfg.SubsetsPkgsDefMap, "test/foo")
+	})
+
+	It("Preserves the files owned by other packages", func() {
+		foo := &pkg.DefaultPackage{Category: "test", Name: "foo", Version: "1.0", Repository: testRepo}
+		bar := &pkg.DefaultPackage{Category: "test", Name: "bar", Version: "1.0", Repository: testRepo}
+		registerPackage(foo, "usr/bin/foo", "usr/share/doc/foo", "usr/share/doc/common")
+		registerPackage(bar, "usr/share/doc/common")
+
+		Expect(m.ApplySubsets(&SubsetsApplyOpts{}, rootfs)).ToNot(HaveOccurred())
+
+		Expect(filepath.Join(rootfs, "usr/bin/foo")).To(BeARegularFile())
+		Expect(filepath.Join(rootfs, "usr/share/doc/foo")).ToNot(BeAnExistingFile())
+		Expect(filepath.Join(rootfs, "usr/share/doc/common")).To(BeARegularFile())
+
+		files, err := m.Database.GetPackageFiles(foo)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files).To(Equal([]string{"usr/bin/foo"}))
+		files, err = m.Database.GetPackageFiles(bar)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files).To(Equal([]string{"usr/share/doc/common"}))
+	})
+
+	It("Doesn't touch the rootfs with pretend", func() {
+		foo := &pkg.DefaultPackage{Category: "test", Name: "foo", Version: "1.0", Repository: testRepo}
+		registerPackage(foo, "usr/bin/foo", "usr/share/doc/foo")
+
+		Expect(m.ApplySubsets(&SubsetsApplyOpts{Pretend: true}, rootfs)).ToNot(HaveOccurred())
+		Expect(filepath.Join(rootfs, "usr/share/doc/foo")).To(BeARegularFile())
+	})
+})
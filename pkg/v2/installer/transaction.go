@@ -0,0 +1,910 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+	solver "github.com/geaaru/luet/pkg/v2/solver"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	TransactionFile      = "transaction.yaml"
+	TransactionBackupDir = "backup"
+
+	TransactionInstall = "install"
+	TransactionUpgrade = "upgrade"
+
+	TransactionRunning     = "running"
+	TransactionCompleted   = "completed"
+	TransactionInterrupted = "interrupted"
+	TransactionRolledBack  = "rolledback"
+
+	TxOpPending  = "pending"
+	TxOpRunning  = "running"
+	TxOpDone     = "done"
+	TxOpReverted = "reverted"
+)
+
+// TransactionPackage is the reference to the package of an operation
+// and to the artifact to install. The artifact is resolved again from
+// the repositories catalog on resume.
+type TransactionPackage struct {
+	Category   string `json:"category" yaml:"category"`
+	Name       string `json:"name" yaml:"name"`
+	Version    string `json:"version" yaml:"version"`
+	Repository string `json:"repository,omitempty" yaml:"repository,omitempty"`
+	Sha256     string `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+}
+
+// TransactionOperation is the journal entry of a single Operation
+// planned by Solver.OrderOperations.
+type TransactionOperation struct {
+	Action  string              `json:"action" yaml:"action"`
+	Status  string              `json:"status" yaml:"status"`
+	Package *TransactionPackage `json:"package" yaml:"package"`
+
+	artifact *artifact.PackageArtifact
+}
+
+// TransactionBackup contains the data needed to revert an operation.
+// It's written under the backup directory when the operation starts.
+type TransactionBackup struct {
+	// List of the files touched by the operation.
+	Files []string `json:"files,omitempty" yaml:"files,omitempty"`
+	// List of the files present before the operation
+	// and saved under the backup directory.
+	Displaced []string `json:"displaced,omitempty" yaml:"displaced,omitempty"`
+	// Package removed to register again on rollback.
+	Package *pkg.DefaultPackage `json:"package,omitempty" yaml:"package,omitempty"`
+	// Finalizer of the removed package to restore on rollback.
+	Finalizer *pkg.PackageFinalizer `json:"finalizer,omitempty" yaml:"finalizer,omitempty"`
+}
+
+func NewTransactionPackage(a *artifact.PackageArtifact) *TransactionPackage {
+	p := a.GetPackage()
+	return &TransactionPackage{
+		Category:   p.GetCategory(),
+		Name:       p.GetName(),
+		Version:    p.GetVersion(),
+		Repository: p.Repository,
+		Sha256:     a.Checksums[string(artifact.SHA256)],
+	}
+}
+
+func (p *TransactionPackage) ToPackage() *pkg.DefaultPackage {
+	return &pkg.DefaultPackage{
+		Category:   p.Category,
+		Name:       p.Name,
+		Version:    p.Version,
+		Repository: p.Repository,
+	}
+}
+
+func (p *TransactionPackage) HumanReadableString() string {
+	return fmt.Sprintf("%s/%s-%s", p.Category, p.Name, p.Version)
+}
+
+func (o *TransactionOperation) GetArtifact() *artifact.PackageArtifact { return o.artifact }
+
+// Transaction is the write-ahead journal of an install/upgrade
+// operation stored under the system database path.
+type Transaction struct {
+	Id             string                  `json:"id" yaml:"id"`
+	Command        string                  `json:"command" yaml:"command"`
+	State          string                  `json:"state" yaml:"state"`
+	Rootfs         string                  `json:"rootfs" yaml:"rootfs"`
+	Created        string                  `json:"created" yaml:"created"`
+	Updated        string                  `json:"updated" yaml:"updated"`
+	FinalizersDone bool                    `json:"finalizers_done,omitempty" yaml:"finalizers_done,omitempty"`
+	Operations     []*TransactionOperation `json:"operations" yaml:"operations"`
+	// Install reason (explicit/dep) of the packages to register
+	// mapped by package name.
+	InstallReasons map[string]string `json:"install_reasons,omitempty" yaml:"install_reasons,omitempty"`
+
+	dir string
+}
+
+func NewTransaction(dir, command, rootfs string, ops *[]*solver.Operation) *Transaction {
+	now := time.Now()
+	id := fmt.Sprintf("%s-%s", now.UTC().Format("20060102150405"),
+		fileHelper.RandStringRunes(6))
+
+	ans := &Transaction{
+		Id:         id,
+		Command:    command,
+		State:      TransactionRunning,
+		Rootfs:     rootfs,
+		Created:    fmt.Sprintf("%d", now.Unix()),
+		Updated:    fmt.Sprintf("%d", now.Unix()),
+		Operations: []*TransactionOperation{},
+		dir:        filepath.Join(dir, id),
+	}
+
+	if ops != nil {
+		for _, op := range *ops {
+			ans.Operations = append(ans.Operations, &TransactionOperation{
+				Action:   op.Action,
+				Status:   TxOpPending,
+				Package:  NewTransactionPackage(op.Artifact),
+				artifact: op.Artifact,
+			})
+		}
+	}
+
+	return ans
+}
+
+func NewTransactionFromFile(file string) (*Transaction, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := &Transaction{}
+	if err = yaml.Unmarshal(data, ans); err != nil {
+		return nil, err
+	}
+	ans.dir = filepath.Dir(file)
+
+	return ans, nil
+}
+
+func (t *Transaction) GetDir() string       { return t.dir }
+func (t *Transaction) GetBackupDir() string { return filepath.Join(t.dir, TransactionBackupDir) }
+
+func (t *Transaction) IsPending() bool {
+	return t.State == TransactionRunning || t.State == TransactionInterrupted
+}
+
+func (t *Transaction) GetOperations(status string) []*TransactionOperation {
+	ans := []*TransactionOperation{}
+	for _, op := range t.Operations {
+		if op.Status == status {
+			ans = append(ans, op)
+		}
+	}
+	return ans
+}
+
+func (t *Transaction) Write() error {
+	return t.write(true)
+}
+
+// write updates the journal. Without sync the journal is only renamed
+// atomically: the status of the operations is recovered on resume
+// from the backup data and the markers of the operations completed.
+func (t *Transaction) write(sync bool) error {
+	err := os.MkdirAll(t.dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	t.Updated = fmt.Sprintf("%d", time.Now().Unix())
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(t.dir, TransactionFile), data, sync)
+}
+
+func (t *Transaction) getBackupFile(idx int) string {
+	return filepath.Join(t.GetBackupDir(), fmt.Sprintf("%d.yaml", idx))
+}
+
+// WriteBackup stores the data needed to revert the operation idx.
+func (t *Transaction) WriteBackup(idx int, b *TransactionBackup) error {
+	if err := os.MkdirAll(t.GetBackupDir(), 0755); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(t.getBackupFile(idx), data, true)
+}
+
+func (t *Transaction) getDoneFile(idx int) string {
+	return filepath.Join(t.GetBackupDir(), fmt.Sprintf("%d.done", idx))
+}
+
+// WriteOperationDone stores the marker of the operation idx completed.
+// The journal is updated without sync and the marker is used on resume
+// to recover the status of the operation.
+func (t *Transaction) WriteOperationDone(idx int) error {
+	if err := os.MkdirAll(t.GetBackupDir(), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(t.getDoneFile(idx), []byte{}, true)
+}
+
+// IsOperationDone returns true if the marker of the operation idx
+// completed is present.
+func (t *Transaction) IsOperationDone(idx int) bool {
+	return fileHelper.Exists(t.getDoneFile(idx))
+}
+
+// ReadBackup returns the backup data of the operation idx or nil
+// if the operation is never started.
+func (t *Transaction) ReadBackup(idx int) (*TransactionBackup, error) {
+	file := t.getBackupFile(idx)
+	if !fileHelper.Exists(file) {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := &TransactionBackup{}
+	if err = yaml.Unmarshal(data, ans); err != nil {
+		return nil, errors.Wrap(err, "error on parse file "+file)
+	}
+
+	return ans, nil
+}
+
+// writeFileAtomic writes the data to a temporary file and renames it
+// to avoid a broken file on crash.
+func writeFileAtomic(file string, data []byte, sync bool) error {
+	tmpFile := filepath.Join(filepath.Dir(file), "."+filepath.Base(file))
+	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if sync {
+		if err = f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	f.Close()
+
+	return os.Rename(tmpFile, file)
+}
+
+// Cleanup removes the backup files of the transaction and
+// maintains only the journal.
+func (t *Transaction) Cleanup() error {
+	return os.RemoveAll(t.GetBackupDir())
+}
+
+// BackupFiles stores the files of the target rootfs that will be replaced or
+// removed by the operation. For the remove operation the files are hardlinked
+// when it's possible because the original files are going to be unlinked.
+func (t *Transaction) BackupFiles(idx int, files []string, targetRootfs string, link bool) ([]string, error) {
+	ans := []string{}
+	backupDir := filepath.Join(t.GetBackupDir(), fmt.Sprintf("%d", idx))
+
+	for _, f := range files {
+		source := filepath.Join(targetRootfs, f)
+		fi, err := os.Lstat(source)
+		if err != nil {
+			// POST: the file doesn't exist on target rootfs.
+			continue
+		}
+		if fi.IsDir() {
+			continue
+		}
+
+		target := filepath.Join(backupDir, f)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return ans, err
+		}
+
+		linked := false
+		if link && fi.Mode().IsRegular() {
+			if err := os.Link(source, target); err == nil {
+				linked = true
+			}
+		}
+
+		if !linked {
+			if err := fileHelper.DeepCopyFile(source, target); err != nil {
+				return ans, errors.Wrap(err,
+					fmt.Sprintf("error on backup file %s", source))
+			}
+		}
+
+		ans = append(ans, f)
+	}
+
+	return ans, nil
+}
+
+// RestoreFiles restores the files saved with BackupFiles.
+func (t *Transaction) RestoreFiles(idx int, files []string, targetRootfs string) error {
+	backupDir := filepath.Join(t.GetBackupDir(), fmt.Sprintf("%d", idx))
+
+	for _, f := range files {
+		source := filepath.Join(backupDir, f)
+		target := filepath.Join(targetRootfs, f)
+
+		if !fileHelper.ExistsLink(source) {
+			Warning(fmt.Sprintf("[%s] Backup file %s not found. Skipped.", t.Id, f))
+			continue
+		}
+
+		if fileHelper.ExistsLink(target) {
+			if err := os.Remove(target); err != nil {
+				return errors.Wrap(err,
+					fmt.Sprintf("error on remove file %s", target))
+			}
+		}
+
+		if err := fileHelper.DeepCopyFile(source, target); err != nil {
+			return errors.Wrap(err,
+				fmt.Sprintf("error on restore file %s", target))
+		}
+	}
+
+	return nil
+}
+
+func (m *ArtifactsManager) GetTransactionsDir() string {
+	return filepath.Join(
+		m.Config.GetSystem().GetSystemRepoDatabaseDirPath(), "transactions",
+	)
+}
+
+func (m *ArtifactsManager) NewTransaction(command, targetRootfs string,
+	ops *[]*solver.Operation, reasons map[string]string) (*Transaction, error) {
+
+	t := NewTransaction(m.GetTransactionsDir(), command, targetRootfs, ops)
+
+	// Store only the install reasons of the packages of the operations.
+	if len(reasons) > 0 {
+		t.InstallReasons = make(map[string]string, 0)
+		for _, top := range t.Operations {
+			name := top.Package.ToPackage().PackageName()
+			if reason, ok := reasons[name]; ok {
+				t.InstallReasons[name] = reason
+			}
+		}
+	}
+	if err := t.Write(); err != nil {
+		return nil, errors.Wrap(err, "error on write transaction journal")
+	}
+
+	Debug(fmt.Sprintf("Created transaction %s with %d operations.",
+		t.Id, len(t.Operations)))
+
+	return t, nil
+}
+
+func (m *ArtifactsManager) GetTransaction(id string) (*Transaction, error) {
+	file := filepath.Join(m.GetTransactionsDir(), id, TransactionFile)
+	if !fileHelper.Exists(file) {
+		return nil, fmt.Errorf("transaction %s not found", id)
+	}
+
+	return NewTransactionFromFile(file)
+}
+
+// GetTransactions returns the list of the transactions
+// sorted by creation time.
+func (m *ArtifactsManager) GetTransactions() ([]*Transaction, error) {
+	ans := []*Transaction{}
+	dir := m.GetTransactionsDir()
+
+	if !fileHelper.Exists(dir) {
+		return ans, nil
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ans, err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		file := filepath.Join(dir, e.Name(), TransactionFile)
+		if !fileHelper.Exists(file) {
+			continue
+		}
+
+		t, err := NewTransactionFromFile(file)
+		if err != nil {
+			Warning(fmt.Sprintf("Error on read transaction %s: %s",
+				e.Name(), err.Error()))
+			continue
+		}
+		ans = append(ans, t)
+	}
+
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].Id < ans[j].Id
+	})
+
+	return ans, nil
+}
+
+// GetPendingTransactions returns the transactions interrupted
+// or not completed.
+func (m *ArtifactsManager) GetPendingTransactions() ([]*Transaction, error) {
+	ans := []*Transaction{}
+	list, err := m.GetTransactions()
+	if err != nil {
+		return ans, err
+	}
+
+	for _, t := range list {
+		if t.IsPending() {
+			ans = append(ans, t)
+		}
+	}
+
+	return ans, nil
+}
+
+// beginOperation stores the backup of the files that will be touched
+// by the operation and marks the operation as running on the journal.
+func (m *ArtifactsManager) beginOperation(t *Transaction, idx int, targetRootfs string) error {
+	if t == nil {
+		return nil
+	}
+
+	var err error
+	top := t.Operations[idx]
+	b := &TransactionBackup{}
+
+	switch top.Action {
+	case solver.RemovePackage:
+		p := top.Package.ToPackage()
+		b.Files, err = m.Database.GetPackageFiles(p)
+		if err != nil {
+			return errors.Wrap(err,
+				fmt.Sprintf("error on retrieve files of the package %s",
+					p.HumanReadableString()))
+		}
+
+		if dbp, err := m.Database.FindPackage(p); err == nil {
+			b.Package = dbp.(*pkg.DefaultPackage)
+		}
+
+		pf, _ := m.Database.GetPackageFinalizer(p)
+		if pf != nil {
+			b.Finalizer = pf
+		}
+
+		b.Displaced, err = t.BackupFiles(idx, b.Files, targetRootfs, true)
+		if err != nil {
+			return err
+		}
+
+	default:
+		if top.artifact == nil {
+			return fmt.Errorf("artifact of %s not available",
+				top.Package.HumanReadableString())
+		}
+		b.Files = top.artifact.Files
+		b.Displaced, err = t.BackupFiles(idx, b.Files, targetRootfs, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The backup data is the durable marker of the operation started.
+	if err = t.WriteBackup(idx, b); err != nil {
+		return err
+	}
+
+	top.Status = TxOpRunning
+
+	return t.write(false)
+}
+
+// completeOperation writes the durable marker of the operation
+// completed and marks the operation as done on the journal.
+func (m *ArtifactsManager) completeOperation(t *Transaction, idx int) error {
+	if t == nil {
+		return nil
+	}
+	if err := t.WriteOperationDone(idx); err != nil {
+		return err
+	}
+	t.Operations[idx].Status = TxOpDone
+	return t.write(false)
+}
+
+// closeTransaction updates the state of the transaction at the
+// end of the execution.
+func (m *ArtifactsManager) closeTransaction(t *Transaction, fail bool) {
+	if t == nil {
+		return
+	}
+
+	if fail {
+		t.State = TransactionInterrupted
+	} else {
+		t.State = TransactionCompleted
+	}
+
+	if err := t.Write(); err != nil {
+		Warning(fmt.Sprintf("Error on update transaction %s: %s",
+			t.Id, err.Error()))
+		return
+	}
+
+	if !fail {
+		if err := t.Cleanup(); err != nil {
+			Warning(fmt.Sprintf("Error on cleanup transaction %s: %s",
+				t.Id, err.Error()))
+		}
+	} else {
+		Warning(fmt.Sprintf(
+			"Transaction %s interrupted. Use luet transaction rollback|resume %s.",
+			t.Id, t.Id))
+	}
+}
+
+// RollbackTransaction reverts the operations executed, or partially
+// executed, by an interrupted transaction in the reverse order.
+func (m *ArtifactsManager) RollbackTransaction(t *Transaction, force bool) error {
+	m.Setup()
+
+	if !t.IsPending() {
+		return fmt.Errorf("transaction %s is in state %s", t.Id, t.State)
+	}
+
+	targetRootfs := t.Rootfs
+	nOps := len(t.Operations)
+
+	for idx := nOps - 1; idx >= 0; idx-- {
+		top := t.Operations[idx]
+		if top.Status == TxOpReverted || top.Package == nil {
+			continue
+		}
+
+		b, err := t.ReadBackup(idx)
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			// POST: operation never started.
+			continue
+		}
+
+		Info(fmt.Sprintf(":back: [%3d of %3d] Reverting %s %s",
+			idx+1, nOps, top.Action, top.Package.HumanReadableString()))
+
+		err = m.revertOperation(t, idx, b, targetRootfs)
+		if err != nil {
+			if !force {
+				t.Write()
+				return err
+			}
+			Warning(fmt.Sprintf("[%s] %s", top.Package.HumanReadableString(),
+				err.Error()))
+		}
+
+		top.Status = TxOpReverted
+		if err = t.write(false); err != nil {
+			return err
+		}
+	}
+
+	t.State = TransactionRolledBack
+	if err := t.Write(); err != nil {
+		return err
+	}
+
+	return t.Cleanup()
+}
+
+func (m *ArtifactsManager) revertOperation(t *Transaction, idx int,
+	b *TransactionBackup, targetRootfs string) error {
+	top := t.Operations[idx]
+	p := top.Package.ToPackage()
+
+	switch top.Action {
+	case solver.RemovePackage:
+		// Restore the files removed and register
+		// again the package.
+		if err := t.RestoreFiles(idx, b.Displaced, targetRootfs); err != nil {
+			return err
+		}
+
+		if b.Package != nil {
+			p = b.Package
+		}
+
+		if _, err := m.Database.FindPackage(p); err != nil {
+			if _, err := m.Database.CreatePackage(p); err != nil {
+				return errors.Wrap(err, "error on register package")
+			}
+		}
+
+		err := m.Database.SetPackageFiles(&pkg.PackageFile{
+			PackageFingerprint: p.GetFingerPrint(),
+			Files:              b.Files,
+		})
+		if err != nil {
+			return errors.Wrap(err, "error on register package files")
+		}
+
+		if b.Finalizer != nil {
+			b.Finalizer.ID = 0
+			err = m.Database.SetPackageFinalizer(b.Finalizer)
+			if err != nil {
+				return errors.Wrap(err, "error on register package finalizer")
+			}
+		}
+
+	default:
+		// Remove the files installed that weren't present before and
+		// restore the displaced files.
+		displaced := make(map[string]bool, len(b.Displaced))
+		for _, f := range b.Displaced {
+			displaced[f] = true
+		}
+
+		files := []string{}
+		for _, f := range b.Files {
+			if _, ok := displaced[f]; !ok {
+				files = append(files, f)
+			}
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(files)))
+
+		for _, f := range files {
+			target := filepath.Join(targetRootfs, f)
+			fi, err := os.Lstat(target)
+			if err != nil {
+				continue
+			}
+			if fi.IsDir() {
+				if empty, _ := fileHelper.DirectoryIsEmpty(target); !empty {
+					continue
+				}
+			}
+			if err := os.Remove(target); err != nil {
+				Debug("Failed removing file", target, err.Error())
+			}
+		}
+
+		if err := t.RestoreFiles(idx, b.Displaced, targetRootfs); err != nil {
+			return err
+		}
+
+		// Drop the package from the database if registered.
+		if _, err := m.Database.FindPackage(p); err == nil {
+			m.Database.RemovePackageFiles(p)
+			m.Database.RemovePackageFilesMeta(p)
+			m.Database.RemovePackageFinalizer(p)
+			if err := m.Database.RemovePackage(p); err != nil {
+				return errors.Wrap(err, "error on remove package from database")
+			}
+		}
+	}
+
+	return nil
+}
+
+// ResumeTransaction executes the operations not completed
+// of an interrupted transaction.
+func (m *ArtifactsManager) ResumeTransaction(t *Transaction, opts *InstallOpts) error {
+	m.Setup()
+
+	if !t.IsPending() {
+		return fmt.Errorf("transaction %s is in state %s", t.Id, t.State)
+	}
+
+	mapRepos, err := m.getTransactionRepos(t)
+	if err != nil {
+		return err
+	}
+
+	ops := []*solver.Operation{}
+	for idx, top := range t.Operations {
+		if top.Package == nil {
+			return fmt.Errorf("transaction %s with invalid operation %d", t.Id, idx)
+		}
+
+		b, err := t.ReadBackup(idx)
+		if err != nil {
+			return err
+		}
+
+		// The journal is updated without sync: an operation
+		// started could be already completed.
+		if top.Status != TxOpDone && b != nil {
+			if err := m.recoverOperation(t, idx); err != nil {
+				return err
+			}
+		}
+
+		if top.Action == solver.RemovePackage {
+			top.artifact = artifact.NewPackageArtifact("")
+			top.artifact.Runtime = top.Package.ToPackage()
+			if b != nil && b.Package != nil {
+				top.artifact.Runtime = b.Package
+			} else if dbp, err := m.Database.FindPackage(top.artifact.Runtime); err == nil {
+				top.artifact.Runtime = dbp.(*pkg.DefaultPackage)
+			}
+		} else {
+			top.artifact, err = m.getTransactionArtifact(top, mapRepos)
+			if err != nil {
+				return err
+			}
+
+			// Check that the artifact is available in cache.
+			top.artifact.ResolveCachePath()
+			if top.Status != TxOpDone && !fileHelper.Exists(top.artifact.CachePath) {
+				return fmt.Errorf(
+					"artifact %s not available in cache. Use rollback instead",
+					top.artifact.CachePath)
+			}
+		}
+
+		ops = append(ops, &solver.Operation{
+			Action:   top.Action,
+			Artifact: top.artifact,
+		})
+	}
+
+	t.State = TransactionRunning
+	if err := t.Write(); err != nil {
+		return err
+	}
+
+	return m.executeOperations(t, &ops, mapRepos, opts, t.Rootfs,
+		t.Command == TransactionUpgrade)
+}
+
+// recoverOperation updates the status of an operation started by an
+// interrupted execution. An operation is completed only with the marker
+// written by completeOperation. The registration of a package not
+// completed is dropped to install the package again.
+func (m *ArtifactsManager) recoverOperation(t *Transaction, idx int) error {
+	top := t.Operations[idx]
+	if t.IsOperationDone(idx) {
+		top.Status = TxOpDone
+		return nil
+	}
+
+	p := top.Package.ToPackage()
+	_, err := m.Database.FindPackage(p)
+
+	if top.Action == solver.RemovePackage {
+		// The package is dropped from the database as last
+		// step of the remove.
+		if err != nil {
+			top.Status = TxOpDone
+			return t.WriteOperationDone(idx)
+		}
+		return nil
+	}
+
+	if err == nil {
+		m.Database.RemovePackageFiles(p)
+		m.Database.RemovePackageFilesMeta(p)
+		m.Database.RemovePackageFinalizer(p)
+		if err := m.Database.RemovePackage(p); err != nil {
+			return errors.Wrap(err, "error on remove the registration of "+
+				p.HumanReadableString())
+		}
+	}
+
+	return nil
+}
+
+// getTransactionArtifact returns the artifact of the operation from the
+// catalog of the repository. The artifact must be the same artifact
+// referenced by the journal.
+func (m *ArtifactsManager) getTransactionArtifact(top *TransactionOperation,
+	mapRepos map[string]*wagon.WagonRepository) (*artifact.PackageArtifact, error) {
+
+	r, ok := mapRepos[top.Package.Repository]
+	if !ok {
+		return nil, fmt.Errorf("repository of the package %s not available",
+			top.Package.HumanReadableString())
+	}
+
+	if r.Stones.Catalog == nil {
+		if _, err := r.Stones.LoadCatalog(r.Identity); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, a := range r.Stones.Catalog.Index {
+		p := a.GetPackage()
+		if p == nil || p.GetCategory() != top.Package.Category ||
+			p.GetName() != top.Package.Name || p.GetVersion() != top.Package.Version {
+			continue
+		}
+
+		if a.Checksums[string(artifact.SHA256)] != top.Package.Sha256 {
+			return nil, fmt.Errorf(
+				"artifact of %s changed on repository %s. Use rollback instead",
+				top.Package.HumanReadableString(), top.Package.Repository)
+		}
+
+		if p.Repository == "" {
+			p.Repository = top.Package.Repository
+		}
+
+		return a, nil
+	}
+
+	return nil, fmt.Errorf(
+		"package %s not available on repository %s. Use rollback instead",
+		top.Package.HumanReadableString(), top.Package.Repository)
+}
+
+func (m *ArtifactsManager) getTransactionRepos(t *Transaction) (map[string]*wagon.WagonRepository, error) {
+	mapRepos := make(map[string]*wagon.WagonRepository, 0)
+
+	for _, top := range t.Operations {
+		if top.Action == solver.RemovePackage || top.Package == nil {
+			continue
+		}
+
+		repoName := top.Package.Repository
+		if _, ok := mapRepos[repoName]; ok || repoName == "" {
+			continue
+		}
+
+		repo, err := m.Config.GetSystemRepository(repoName)
+		if err != nil {
+			return nil, err
+		}
+
+		wr := wagon.NewWagonRepository(repo)
+		err = wr.ReadWagonIdentify(
+			m.Config.GetSystem().GetRepoDatabaseDirPath(repoName))
+		if err != nil {
+			return nil, errors.Wrap(err, "Error on read repository identity file")
+		}
+		mapRepos[repoName] = wr
+	}
+
+	return mapRepos, nil
+}
+
+// checkPendingTransactions avoids to execute new operations
+// when the rootfs contains an interrupted transaction.
+func (m *ArtifactsManager) checkPendingTransactions(force bool) error {
+	pending, err := m.GetPendingTransactions()
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		msg := fmt.Sprintf(
+			"Found interrupted transaction %s. Use luet transaction rollback|resume %s.",
+			pending[0].Id, pending[0].Id)
+		if !force {
+			return errors.New(msg)
+		}
+		Warning(msg)
+	}
+
+	return nil
+}
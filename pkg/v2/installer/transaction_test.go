@@ -0,0 +1,269 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer_test
+
+import (
+	"os"
+	"path/filepath"
+
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	pkg "github.com/geaaru/luet/pkg/package"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	. "github.com/geaaru/luet/pkg/v2/installer"
+	solver "github.com/geaaru/luet/pkg/v2/solver"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transaction", func() {
+	var tmpdir, rootfs string
+	var m *ArtifactsManager
+	var foo *artifact.PackageArtifact
+	opts := &InstallOpts{SkipFinalizers: true, SkipHooks: true}
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "transaction")
+		Expect(err).ToNot(HaveOccurred())
+		m = setupTestSystem(tmpdir)
+		rootfs = filepath.Join(tmpdir, "rootfs")
+
+		foo = newTestArtifact(tmpdir, "test", "foo", "1.0", map[string]string{
+			"usr/bin/foo": "foo",
+		})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	readFile := func(f string) string {
+		data, err := os.ReadFile(filepath.Join(rootfs, f))
+		Expect(err).ToNot(HaveOccurred())
+		return string(data)
+	}
+
+	Context("Journal", func() {
+
+		It("Stores only the references of the packages", func() {
+			t, err := m.NewTransaction(TransactionInstall, rootfs,
+				&[]*solver.Operation{solver.NewOperation(solver.AddPackage, foo)},
+				map[string]string{
+					"test/foo": pkg.InstallReasonExplicit,
+					"test/bar": pkg.InstallReasonExplicit,
+				})
+			Expect(err).ToNot(HaveOccurred())
+
+			data, err := os.ReadFile(filepath.Join(t.GetDir(), TransactionFile))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).ToNot(ContainSubstring("usr/bin/foo"))
+
+			t2, err := m.GetTransaction(t.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t2.State).To(Equal(TransactionRunning))
+			Expect(t2.Operations).To(HaveLen(1))
+			Expect(*t2.Operations[0].Package).To(Equal(TransactionPackage{
+				Category:   "test",
+				Name:       "foo",
+				Version:    "1.0",
+				Repository: testRepo,
+				Sha256:     foo.Checksums[string(artifact.SHA256)],
+			}))
+			Expect(t2.InstallReasons).To(Equal(map[string]string{
+				"test/foo": pkg.InstallReasonExplicit,
+			}))
+
+			pending, err := m.GetPendingTransactions()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pending).To(HaveLen(1))
+		})
+	})
+
+	Context("Resume", func() {
+
+		It("Executes the pending operations", func() {
+			writeTestRepository(foo)
+			t, err := m.NewTransaction(TransactionInstall, rootfs,
+				&[]*solver.Operation{solver.NewOperation(solver.AddPackage, foo)},
+				map[string]string{"test/foo": pkg.InstallReasonExplicit})
+			Expect(err).ToNot(HaveOccurred())
+
+			t, err = m.GetTransaction(t.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.ResumeTransaction(t, opts)).ToNot(HaveOccurred())
+
+			Expect(readFile("usr/bin/foo")).To(Equal("foo"))
+			p, err := m.Database.FindPackage(foo.GetPackage())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.(*pkg.DefaultPackage).IsExplicit()).To(BeTrue())
+
+			t, err = m.GetTransaction(t.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.State).To(Equal(TransactionCompleted))
+			Expect(t.GetOperations(TxOpDone)).To(HaveLen(1))
+			Expect(fileHelper.Exists(t.GetBackupDir())).To(BeFalse())
+		})
+
+		It("Skips the operations already applied", func() {
+			writeTestRepository(foo)
+			t, err := m.NewTransaction(TransactionInstall, rootfs,
+				&[]*solver.Operation{solver.NewOperation(solver.AddPackage, foo)},
+				nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			// Simulate a crash after the completion of the operation
+			// but before the update of the journal.
+			Expect(t.WriteBackup(0, &TransactionBackup{Files: foo.Files})).ToNot(HaveOccurred())
+			foo.ResolveCachePath()
+			Expect(m.InstallPackage(foo, nil, rootfs)).ToNot(HaveOccurred())
+			Expect(m.RegisterPackage(foo, nil, false)).ToNot(HaveOccurred())
+			Expect(t.WriteOperationDone(0)).ToNot(HaveOccurred())
+			Expect(os.Remove(foo.CachePath)).ToNot(HaveOccurred())
+
+			t, err = m.GetTransaction(t.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.Operations[0].Status).To(Equal(TxOpPending))
+			Expect(m.ResumeTransaction(t, opts)).ToNot(HaveOccurred())
+			Expect(t.Operations[0].Status).To(Equal(TxOpDone))
+			Expect(t.State).To(Equal(TransactionCompleted))
+		})
+
+		It("Executes again the operations registered but not completed", func() {
+			writeTestRepository(foo)
+			t, err := m.NewTransaction(TransactionInstall, rootfs,
+				&[]*solver.Operation{solver.NewOperation(solver.AddPackage, foo)},
+				nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			// Simulate a crash after the registration of a package
+			// with the files not extracted.
+			Expect(t.WriteBackup(0, &TransactionBackup{Files: foo.Files})).ToNot(HaveOccurred())
+			Expect(m.RegisterPackage(foo, nil, false)).ToNot(HaveOccurred())
+
+			t, err = m.GetTransaction(t.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.ResumeTransaction(t, opts)).ToNot(HaveOccurred())
+			Expect(t.State).To(Equal(TransactionCompleted))
+			Expect(readFile("usr/bin/foo")).To(Equal("foo"))
+
+			pkgs, err := m.Database.FindPackages(foo.GetPackage())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pkgs).To(HaveLen(1))
+		})
+
+		It("Doesn't register the packages not installed", func() {
+			bar := newTestArtifact(tmpdir, "test", "bar", "1.0", nil)
+			writeTestRepository(bar)
+
+			t, err := m.NewTransaction(TransactionInstall, rootfs,
+				&[]*solver.Operation{solver.NewOperation(solver.AddPackage, bar)},
+				nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			t, err = m.GetTransaction(t.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.ResumeTransaction(t, opts)).To(HaveOccurred())
+
+			t, err = m.GetTransaction(t.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.State).To(Equal(TransactionInterrupted))
+			Expect(t.GetOperations(TxOpDone)).To(BeEmpty())
+			Expect(t.IsOperationDone(0)).To(BeFalse())
+			_, err = m.Database.FindPackage(bar.GetPackage())
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Refuses an artifact changed on repository", func() {
+			foo2 := newTestArtifact(tmpdir, "test", "foo", "1.0", map[string]string{
+				"usr/bin/foo": "foo2",
+			})
+			writeTestRepository(foo2)
+
+			t, err := m.NewTransaction(TransactionInstall, rootfs,
+				&[]*solver.Operation{solver.NewOperation(solver.AddPackage, foo)},
+				nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			t, err = m.GetTransaction(t.Id)
+			Expect(err).ToNot(HaveOccurred())
+			err = m.ResumeTransaction(t, opts)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Use rollback"))
+			Expect(fileHelper.Exists(filepath.Join(rootfs, "usr/bin/foo"))).To(BeFalse())
+		})
+	})
+
+	Context("Rollback", func() {
+
+		It("Reverts the operations of an interrupted transaction", func() {
+			// Installed package to remove and file replaced by foo.
+			old := &pkg.DefaultPackage{
+				Category: "test", Name: "old", Version: "1.0", Repository: testRepo,
+			}
+			old.SetExplicit(true)
+			_, err := m.Database.CreatePackage(old)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.Database.SetPackageFiles(&pkg.PackageFile{
+				PackageFingerprint: old.GetFingerPrint(),
+				Files:              []string{"usr/share/old"},
+			})).ToNot(HaveOccurred())
+			Expect(os.MkdirAll(filepath.Join(rootfs, "usr", "share"), 0755)).ToNot(HaveOccurred())
+			Expect(os.MkdirAll(filepath.Join(rootfs, "usr", "bin"), 0755)).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(rootfs, "usr/share/old"), []byte("old"), 0644)).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(rootfs, "usr/bin/foo"), []byte("previous"), 0644)).ToNot(HaveOccurred())
+
+			oldArt := artifact.NewPackageArtifact("")
+			oldArt.Runtime = old.Clone().(*pkg.DefaultPackage)
+
+			// The corrupted artifact interrupts the transaction.
+			bar := newTestArtifact(tmpdir, "test", "bar", "1.0", nil)
+			writeTestRepository(foo, bar)
+
+			t, err := m.NewTransaction(TransactionUpgrade, rootfs,
+				&[]*solver.Operation{
+					solver.NewOperation(solver.RemovePackage, oldArt),
+					solver.NewOperation(solver.AddPackage, foo),
+					solver.NewOperation(solver.AddPackage, bar),
+				}, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			t, err = m.GetTransaction(t.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.ResumeTransaction(t, opts)).To(HaveOccurred())
+
+			t, err = m.GetTransaction(t.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.State).To(Equal(TransactionInterrupted))
+			Expect(t.GetOperations(TxOpDone)).To(HaveLen(2))
+			Expect(readFile("usr/bin/foo")).To(Equal("foo"))
+			Expect(fileHelper.Exists(filepath.Join(rootfs, "usr/share/old"))).To(BeFalse())
+
+			Expect(m.RollbackTransaction(t, false)).ToNot(HaveOccurred())
+			Expect(t.State).To(Equal(TransactionRolledBack))
+			Expect(t.GetOperations(TxOpReverted)).To(HaveLen(3))
+			Expect(fileHelper.Exists(t.GetBackupDir())).To(BeFalse())
+
+			Expect(readFile("usr/bin/foo")).To(Equal("previous"))
+			Expect(readFile("usr/share/old")).To(Equal("old"))
+
+			p, err := m.Database.FindPackage(old)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.(*pkg.DefaultPackage).IsExplicit()).To(BeTrue())
+			files, err := m.Database.GetPackageFiles(old)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(Equal([]string{"usr/share/old"}))
+
+			_, err = m.Database.FindPackage(foo.GetPackage())
+			Expect(err).To(HaveOccurred())
+			_, err = m.Database.FindPackage(bar.GetPackage())
+			Expect(err).To(HaveOccurred())
+
+			pending, err := m.GetPendingTransactions()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pending).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,147 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer
+
+import (
+	"fmt"
+
+	cfg "github.com/geaaru/luet/pkg/config"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/v2/integrity"
+
+	"github.com/pkg/errors"
+)
+
+type VerifyOpts struct {
+	// Verify also the config protected files.
+	IncludeConfig bool
+}
+
+type PackageVerifyReport struct {
+	Package string                 `json:"package" yaml:"package"`
+	Files   []*integrity.FileIssue `json:"files" yaml:"files"`
+	// NoMetadata is true for the packages installed without
+	// the integrity metadata.
+	NoMetadata bool `json:"no_metadata,omitempty" yaml:"no_metadata,omitempty"`
+}
+
+// RegisterPackageFilesMeta stores the integrity metadata of the files
+// of the package computed from the archive on unpack. The metadata
+// already stored of the files not extracted are maintained.
+func (m *ArtifactsManager) RegisterPackageFilesMeta(p pkg.Package,
+	files []string, meta []*pkg.FileMeta) error {
+
+	selected := make(map[string]bool, len(files))
+	for _, f := range files {
+		selected[f] = true
+	}
+
+	filesMeta := make(map[string]*pkg.FileMeta, len(files))
+	stored, err := m.Database.GetPackageFilesMeta(p)
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		for _, f := range stored.Files {
+			filesMeta[f.Path] = f
+		}
+	}
+	for _, f := range meta {
+		filesMeta[f.Path] = f
+	}
+
+	ans := []*pkg.FileMeta{}
+	for _, f := range files {
+		if fm, ok := filesMeta[f]; ok && selected[f] {
+			ans = append(ans, fm)
+			delete(filesMeta, f)
+		}
+	}
+
+	err = m.Database.RemovePackageFilesMeta(p)
+	if err != nil {
+		return err
+	}
+
+	return m.Database.SetPackageFilesMeta(&pkg.PackageFilesMeta{
+		PackageFingerprint: p.GetFingerPrint(),
+		Files:              ans,
+	})
+}
+
+// VerifyPackages compares the files of the installed packages in
+// input or of all installed packages with the integrity metadata
+// stored on install. Only the packages with issues are returned.
+func (m *ArtifactsManager) VerifyPackages(opts *VerifyOpts, targetRootfs string,
+	packs ...*pkg.DefaultPackage) ([]*PackageVerifyReport, error) {
+
+	m.Setup()
+
+	ans := []*PackageVerifyReport{}
+	installed := pkg.Packages{}
+
+	if len(packs) == 0 {
+		installed = m.Database.World()
+	} else {
+		for _, p := range packs {
+			pkgs, err := m.Database.FindPackages(p)
+			if err != nil || len(pkgs) == 0 {
+				return nil, fmt.Errorf("package %s is not installed",
+					p.HumanReadableString())
+			}
+			installed = append(installed, pkgs...)
+		}
+	}
+
+	for _, p := range installed {
+		report := &PackageVerifyReport{
+			Package: p.HumanReadableString(),
+			Files:   []*integrity.FileIssue{},
+		}
+
+		meta, err := m.Database.GetPackageFilesMeta(p)
+		if err != nil {
+			return nil, err
+		}
+		if meta == nil {
+			report.NoMetadata = true
+			ans = append(ans, report)
+			continue
+		}
+
+		annotationDir := ""
+		if dir, ok := p.GetAnnotationByKey(string(pkg.ConfigProtectAnnnotation)).(string); ok {
+			annotationDir = dir
+		}
+		cp := cfg.NewConfigProtect(annotationDir)
+		files := []string{}
+		for _, f := range meta.Files {
+			files = append(files, f.Path)
+		}
+		cp.Map(files)
+
+		for _, f := range meta.Files {
+			protected := cp.Protected(f.Path)
+			if protected && !opts.IncludeConfig {
+				continue
+			}
+
+			issue, err := integrity.VerifyFile(targetRootfs, f)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Error on verify file %s", f.Path)
+			}
+			if issue != nil {
+				issue.Config = protected
+				report.Files = append(report.Files, issue)
+			}
+		}
+
+		if len(report.Files) > 0 {
+			ans = append(ans, report)
+		}
+	}
+
+	return ans, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package installer_test
+
+import (
+	"os"
+	"path/filepath"
+
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	. "github.com/geaaru/luet/pkg/v2/installer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verify", func() {
+	var tmpdir, rootfs string
+	var m *ArtifactsManager
+	var foo *artifact.PackageArtifact
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "verify")
+		Expect(err).ToNot(HaveOccurred())
+		m = setupTestSystem(tmpdir)
+		rootfs = filepath.Join(tmpdir, "rootfs")
+
+		foo = newTestArtifact(tmpdir, "test", "foo", "1.0", map[string]string{
+			"usr/bin/foo": "foo",
+			"usr/bin/bar": "bar",
+		})
+		foo.ResolveCachePath()
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Stores the metadata of the files of the archive", func() {
+		Expect(m.InstallPackage(foo, nil, rootfs)).ToNot(HaveOccurred())
+		Expect(foo.FilesMeta).To(HaveLen(2))
+
+		// A file changed after the unpack isn't trusted.
+		Expect(os.WriteFile(filepath.Join(rootfs, "usr/bin/foo"),
+			[]byte("tampered"), 0644)).ToNot(HaveOccurred())
+
+		Expect(m.RegisterPackage(foo, nil, false)).ToNot(HaveOccurred())
+		Expect(m.RegisterPackageFilesMeta(foo.GetPackage(), foo.Files,
+			foo.FilesMeta)).ToNot(HaveOccurred())
+
+		meta, err := m.Database.GetPackageFilesMeta(foo.GetPackage())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(meta.Files).To(HaveLen(2))
+
+		reports, err := m.VerifyPackages(&VerifyOpts{}, rootfs, foo.GetPackage())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reports).To(HaveLen(1))
+		Expect(reports[0].Files).To(HaveLen(1))
+		Expect(reports[0].Files[0].Path).To(Equal("usr/bin/foo"))
+		Expect(reports[0].Files[0].Flags()).To(Equal("S.5..."))
+	})
+
+	It("Maintains the metadata of the files not extracted", func() {
+		Expect(m.InstallPackage(foo, nil, rootfs)).ToNot(HaveOccurred())
+		Expect(m.RegisterPackage(foo, nil, false)).ToNot(HaveOccurred())
+		Expect(m.RegisterPackageFilesMeta(foo.GetPackage(), foo.Files,
+			foo.FilesMeta)).ToNot(HaveOccurred())
+
+		// Only usr/bin/bar is extracted.
+		Expect(foo.UnpackFiles(rootfs, []string{"usr/bin/bar"})).ToNot(HaveOccurred())
+		Expect(foo.FilesMeta).To(HaveLen(1))
+		Expect(m.RegisterPackageFilesMeta(foo.GetPackage(), foo.Files,
+			foo.FilesMeta)).ToNot(HaveOccurred())
+
+		meta, err := m.Database.GetPackageFilesMeta(foo.GetPackage())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(meta.Files).To(HaveLen(2))
+
+		reports, err := m.VerifyPackages(&VerifyOpts{}, rootfs, foo.GetPackage())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reports).To(BeEmpty())
+	})
+})
@@ -0,0 +1,157 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package integrity
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	pkg "github.com/geaaru/luet/pkg/package"
+)
+
+// FileIssue describes the differences between an installed file
+// and the metadata stored on install.
+type FileIssue struct {
+	Path    string `json:"path" yaml:"path"`
+	Missing bool   `json:"missing,omitempty" yaml:"missing,omitempty"`
+	Size    bool   `json:"size,omitempty" yaml:"size,omitempty"`
+	Mode    bool   `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Digest  bool   `json:"digest,omitempty" yaml:"digest,omitempty"`
+	Link    bool   `json:"link,omitempty" yaml:"link,omitempty"`
+	User    bool   `json:"user,omitempty" yaml:"user,omitempty"`
+	Group   bool   `json:"group,omitempty" yaml:"group,omitempty"`
+	// Config is true for the config protected files.
+	Config bool `json:"config,omitempty" yaml:"config,omitempty"`
+}
+
+// FileSha256 returns the sha256 of the content of the file.
+func FileSha256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// NewFileMeta returns the metadata of the file of the rootfs.
+// The file is relative to the rootfs.
+func NewFileMeta(rootfs, file string) (*pkg.FileMeta, error) {
+	path := filepath.Join(rootfs, file)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := &pkg.FileMeta{
+		Path: file,
+		Mode: info.Mode(),
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		ans.Uid = int(stat.Uid)
+		ans.Gid = int(stat.Gid)
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		ans.Link, err = os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+	case info.Mode().IsRegular():
+		ans.Size = info.Size()
+		ans.Sha256, err = FileSha256(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ans, nil
+}
+
+// ComputeFilesMeta returns the metadata of the files of the rootfs.
+// The files not present (for example excluded by subsets) are
+// ignored.
+func ComputeFilesMeta(rootfs string, files []string) ([]*pkg.FileMeta, error) {
+	ans := []*pkg.FileMeta{}
+	for _, f := range files {
+		meta, err := NewFileMeta(rootfs, f)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		ans = append(ans, meta)
+	}
+	return ans, nil
+}
+
+// VerifyFile compares the file of the rootfs with the metadata
+// in input. It returns nil if the file is not changed.
+func VerifyFile(rootfs string, meta *pkg.FileMeta) (*FileIssue, error) {
+	issue := &FileIssue{Path: meta.Path}
+
+	current, err := NewFileMeta(rootfs, meta.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			issue.Missing = true
+			return issue, nil
+		}
+		return nil, err
+	}
+
+	issue.Mode = current.Mode != meta.Mode
+	issue.User = current.Uid != meta.Uid
+	issue.Group = current.Gid != meta.Gid
+	issue.Link = current.Link != meta.Link
+	if meta.Mode.IsRegular() {
+		issue.Size = current.Size != meta.Size
+		issue.Digest = current.Sha256 != meta.Sha256
+	}
+
+	if issue.IsEmpty() {
+		return nil, nil
+	}
+	return issue, nil
+}
+
+func (i *FileIssue) IsEmpty() bool {
+	return !i.Missing && !i.Size && !i.Mode && !i.Digest &&
+		!i.Link && !i.User && !i.Group
+}
+
+// Flags returns the issues in the rpm -V format: S size,
+// M mode, 5 digest, L link, U user, G group or missing.
+func (i *FileIssue) Flags() string {
+	if i.Missing {
+		return "missing"
+	}
+
+	flag := func(v bool, c byte) byte {
+		if v {
+			return c
+		}
+		return '.'
+	}
+
+	return string([]byte{
+		flag(i.Size, 'S'),
+		flag(i.Mode, 'M'),
+		flag(i.Digest, '5'),
+		flag(i.Link, 'L'),
+		flag(i.User, 'U'),
+		flag(i.Group, 'G'),
+	})
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package integrity_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/geaaru/luet/pkg/v2/integrity"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Integrity", func() {
+	Context("Verify the files of a rootfs", func() {
+
+		var rootfs string
+
+		BeforeEach(func() {
+			var err error
+			rootfs, err = os.MkdirTemp("", "integrity")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(os.MkdirAll(filepath.Join(rootfs, "usr/bin"), 0755)).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(rootfs, "usr/bin/foo"),
+				[]byte("foo"), 0755)).ToNot(HaveOccurred())
+			Expect(os.Symlink("foo", filepath.Join(rootfs, "usr/bin/bar"))).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(rootfs)
+		})
+
+		It("Computes the files metadata", func() {
+			meta, err := ComputeFilesMeta(rootfs,
+				[]string{"usr/bin/foo", "usr/bin/bar", "usr/bin/missing"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(meta).To(HaveLen(2))
+
+			Expect(meta[0].Path).To(Equal("usr/bin/foo"))
+			Expect(meta[0].Size).To(Equal(int64(3)))
+			Expect(meta[0].Sha256).To(Equal(
+				"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"))
+			Expect(meta[0].Mode.Perm()).To(Equal(os.FileMode(0755)))
+
+			Expect(meta[1].Path).To(Equal("usr/bin/bar"))
+			Expect(meta[1].Link).To(Equal("foo"))
+			Expect(meta[1].Sha256).To(BeEmpty())
+		})
+
+		It("Detects the changed files", func() {
+			meta, err := ComputeFilesMeta(rootfs, []string{"usr/bin/foo", "usr/bin/bar"})
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, m := range meta {
+				issue, err := VerifyFile(rootfs, m)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(issue).To(BeNil())
+			}
+
+			Expect(os.WriteFile(filepath.Join(rootfs, "usr/bin/foo"),
+				[]byte("bar"), 0755)).ToNot(HaveOccurred())
+			issue, err := VerifyFile(rootfs, meta[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(issue).ToNot(BeNil())
+			Expect(issue.Flags()).To(Equal("..5..."))
+
+			Expect(os.Chmod(filepath.Join(rootfs, "usr/bin/foo"), 0700)).ToNot(HaveOccurred())
+			issue, err = VerifyFile(rootfs, meta[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(issue.Flags()).To(Equal(".M5..."))
+
+			Expect(os.Remove(filepath.Join(rootfs, "usr/bin/bar"))).ToNot(HaveOccurred())
+			Expect(os.Symlink("baz", filepath.Join(rootfs, "usr/bin/bar"))).ToNot(HaveOccurred())
+			issue, err = VerifyFile(rootfs, meta[1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(issue.Flags()).To(Equal("...L.."))
+
+			Expect(os.Remove(filepath.Join(rootfs, "usr/bin/foo"))).ToNot(HaveOccurred())
+			issue, err = VerifyFile(rootfs, meta[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(issue.Missing).To(BeTrue())
+			Expect(issue.Flags()).To(Equal("missing"))
+		})
+	})
+
+	Context("Compute the metadata from a tar stream", func() {
+
+		It("Returns the metadata of the files read", func() {
+			buf := &bytes.Buffer{}
+			tw := tar.NewWriter(buf)
+			add := func(h *tar.Header, content string) {
+				h.Size = int64(len(content))
+				Expect(tw.WriteHeader(h)).ToNot(HaveOccurred())
+				_, err := tw.Write([]byte(content))
+				Expect(err).ToNot(HaveOccurred())
+			}
+			add(&tar.Header{Name: "usr/bin/", Typeflag: tar.TypeDir, Mode: 0755}, "")
+			add(&tar.Header{Name: "usr/bin/foo", Typeflag: tar.TypeReg, Mode: 0755, Uid: 10}, "foo")
+			add(&tar.Header{Name: "usr/bin/bar", Typeflag: tar.TypeSymlink, Linkname: "foo"}, "")
+			add(&tar.Header{Name: "usr/bin/baz", Typeflag: tar.TypeLink, Linkname: "usr/bin/foo"}, "")
+			add(&tar.Header{Name: "usr/share/doc", Typeflag: tar.TypeReg, Mode: 0644}, "doc")
+			Expect(tw.Close()).ToNot(HaveOccurred())
+
+			r := NewTarMetaReader(buf, true, func(f string) bool {
+				return f == "/usr/share/doc"
+			})
+			_, err := io.Copy(io.Discard, r)
+			Expect(err).ToNot(HaveOccurred())
+			meta, err := r.Close()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(meta).To(HaveLen(3))
+
+			Expect(meta[0].Path).To(Equal("usr/bin/foo"))
+			Expect(meta[0].Size).To(Equal(int64(3)))
+			Expect(meta[0].Sha256).To(Equal(
+				"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"))
+			Expect(meta[0].Mode.Perm()).To(Equal(os.FileMode(0755)))
+			Expect(meta[0].Uid).To(Equal(10))
+
+			Expect(meta[1].Path).To(Equal("usr/bin/bar"))
+			Expect(meta[1].Link).To(Equal("foo"))
+
+			Expect(meta[2].Path).To(Equal("usr/bin/baz"))
+			Expect(meta[2].Sha256).To(Equal(meta[0].Sha256))
+		})
+	})
+})
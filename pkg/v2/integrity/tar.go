@@ -0,0 +1,135 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package integrity
+
+import (
+	"archive/tar"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	pkg "github.com/geaaru/luet/pkg/package"
+)
+
+// TarMetaReader computes the metadata of the files of a tar stream
+// while the stream is consumed by another reader. In this way the
+// metadata are the values of the archive and not of the files
+// written in the rootfs.
+type TarMetaReader struct {
+	// SameOwner is false when the files are extracted with the
+	// owner of the process.
+	SameOwner bool
+	// Skip returns true for the files (with the initial slash)
+	// not extracted.
+	Skip func(string) bool
+
+	reader io.Reader
+	pw     *io.PipeWriter
+	done   chan error
+	meta   []*pkg.FileMeta
+}
+
+// NewTarMetaReader returns a reader that returns the data of the
+// tar stream in input.
+func NewTarMetaReader(in io.Reader, sameOwner bool, skip func(string) bool) *TarMetaReader {
+	pr, pw := io.Pipe()
+	ans := &TarMetaReader{
+		SameOwner: sameOwner,
+		Skip:      skip,
+		reader:    io.TeeReader(in, pw),
+		pw:        pw,
+		done:      make(chan error, 1),
+		meta:      []*pkg.FileMeta{},
+	}
+
+	go func() {
+		err := ans.parse(pr)
+		// Consume the data after the end of the archive to
+		// avoid blocking the reader.
+		io.Copy(io.Discard, pr)
+		ans.done <- err
+	}()
+
+	return ans
+}
+
+func (r *TarMetaReader) Read(p []byte) (int, error) {
+	return r.reader.Read(p)
+}
+
+// Close waits the parsing of the data read and returns the metadata
+// of the files of the archive.
+func (r *TarMetaReader) Close() ([]*pkg.FileMeta, error) {
+	r.pw.Close()
+	if err := <-r.done; err != nil {
+		return nil, err
+	}
+	return r.meta, nil
+}
+
+func (r *TarMetaReader) parse(in io.Reader) error {
+	tr := tar.NewReader(in)
+	regulars := make(map[string]*pkg.FileMeta, 0)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimPrefix(header.Name, "./")
+		name = strings.TrimPrefix(name, "/")
+		if r.Skip != nil && r.Skip("/"+name) {
+			continue
+		}
+
+		meta := &pkg.FileMeta{
+			Path: name,
+			Mode: header.FileInfo().Mode(),
+			Uid:  header.Uid,
+			Gid:  header.Gid,
+		}
+		if !r.SameOwner {
+			meta.Uid = os.Getuid()
+			meta.Gid = os.Getgid()
+		}
+
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			h256 := sha256.New()
+			h1 := sha1.New()
+			meta.Size, err = io.Copy(io.MultiWriter(h256, h1), tr)
+			if err != nil {
+				return err
+			}
+			meta.Sha256 = fmt.Sprintf("%x", h256.Sum(nil))
+			meta.Sha1 = fmt.Sprintf("%x", h1.Sum(nil))
+			regulars[name] = meta
+		case tar.TypeSymlink:
+			meta.Mode = os.ModeSymlink | os.ModePerm
+			meta.Link = header.Linkname
+		case tar.TypeLink:
+			// The hardlinks are regular files in the rootfs.
+			target, ok := regulars[strings.TrimPrefix(
+				strings.TrimPrefix(header.Linkname, "./"), "/")]
+			if !ok {
+				continue
+			}
+			link := *target
+			link.Path = name
+			meta = &link
+		case tar.TypeDir:
+			continue
+		}
+
+		r.meta = append(r.meta, meta)
+	}
+}
@@ -0,0 +1,343 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	version "github.com/geaaru/luet/pkg/versioner"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Directory under the repository database directory where are
+	// stored the snapshots of the packages of the revisions.
+	REVISIONS_DIR = "revisions"
+	// Number of the snapshots maintained for repository.
+	MaxRevisionsSnapshots = 10
+)
+
+// WagonSnapshot contains the versions of the packages available
+// in a revision of the repository.
+type WagonSnapshot struct {
+	Revision   int                 `json:"revision" yaml:"revision"`
+	LastUpdate string              `json:"last_update,omitempty" yaml:"last_update,omitempty"`
+	Packages   map[string][]string `json:"packages" yaml:"packages"`
+}
+
+type PackageChange struct {
+	Package string `json:"package" yaml:"package"`
+	// Versions available before the sync.
+	From []string `json:"from,omitempty" yaml:"from,omitempty"`
+	// Versions available after the sync.
+	To []string `json:"to,omitempty" yaml:"to,omitempty"`
+}
+
+// WagonChangelog describes the packages added, removed and
+// updated between two revisions of a repository.
+type WagonChangelog struct {
+	Repository   string           `json:"repository" yaml:"repository"`
+	FromRevision int              `json:"from_revision" yaml:"from_revision"`
+	ToRevision   int              `json:"to_revision" yaml:"to_revision"`
+	Added        []*PackageChange `json:"added" yaml:"added"`
+	Removed      []*PackageChange `json:"removed" yaml:"removed"`
+	Updated      []*PackageChange `json:"updated" yaml:"updated"`
+}
+
+func NewWagonSnapshot(revision int, lastUpdate string) *WagonSnapshot {
+	return &WagonSnapshot{
+		Revision:   revision,
+		LastUpdate: lastUpdate,
+		Packages:   make(map[string][]string, 0),
+	}
+}
+
+// NewWagonSnapshotFromTreefs reads the packages from the directories
+// <category>/<name>/<version> of the unpacked tree.
+func NewWagonSnapshotFromTreefs(treefs string, revision int, lastUpdate string) (*WagonSnapshot, error) {
+	ans := NewWagonSnapshot(revision, lastUpdate)
+	v := version.DefaultVersioner()
+
+	cats, err := os.ReadDir(treefs)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error on read tree "+treefs)
+	}
+
+	for _, cat := range cats {
+		if !cat.IsDir() {
+			continue
+		}
+		names, err := os.ReadDir(filepath.Join(treefs, cat.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range names {
+			if !name.IsDir() {
+				continue
+			}
+			pdir := filepath.Join(treefs, cat.Name(), name.Name())
+			versions, err := os.ReadDir(pdir)
+			if err != nil {
+				return nil, err
+			}
+
+			vv := []string{}
+			for _, ver := range versions {
+				if !ver.IsDir() {
+					continue
+				}
+				vdir := filepath.Join(pdir, ver.Name())
+				if fileHelper.Exists(filepath.Join(vdir, "metadata.json")) ||
+					fileHelper.Exists(filepath.Join(vdir, "definition.yaml")) {
+					vv = append(vv, ver.Name())
+				}
+			}
+
+			if len(vv) > 0 {
+				ans.Packages[cat.Name()+"/"+name.Name()] = v.Sort(vv)
+			}
+		}
+	}
+
+	return ans, nil
+}
+
+func (s *WagonSnapshot) Write(f string) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(f), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(f, data, 0644)
+}
+
+func LoadWagonSnapshot(f string) (*WagonSnapshot, error) {
+	data, err := os.ReadFile(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error on read file "+f)
+	}
+
+	ans := NewWagonSnapshot(0, "")
+	err = yaml.Unmarshal(data, ans)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error on parse file "+f)
+	}
+
+	return ans, nil
+}
+
+// NewWagonChangelog returns the changes between the snapshots.
+func NewWagonChangelog(repo string, from, to *WagonSnapshot) *WagonChangelog {
+	ans := &WagonChangelog{
+		Repository:   repo,
+		FromRevision: from.Revision,
+		ToRevision:   to.Revision,
+		Added:        []*PackageChange{},
+		Removed:      []*PackageChange{},
+		Updated:      []*PackageChange{},
+	}
+
+	for p, versions := range to.Packages {
+		prev, ok := from.Packages[p]
+		if !ok {
+			ans.Added = append(ans.Added, &PackageChange{Package: p, To: versions})
+		} else if strings.Join(prev, " ") != strings.Join(versions, " ") {
+			ans.Updated = append(ans.Updated, &PackageChange{
+				Package: p, From: prev, To: versions,
+			})
+		}
+	}
+
+	for p, versions := range from.Packages {
+		if _, ok := to.Packages[p]; !ok {
+			ans.Removed = append(ans.Removed, &PackageChange{Package: p, From: versions})
+		}
+	}
+
+	for _, l := range [][]*PackageChange{ans.Added, ans.Removed, ans.Updated} {
+		list := l
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Package < list[j].Package
+		})
+	}
+
+	return ans
+}
+
+func (c *WagonChangelog) IsEmpty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Updated) == 0
+}
+
+func lastVersion(versions []string) string {
+	if len(versions) == 0 {
+		return ""
+	}
+	return versions[len(versions)-1]
+}
+
+// Lines returns the changelog as text lines with the prefix:
+// + for the packages added, - for the packages removed and
+// ~ for the packages with a different list of versions.
+func (c *WagonChangelog) Lines() []string {
+	ans := []string{}
+
+	for _, p := range c.Added {
+		ans = append(ans, fmt.Sprintf("+ %s-%s", p.Package, lastVersion(p.To)))
+	}
+	for _, p := range c.Removed {
+		ans = append(ans, fmt.Sprintf("- %s-%s", p.Package, lastVersion(p.From)))
+	}
+	for _, p := range c.Updated {
+		from := lastVersion(p.From)
+		to := lastVersion(p.To)
+		if from == to {
+			ans = append(ans, fmt.Sprintf("~ %s (versions %s -> %s)", p.Package,
+				strings.Join(p.From, ","), strings.Join(p.To, ",")))
+		} else {
+			ans = append(ans, fmt.Sprintf("~ %s %s -> %s", p.Package, from, to))
+		}
+	}
+
+	return ans
+}
+
+func GetRevisionsDir(repobasedir string) string {
+	return filepath.Join(repobasedir, REVISIONS_DIR)
+}
+
+func GetSnapshotFile(repobasedir string, revision int) string {
+	return filepath.Join(GetRevisionsDir(repobasedir), fmt.Sprintf("%d.yaml", revision))
+}
+
+// ListSnapshots returns the revisions with a snapshot sorted
+// from the older to the newer.
+func ListSnapshots(repobasedir string) ([]int, error) {
+	ans := []int{}
+
+	entries, err := os.ReadDir(GetRevisionsDir(repobasedir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ans, nil
+		}
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".yaml") {
+			continue
+		}
+		rev, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".yaml"))
+		if err != nil {
+			continue
+		}
+		ans = append(ans, rev)
+	}
+	sort.Ints(ans)
+
+	return ans, nil
+}
+
+// pruneSnapshots removes the older snapshots.
+func pruneSnapshots(repobasedir string) error {
+	revs, err := ListSnapshots(repobasedir)
+	if err != nil {
+		return err
+	}
+
+	for len(revs) > MaxRevisionsSnapshots {
+		err = os.Remove(GetSnapshotFile(repobasedir, revs[0]))
+		if err != nil {
+			return err
+		}
+		revs = revs[1:]
+	}
+
+	return nil
+}
+
+// GetChangelog returns the changes between the snapshot of the
+// revision fromRev and the current revision. With fromRev <= 0 it's
+// used the snapshot of the previous revision available.
+func (w *WagonRepository) GetChangelog(repobasedir string, fromRev int) (*WagonChangelog, error) {
+	revs, err := ListSnapshots(repobasedir)
+	if err != nil {
+		return nil, err
+	}
+
+	toRev := w.Identity.GetRevision()
+
+	if fromRev <= 0 {
+		for _, r := range revs {
+			if r < toRev {
+				fromRev = r
+			}
+		}
+		if fromRev <= 0 {
+			return nil, fmt.Errorf(
+				"No previous revision available for the repository %s",
+				w.Identity.GetName())
+		}
+	}
+
+	from, err := LoadWagonSnapshot(GetSnapshotFile(repobasedir, fromRev))
+	if err != nil {
+		revsStr := []string{}
+		for _, r := range revs {
+			revsStr = append(revsStr, strconv.Itoa(r))
+		}
+		return nil, fmt.Errorf(
+			"Revision %d not available for the repository %s (available: %s)",
+			fromRev, w.Identity.GetName(), strings.Join(revsStr, ", "))
+	}
+
+	to, err := LoadWagonSnapshot(GetSnapshotFile(repobasedir, toRev))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWagonChangelog(w.Identity.GetName(), from, to), nil
+}
+
+// writeSnapshots stores the snapshot of the previous tree and of the
+// new tree and it creates the changelog between the two revisions.
+func (w *WagonRepository) writeSnapshots(repobasedir, treefs string, prev *WagonSnapshot) error {
+	if prev != nil && prev.Revision > 0 {
+		err := prev.Write(GetSnapshotFile(repobasedir, prev.Revision))
+		if err != nil {
+			return err
+		}
+	}
+
+	snapshot, err := NewWagonSnapshotFromTreefs(treefs,
+		w.Identity.GetRevision(), w.Identity.GetLastUpdate())
+	if err != nil {
+		return err
+	}
+
+	err = snapshot.Write(GetSnapshotFile(repobasedir, snapshot.Revision))
+	if err != nil {
+		return err
+	}
+
+	if prev != nil {
+		w.Changelog = NewWagonChangelog(w.Identity.GetName(), prev, snapshot)
+	}
+
+	return pruneSnapshots(repobasedir)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package repository_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/geaaru/luet/pkg/v2/repository"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Changelog", func() {
+
+	Context("NewWagonChangelog", func() {
+
+		It("Detects the packages added, removed and updated", func() {
+			from := NewWagonSnapshot(1, "")
+			from.Packages["sys/a"] = []string{"1.0"}
+			from.Packages["sys/b"] = []string{"1.0", "1.1"}
+			from.Packages["sys/c"] = []string{"2.0"}
+			from.Packages["sys/d"] = []string{"1.0"}
+
+			to := NewWagonSnapshot(2, "")
+			to.Packages["sys/b"] = []string{"1.1", "1.2"}
+			to.Packages["sys/c"] = []string{"2.0"}
+			to.Packages["sys/e"] = []string{"3.0"}
+			to.Packages["sys/d"] = []string{"1.0"}
+			to.Packages["app/f"] = []string{"0.1"}
+
+			c := NewWagonChangelog("repo", from, to)
+			Expect(c.Repository).To(Equal("repo"))
+			Expect(c.FromRevision).To(Equal(1))
+			Expect(c.ToRevision).To(Equal(2))
+			Expect(c.IsEmpty()).To(BeFalse())
+
+			Expect(c.Added).To(HaveLen(2))
+			Expect(c.Added[0].Package).To(Equal("app/f"))
+			Expect(c.Added[1].Package).To(Equal("sys/e"))
+			Expect(c.Added[1].To).To(Equal([]string{"3.0"}))
+
+			Expect(c.Removed).To(HaveLen(1))
+			Expect(c.Removed[0].Package).To(Equal("sys/a"))
+			Expect(c.Removed[0].From).To(Equal([]string{"1.0"}))
+
+			Expect(c.Updated).To(HaveLen(1))
+			Expect(c.Updated[0].Package).To(Equal("sys/b"))
+			Expect(c.Updated[0].From).To(Equal([]string{"1.0", "1.1"}))
+			Expect(c.Updated[0].To).To(Equal([]string{"1.1", "1.2"}))
+		})
+
+		It("Returns an empty changelog with the same packages", func() {
+			from := NewWagonSnapshot(1, "")
+			from.Packages["sys/a"] = []string{"1.0"}
+			to := NewWagonSnapshot(2, "")
+			to.Packages["sys/a"] = []string{"1.0"}
+
+			c := NewWagonChangelog("repo", from, to)
+			Expect(c.IsEmpty()).To(BeTrue())
+			Expect(c.Lines()).To(BeEmpty())
+		})
+	})
+
+	Context("Lines", func() {
+
+		It("Prints the last version of the packages", func() {
+			c := &WagonChangelog{
+				Added: []*PackageChange{
+					{Package: "sys/e", To: []string{"2.0", "3.0"}},
+				},
+				Removed: []*PackageChange{
+					{Package: "sys/a", From: []string{"1.0"}},
+				},
+				Updated: []*PackageChange{
+					{Package: "sys/b", From: []string{"1.0", "1.1"}, To: []string{"1.1", "1.2"}},
+					{Package: "sys/c", From: []string{"1.0", "2.0"}, To: []string{"2.0"}},
+				},
+			}
+
+			Expect(c.Lines()).To(Equal([]string{
+				"+ sys/e-3.0",
+				"- sys/a-1.0",
+				"~ sys/b 1.1 -> 1.2",
+				"~ sys/c (versions 1.0,2.0 -> 2.0)",
+			}))
+		})
+	})
+
+	Context("Snapshots", func() {
+		var repobasedir string
+
+		BeforeEach(func() {
+			var err error
+			repobasedir, err = os.MkdirTemp("", "luet-changelog")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(repobasedir)
+		})
+
+		writeSnapshot := func(rev int) {
+			s := NewWagonSnapshot(rev, "")
+			s.Packages["sys/a"] = []string{"1.0"}
+			Expect(s.Write(GetSnapshotFile(repobasedir, rev))).ToNot(HaveOccurred())
+		}
+
+		It("Returns an empty list without the revisions directory", func() {
+			revs, err := ListSnapshots(repobasedir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revs).To(BeEmpty())
+		})
+
+		It("Lists the revisions sorted and ignores the other files", func() {
+			for _, rev := range []int{10, 2, 9} {
+				writeSnapshot(rev)
+			}
+			dir := GetRevisionsDir(repobasedir)
+			Expect(os.WriteFile(filepath.Join(dir, "notes.yaml"), []byte("x"), 0644)).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(dir, "3.txt"), []byte("x"), 0644)).ToNot(HaveOccurred())
+			Expect(os.MkdirAll(filepath.Join(dir, "4.yaml"), os.ModePerm)).ToNot(HaveOccurred())
+
+			revs, err := ListSnapshots(repobasedir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revs).To(Equal([]int{2, 9, 10}))
+
+			s, err := LoadWagonSnapshot(GetSnapshotFile(repobasedir, 9))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s.Revision).To(Equal(9))
+			Expect(s.Packages).To(HaveKeyWithValue("sys/a", []string{"1.0"}))
+		})
+
+		It("Prunes the older snapshots", func() {
+			for rev := 1; rev <= MaxRevisionsSnapshots+3; rev++ {
+				writeSnapshot(rev)
+			}
+
+			Expect(PruneSnapshots(repobasedir)).ToNot(HaveOccurred())
+
+			revs, err := ListSnapshots(repobasedir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revs).To(HaveLen(MaxRevisionsSnapshots))
+			Expect(revs[0]).To(Equal(4))
+			Expect(revs[len(revs)-1]).To(Equal(MaxRevisionsSnapshots + 3))
+		})
+
+		It("Doesn't prune when the snapshots are under the limit", func() {
+			writeSnapshot(1)
+			writeSnapshot(2)
+
+			Expect(PruneSnapshots(repobasedir)).ToNot(HaveOccurred())
+
+			revs, err := ListSnapshots(repobasedir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revs).To(Equal([]int{1, 2}))
+		})
+	})
+})
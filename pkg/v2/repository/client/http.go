@@ -9,11 +9,13 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/geaaru/luet/pkg/config"
@@ -26,14 +28,25 @@ import (
 	"github.com/schollz/progressbar/v3"
 )
 
+// errRangeIgnored is returned when the server replies with the
+// full content to a Range request.
+var errRangeIgnored = errors.New("range request ignored")
+
 type HttpClient struct {
 	Repository *config.LuetRepository
+	Progress   *MultiProgress
 }
 
 func NewHttpClient(r *config.LuetRepository) *HttpClient {
 	return &HttpClient{Repository: r}
 }
 
+// SetProgress permits to render the download progress through
+// a shared MultiProgress instead of a dedicated progress bar.
+func (c *HttpClient) SetProgress(p *MultiProgress) {
+	c.Progress = p
+}
+
 func NewGrabClient() *grab.Client {
 	httpTimeout := config.LuetCfg.GetGeneral().ClientTimeout
 	timeout := os.Getenv("HTTP_TIMEOUT")
@@ -82,6 +95,23 @@ func (c *HttpClient) PrepareReq(dst, url string) (*grab.Request, error) {
 	return req, err
 }
 
+// traceFirstByte returns the request that traces the time to first
+// byte of the first response received.
+func traceFirstByte(req *grab.Request) (*grab.Request, func() time.Duration) {
+	var ttfb int64
+	start := time.Now()
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			atomic.CompareAndSwapInt64(&ttfb, 0, int64(time.Since(start)))
+		},
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	return req, func() time.Duration {
+		return time.Duration(atomic.LoadInt64(&ttfb))
+	}
+}
+
 func Round(input float64) float64 {
 	if input < 0 {
 		return math.Ceil(input - 0.5)
@@ -90,16 +120,10 @@ func Round(input float64) float64 {
 }
 
 func (c *HttpClient) DownloadArtifact(a *artifact.PackageArtifact, msg string) error {
-	var u *url.URL = nil
 	var err error
-	var req *grab.Request
-	var temp string
 
 	artifactName := path.Base(a.Path)
-	cacheFile := filepath.Join(
-		config.LuetCfg.GetSystem().GetSystemPkgsCacheDirPath(),
-		artifactName,
-	)
+	cacheFile := a.GetCacheFile()
 	ok := false
 
 	// Check if file is already in cache
@@ -107,110 +131,197 @@ func (c *HttpClient) DownloadArtifact(a *artifact.PackageArtifact, msg string) e
 		Debug("Use artifact", artifactName, "from cache.")
 	} else {
 
-		temp, err = config.LuetCfg.GetSystem().TempDir("tree")
-		if err != nil {
-			return err
+		if err := fileHelper.EnsureDir(cacheFile); err != nil {
+			return errors.Wrapf(err, "could not create cache folder for %s", cacheFile)
 		}
-		defer os.RemoveAll(temp)
 
 		client := NewGrabClient()
+		health := GetMirrorsHealth(c.Repository)
+		retries := config.LuetCfg.GetGeneral().GetClientRetries()
+
+		for round := 0; round < retries && !ok; round++ {
+			if round > 0 {
+				backoff := GetBackoff(round)
+				Debug(fmt.Sprintf("Retry download of %s in %s...",
+					artifactName, backoff))
+				time.Sleep(backoff)
+			}
 
-		for _, uri := range c.Repository.Urls {
-			Debug("Downloading artifact", artifactName, "from", uri)
+			for _, uri := range health.Rank(c.Repository.Urls) {
+				Debug("Downloading artifact", artifactName, "from", uri)
 
-			u, err = url.Parse(uri)
-			if err != nil {
-				continue
-			}
-			u.Path = path.Join(u.Path, artifactName)
+				err = c.fetchArtifact(client, uri, cacheFile, artifactName, a, msg)
+				if err != nil {
+					Debug(fmt.Sprintf("Download of %s from %s failed: %s",
+						artifactName, uri, err.Error()))
+					health.RecordFailure(uri, err)
+					continue
+				}
 
-			req, err = c.PrepareReq(temp, u.String())
-			if err != nil {
-				continue
+				ok = true
+				break
 			}
+		}
 
-			resp := client.Do(req)
+		if !ok {
+			return err
+		}
 
-			bar := progressbar.NewOptions64(
-				resp.Size(),
-				progressbar.OptionSetDescription(
-					Emojize(fmt.Sprintf("[green]:package:%s # [reset]",
-						msg,
-					))),
-				//filepath.Base(resp.Request.HTTPRequest.URL.RequestURI()))),
-				//progressbar.OptionSetRenderBlankState(true),
-				progressbar.OptionEnableColorCodes(config.LuetCfg.GetLogging().Color),
-				progressbar.OptionClearOnFinish(),
-				progressbar.OptionShowBytes(true),
-				progressbar.OptionShowCount(),
-				progressbar.OptionSetPredictTime(true),
-				progressbar.OptionFullWidth(),
-				progressbar.OptionSetTheme(progressbar.Theme{
-					Saucer:        "[white]=[reset]",
-					SaucerHead:    "[white]>[reset]",
-					SaucerPadding: " ",
-					BarStart:      "[",
-					BarEnd:        "]",
-				}),
-			)
-
-			// start download loop
-			t := time.NewTicker(300 * time.Millisecond)
-			defer t.Stop()
-
-		download_loop:
-
-			for {
-				select {
-				case <-t.C:
-					bar.Set64(resp.BytesComplete())
-
-				case <-resp.Done:
-
-					//bar.Reset()
-					bar.Finish()
-					// download is complete
-					break download_loop
-				}
-			}
+	}
 
-			if err = resp.Err(); err != nil {
-				continue
-			}
+	a.CachePath = cacheFile
+	return nil
+}
 
-			if err != nil {
-				continue
-			}
+// fetchArtifact downloads the artifact from a specific mirror.
+// The content is stored in the packages cache as a partial file
+// with a sidecar state that permits to resume the download through
+// a Range request on the next attempt. The file is validated and moved
+// to the final path only when completed. A file with a wrong checksum
+// is discarded and considered a failure of the mirror.
+func (c *HttpClient) fetchArtifact(client *grab.Client, uri, cacheFile, artifactName string,
+	a *artifact.PackageArtifact, msg string) error {
 
-			//bar.Reset()
-			//bar.Finish()
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	u.Path = path.Join(u.Path, artifactName)
 
-			Debug("\nDownloaded", artifactName, "of",
-				fmt.Sprintf("%.2f", (float64(resp.BytesComplete())/1000)/1000), "MB (",
-				fmt.Sprintf("%.2f", (float64(resp.BytesPerSecond())/1024)/1024), "MiB/s )")
+	sha256 := a.Checksums[string(artifact.SHA256)]
 
-			Debug("\nCopying file ", filepath.Join(temp, artifactName), "to", cacheFile)
-			err = fileHelper.CopyFile(filepath.Join(temp, artifactName), cacheFile)
-			if err != nil {
-				return errors.Wrap(err,
-					fmt.Sprintf("Copying file %s to %s", filepath.Join(temp, artifactName),
-						cacheFile))
-			}
+	state, err := NewPartialDownloadFromFile(cacheFile)
+	if err != nil || !state.CanResume(u.String(), sha256) {
+		// POST: the partial content is not valid for this artifact.
+		RemovePartialDownload(cacheFile)
+		state = NewPartialDownload(cacheFile, artifactName)
+	}
+	state.Url = u.String()
+	state.Sha256 = sha256
 
-			ok = true
-			break
+	req, err := c.PrepareReq(state.GetPartialFile(), u.String())
+	if err != nil {
+		return err
+	}
+	if v := state.GetIfRange(u.String()); v != "" {
+		req.HTTPRequest.Header.Set("If-Range", v)
+	}
+	req.BeforeCopy = func(resp *grab.Response) error {
+		if resp.DidResume && resp.HTTPResponse.StatusCode != http.StatusPartialContent {
+			return errRangeIgnored
 		}
+		return nil
+	}
 
-		if !ok {
-			return err
+	req, ttfb := traceFirstByte(req)
+	resp := client.Do(req)
+
+	if resp.HTTPResponse != nil {
+		state.ETag = resp.HTTPResponse.Header.Get("ETag")
+		state.LastModified = resp.HTTPResponse.Header.Get("Last-Modified")
+	}
+	state.Size = resp.Size()
+	if err = state.Write(); err != nil {
+		Debug("Error on write download state file:", err.Error())
+	}
+
+	if resp.DidResume {
+		Debug(fmt.Sprintf("Resuming download of %s from %s.",
+			artifactName, u.String()))
+	}
+
+	if c.Progress != nil {
+		bar := c.Progress.AddBar(msg, resp.Size())
+		waitDownload(resp, bar.Set64)
+		bar.Finish()
+	} else {
+		bar := newDownloadBar(resp.Size(), msg)
+		waitDownload(resp, bar.Set64)
+		bar.Finish()
+	}
+
+	if err = resp.Err(); err != nil {
+		if errors.Is(err, errRangeIgnored) {
+			// The remote file is changed or the server doesn't
+			// support range requests. Restart from scratch.
+			Debug(fmt.Sprintf("Restarting download of %s from %s.",
+				artifactName, u.String()))
+			state.Remove()
+			return c.fetchArtifact(client, uri, cacheFile, artifactName, a, msg)
 		}
+		// Keep the partial content for the next attempt.
+		return err
+	}
 
+	if resp.Size() > 0 && resp.BytesComplete() != resp.Size() {
+		return fmt.Errorf("truncated file: received %d of %d bytes",
+			resp.BytesComplete(), resp.Size())
 	}
 
-	a.CachePath = cacheFile
+	if len(a.Checksums) > 0 {
+		tmpArt := a.ShallowCopy()
+		tmpArt.CachePath = state.GetPartialFile()
+		if err = tmpArt.Verify(); err != nil {
+			state.Remove()
+			return errors.Wrap(err, "invalid file")
+		}
+	}
+
+	Debug("\nDownloaded", artifactName, "of",
+		fmt.Sprintf("%.2f", (float64(resp.BytesComplete())/1000)/1000), "MB (",
+		fmt.Sprintf("%.2f", (float64(resp.BytesPerSecond())/1024)/1024), "MiB/s )")
+
+	_, err = a.PublishCacheFile(state.GetPartialFile())
+	if err != nil {
+		return errors.Wrap(err,
+			fmt.Sprintf("Moving file %s to %s", state.GetPartialFile(), cacheFile))
+	}
+	state.Remove()
+
+	GetMirrorsHealth(c.Repository).RecordSuccess(uri, resp.BytesPerSecond(), ttfb())
+
 	return nil
 }
 
+func newDownloadBar(size int64, msg string) *progressbar.ProgressBar {
+	return progressbar.NewOptions64(
+		size,
+		progressbar.OptionSetDescription(
+			Emojize(fmt.Sprintf("[green]:package:%s # [reset]",
+				msg,
+			))),
+		progressbar.OptionEnableColorCodes(config.LuetCfg.GetLogging().Color),
+		progressbar.OptionClearOnFinish(),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionShowCount(),
+		progressbar.OptionSetPredictTime(true),
+		progressbar.OptionFullWidth(),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        "[white]=[reset]",
+			SaucerHead:    "[white]>[reset]",
+			SaucerPadding: " ",
+			BarStart:      "[",
+			BarEnd:        "]",
+		}),
+	)
+}
+
+// waitDownload updates the progress until the download is completed.
+func waitDownload(resp *grab.Response, set func(int64) error) {
+	t := time.NewTicker(300 * time.Millisecond)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			set(resp.BytesComplete())
+		case <-resp.Done:
+			set(resp.BytesComplete())
+			return
+		}
+	}
+}
+
 func (c *HttpClient) DownloadFile(name string) (string, error) {
 	var file *os.File = nil
 	var u *url.URL = nil
@@ -224,44 +335,76 @@ func (c *HttpClient) DownloadFile(name string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	defer os.RemoveAll(temp)
 
 	client := NewGrabClient()
+	health := GetMirrorsHealth(c.Repository)
+	retries := config.LuetCfg.GetGeneral().GetClientRetries()
+
+	for round := 0; round < retries && !ok; round++ {
+		if round > 0 {
+			backoff := GetBackoff(round)
+			Debug(fmt.Sprintf("Retry download of %s in %s...", name, backoff))
+			time.Sleep(backoff)
+		}
 
-	for _, uri := range c.Repository.Urls {
+		for _, uri := range health.Rank(c.Repository.Urls) {
 
-		file, err = config.LuetCfg.GetSystem().TempFile("HttpClient")
-		if err != nil {
-			continue
-		}
+			file, err = config.LuetCfg.GetSystem().TempFile("HttpClient")
+			if err != nil {
+				health.RecordFailure(uri, err)
+				continue
+			}
+			file.Close()
 
-		u, err = url.Parse(uri)
-		if err != nil {
-			continue
-		}
-		u.Path = path.Join(u.Path, name)
+			u, err = url.Parse(uri)
+			if err != nil {
+				health.RecordFailure(uri, err)
+				os.Remove(file.Name())
+				continue
+			}
+			u.Path = path.Join(u.Path, name)
 
-		Debug("Downloading", u.String())
+			Debug("Downloading", u.String())
 
-		req, err = c.PrepareReq(temp, u.String())
-		if err != nil {
-			continue
-		}
+			req, err = c.PrepareReq(temp, u.String())
+			if err != nil {
+				health.RecordFailure(uri, err)
+				os.Remove(file.Name())
+				continue
+			}
+			req.NoResume = true
 
-		resp := client.Do(req)
-		if err = resp.Err(); err != nil {
-			continue
-		}
+			req, ttfb := traceFirstByte(req)
+			resp := client.Do(req)
+			if err = resp.Err(); err != nil {
+				health.RecordFailure(uri, err)
+				os.Remove(file.Name())
+				continue
+			}
 
-		Debug("Downloaded", filepath.Base(resp.Filename), "of",
-			fmt.Sprintf("%.2f", (float64(resp.BytesComplete())/1000)/1000), "MB (",
-			fmt.Sprintf("%.2f", (float64(resp.BytesPerSecond())/1024)/1024), "MiB/s )")
+			if resp.Size() > 0 && resp.BytesComplete() != resp.Size() {
+				err = fmt.Errorf("truncated file: received %d of %d bytes",
+					resp.BytesComplete(), resp.Size())
+				health.RecordFailure(uri, err)
+				os.Remove(file.Name())
+				continue
+			}
 
-		err = fileHelper.CopyFile(filepath.Join(temp, name), file.Name())
-		if err != nil {
-			continue
+			Debug("Downloaded", filepath.Base(resp.Filename), "of",
+				fmt.Sprintf("%.2f", (float64(resp.BytesComplete())/1000)/1000), "MB (",
+				fmt.Sprintf("%.2f", (float64(resp.BytesPerSecond())/1024)/1024), "MiB/s )")
+
+			err = fileHelper.CopyFile(filepath.Join(temp, name), file.Name())
+			if err != nil {
+				health.RecordFailure(uri, err)
+				os.Remove(file.Name())
+				continue
+			}
+			health.RecordSuccess(uri, resp.BytesPerSecond(), ttfb())
+			ok = true
+			break
 		}
-		ok = true
-		break
 	}
 
 	if !ok {
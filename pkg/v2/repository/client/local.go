@@ -13,6 +13,8 @@ import (
 	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
 	. "github.com/geaaru/luet/pkg/logger"
 	"github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+
+	"github.com/pkg/errors"
 )
 
 type LocalClient struct {
@@ -28,7 +30,7 @@ func (c *LocalClient) DownloadArtifact(a *artifact.PackageArtifact, msg string)
 
 	rootfs := ""
 	artifactName := path.Base(a.Path)
-	cacheFile := filepath.Join(config.LuetCfg.GetSystem().GetSystemPkgsCacheDirPath(), artifactName)
+	cacheFile := a.GetCacheFile()
 
 	if !config.LuetCfg.ConfigFromHost {
 		rootfs, err = config.LuetCfg.GetSystem().GetRootFsAbs()
@@ -42,15 +44,29 @@ func (c *LocalClient) DownloadArtifact(a *artifact.PackageArtifact, msg string)
 		Debug("Use artifact", artifactName, "from cache.")
 	} else {
 		ok := false
+
+		if err := fileHelper.EnsureDir(cacheFile); err != nil {
+			return errors.Wrapf(err, "could not create cache folder for %s", cacheFile)
+		}
+
 		for _, uri := range c.Repository.Urls {
 
 			uri = filepath.Join(rootfs, uri)
 
 			Debug("Downloading artifact", artifactName, "from", uri)
 
-			//defer os.Remove(file.Name())
-			err = fileHelper.CopyFile(filepath.Join(uri, artifactName), cacheFile)
+			// Copy the file with a temporary name to avoid
+			// to leave a broken file in the cache on errors.
+			err = fileHelper.CopyFile(filepath.Join(uri, artifactName),
+				cacheFile+PartialFileExt)
+			if err != nil {
+				os.Remove(cacheFile + PartialFileExt)
+				continue
+			}
+
+			_, err = a.PublishCacheFile(cacheFile + PartialFileExt)
 			if err != nil {
+				os.Remove(cacheFile + PartialFileExt)
 				continue
 			}
 			ok = true
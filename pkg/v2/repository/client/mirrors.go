@@ -0,0 +1,253 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/logger"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MirrorsHealthFile = "mirrors.yaml"
+
+	// Weight of the last measure on the moving averages.
+	mirrorsAverageWeight = 0.3
+	// Size of the download used to compare the mirrors with
+	// the time to first byte and the throughput.
+	mirrorsRankingSize = 1024 * 1024
+	// Base time of the exponential backoff between the retries.
+	mirrorsBackoffBase = time.Second
+)
+
+// MirrorHealth contains the statistics of a repository URL.
+// The throughput is in bytes per second and the time to first
+// byte in milliseconds.
+type MirrorHealth struct {
+	Url                 string `yaml:"url" json:"url"`
+	Throughput          int64  `yaml:"throughput" json:"throughput"`
+	TimeToFirstByte     int64  `yaml:"ttfb_ms,omitempty" json:"ttfb_ms,omitempty"`
+	Successes           int    `yaml:"successes" json:"successes"`
+	Failures            int    `yaml:"failures" json:"failures"`
+	ConsecutiveFailures int    `yaml:"consecutive_failures" json:"consecutive_failures"`
+	LastError           string `yaml:"last_error,omitempty" json:"last_error,omitempty"`
+	LastErrorTime       string `yaml:"last_error_time,omitempty" json:"last_error_time,omitempty"`
+	LastSuccessTime     string `yaml:"last_success_time,omitempty" json:"last_success_time,omitempty"`
+}
+
+// MirrorsHealth tracks the health of the URLs of a repository.
+// The data are persisted in the repository database directory and
+// used to sort the mirrors before every download.
+type MirrorsHealth struct {
+	Repository string          `yaml:"repository" json:"repository"`
+	Mirrors    []*MirrorHealth `yaml:"mirrors" json:"mirrors"`
+
+	file  string
+	mutex sync.Mutex
+}
+
+var (
+	mirrorsRegistry      = map[string]*MirrorsHealth{}
+	mirrorsRegistryMutex sync.Mutex
+)
+
+// GetMirrorsHealth returns the MirrorsHealth of the repository shared
+// between all the clients of the process.
+func GetMirrorsHealth(r *config.LuetRepository) *MirrorsHealth {
+	mirrorsRegistryMutex.Lock()
+	defer mirrorsRegistryMutex.Unlock()
+
+	if h, ok := mirrorsRegistry[r.Name]; ok {
+		return h
+	}
+
+	file := filepath.Join(
+		config.LuetCfg.GetSystem().GetRepoDatabaseDirPath(r.Name),
+		MirrorsHealthFile,
+	)
+
+	h, err := NewMirrorsHealthFromFile(file)
+	if err != nil {
+		Debug(fmt.Sprintf("[%s] Error on read mirrors health file: %s",
+			r.Name, err.Error()))
+		h = NewMirrorsHealth(r.Name, file)
+	}
+	mirrorsRegistry[r.Name] = h
+
+	return h
+}
+
+func NewMirrorsHealth(repo, file string) *MirrorsHealth {
+	return &MirrorsHealth{
+		Repository: repo,
+		Mirrors:    []*MirrorHealth{},
+		file:       file,
+	}
+}
+
+func NewMirrorsHealthFromFile(file string) (*MirrorsHealth, error) {
+	ans := &MirrorsHealth{
+		Mirrors: []*MirrorHealth{},
+		file:    file,
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(data, ans)
+	if err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+// Write stores the mirrors health file atomically.
+func (h *MirrorsHealth) Write() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.write()
+}
+
+func (h *MirrorsHealth) write() error {
+	if h.file == "" {
+		return nil
+	}
+
+	data, err := yaml.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	tmp := h.file + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, h.file)
+}
+
+func (h *MirrorsHealth) getMirror(url string) *MirrorHealth {
+	for _, m := range h.Mirrors {
+		if m.Url == url {
+			return m
+		}
+	}
+	m := &MirrorHealth{Url: url}
+	h.Mirrors = append(h.Mirrors, m)
+	return m
+}
+
+// estimatedTime returns the seconds needed to download a file of
+// the reference size from the mirror.
+func (m *MirrorHealth) estimatedTime() float64 {
+	return float64(m.TimeToFirstByte)/1000 +
+		float64(mirrorsRankingSize)/float64(m.Throughput)
+}
+
+// GetRanking returns the health of the urls sorted by preference.
+// The mirrors with consecutive failures are demoted and the others
+// are sorted by the time to first byte and the throughput. The urls
+// never used keep the configured order and are preferred to permit
+// a first measure.
+func (h *MirrorsHealth) GetRanking(urls []string) []*MirrorHealth {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	ans := []*MirrorHealth{}
+	for _, u := range urls {
+		m := *h.getMirror(u)
+		ans = append(ans, &m)
+	}
+
+	sort.SliceStable(ans, func(i, j int) bool {
+		if ans[i].ConsecutiveFailures != ans[j].ConsecutiveFailures {
+			return ans[i].ConsecutiveFailures < ans[j].ConsecutiveFailures
+		}
+		if ans[i].Throughput == 0 || ans[j].Throughput == 0 {
+			return ans[i].Throughput == 0 && ans[j].Throughput != 0
+		}
+		return ans[i].estimatedTime() < ans[j].estimatedTime()
+	})
+
+	return ans
+}
+
+// Rank returns the urls sorted by preference.
+func (h *MirrorsHealth) Rank(urls []string) []string {
+	ans := []string{}
+	for _, m := range h.GetRanking(urls) {
+		ans = append(ans, m.Url)
+	}
+	return ans
+}
+
+// RecordSuccess updates the statistics of the mirror with a completed
+// download with the throughput in bytes per second and the time to
+// first byte of the response.
+func (h *MirrorsHealth) RecordSuccess(url string, bps float64, ttfb time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	m := h.getMirror(url)
+	if bps > 0 {
+		m.Throughput = movingAverage(m.Throughput, bps)
+	}
+	if ttfb > 0 {
+		m.TimeToFirstByte = movingAverage(m.TimeToFirstByte,
+			float64(ttfb.Milliseconds()))
+	}
+	m.Successes++
+	m.ConsecutiveFailures = 0
+	m.LastSuccessTime = fmt.Sprintf("%d", time.Now().Unix())
+
+	if err := h.write(); err != nil {
+		Debug("Error on write mirrors health file:", err.Error())
+	}
+}
+
+func (h *MirrorsHealth) RecordFailure(url string, e error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	m := h.getMirror(url)
+	m.Failures++
+	m.ConsecutiveFailures++
+	if e != nil {
+		m.LastError = e.Error()
+	}
+	m.LastErrorTime = fmt.Sprintf("%d", time.Now().Unix())
+
+	if err := h.write(); err != nil {
+		Debug("Error on write mirrors health file:", err.Error())
+	}
+}
+
+func movingAverage(avg int64, v float64) int64 {
+	if avg == 0 {
+		return int64(v)
+	}
+	return int64(mirrorsAverageWeight*v +
+		(1-mirrorsAverageWeight)*float64(avg))
+}
+
+// GetBackoff returns the time to wait before the retry round.
+func GetBackoff(round int) time.Duration {
+	if round <= 0 {
+		return 0
+	}
+	return mirrorsBackoffBase * time.Duration(1<<uint(round-1))
+}
@@ -0,0 +1,172 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package client_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/geaaru/luet/pkg/config"
+	. "github.com/geaaru/luet/pkg/v2/repository/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mirrors", func() {
+	var tmpdir string
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = os.MkdirTemp("", "mirrors")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	Context("Ranking", func() {
+		It("Keeps the configured order without statistics", func() {
+			h := NewMirrorsHealth("repo", "")
+			Expect(h.Rank([]string{"a", "b", "c"})).To(Equal([]string{"a", "b", "c"}))
+		})
+
+		It("Prefers the mirrors with higher throughput", func() {
+			h := NewMirrorsHealth("repo", "")
+			h.RecordSuccess("a", 1000, 0)
+			h.RecordSuccess("b", 5000, 0)
+			h.RecordSuccess("c", 3000, 0)
+			Expect(h.Rank([]string{"a", "b", "c"})).To(Equal([]string{"b", "c", "a"}))
+		})
+
+		It("Prefers the mirrors with lower time to first byte", func() {
+			h := NewMirrorsHealth("repo", "")
+			h.RecordSuccess("a", 10*1024*1024, 2*time.Second)
+			h.RecordSuccess("b", 5*1024*1024, 50*time.Millisecond)
+			h.RecordSuccess("c", 5*1024*1024, 0)
+			Expect(h.Rank([]string{"a", "b", "c"})).To(Equal([]string{"c", "b", "a"}))
+		})
+
+		It("Tries the mirrors never measured before the others", func() {
+			h := NewMirrorsHealth("repo", "")
+			h.RecordSuccess("a", 1000, 0)
+			Expect(h.Rank([]string{"a", "b"})).To(Equal([]string{"b", "a"}))
+		})
+
+		It("Demotes the mirrors with consecutive failures", func() {
+			h := NewMirrorsHealth("repo", "")
+			h.RecordSuccess("a", 5000, 0)
+			h.RecordSuccess("b", 1000, 0)
+			h.RecordFailure("a", errors.New("timeout"))
+			Expect(h.Rank([]string{"a", "b"})).To(Equal([]string{"b", "a"}))
+
+			// A success resets the consecutive failures.
+			h.RecordSuccess("a", 5000, 0)
+			ranking := h.GetRanking([]string{"a", "b"})
+			Expect(ranking[0].Url).To(Equal("a"))
+			Expect(ranking[0].Failures).To(Equal(1))
+			Expect(ranking[0].ConsecutiveFailures).To(Equal(0))
+			Expect(ranking[0].LastError).To(Equal("timeout"))
+		})
+	})
+
+	Context("Throughput", func() {
+		It("Uses a moving average", func() {
+			h := NewMirrorsHealth("repo", "")
+			h.RecordSuccess("a", 1000, 0)
+			Expect(h.GetRanking([]string{"a"})[0].Throughput).To(Equal(int64(1000)))
+			h.RecordSuccess("a", 2000, 0)
+			Expect(h.GetRanking([]string{"a"})[0].Throughput).To(Equal(int64(1300)))
+			// Without a measure the average is unchanged.
+			h.RecordSuccess("a", 0, 0)
+			m := h.GetRanking([]string{"a"})[0]
+			Expect(m.Throughput).To(Equal(int64(1300)))
+			Expect(m.Successes).To(Equal(3))
+		})
+
+		It("Uses a moving average for the time to first byte", func() {
+			h := NewMirrorsHealth("repo", "")
+			h.RecordSuccess("a", 1000, 100*time.Millisecond)
+			Expect(h.GetRanking([]string{"a"})[0].TimeToFirstByte).To(Equal(int64(100)))
+			h.RecordSuccess("a", 1000, 200*time.Millisecond)
+			Expect(h.GetRanking([]string{"a"})[0].TimeToFirstByte).To(Equal(int64(130)))
+			h.RecordSuccess("a", 1000, 0)
+			Expect(h.GetRanking([]string{"a"})[0].TimeToFirstByte).To(Equal(int64(130)))
+		})
+	})
+
+	Context("Persistence", func() {
+		It("Stores the statistics on file", func() {
+			f := filepath.Join(tmpdir, MirrorsHealthFile)
+			h := NewMirrorsHealth("repo", f)
+			h.RecordSuccess("a", 1000, 0)
+			h.RecordFailure("b", errors.New("not found"))
+
+			h2, err := NewMirrorsHealthFromFile(f)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(h2.Repository).To(Equal("repo"))
+			Expect(h2.Rank([]string{"b", "a"})).To(Equal([]string{"a", "b"}))
+			Expect(h2.GetRanking([]string{"b"})[0].LastError).To(Equal("not found"))
+		})
+	})
+
+	Context("Backoff", func() {
+		It("Doubles the wait time at every round", func() {
+			Expect(GetBackoff(0)).To(Equal(time.Duration(0)))
+			Expect(GetBackoff(1)).To(Equal(time.Second))
+			Expect(GetBackoff(3)).To(Equal(4 * time.Second))
+		})
+	})
+
+	Context("HttpClient", func() {
+		It("Records the failures and uses the next mirror", func() {
+			broken := httptest.NewServer(http.NotFoundHandler())
+			defer broken.Close()
+			good := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					time.Sleep(10 * time.Millisecond)
+					w.Write([]byte("repository content"))
+				}))
+			defer good.Close()
+
+			cfg := config.LuetCfg
+			cfg.GetSystem().Rootfs = "/"
+			cfg.GetSystem().DatabasePath = filepath.Join(tmpdir, "db")
+			cfg.GetSystem().TmpDirBase = filepath.Join(tmpdir, "tmp")
+			cfg.GetGeneral().ClientRetries = 1
+
+			repo := &config.LuetRepository{
+				Name: "mirrors-http",
+				Type: "http",
+				Urls: []string{broken.URL, "://invalid", good.URL},
+			}
+
+			f, err := NewHttpClient(repo).DownloadFile("repository.yaml")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.Remove(f)
+			data, err := os.ReadFile(f)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal("repository content"))
+
+			// Only the downloaded file is left in the temporary directory.
+			files, err := os.ReadDir(cfg.GetSystem().TmpDirBase)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(HaveLen(1))
+
+			ranking := GetMirrorsHealth(repo).GetRanking(repo.Urls)
+			Expect(ranking[0].Url).To(Equal(good.URL))
+			Expect(ranking[0].Successes).To(Equal(1))
+			Expect(ranking[0].Throughput).To(BeNumerically(">", 0))
+			Expect(ranking[0].TimeToFirstByte).To(BeNumerically(">=", 10))
+			Expect(ranking[1].Failures).To(Equal(1))
+			Expect(ranking[2].Failures).To(Equal(1))
+		})
+	})
+})
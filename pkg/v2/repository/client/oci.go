@@ -0,0 +1,121 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package client
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/geaaru/luet/pkg/config"
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	. "github.com/geaaru/luet/pkg/logger"
+	"github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	"github.com/geaaru/luet/pkg/v2/repository/oci"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+)
+
+// OciClient downloads the package tarballs and the repository
+// files stored as OCI artifacts on a registry. The urls of the
+// repository are the registry repositories (ex. quay.io/org/repo).
+type OciClient struct {
+	Repository *config.LuetRepository
+	opts       []remote.Option
+}
+
+func NewOciClient(r *config.LuetRepository) *OciClient {
+	return &OciClient{
+		Repository: r,
+		opts:       oci.RemoteOptions(r.Authentication),
+	}
+}
+
+func (c *OciClient) DownloadArtifact(a *artifact.PackageArtifact, msg string) error {
+	var err error
+
+	artifactName := path.Base(a.Path)
+	cacheFile := a.GetCacheFile()
+
+	// Check if file is already in cache
+	if fileHelper.Exists(cacheFile) {
+		Debug("Use artifact", artifactName, "from cache.")
+	} else {
+		ok := false
+
+		if err := fileHelper.EnsureDir(cacheFile); err != nil {
+			return errors.Wrapf(err, "could not create cache folder for %s", cacheFile)
+		}
+
+		tag := a.GetPackage().ImageID()
+		for _, uri := range c.Repository.Urls {
+			ref, e := oci.ParseReference(uri, tag)
+			if e != nil {
+				err = e
+				continue
+			}
+
+			Debug("Downloading artifact", artifactName, "from", ref.String())
+
+			desc, e := oci.Pull(ref, artifactName,
+				a.Checksums[string(artifact.SHA256)], cacheFile+PartialFileExt, c.opts...)
+			if e != nil {
+				err = e
+				Warning(fmt.Sprintf("Failed download of %s from %s: %s",
+					artifactName, ref.String(), e.Error()))
+				continue
+			}
+
+			_, err = a.PublishCacheFile(cacheFile + PartialFileExt)
+			if err != nil {
+				os.Remove(cacheFile + PartialFileExt)
+				continue
+			}
+
+			Debug(fmt.Sprintf("Pulled %s (%s).", artifactName, desc.Digest.String()))
+			ok = true
+			break
+		}
+
+		if !ok {
+			return err
+		}
+	}
+
+	a.CachePath = cacheFile
+	return nil
+}
+
+func (c *OciClient) DownloadFile(name string) (string, error) {
+	var err error
+
+	for _, uri := range c.Repository.Urls {
+		ref, e := oci.ParseReference(uri, name)
+		if e != nil {
+			err = e
+			continue
+		}
+
+		file, e := config.LuetCfg.GetSystem().TempFile("ociclient")
+		if e != nil {
+			err = e
+			continue
+		}
+		file.Close()
+
+		Debug("Downloading file", name, "from", ref.String())
+
+		_, err = oci.Pull(ref, name, "", file.Name(), c.opts...)
+		if err != nil {
+			os.Remove(file.Name())
+			continue
+		}
+
+		return file.Name(), nil
+	}
+
+	return "", err
+}
@@ -0,0 +1,119 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package client
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	PartialFileExt  = ".part"
+	PartialStateExt = ".part.state"
+)
+
+// PartialDownload is the sidecar state of an artifact partially
+// downloaded in the packages cache. It permits to resume the download
+// with a Range request from the same URL or from another mirror
+// when the expected checksum is the same.
+type PartialDownload struct {
+	Artifact     string `yaml:"artifact" json:"artifact"`
+	Url          string `yaml:"url" json:"url"`
+	Size         int64  `yaml:"size" json:"size"`
+	Sha256       string `yaml:"sha256,omitempty" json:"sha256,omitempty"`
+	ETag         string `yaml:"etag,omitempty" json:"etag,omitempty"`
+	LastModified string `yaml:"last_modified,omitempty" json:"last_modified,omitempty"`
+	Created      string `yaml:"created" json:"created"`
+	Updated      string `yaml:"updated" json:"updated"`
+
+	cacheFile string
+}
+
+func NewPartialDownload(cacheFile, artifactName string) *PartialDownload {
+	now := fmt.Sprintf("%d", time.Now().Unix())
+	return &PartialDownload{
+		Artifact:  artifactName,
+		Created:   now,
+		Updated:   now,
+		cacheFile: cacheFile,
+	}
+}
+
+func NewPartialDownloadFromFile(cacheFile string) (*PartialDownload, error) {
+	data, err := os.ReadFile(cacheFile + PartialStateExt)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := &PartialDownload{}
+	if err = yaml.Unmarshal(data, ans); err != nil {
+		return nil, err
+	}
+	ans.cacheFile = cacheFile
+
+	return ans, nil
+}
+
+func (p *PartialDownload) GetPartialFile() string {
+	return p.cacheFile + PartialFileExt
+}
+
+func (p *PartialDownload) GetStateFile() string {
+	return p.cacheFile + PartialStateExt
+}
+
+// CanResume returns true if the partial content could be completed
+// from the url for an artifact with the sha256 checksum in input.
+func (p *PartialDownload) CanResume(url, sha256 string) bool {
+	if p.Sha256 != "" || sha256 != "" {
+		return p.Sha256 == sha256
+	}
+	return p.Url == url
+}
+
+// GetIfRange returns the validator to send with the If-Range header
+// when the download is resumed from the url in input. A weak ETag
+// can't be used with If-Range and the Last-Modified date is used
+// instead. The validators of another mirror are not valid.
+func (p *PartialDownload) GetIfRange(url string) string {
+	if p.Url != url {
+		return ""
+	}
+	if p.ETag != "" && !strings.HasPrefix(p.ETag, "W/") {
+		return p.ETag
+	}
+	return p.LastModified
+}
+
+// Write stores the state file atomically.
+func (p *PartialDownload) Write() error {
+	p.Updated = fmt.Sprintf("%d", time.Now().Unix())
+
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	tmp := p.GetStateFile() + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, p.GetStateFile())
+}
+
+// Remove drops the partial content and the state file.
+func (p *PartialDownload) Remove() {
+	RemovePartialDownload(p.cacheFile)
+}
+
+func RemovePartialDownload(cacheFile string) {
+	os.Remove(cacheFile + PartialFileExt)
+	os.Remove(cacheFile + PartialStateExt)
+}
@@ -0,0 +1,153 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package client_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/geaaru/luet/pkg/config"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	. "github.com/geaaru/luet/pkg/v2/repository/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Partial downloads", func() {
+
+	Context("If-Range", func() {
+		It("Uses the strong ETag of the same url", func() {
+			p := NewPartialDownload("/cache/foo", "foo.tar")
+			p.Url = "http://mirror1/foo.tar"
+			p.ETag = `"abc"`
+			p.LastModified = "Mon, 02 Jan 2023 15:04:05 GMT"
+
+			Expect(p.GetIfRange("http://mirror1/foo.tar")).To(Equal(`"abc"`))
+			Expect(p.GetIfRange("http://mirror2/foo.tar")).To(Equal(""))
+
+			p.ETag = `W/"abc"`
+			Expect(p.GetIfRange("http://mirror1/foo.tar")).To(Equal(p.LastModified))
+		})
+	})
+
+	Context("HttpClient", func() {
+		var tmpdir string
+		var server *httptest.Server
+		var content []byte
+		var etag string
+		var mutex sync.Mutex
+		var requests []http.Header
+
+		newArtifact := func() *artifact.PackageArtifact {
+			a := artifact.NewPackageArtifact("foo-test-1.0.package.tar")
+			a.Runtime = &pkg.DefaultPackage{
+				Category:   "test",
+				Name:       "foo",
+				Version:    "1.0",
+				Repository: "partial",
+			}
+			a.Checksums = artifact.Checksums{
+				string(artifact.SHA256): fmt.Sprintf("%x", sha256.Sum256(content)),
+			}
+			return a
+		}
+
+		BeforeEach(func() {
+			var err error
+			tmpdir, err = os.MkdirTemp("", "partial")
+			Expect(err).ToNot(HaveOccurred())
+
+			cfg := config.LuetCfg
+			cfg.GetSystem().Rootfs = "/"
+			cfg.GetSystem().DatabasePath = filepath.Join(tmpdir, "db")
+			cfg.GetSystem().PkgsCachePath = filepath.Join(tmpdir, "cache")
+			cfg.GetSystem().TmpDirBase = filepath.Join(tmpdir, "tmp")
+			cfg.GetGeneral().ClientRetries = 1
+
+			content = bytes.Repeat([]byte("0123456789"), 1000)
+			etag = `"v1"`
+			requests = []http.Header{}
+			server = httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					if r.Method == http.MethodGet {
+						requests = append(requests, r.Header.Clone())
+					}
+					w.Header().Set("ETag", etag)
+					http.ServeContent(w, r, "foo.tar", time.Time{},
+						bytes.NewReader(content))
+				}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+			os.RemoveAll(tmpdir)
+		})
+
+		// writePartial stores the first bytes of the content
+		// as a partial download from the server.
+		writePartial := func(a *artifact.PackageArtifact, data []byte) {
+			cacheFile := a.GetCacheFile()
+			Expect(os.MkdirAll(filepath.Dir(cacheFile), 0755)).ToNot(HaveOccurred())
+			state := NewPartialDownload(cacheFile, filepath.Base(a.Path))
+			state.Url = server.URL + "/" + filepath.Base(a.Path)
+			state.Sha256 = a.Checksums[string(artifact.SHA256)]
+			state.ETag = `"v1"`
+			Expect(state.Write()).ToNot(HaveOccurred())
+			Expect(os.WriteFile(state.GetPartialFile(), data, 0644)).ToNot(HaveOccurred())
+		}
+
+		download := func(a *artifact.PackageArtifact) {
+			repo := &config.LuetRepository{
+				Name: "partial",
+				Type: "http",
+				Urls: []string{server.URL},
+			}
+			Expect(NewHttpClient(repo).DownloadArtifact(a, "foo")).ToNot(HaveOccurred())
+
+			data, err := os.ReadFile(a.CachePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal(content))
+			Expect(a.CachePath + PartialFileExt).ToNot(BeAnExistingFile())
+			Expect(a.CachePath + PartialStateExt).ToNot(BeAnExistingFile())
+		}
+
+		It("Resumes the download with a conditional range request", func() {
+			a := newArtifact()
+			writePartial(a, content[0:4000])
+
+			download(a)
+
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].Get("Range")).To(Equal("bytes=4000-"))
+			Expect(requests[0].Get("If-Range")).To(Equal(`"v1"`))
+		})
+
+		It("Restarts the download when the remote file is changed", func() {
+			old := content
+			content = bytes.Repeat([]byte("abcdefghij"), 1000)
+			etag = `"v2"`
+			a := newArtifact()
+			writePartial(a, old[0:4000])
+
+			download(a)
+
+			Expect(requests).To(HaveLen(2))
+			Expect(requests[0].Get("If-Range")).To(Equal(`"v1"`))
+			Expect(requests[1].Get("Range")).To(Equal(""))
+			Expect(requests[1].Get("If-Range")).To(Equal(""))
+		})
+	})
+})
@@ -0,0 +1,201 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package client
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/geaaru/luet/pkg/logger"
+
+	"golang.org/x/term"
+)
+
+const (
+	multiProgressRefreshMs = 250
+	multiProgressBarWidth  = 30
+)
+
+// MultiProgress renders a consolidated view of the progress bars
+// of the downloads executed in parallel. Every active download
+// has his line that is redraw periodically. Messages printed through
+// the Println method are written over the bars that are then
+// redraw below.
+type MultiProgress struct {
+	mutex    sync.Mutex
+	writer   io.Writer
+	bars     []*ProgressBar
+	lines    int
+	tty      bool
+	ticker   *time.Ticker
+	done     chan bool
+	stopOnce sync.Once
+}
+
+// ProgressBar is the progress of a single download.
+type ProgressBar struct {
+	Description string
+	Total       int64
+	Current     int64
+
+	parent *MultiProgress
+}
+
+func NewMultiProgress() *MultiProgress {
+	return NewMultiProgressWriter(os.Stdout,
+		term.IsTerminal(int(os.Stdout.Fd())))
+}
+
+// NewMultiProgressWriter creates a MultiProgress that writes to the
+// writer in input. The bars are rendered only on a terminal.
+func NewMultiProgressWriter(w io.Writer, tty bool) *MultiProgress {
+	return &MultiProgress{
+		writer: w,
+		bars:   []*ProgressBar{},
+		tty:    tty,
+		done:   make(chan bool),
+	}
+}
+
+// Start begins the periodic redraw of the active bars.
+func (mp *MultiProgress) Start() {
+	if !mp.tty {
+		return
+	}
+	mp.ticker = time.NewTicker(multiProgressRefreshMs * time.Millisecond)
+	go func() {
+		for {
+			select {
+			case <-mp.ticker.C:
+				mp.mutex.Lock()
+				mp.render()
+				mp.mutex.Unlock()
+			case <-mp.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the redraw and clears the remaining bars.
+func (mp *MultiProgress) Stop() {
+	mp.stopOnce.Do(func() {
+		if mp.ticker != nil {
+			mp.ticker.Stop()
+			mp.done <- true
+		}
+		mp.mutex.Lock()
+		mp.clear()
+		mp.mutex.Unlock()
+	})
+}
+
+// AddBar registers a new bar with the description and the
+// size in bytes of the file to download.
+func (mp *MultiProgress) AddBar(descr string, total int64) *ProgressBar {
+	bar := &ProgressBar{
+		Description: descr,
+		Total:       total,
+		parent:      mp,
+	}
+	mp.mutex.Lock()
+	mp.bars = append(mp.bars, bar)
+	mp.mutex.Unlock()
+	return bar
+}
+
+// Println prints a message without break the bars layout.
+func (mp *MultiProgress) Println(msg string) {
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+	mp.clear()
+	fmt.Fprintln(mp.writer, msg)
+	mp.render()
+}
+
+func (b *ProgressBar) Set64(current int64) error {
+	b.parent.mutex.Lock()
+	b.Current = current
+	b.parent.mutex.Unlock()
+	return nil
+}
+
+// Finish removes the bar from the active bars.
+func (b *ProgressBar) Finish() {
+	mp := b.parent
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+	for idx, bar := range mp.bars {
+		if bar == b {
+			mp.bars = append(mp.bars[:idx], mp.bars[idx+1:]...)
+			break
+		}
+	}
+}
+
+func (b *ProgressBar) String() string {
+	perc := float64(0)
+	if b.Total > 0 {
+		perc = float64(b.Current) / float64(b.Total)
+		if perc > 1 {
+			perc = 1
+		}
+	}
+	filled := int(perc * multiProgressBarWidth)
+
+	saucer := strings.Repeat("=", filled)
+	if filled < multiProgressBarWidth {
+		saucer += ">" + strings.Repeat(" ", multiProgressBarWidth-filled-1)
+	}
+
+	return fmt.Sprintf("%s [%s] %3d%% (%s/%s)",
+		Emojize(fmt.Sprintf(":package:%s #", b.Description)),
+		saucer, int(perc*100),
+		humanBytes(b.Current), humanBytes(b.Total))
+}
+
+// clear removes the bars lines. It requires the lock.
+func (mp *MultiProgress) clear() {
+	if !mp.tty || mp.lines == 0 {
+		return
+	}
+	fmt.Fprintf(mp.writer, "\033[%dA\033[J", mp.lines)
+	mp.lines = 0
+}
+
+// render redraws the active bars. It requires the lock.
+func (mp *MultiProgress) render() {
+	if !mp.tty {
+		return
+	}
+	if mp.lines > 0 {
+		fmt.Fprintf(mp.writer, "\033[%dA", mp.lines)
+	}
+	for _, bar := range mp.bars {
+		fmt.Fprintf(mp.writer, "\r\033[2K%s\n", bar.String())
+	}
+	if len(mp.bars) < mp.lines {
+		// Clean the lines of the completed bars
+		fmt.Fprint(mp.writer, "\033[J")
+	}
+	mp.lines = len(mp.bars)
+}
+
+func humanBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,71 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package client_test
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/geaaru/luet/pkg/v2/repository/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MultiProgress", func() {
+
+	It("Prints only the messages without a terminal", func() {
+		buf := &bytes.Buffer{}
+		mp := NewMultiProgressWriter(buf, false)
+		mp.Start()
+
+		bar := mp.AddBar("foo", 2048)
+		Expect(bar.Set64(1024)).ToNot(HaveOccurred())
+		mp.Println("message")
+		bar.Finish()
+		mp.Stop()
+		mp.Stop()
+
+		Expect(buf.String()).To(Equal("message\n"))
+	})
+
+	It("Redraws the active bars below the messages", func() {
+		buf := &bytes.Buffer{}
+		mp := NewMultiProgressWriter(buf, true)
+
+		foo := mp.AddBar("foo", 2048)
+		mp.AddBar("bar", 0)
+		Expect(foo.Set64(1024)).ToNot(HaveOccurred())
+
+		mp.Println("first")
+		out := buf.String()
+		Expect(out).To(HavePrefix("first\n"))
+		Expect(strings.Count(out, "\r\033[2K")).To(Equal(2))
+		Expect(out).To(ContainSubstring("foo #"))
+		Expect(out).To(ContainSubstring(" 50% (1.0 KiB/2.0 KiB)"))
+
+		// The bars lines are cleared before the next message.
+		buf.Reset()
+		foo.Finish()
+		mp.Println("second")
+		out = buf.String()
+		Expect(out).To(HavePrefix("\033[2A\033[Jsecond\n"))
+		Expect(strings.Count(out, "\r\033[2K")).To(Equal(1))
+		Expect(out).ToNot(ContainSubstring("foo #"))
+
+		buf.Reset()
+		mp.Stop()
+		Expect(buf.String()).To(Equal("\033[1A\033[J"))
+	})
+
+	It("Formats the progress of the bar", func() {
+		mp := NewMultiProgressWriter(&bytes.Buffer{}, false)
+		bar := mp.AddBar("foo", 1024*1024)
+		Expect(bar.String()).To(ContainSubstring("[>" + strings.Repeat(" ", 29) + "]   0% (0 B/1.0 MiB)"))
+
+		Expect(bar.Set64(2 * 1024 * 1024)).ToNot(HaveOccurred())
+		Expect(bar.String()).To(ContainSubstring("[" + strings.Repeat("=", 30) + "] 100%"))
+	})
+})
@@ -0,0 +1,8 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package repository
+
+// PruneSnapshots exposes pruneSnapshots to the tests.
+var PruneSnapshots = pruneSnapshots
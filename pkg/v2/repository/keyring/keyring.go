@@ -0,0 +1,193 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	. "github.com/geaaru/luet/pkg/logger"
+)
+
+func NewKeyring() *Keyring {
+	return &Keyring{
+		Keys: []*TrustedKey{},
+	}
+}
+
+// KeyId returns the identifier of the public key used in the
+// signature file to select the right key.
+func KeyId(k ed25519.PublicKey) string {
+	return fmt.Sprintf("%x", sha256.Sum256(k))[0:16]
+}
+
+func (k *Keyring) Len() int { return len(k.Keys) }
+
+func (k *Keyring) AddKey(file string, pub ed25519.PublicKey) {
+	k.Keys = append(k.Keys, &TrustedKey{
+		Id:   KeyId(pub),
+		File: file,
+		Key:  pub,
+	})
+}
+
+// LoadDir loads all public keys (*.pem, *.pub) available
+// in the directory.
+func (k *Keyring) LoadDir(dir string) error {
+	var regexKey = regexp.MustCompile(`.pem$|.pub$`)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !regexKey.MatchString(e.Name()) {
+			continue
+		}
+
+		file := filepath.Join(dir, e.Name())
+		pub, err := LoadPublicKey(file)
+		if err != nil {
+			Warning(fmt.Sprintf("Error on load key %s: %s", file, err.Error()))
+			continue
+		}
+
+		k.AddKey(file, pub)
+	}
+
+	return nil
+}
+
+// Verify validates the signature of the data with the
+// trusted keys of the keyring.
+func (k *Keyring) Verify(data []byte, s *DetachedSignature) error {
+	if len(k.Keys) == 0 {
+		return errors.New("no trusted keys available")
+	}
+
+	if s.Algorithm != SignatureAlgorithm {
+		return fmt.Errorf("unsupported signature algorithm %s", s.Algorithm)
+	}
+
+	sig, err := s.GetSignature()
+	if err != nil {
+		return err
+	}
+
+	for _, tk := range k.Keys {
+		if s.KeyId != "" && s.KeyId != tk.Id {
+			continue
+		}
+
+		if ed25519.Verify(tk.Key, data, sig) {
+			Debug(fmt.Sprintf("Signature verified with key %s (%s).", tk.Id, tk.File))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("signature not valid or generated by an untrusted key %s", s.KeyId)
+}
+
+// VerifyFile validates a file with the detached signature file.
+func (k *Keyring) VerifyFile(file, sigFile string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	s, err := NewDetachedSignatureFromFile(sigFile)
+	if err != nil {
+		return err
+	}
+
+	return k.Verify(data, s)
+}
+
+func LoadPublicKey(file string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM file")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("the key is not an ed25519 public key")
+	}
+
+	return pub, nil
+}
+
+func LoadPrivateKey(file string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM file")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("the key is not an ed25519 private key")
+	}
+
+	return priv, nil
+}
+
+// GenerateKeys creates a new ed25519 key pair in PEM format
+// compatible with openssl genpkey -algorithm ed25519.
+func GenerateKeys(privFile, pubFile string) error {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return err
+	}
+
+	privData, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	pubData, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(privFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: privData,
+	}), 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: pubData,
+	}), 0644)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package keyring_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/geaaru/luet/pkg/v2/repository/keyring"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Keyring", func() {
+
+	Context("Sign and verify", func() {
+
+		It("Verify a signed file", func() {
+			tmpdir, err := os.MkdirTemp(os.TempDir(), "keyring")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(tmpdir)
+
+			keysdir := filepath.Join(tmpdir, "keys")
+			Expect(os.MkdirAll(keysdir, 0755)).ToNot(HaveOccurred())
+
+			privFile := filepath.Join(tmpdir, "repo.key")
+			pubFile := filepath.Join(keysdir, "repo.pub")
+			Expect(GenerateKeys(privFile, pubFile)).ToNot(HaveOccurred())
+
+			file := filepath.Join(tmpdir, "repository.yaml")
+			Expect(os.WriteFile(file, []byte("name: test\nrevision: 1\n"), 0644)).ToNot(HaveOccurred())
+
+			priv, err := LoadPrivateKey(privFile)
+			Expect(err).ToNot(HaveOccurred())
+
+			sigFile, err := SignFile(file, priv)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sigFile).To(Equal(file + SignatureExt))
+
+			kr := NewKeyring()
+			Expect(kr.LoadDir(keysdir)).ToNot(HaveOccurred())
+			Expect(kr.Len()).To(Equal(1))
+			Expect(kr.VerifyFile(file, sigFile)).ToNot(HaveOccurred())
+
+			// Tamper the signed file
+			Expect(os.WriteFile(file, []byte("name: test\nrevision: 2\n"), 0644)).ToNot(HaveOccurred())
+			Expect(kr.VerifyFile(file, sigFile)).To(HaveOccurred())
+		})
+
+		It("Reject untrusted keys", func() {
+			tmpdir, err := os.MkdirTemp(os.TempDir(), "keyring")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(tmpdir)
+
+			Expect(GenerateKeys(
+				filepath.Join(tmpdir, "a.key"), filepath.Join(tmpdir, "a.pub"),
+			)).ToNot(HaveOccurred())
+			Expect(GenerateKeys(
+				filepath.Join(tmpdir, "b.key"), filepath.Join(tmpdir, "b.pub"),
+			)).ToNot(HaveOccurred())
+
+			file := filepath.Join(tmpdir, "repository.yaml")
+			Expect(os.WriteFile(file, []byte("name: test\n"), 0644)).ToNot(HaveOccurred())
+
+			priv, err := LoadPrivateKey(filepath.Join(tmpdir, "b.key"))
+			Expect(err).ToNot(HaveOccurred())
+			sigFile, err := SignFile(file, priv)
+			Expect(err).ToNot(HaveOccurred())
+
+			pub, err := LoadPublicKey(filepath.Join(tmpdir, "a.pub"))
+			Expect(err).ToNot(HaveOccurred())
+
+			kr := NewKeyring()
+			kr.AddKey("a.pub", pub)
+			Expect(kr.VerifyFile(file, sigFile)).To(HaveOccurred())
+
+			Expect(NewKeyring().VerifyFile(file, sigFile)).To(HaveOccurred())
+		})
+
+	})
+})
@@ -0,0 +1,45 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package keyring
+
+import (
+	"path/filepath"
+
+	"github.com/geaaru/luet/pkg/config"
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	. "github.com/geaaru/luet/pkg/logger"
+)
+
+// LoadRepositoryKeyring loads the trusted keys of a repository
+// from the directories <repos_keyringdir>/<repository-name>/.
+func LoadRepositoryKeyring(c *config.LuetConfig, repoName string) (*Keyring, error) {
+	var err error
+	ans := NewKeyring()
+	rootfs := ""
+
+	// Respect the rootfs param on read keys as for
+	// the repositories.
+	if !c.ConfigFromHost {
+		rootfs, err = c.GetSystem().GetRootFsAbs()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, kdir := range c.RepositoriesKeyring {
+		dir := filepath.Join(rootfs, kdir, repoName)
+		if !fileHelper.Exists(dir) {
+			Debug("Skip keyring dir", dir)
+			continue
+		}
+
+		err = ans.LoadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ans, nil
+}
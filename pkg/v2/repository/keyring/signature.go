@@ -0,0 +1,65 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package keyring
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+func NewDetachedSignature(data []byte, priv ed25519.PrivateKey) *DetachedSignature {
+	sig := ed25519.Sign(priv, data)
+	return &DetachedSignature{
+		Algorithm: SignatureAlgorithm,
+		KeyId:     KeyId(priv.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	}
+}
+
+func NewDetachedSignatureFromFile(file string) (*DetachedSignature, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := &DetachedSignature{}
+	if err = yaml.Unmarshal(data, ans); err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+func (s *DetachedSignature) GetSignature() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(s.Signature)
+}
+
+func (s *DetachedSignature) Write(file string) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// SignFile creates the detached signature file <file>.sig
+// of the selected file.
+func SignFile(file string, priv ed25519.PrivateKey) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	sigFile := file + SignatureExt
+	err = NewDetachedSignature(data, priv).Write(sigFile)
+	if err != nil {
+		return "", err
+	}
+
+	return sigFile, nil
+}
@@ -0,0 +1,34 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package keyring
+
+import (
+	"crypto/ed25519"
+)
+
+const (
+	SignatureExt       = ".sig"
+	SignatureAlgorithm = "ed25519"
+)
+
+// DetachedSignature is the content of the signature file
+// generated for a repository document (ex. repository.yaml.sig).
+type DetachedSignature struct {
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+	KeyId     string `json:"key_id" yaml:"key_id"`
+	Signature string `json:"signature" yaml:"signature"`
+}
+
+type TrustedKey struct {
+	Id   string
+	File string
+	Key  ed25519.PublicKey
+}
+
+// Keyring contains the list of the trusted public keys
+// used to verify the documents of a repository.
+type Keyring struct {
+	Keys []*TrustedKey
+}
@@ -6,6 +6,9 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/geaaru/luet/pkg/config"
@@ -23,11 +26,14 @@ type WagonsRails struct {
 type SyncOpts struct {
 	IgnoreErrors bool
 	Force        bool
+	// Print the packages changes of the repositories updated.
+	ShowChangelog bool
 }
 
 type ChannelRepoOpRes struct {
-	Error error
-	Repo  *config.LuetRepository
+	Error     error
+	Repo      *config.LuetRepository
+	Changelog *WagonChangelog
 }
 
 func NewWagonsRails(c *cfg.LuetConfig) *WagonsRails {
@@ -56,19 +62,20 @@ func (w *WagonsRails) processRepository(
 	if r.HasLocalWagonIdentity(repobasedir) {
 		err = r.ReadWagonIdentify(repobasedir)
 		if err != nil && (!force) {
-			channel <- ChannelRepoOpRes{err, repo}
+			channel <- ChannelRepoOpRes{err, repo, nil}
 			return
 		}
 	}
 
 	err = r.Sync(force)
 	r.ClearCatalog()
+	changelog := r.Changelog
 	r = nil
 
 	if err != nil {
-		channel <- ChannelRepoOpRes{err, repo}
+		channel <- ChannelRepoOpRes{err, repo, nil}
 	} else {
-		channel <- ChannelRepoOpRes{nil, repo}
+		channel <- ChannelRepoOpRes{nil, repo, changelog}
 	}
 	return
 }
@@ -112,16 +119,29 @@ func (w *WagonsRails) SyncRepos(repos []string, opts *SyncOpts) error {
 
 	if nOps > 0 {
 		withErr := false
+		changelogs := []*WagonChangelog{}
 		for i := 0; i < nOps; i++ {
 			resp := <-ch
 			if resp.Error != nil && !opts.IgnoreErrors {
 				withErr = true
 				Error("Error on update repository " + resp.Repo.Name + ": " + resp.Error.Error())
 			}
+			if resp.Changelog != nil {
+				changelogs = append(changelogs, resp.Changelog)
+			}
 		}
 
 		waitGroup.Wait()
 
+		if opts.ShowChangelog {
+			sort.Slice(changelogs, func(i, j int) bool {
+				return changelogs[i].Repository < changelogs[j].Repository
+			})
+			for _, c := range changelogs {
+				ShowChangelog(c)
+			}
+		}
+
 		if withErr {
 			return errors.New("Not all repositories are been synced.")
 		}
@@ -132,3 +152,31 @@ func (w *WagonsRails) SyncRepos(repos []string, opts *SyncOpts) error {
 
 	return nil
 }
+
+// ShowChangelog prints the packages changes of the repository.
+func ShowChangelog(c *WagonChangelog) {
+	aurora := GetAurora()
+
+	if c.IsEmpty() {
+		InfoC(fmt.Sprintf(
+			":scroll:Repository %s: no packages changes from revision %d to %d.",
+			aurora.Bold(c.Repository), c.FromRevision, c.ToRevision))
+		return
+	}
+
+	InfoC(fmt.Sprintf(
+		":scroll:Repository %s: changes from revision %d to %d (%d added, %d removed, %d updated):",
+		aurora.Bold(c.Repository), c.FromRevision, c.ToRevision,
+		len(c.Added), len(c.Removed), len(c.Updated)))
+
+	for _, l := range c.Lines() {
+		switch {
+		case strings.HasPrefix(l, "+"):
+			Info("  " + aurora.Green(l).String())
+		case strings.HasPrefix(l, "-"):
+			Info("  " + aurora.Red(l).String())
+		default:
+			Info("  " + aurora.Yellow(l).String())
+		}
+	}
+}
@@ -0,0 +1,270 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/geaaru/luet/pkg/v2/compiler/types/compression"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Media type of the config blob of the luet artifacts.
+	ArtifactConfigMediaType = "application/vnd.luet.artifact.config.v1+json"
+
+	// Media type of the package tarball. The compression is
+	// appended as suffix: +zstd, +gzip.
+	PackageMediaType = "application/vnd.luet.package.v1.tar"
+	// Media type of the delta tarball of a package.
+	DeltaMediaType = "application/vnd.luet.package.delta.v1.tar"
+	// Media type of the metadata yaml of a package.
+	MetadataMediaType = "application/vnd.luet.package.metadata.v1+yaml"
+	// Media type of the repository files (repository.yaml,
+	// tree tarball, signature).
+	RepositoryFileMediaType = "application/vnd.luet.repository.file.v1"
+
+	// Annotation with the filename of the blob.
+	TitleAnnotation = "org.opencontainers.image.title"
+)
+
+var invalidTagChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// TarballMediaType returns the media type of a package or
+// delta tarball with the compression in input.
+func TarballMediaType(base string, c compression.Implementation) string {
+	switch c {
+	case compression.Zstandard:
+		return base + "+zstd"
+	case compression.GZip:
+		return base + "+gzip"
+	default:
+		return base
+	}
+}
+
+// Tag returns a valid tag for the string in input.
+func Tag(s string) string {
+	ans := invalidTagChars.ReplaceAllString(s, "-")
+	ans = strings.TrimLeft(ans, ".-")
+	if len(ans) > 128 {
+		ans = ans[:128]
+	}
+	return ans
+}
+
+// ParseReference parses the reference <uri>:<tag>. The uri
+// with the prefix http:// is used as an insecure registry.
+func ParseReference(uri, tag string) (name.Reference, error) {
+	opts := []name.Option{}
+	if strings.HasPrefix(uri, "http://") {
+		opts = append(opts, name.Insecure)
+		uri = strings.TrimPrefix(uri, "http://")
+	}
+	uri = strings.TrimSuffix(strings.TrimPrefix(uri, "https://"), "/")
+
+	return name.ParseReference(fmt.Sprintf("%s:%s", uri, Tag(tag)), opts...)
+}
+
+// RemoteOptions returns the options of the registry client from the
+// authentication of the repository. The keys are the same used by
+// the docker repositories: username, password, auth, identitytoken
+// and registrytoken. Without keys the docker config file is used.
+func RemoteOptions(auth map[string]string) []remote.Option {
+	cfg := authn.AuthConfig{}
+	if len(auth) > 0 {
+		dat, _ := json.Marshal(auth)
+		json.Unmarshal(dat, &cfg)
+	}
+
+	if cfg == (authn.AuthConfig{}) {
+		return []remote.Option{remote.WithAuthFromKeychain(authn.DefaultKeychain)}
+	}
+
+	return []remote.Option{remote.WithAuth(authn.FromConfig(cfg))}
+}
+
+// Blob is a file stored as a layer of an OCI artifact.
+type Blob struct {
+	Path      string
+	Title     string
+	MediaType string
+}
+
+func NewBlob(path, mediaType string) *Blob {
+	return &Blob{
+		Path:      path,
+		Title:     filepath.Base(path),
+		MediaType: mediaType,
+	}
+}
+
+// fileLayer implements the v1.Layer interface for a file
+// pushed without changes.
+type fileLayer struct {
+	path      string
+	digest    v1.Hash
+	size      int64
+	mediaType types.MediaType
+}
+
+func newFileLayer(b *Blob) (*fileLayer, error) {
+	f, err := os.Open(b.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error on read "+b.Path)
+	}
+
+	return &fileLayer{
+		path: b.Path,
+		digest: v1.Hash{
+			Algorithm: "sha256",
+			Hex:       hex.EncodeToString(h.Sum(nil)),
+		},
+		size:      size,
+		mediaType: types.MediaType(b.MediaType),
+	}, nil
+}
+
+func (l *fileLayer) Digest() (v1.Hash, error)             { return l.digest, nil }
+func (l *fileLayer) DiffID() (v1.Hash, error)             { return l.digest, nil }
+func (l *fileLayer) Size() (int64, error)                 { return l.size, nil }
+func (l *fileLayer) MediaType() (types.MediaType, error)  { return l.mediaType, nil }
+func (l *fileLayer) Compressed() (io.ReadCloser, error)   { return os.Open(l.path) }
+func (l *fileLayer) Uncompressed() (io.ReadCloser, error) { return os.Open(l.path) }
+
+// NewArtifact returns the OCI image manifest with the blobs
+// as layers and the luet config media type.
+func NewArtifact(blobs []*Blob) (v1.Image, error) {
+	img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, ArtifactConfigMediaType)
+
+	adds := []mutate.Addendum{}
+	for _, b := range blobs {
+		l, err := newFileLayer(b)
+		if err != nil {
+			return nil, err
+		}
+		adds = append(adds, mutate.Addendum{
+			Layer:     l,
+			MediaType: l.mediaType,
+			Annotations: map[string]string{
+				TitleAnnotation: b.Title,
+			},
+		})
+	}
+
+	return mutate.Append(img, adds...)
+}
+
+// Push pushes the blobs as an OCI artifact with the reference in input.
+func Push(ref name.Reference, blobs []*Blob, opts ...remote.Option) error {
+	img, err := NewArtifact(blobs)
+	if err != nil {
+		return err
+	}
+
+	return remote.Write(ref, img, opts...)
+}
+
+// Exists returns true if the reference is available on the registry.
+func Exists(ref name.Reference, opts ...remote.Option) bool {
+	_, err := remote.Head(ref, opts...)
+	return err == nil
+}
+
+// Pull downloads the blob with the title in input of the artifact
+// to the file dst. The content is verified with the digest of the
+// manifest while it's downloaded. If the sha256 in input is not empty
+// it's compared with the digest of the blob.
+func Pull(ref name.Reference, title, sha256sum, dst string, opts ...remote.Option) (*v1.Descriptor, error) {
+	img, err := remote.Image(ref, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest.Config.MediaType != ArtifactConfigMediaType {
+		return nil, fmt.Errorf("%s is not a luet artifact (config media type %s)",
+			ref.String(), manifest.Config.MediaType)
+	}
+
+	var desc *v1.Descriptor
+	for idx, l := range manifest.Layers {
+		if l.Annotations[TitleAnnotation] == title {
+			desc = &manifest.Layers[idx]
+			break
+		}
+	}
+	if desc == nil {
+		return nil, fmt.Errorf("Blob %s not found on %s", title, ref.String())
+	}
+
+	if sha256sum != "" &&
+		(desc.Digest.Algorithm != "sha256" || desc.Digest.Hex != sha256sum) {
+		return nil, fmt.Errorf(
+			"Digest %s of the blob %s doesn't match with the checksum %s",
+			desc.Digest.String(), title, sha256sum)
+	}
+
+	layer, err := img.LayerByDigest(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	// The reader of the remote layers fails on EOF if the
+	// digest doesn't match.
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(out, rc)
+	if err != nil {
+		out.Close()
+		os.Remove(dst)
+		return nil, errors.Wrapf(err, "Error on download blob %s", title)
+	}
+
+	err = out.Close()
+	if err != nil {
+		os.Remove(dst)
+		return nil, err
+	}
+
+	return desc, nil
+}
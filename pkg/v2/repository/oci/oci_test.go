@@ -0,0 +1,110 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package oci_test
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	fileHelper "github.com/geaaru/luet/pkg/helpers/file"
+	"github.com/geaaru/luet/pkg/v2/compiler/types/compression"
+	. "github.com/geaaru/luet/pkg/v2/repository/oci"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OCI", func() {
+
+	Context("Tags", func() {
+		It("Sanitizes tags", func() {
+			Expect(Tag("foo-cat-1.0+2")).To(Equal("foo-cat-1.0-2"))
+			Expect(Tag("repository.yaml")).To(Equal("repository.yaml"))
+			Expect(TarballMediaType(PackageMediaType, compression.Zstandard)).To(
+				Equal("application/vnd.luet.package.v1.tar+zstd"))
+		})
+	})
+
+	Context("Registry", func() {
+		var tmpdir, uri string
+		var srv *httptest.Server
+		opts := []remote.Option{}
+
+		BeforeEach(func() {
+			var err error
+			tmpdir, err = os.MkdirTemp("", "oci")
+			Expect(err).ToNot(HaveOccurred())
+
+			srv = httptest.NewServer(registry.New(
+				registry.Logger(log.New(io.Discard, "", 0))))
+			uri = strings.TrimPrefix(srv.URL, "http://") + "/luet/repo"
+
+			err = os.WriteFile(filepath.Join(tmpdir, "foo-cat-1.0.package.tar.zst"),
+				[]byte("package content"), 0644)
+			Expect(err).ToNot(HaveOccurred())
+			err = os.WriteFile(filepath.Join(tmpdir, "foo-cat-1.0.metadata.yaml"),
+				[]byte("path: foo-cat-1.0.package.tar.zst\n"), 0644)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			srv.Close()
+			os.RemoveAll(tmpdir)
+		})
+
+		It("Pushes and pulls blobs", func() {
+			ref, err := ParseReference(uri, "foo-cat-1.0")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(Exists(ref, opts...)).To(BeFalse())
+
+			err = Push(ref, []*Blob{
+				NewBlob(filepath.Join(tmpdir, "foo-cat-1.0.package.tar.zst"),
+					TarballMediaType(PackageMediaType, compression.Zstandard)),
+				NewBlob(filepath.Join(tmpdir, "foo-cat-1.0.metadata.yaml"),
+					MetadataMediaType),
+			}, opts...)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(Exists(ref, opts...)).To(BeTrue())
+
+			sha, err := fileHelper.Sha256Sum(filepath.Join(tmpdir, "foo-cat-1.0.package.tar.zst"))
+			Expect(err).ToNot(HaveOccurred())
+
+			dst := filepath.Join(tmpdir, "pulled")
+			desc, err := Pull(ref, "foo-cat-1.0.package.tar.zst", sha, dst, opts...)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(desc.Digest.Hex).To(Equal(sha))
+			Expect(string(desc.MediaType)).To(Equal("application/vnd.luet.package.v1.tar+zstd"))
+
+			data, err := os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal("package content"))
+		})
+
+		It("Rejects blobs with a different checksum", func() {
+			ref, err := ParseReference(uri, "foo-cat-1.0")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = Push(ref, []*Blob{
+				NewBlob(filepath.Join(tmpdir, "foo-cat-1.0.package.tar.zst"), PackageMediaType),
+			}, opts...)
+			Expect(err).ToNot(HaveOccurred())
+
+			dst := filepath.Join(tmpdir, "pulled")
+			_, err = Pull(ref, "foo-cat-1.0.package.tar.zst",
+				strings.Repeat("0", 64), dst, opts...)
+			Expect(err).To(HaveOccurred())
+			Expect(fileHelper.Exists(dst)).To(BeFalse())
+
+			_, err = Pull(ref, "missing.tar", "", dst, opts...)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,20 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package repository_test
+
+import (
+	"testing"
+
+	. "github.com/geaaru/luet/pkg/logger"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestRepository(t *testing.T) {
+	RegisterFailHandler(Fail)
+	InitAurora()
+	RunSpecs(t, "Repository Suite")
+}
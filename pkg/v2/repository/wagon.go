@@ -16,6 +16,7 @@ import (
 	. "github.com/geaaru/luet/pkg/logger"
 	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
 	"github.com/geaaru/luet/pkg/v2/repository/client"
+	"github.com/geaaru/luet/pkg/v2/repository/keyring"
 	"github.com/geaaru/luet/pkg/v2/repository/mask"
 	"github.com/geaaru/luet/pkg/v2/tree"
 
@@ -25,6 +26,7 @@ import (
 const (
 	REPOSITORY_METAFILE  = "repository.meta.yaml"
 	REPOSITORY_SPECFILE  = "repository.yaml"
+	REPOSITORY_SIGFILE   = "repository.yaml.sig"
 	TREE_TARBALL         = "tree.tar"
 	COMPILERTREE_TARBALL = "compilertree.tar"
 
@@ -37,6 +39,7 @@ const (
 	DiskRepositoryType   = "disk"
 	HttpRepositoryType   = "http"
 	DockerRepositoryType = "docker"
+	OciRepositoryType    = "oci"
 )
 
 type Client interface {
@@ -47,6 +50,9 @@ type Client interface {
 type WagonRepository struct {
 	Identity *WagonIdentity
 	Stones   *WagonStones
+
+	// Changes of the packages of the last sync.
+	Changelog *WagonChangelog
 }
 
 func NewWagonRepository(l *config.LuetRepository) *WagonRepository {
@@ -99,6 +105,7 @@ func (w *WagonRepository) ReadWagonIdentify(wdir string) error {
 	repoPriority := w.Identity.Priority
 	repoAuthentication := w.Identity.Authentication
 	repoType := w.Identity.GetType()
+	repoVerify := w.Identity.GetVerify()
 
 	err := w.Identity.Load(file)
 	if err != nil {
@@ -110,6 +117,7 @@ func (w *WagonRepository) ReadWagonIdentify(wdir string) error {
 	w.Identity.Priority = repoPriority
 	w.Identity.Authentication = repoAuthentication
 	w.Identity.Type = repoType
+	w.Identity.Verify = repoVerify
 
 	return nil
 }
@@ -135,6 +143,10 @@ func (w *WagonRepository) ClearCatalog() {
 //
 // In particular, follow these steps:
 //   - download the main repository.yaml file to a temporary directory
+//   - if the repository has verify enabled, download the detached
+//     signature repository.yaml.sig and validate it with the trusted keys.
+//     The checksums of the tree and meta documents are read from
+//     the signed file.
 //   - load the new repository.yaml as WagonIdentity and compare revision
 //     and last update date with the current status.
 //   - if there is a new revision download the meta and tree file
@@ -165,21 +177,32 @@ func (w *WagonRepository) Sync(force bool) error {
 		return errors.Wrap(err, "While downloading "+REPOSITORY_SPECFILE)
 	}
 
+	// Remove temporary file that contains repository.yaml
+	// Example: /tmp/HttpClient236052003
+	defer os.RemoveAll(file)
+
+	verify := w.Identity.GetVerify()
+	if verify {
+		err = w.verifyIdentityFile(c, file)
+		if err != nil {
+			return errors.Wrap(err,
+				"Signature verification failed for "+REPOSITORY_SPECFILE)
+		}
+	}
+
 	repobasedir := config.LuetCfg.GetSystem().GetRepoDatabaseDirPath(w.Identity.Name)
 	newIdentity := NewWagonIdentify(w.Identity.LuetRepository.Clone())
 	err = newIdentity.Load(file)
 	if err != nil {
 		return err
 	}
+	// The verify option is a client option.
+	newIdentity.SetVerify(verify)
 
 	if !newIdentity.Valid() {
 		return errors.New("Corrupted remote repository.yaml file")
 	}
 
-	// Remove temporary file that contains repository.yaml
-	// Example: /tmp/HttpClient236052003
-	defer os.RemoveAll(file)
-
 	toUpdate := w.Identity.Is2Update(newIdentity)
 
 	if w.Identity.GetTreePath() == "" {
@@ -237,6 +260,19 @@ func (w *WagonRepository) Sync(force bool) error {
 		if err != nil {
 			return errors.Wrap(err, "Error on update "+REPOSITORY_SPECFILE)
 		}
+		// Store the packages of the previous tree to compute
+		// the changelog of the new revision.
+		var prevSnapshot *WagonSnapshot
+		if fileHelper.Exists(treefs) {
+			prevSnapshot, err = NewWagonSnapshotFromTreefs(treefs,
+				w.Identity.GetRevision(), w.Identity.GetLastUpdate())
+			if err != nil {
+				Warning(fmt.Sprintf("[%s] Error on read previous tree: %s",
+					w.Identity.GetName(), err.Error()))
+				prevSnapshot = nil
+			}
+		}
+
 		// Remove previous tree
 		os.RemoveAll(treefs)
 		// Remove previous meta dir
@@ -272,6 +308,12 @@ func (w *WagonRepository) Sync(force bool) error {
 
 		w.Identity = newIdentity
 
+		err = w.writeSnapshots(repobasedir, treefs, prevSnapshot)
+		if err != nil {
+			Warning(fmt.Sprintf("[%s] Error on store the revision snapshot: %s",
+				w.Identity.GetName(), err.Error()))
+		}
+
 		if !repoV2 {
 			// Build metadata for package. This will be handled from a new tarball
 			// in the near future. In particolar, i will write a metadata.yaml file
@@ -301,6 +343,35 @@ func (w *WagonRepository) Sync(force bool) error {
 	return nil
 }
 
+// verifyIdentityFile downloads the detached signature of the
+// repository.yaml and validates it with the repository keyring.
+func (w *WagonRepository) verifyIdentityFile(c Client, file string) error {
+	kr, err := keyring.LoadRepositoryKeyring(config.LuetCfg, w.Identity.GetName())
+	if err != nil {
+		return err
+	}
+	if kr.Len() == 0 {
+		return fmt.Errorf("no trusted keys found for repository %s",
+			w.Identity.GetName())
+	}
+
+	sigFile, err := c.DownloadFile(REPOSITORY_SIGFILE)
+	if err != nil {
+		return errors.Wrap(err, "While downloading "+REPOSITORY_SIGFILE)
+	}
+	defer os.RemoveAll(sigFile)
+
+	err = kr.VerifyFile(file, sigFile)
+	if err != nil {
+		return err
+	}
+
+	Debug(fmt.Sprintf("[%s] Signature of %s verified.",
+		w.Identity.GetName(), REPOSITORY_SPECFILE))
+
+	return nil
+}
+
 func (w *WagonRepository) ExplodeMetadata() error {
 	w.ClearCatalog()
 
@@ -445,6 +516,8 @@ func (w *WagonRepository) Client() Client {
 		return client.NewHttpClient(w.Identity.LuetRepository)
 	case DockerRepositoryType:
 		return client.NewDockerClient(w.Identity.LuetRepository)
+	case OciRepositoryType:
+		return client.NewOciClient(w.Identity.LuetRepository)
 	}
 	return nil
 }
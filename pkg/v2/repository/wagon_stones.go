@@ -79,6 +79,9 @@ type Stone struct {
 	Labels      map[string]string      `json:"labels,omitempty" yaml:"labels,omitempty"`
 	UseFlags    []string               `json:"use_flags,omitempty" yaml:"use_flags,omitempty"`
 
+	// Install reason (explicit/dep) of the installed packages.
+	InstallReason string `json:"install_reason,omitempty" yaml:"install_reason,omitempty"`
+
 	Provides  []*Stone `json:"provides,omitempty" yaml:"provides,omitempty"`
 	Requires  []*Stone `json:"requires,omitempty" yaml:"requires,omitempty"`
 	Conflicts []*Stone `json:"conflicts,omitempty" yaml:"conflicts,omitempty"`
@@ -169,6 +172,8 @@ func NewStone(p *artifact.PackageArtifact, repo string, withFiles, full bool) *S
 		Labels:      p.Runtime.Labels,
 	}
 
+	ans.InstallReason = p.Runtime.GetInstallReason()
+
 	if withFiles {
 		ans.Files = p.Files
 	}
@@ -236,6 +241,10 @@ func (s *Stone) ToPackage() *pkg.DefaultPackage {
 	return ans
 }
 
+func (s *Stone) IsExplicit() bool {
+	return s.InstallReason == pkg.InstallReasonExplicit
+}
+
 func (s *Stone) HumanReadableString() string {
 	return fmt.Sprintf("%s/%s-%s", s.Category, s.Name, s.Version)
 }
@@ -0,0 +1,753 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package solver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+)
+
+const (
+	// Default number of candidates evaluated by the backtracking
+	// search before to give up.
+	DefaultBacktrackMaxSteps = 10000
+)
+
+var errBacktrackBudget = errors.New("backtracking steps exhausted")
+
+// ConflictError is returned when the backtracking search doesn't
+// find a solution. Packages is the minimal set of the requested
+// packages that could not be installed together.
+type ConflictError struct {
+	Packages []string
+	Reasons  []string
+}
+
+func (e *ConflictError) Error() string {
+	ans := fmt.Sprintf("No solution found for the packages %s",
+		strings.Join(e.Packages, ", "))
+	if len(e.Reasons) > 0 {
+		ans += ":\n  - " + strings.Join(e.Reasons, "\n  - ")
+	}
+	return ans
+}
+
+type btRequire struct {
+	req *pkg.DefaultPackage
+	by  string
+}
+
+// backtracker explores the candidate versions of the packages
+// with a depth-first search. The installed packages are immutable
+// except for the movable packages that could be upgraded.
+type backtracker struct {
+	s *Solver
+
+	assigned map[string]*artifact.PackageArtifact
+	// Map of the names provided by the assigned artifacts.
+	provided map[string]*artifact.PackageArtifact
+	// Map of the names provided by the installed packages.
+	sysProvides map[string]*pkg.DefaultPackage
+	// Packages searched on repositories with all versions.
+	searched map[string][]*artifact.PackageArtifact
+
+	// Installed packages that could be upgraded.
+	movable map[string]bool
+	// Artifacts that keep the installed version of a movable package.
+	kept map[string]*artifact.PackageArtifact
+	// Movable packages replaced by an artifact through provides.
+	replaces map[*artifact.PackageArtifact]string
+
+	steps    int
+	maxSteps int
+	// Reasons of the failure of the last branch.
+	reasons []string
+}
+
+func (s *Solver) newBacktracker() *backtracker {
+	maxSteps := s.Config.GetSolverOptions().BacktrackMaxSteps
+	if maxSteps <= 0 {
+		maxSteps = DefaultBacktrackMaxSteps
+	}
+
+	ans := &backtracker{
+		s:           s,
+		assigned:    make(map[string]*artifact.PackageArtifact, 0),
+		provided:    make(map[string]*artifact.PackageArtifact, 0),
+		sysProvides: make(map[string]*pkg.DefaultPackage, 0),
+		searched:    make(map[string][]*artifact.PackageArtifact, 0),
+		movable:     make(map[string]bool, 0),
+		kept:        make(map[string]*artifact.PackageArtifact, 0),
+		replaces:    make(map[*artifact.PackageArtifact]string, 0),
+		maxSteps:    maxSteps,
+	}
+
+	for _, pp := range s.systemMap.Packages {
+		for _, prov := range pp[0].GetProvides() {
+			ans.sysProvides[prov.PackageName()] = pp[0]
+		}
+	}
+
+	return ans
+}
+
+func (b *backtracker) reset() {
+	b.assigned = make(map[string]*artifact.PackageArtifact, 0)
+	b.provided = make(map[string]*artifact.PackageArtifact, 0)
+	b.replaces = make(map[*artifact.PackageArtifact]string, 0)
+	b.reasons = []string{}
+}
+
+func (b *backtracker) isKept(art *artifact.PackageArtifact) bool {
+	k, ok := b.kept[art.GetPackage().PackageName()]
+	return ok && k == art
+}
+
+func (b *backtracker) fail(reason string) {
+	for _, r := range b.reasons {
+		if r == reason {
+			return
+		}
+	}
+	b.reasons = append(b.reasons, reason)
+}
+
+// relates returns true if the package x has requires or conflicts
+// related to the package y or to its provides.
+func relates(x, y *pkg.DefaultPackage) bool {
+	match := func(d *pkg.DefaultPackage) bool {
+		if d.AtomMatches(y) {
+			return true
+		}
+		for _, prov := range y.GetProvides() {
+			if d.AtomMatches(prov) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, r := range x.PackageRequires {
+		if match(r) {
+			return true
+		}
+	}
+	for _, c := range x.PackageConflicts {
+		if match(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAdmit checks if the package p satisfies the requirement.
+func requireAdmit(req, p *pkg.DefaultPackage) bool {
+	virt := &pkg.DefaultPackage{
+		PackageRequires: []*pkg.DefaultPackage{req},
+	}
+	admit, _ := virt.Admit(p)
+	return admit
+}
+
+// candidates returns the artifacts with the package or a provides
+// that match the requirement sorted by version and repositories.
+func (b *backtracker) candidates(req *pkg.DefaultPackage) ([]*artifact.PackageArtifact, error) {
+	name := req.PackageName()
+
+	all, ok := b.searched[name]
+	if !ok {
+		searchOpts := &wagon.StonesSearchOpts{
+			Packages: []*pkg.DefaultPackage{
+				pkg.NewPackageWithCatThin(req.Category, req.Name, ">=0"),
+			},
+			Categories:       []string{},
+			Labels:           []string{},
+			LabelsMatches:    []string{},
+			Matches:          []string{},
+			FilesOwner:       []string{},
+			Annotations:      []string{},
+			Hidden:           false,
+			AndCondition:     false,
+			WithFiles:        true,
+			WithRootfsPrefix: false,
+			Full:             true,
+			OnlyPackages:     true,
+			IgnoreMasks:      b.s.Opts.IgnoreMasks,
+		}
+		arts, err := b.s.searchArtifacts(searchOpts)
+		if err != nil {
+			return nil, err
+		}
+		if b.s.MapRepos != nil {
+			wagon.SortArtifactList4VersionAndRepos(arts, &b.s.MapRepos, true)
+		}
+		all = *arts
+		b.searched[name] = all
+	}
+
+	ans := []*artifact.PackageArtifact{}
+	for _, a := range all {
+		p := a.GetPackage()
+		if p.PackageName() != name && p.GetProvidePackage(name) == nil {
+			continue
+		}
+		if requireAdmit(req, p) {
+			ans = append(ans, a)
+		}
+	}
+
+	return ans, nil
+}
+
+// upgradeCandidates returns the artifacts that could replace the
+// installed package with a newer version admitted by the holds.
+// The installed version is the last choice.
+func (b *backtracker) upgradeCandidates(req, installed *pkg.DefaultPackage) ([]*artifact.PackageArtifact, error) {
+	cands, err := b.candidates(req)
+	if err != nil {
+		return nil, err
+	}
+
+	gpI, err := installed.ToGentooPackage()
+	if err != nil {
+		return nil, err
+	}
+
+	ans := []*artifact.PackageArtifact{}
+	for _, a := range cands {
+		ap := a.GetPackage()
+		if !ap.AtomMatches(installed) {
+			ap = ap.GetProvidePackage(installed.PackageName())
+			if ap == nil {
+				continue
+			}
+		}
+
+		gp, err := ap.ToGentooPackage()
+		if err != nil {
+			return nil, err
+		}
+		if newer, _ := gp.GreaterThan(gpI); !newer {
+			continue
+		}
+
+		admit, err := b.s.artefactAdmitByHold(installed.PackageName(), a)
+		if err != nil {
+			return nil, err
+		}
+		if !admit {
+			continue
+		}
+		if held, _ := b.s.artefactReplacesHold(a); held != nil &&
+			held.PackageName() != installed.PackageName() {
+			continue
+		}
+
+		ans = append(ans, a)
+	}
+
+	if requireAdmit(req, installed) {
+		keep, ok := b.kept[installed.PackageName()]
+		if !ok {
+			keep = &artifact.PackageArtifact{Runtime: installed}
+			b.kept[installed.PackageName()] = keep
+		}
+		ans = append(ans, keep)
+	}
+
+	return ans, nil
+}
+
+// consistent checks the candidate with the installed packages
+// and the artifacts already assigned.
+func (b *backtracker) consistent(art *artifact.PackageArtifact) bool {
+	p := art.GetPackage()
+	kept := b.isKept(art)
+
+	if !kept && !b.s.Opts.IgnoreConflicts && b.s.artefactIsInConflict(art) {
+		b.fail(fmt.Sprintf("%s is in conflict with the installed packages",
+			p.HumanReadableString()))
+		return false
+	}
+
+	// The installed packages not upgraded must admit the new version.
+	if !kept && b.s.requiresMap != nil {
+		names := []string{p.PackageName()}
+		for _, prov := range p.GetProvides() {
+			names = append(names, prov.PackageName())
+		}
+		for _, name := range names {
+			for _, user := range b.s.requiresMap.Packages[name] {
+				if b.movable[user.PackageName()] {
+					continue
+				}
+				if admit, _ := user.Admit(p); !admit {
+					b.fail(fmt.Sprintf("%s is not admitted by the installed %s",
+						p.HumanReadableString(), user.HumanReadableString()))
+					return false
+				}
+			}
+		}
+	}
+
+	for _, a := range b.assigned {
+		if !relates(a.GetPackage(), p) && !relates(p, a.GetPackage()) {
+			continue
+		}
+		if admit, _ := a.GetPackage().Admit(p); !admit {
+			b.fail(fmt.Sprintf("%s doesn't admit %s",
+				a.GetPackage().HumanReadableString(), p.HumanReadableString()))
+			return false
+		}
+		if admit, _ := p.Admit(a.GetPackage()); !admit {
+			b.fail(fmt.Sprintf("%s doesn't admit %s",
+				p.HumanReadableString(), a.GetPackage().HumanReadableString()))
+			return false
+		}
+	}
+
+	return true
+}
+
+func (b *backtracker) assign(art *artifact.PackageArtifact) {
+	p := art.GetPackage()
+	b.assigned[p.PackageName()] = art
+	for _, prov := range p.GetProvides() {
+		if _, present := b.provided[prov.PackageName()]; !present {
+			b.provided[prov.PackageName()] = art
+		}
+	}
+}
+
+func (b *backtracker) unassign(art *artifact.PackageArtifact) {
+	p := art.GetPackage()
+	delete(b.assigned, p.PackageName())
+	for _, prov := range p.GetProvides() {
+		if a, present := b.provided[prov.PackageName()]; present && a == art {
+			delete(b.provided, prov.PackageName())
+		}
+	}
+}
+
+func (b *backtracker) solve(agenda []*btRequire) (bool, error) {
+	if len(agenda) == 0 {
+		return true, nil
+	}
+
+	r := agenda[0]
+	rest := agenda[1:]
+	name := r.req.PackageName()
+
+	// The installed packages are not replaced.
+	val, installed := b.s.systemMap.Packages[name]
+	if installed && !b.movable[name] {
+		if requireAdmit(r.req, val[0]) {
+			return b.solve(rest)
+		}
+		b.fail(fmt.Sprintf("%s requires %s but is installed %s",
+			r.by, selectorString(r.req), val[0].HumanReadableString()))
+		return false, nil
+	}
+	if sp, ok := b.sysProvides[name]; ok && !b.movable[sp.PackageName()] &&
+		requireAdmit(r.req, sp) {
+		return b.solve(rest)
+	}
+
+	if a, ok := b.assigned[name]; ok {
+		if requireAdmit(r.req, a.GetPackage()) {
+			return b.solve(rest)
+		}
+		b.fail(fmt.Sprintf("%s requires %s but is selected %s",
+			r.by, selectorString(r.req), a.GetPackage().HumanReadableString()))
+		return false, nil
+	}
+	if a, ok := b.provided[name]; ok {
+		if requireAdmit(r.req, a.GetPackage()) {
+			return b.solve(rest)
+		}
+		b.fail(fmt.Sprintf("%s requires %s but is provided by %s",
+			r.by, selectorString(r.req), a.GetPackage().HumanReadableString()))
+		return false, nil
+	}
+
+	var cands []*artifact.PackageArtifact
+	var err error
+	if installed {
+		cands, err = b.upgradeCandidates(r.req, val[0])
+	} else {
+		cands, err = b.candidates(r.req)
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(cands) == 0 {
+		if r.by != "" {
+			b.fail(fmt.Sprintf("%s requires %s that is not available",
+				r.by, selectorString(r.req)))
+		} else {
+			b.fail(fmt.Sprintf("%s is not available", selectorString(r.req)))
+		}
+		return false, nil
+	}
+
+	for _, art := range cands {
+		b.steps++
+		if b.steps > b.maxSteps {
+			return false, errBacktrackBudget
+		}
+
+		if !b.consistent(art) {
+			continue
+		}
+
+		b.assign(art)
+		if installed && art.GetPackage().PackageName() != name {
+			b.replaces[art] = name
+		}
+
+		next := rest
+		if !b.s.Opts.NoDeps && !b.isKept(art) {
+			p := art.GetPackage()
+			next = make([]*btRequire, 0, len(rest)+len(p.PackageRequires))
+			for _, dep := range p.PackageRequires {
+				next = append(next, &btRequire{
+					req: dep,
+					by:  p.HumanReadableString(),
+				})
+			}
+			next = append(next, rest...)
+		}
+
+		ok, err := b.solve(next)
+		if err != nil || ok {
+			return ok, err
+		}
+
+		b.unassign(art)
+		delete(b.replaces, art)
+	}
+
+	return false, nil
+}
+
+func (b *backtracker) solveRequests(pkgs []*pkg.DefaultPackage) (bool, error) {
+	b.reset()
+	agenda := []*btRequire{}
+	for _, p := range pkgs {
+		agenda = append(agenda, &btRequire{req: p})
+	}
+	return b.solve(agenda)
+}
+
+// minimalConflict reduces the packages in input to a minimal set
+// that doesn't admit a solution. Every package of the set is
+// needed to reproduce the conflict. It must be called after the
+// failed resolution of all the packages in input to use its reasons
+// when the steps are exhausted.
+func (b *backtracker) minimalConflict(pkgs []*pkg.DefaultPackage) ([]*pkg.DefaultPackage, []string) {
+	set := append([]*pkg.DefaultPackage{}, pkgs...)
+	reasons := b.reasons
+
+	for i := 0; i < len(set) && len(set) > 1; {
+		subset := append(append([]*pkg.DefaultPackage{}, set[:i]...), set[i+1:]...)
+		ok, err := b.solveRequests(subset)
+		if err != nil {
+			// POST: steps exhausted. I return the current set.
+			break
+		}
+		if ok {
+			// The package is needed to reproduce the conflict.
+			i++
+		} else {
+			set = subset
+			reasons = b.reasons
+		}
+	}
+
+	return set, reasons
+}
+
+// run searches a solution for the packages in input and returns
+// a ConflictError with the minimal set of the packages in conflict
+// when there isn't a solution.
+func (b *backtracker) run(pkgs []*pkg.DefaultPackage) error {
+	Debug(fmt.Sprintf(":brain:Starting backtracking search for %d packages...", len(pkgs)))
+
+	ok, err := b.solveRequests(pkgs)
+	if err == errBacktrackBudget {
+		return fmt.Errorf(
+			"No solution found in %d steps. Increase solver.backtrack_max_steps to explore more candidates.",
+			b.maxSteps)
+	} else if err != nil {
+		return err
+	}
+
+	if !ok {
+		b.steps = 0
+		set, reasons := b.minimalConflict(pkgs)
+		names := []string{}
+		for _, p := range set {
+			names = append(names, selectorString(p))
+		}
+		return &ConflictError{
+			Packages: names,
+			Reasons:  reasons,
+		}
+	}
+
+	Debug(fmt.Sprintf(":brain:Backtracking solution found in %d steps.", b.steps))
+
+	return nil
+}
+
+// backtrackInstall resolves the packages to install exploring
+// the older versions of the candidates. It's used when the greedy
+// resolution fails.
+func (s *Solver) backtrackInstall(pkgs []*pkg.DefaultPackage) error {
+	b := s.newBacktracker()
+
+	if err := b.run(pkgs); err != nil {
+		return err
+	}
+
+	s.candidatesMap = artifact.NewArtifactsMap()
+	for name, art := range b.assigned {
+		s.explain(name, art, ExplainBacktrack,
+			fmt.Sprintf("selected by backtracking in %d steps", b.steps), nil)
+		s.candidatesMap.Artifacts[name] = []*artifact.PackageArtifact{art}
+	}
+
+	return nil
+}
+
+// backtrackUpgrade resolves the upgrade of the installed packages
+// in input exploring the combinations of the new versions and of
+// the installed versions. It's used when the candidates selected by
+// the greedy resolution are not valid together.
+func (s *Solver) backtrackUpgrade(pkgnames []string) error {
+	b := s.newBacktracker()
+
+	reqs := []*pkg.DefaultPackage{}
+	for _, name := range pkgnames {
+		val, ok := s.systemMap.Packages[name]
+		if !ok {
+			continue
+		}
+		b.movable[name] = true
+		reqs = append(reqs, pkg.NewPackageWithCatThin(
+			val[0].GetCategory(), val[0].GetName(), ">=0"))
+	}
+
+	if err := b.run(reqs); err != nil {
+		return err
+	}
+
+	s.candidatesMap = artifact.NewArtifactsMap()
+	for name, art := range b.assigned {
+		if b.isKept(art) {
+			continue
+		}
+		// The candidates that replace an installed package through
+		// provides are stored with the name of the replaced package.
+		if replaced, ok := b.replaces[art]; ok {
+			name = replaced
+		}
+		s.explain(name, art, ExplainBacktrack,
+			fmt.Sprintf("selected by backtracking in %d steps", b.steps), nil)
+		s.candidatesMap.Artifacts[name] = []*artifact.PackageArtifact{art}
+	}
+
+	return nil
+}
+
+// checkCandidatesConsistency verifies that the requires and the
+// conflicts of the candidates selected by the greedy resolution
+// are satisfied by the installed packages and the other candidates.
+func (s *Solver) checkCandidatesConsistency() error {
+	selected := make(map[string]*pkg.DefaultPackage, 0)
+	for name, arts := range s.candidatesMap.Artifacts {
+		p := arts[0].GetPackage()
+		selected[name] = p
+		for _, prov := range p.GetProvides() {
+			if _, present := selected[prov.PackageName()]; !present {
+				selected[prov.PackageName()] = p
+			}
+		}
+	}
+
+	// The candidates replace the installed versions on upgrade.
+	lookup := func(name string) *pkg.DefaultPackage {
+		if p, ok := selected[name]; ok {
+			return p
+		}
+		if val, ok := s.systemMap.Packages[name]; ok {
+			return val[0]
+		}
+		return nil
+	}
+
+	for _, arts := range s.candidatesMap.Artifacts {
+		p := arts[0].GetPackage()
+
+		if !s.Opts.NoDeps {
+			for _, r := range p.PackageRequires {
+				d := lookup(r.PackageName())
+				if d == nil {
+					// POST: the dependency could be provided by an installed package.
+					continue
+				}
+				if !requireAdmit(r, d) {
+					return fmt.Errorf("%s requires %s but is selected %s",
+						p.HumanReadableString(), selectorString(r), d.HumanReadableString())
+				}
+			}
+		}
+
+		for _, c := range p.PackageConflicts {
+			d := lookup(c.PackageName())
+			if d == nil {
+				continue
+			}
+			if admit, _ := p.Admit(d); !admit {
+				return fmt.Errorf("%s is in conflict with %s",
+					p.HumanReadableString(), d.HumanReadableString())
+			}
+		}
+
+		// The installed packages not upgraded must admit the candidate.
+		if s.requiresMap != nil && !s.Opts.NoDeps {
+			for _, user := range s.requiresMap.Packages[p.PackageName()] {
+				if _, present := s.candidatesMap.Artifacts[user.PackageName()]; present {
+					continue
+				}
+				if admit, _ := user.Admit(p); !admit {
+					return fmt.Errorf("%s is not admitted by the installed %s",
+						p.HumanReadableString(), user.HumanReadableString())
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// solverJournal stores the previous values of the keys of the
+// candidates, of the available artifacts and of the provides changed
+// after a checkpoint. In this way a candidate rejected after the
+// resolution of a part of its dependencies is dropped without copying
+// the maps for every candidate.
+type solverJournal struct {
+	entries []*journalEntry
+	// Number of the active checkpoints. Without checkpoints the
+	// changes are not recorded.
+	depth int
+}
+
+const (
+	journalCandidates = iota
+	journalAvailable
+	journalProvides
+)
+
+type journalEntry struct {
+	kind    int
+	key     string
+	present bool
+	arts    []*artifact.PackageArtifact
+	pkgs    []*pkg.DefaultPackage
+}
+
+// checkpoint starts the recording of the changes and returns the
+// position used by rollback.
+func (s *Solver) checkpoint() int {
+	s.journal.depth++
+	return len(s.journal.entries)
+}
+
+// rollback restores the values of the keys changed after the
+// checkpoint in input and closes the checkpoint.
+func (s *Solver) rollback(pos int) {
+	for i := len(s.journal.entries) - 1; i >= pos; i-- {
+		e := s.journal.entries[i]
+		switch e.kind {
+		case journalCandidates:
+			restoreArtifacts(s.candidatesMap, e)
+		case journalAvailable:
+			restoreArtifacts(s.availableArtsMap, e)
+		default:
+			if e.present {
+				s.providesMap.Packages[e.key] = e.pkgs
+			} else {
+				delete(s.providesMap.Packages, e.key)
+			}
+		}
+	}
+	s.journal.entries = s.journal.entries[:pos]
+	s.release()
+}
+
+func restoreArtifacts(m *artifact.ArtifactsMap, e *journalEntry) {
+	if e.present {
+		m.Artifacts[e.key] = e.arts
+	} else {
+		delete(m.Artifacts, e.key)
+	}
+}
+
+// release closes the last checkpoint maintaining the changes.
+// The changes are still recorded for the outer checkpoints.
+func (s *Solver) release() {
+	s.journal.depth--
+	if s.journal.depth == 0 {
+		s.journal.entries = s.journal.entries[:0]
+	}
+}
+
+func (s *Solver) recordArtifacts(m *artifact.ArtifactsMap, key string, kind int) {
+	if s.journal.depth == 0 {
+		return
+	}
+	arts, present := m.Artifacts[key]
+	s.journal.entries = append(s.journal.entries, &journalEntry{
+		kind:    kind,
+		key:     key,
+		present: present,
+		arts:    arts,
+	})
+}
+
+// setCandidates sets the valid artifacts of the package.
+func (s *Solver) setCandidates(key string, arts []*artifact.PackageArtifact) {
+	s.recordArtifacts(s.candidatesMap, key, journalCandidates)
+	s.candidatesMap.Artifacts[key] = arts
+}
+
+// addAvailable adds an artifact to the available artifacts. The
+// previous slice is maintained because the appends don't change
+// its elements.
+func (s *Solver) addAvailable(art *artifact.PackageArtifact) {
+	s.recordArtifacts(s.availableArtsMap, art.GetPackage().PackageName(), journalAvailable)
+	s.availableArtsMap.Add(art)
+}
+
+// addProvides adds the package that provides the key.
+func (s *Solver) addProvides(key string, p *pkg.DefaultPackage) {
+	if s.journal.depth > 0 {
+		pkgs, present := s.providesMap.Packages[key]
+		s.journal.entries = append(s.journal.entries, &journalEntry{
+			kind:    journalProvides,
+			key:     key,
+			present: present,
+			pkgs:    pkgs,
+		})
+	}
+	s.providesMap.Add(key, p)
+}
@@ -0,0 +1,180 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package solver_test
+
+import (
+	"github.com/geaaru/luet/pkg/config"
+	pkg "github.com/geaaru/luet/pkg/package"
+	. "github.com/geaaru/luet/pkg/v2/solver"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backtracking", func() {
+	var s *Solver
+	var db pkg.PackageDatabase
+	var cfg *config.LuetConfig
+
+	sel := func(name, version string) *pkg.DefaultPackage {
+		return &pkg.DefaultPackage{Category: "test", Name: name, Version: version}
+	}
+
+	backtracked := func() []string {
+		ans := []string{}
+		for _, e := range s.GetExplain().Entries {
+			if e.Decision == ExplainBacktrack {
+				ans = append(ans, e.Artifact)
+			}
+		}
+		return ans
+	}
+
+	BeforeEach(func() {
+		cfg = config.LuetCfg
+		cfg.GetGeneral().Concurrency = 1
+		cfg.SystemRepositories = []config.LuetRepository{}
+		cfg.HoldsFile = ""
+		cfg.GetSolverOptions().BacktrackMaxSteps = 0
+
+		db = pkg.NewInMemoryDatabase(false)
+		opts := NewSolverOpts()
+		opts.Explain = true
+		s = NewSolver(cfg, opts)
+		s.SetDatabase(db)
+	})
+
+	Context("Install", func() {
+
+		It("Selects the latest versions without backtracking", func() {
+			s.Searcher = newTestSearcher(
+				newTestPackage("lib", "1.0"),
+				newTestPackage("lib", "2.0"),
+				newTestPackage("app", "1.0", sel("lib", ">=1.0")),
+				newTestPackage("app", "2.0", sel("lib", ">=2.0")),
+			)
+
+			toInstall, _, err := s.Install(&[]*pkg.DefaultPackage{sel("app", ">=0")})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(artifactsNames(toInstall.Artifacts)).To(ConsistOf(
+				"test/app-2.0", "test/lib-2.0",
+			))
+			Expect(backtracked()).To(BeEmpty())
+		})
+
+		It("Drops the dependencies selected for a rejected version", func() {
+			s.Searcher = newTestSearcher(
+				newTestPackage("dep", "1.0"),
+				newTestPackage("app", "1.0"),
+				newTestPackage("app", "2.0", sel("dep", ">=1.0"), sel("old", ">=2.0")),
+			)
+			// The installed version isn't admitted by app-2.0.
+			_, err := db.CreatePackage(sel("old", "1.0"))
+			Expect(err).ToNot(HaveOccurred())
+
+			toInstall, _, err := s.Install(&[]*pkg.DefaultPackage{sel("app", ">=0")})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(artifactsNames(toInstall.Artifacts)).To(ConsistOf("test/app-1.0"))
+			Expect(backtracked()).To(BeEmpty())
+		})
+
+		It("Selects an older version when the latest versions are not valid together", func() {
+			s.Searcher = newTestSearcher(
+				newTestPackage("lib", "1.0"),
+				newTestPackage("lib", "2.0"),
+				newTestPackage("app", "1.0", sel("lib", ">=1.0")),
+				newTestPackage("app", "2.0", sel("lib", ">=2.0")),
+				newTestPackage("tool", "1.0", sel("lib", "<2.0")),
+			)
+
+			toInstall, _, err := s.Install(&[]*pkg.DefaultPackage{
+				sel("app", ">=0"), sel("tool", ">=0"),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(artifactsNames(toInstall.Artifacts)).To(ConsistOf(
+				"test/app-1.0", "test/lib-1.0", "test/tool-1.0",
+			))
+			Expect(backtracked()).ToNot(BeEmpty())
+		})
+
+		It("Reports the minimal set of the packages in conflict", func() {
+			s.Searcher = newTestSearcher(
+				newTestPackage("lib", "1.0"),
+				newTestPackage("lib", "2.0"),
+				newTestPackage("a", "1.0", sel("lib", ">=2.0")),
+				newTestPackage("b", "1.0", sel("lib", "<2.0")),
+				newTestPackage("c", "1.0"),
+			)
+
+			_, _, err := s.Install(&[]*pkg.DefaultPackage{
+				sel("a", ">=0"), sel("c", ">=0"), sel("b", ">=0"),
+			})
+			Expect(err).To(HaveOccurred())
+
+			cerr, ok := err.(*ConflictError)
+			Expect(ok).To(BeTrue())
+			Expect(cerr.Packages).To(HaveLen(2))
+			Expect(cerr.Packages).ToNot(ContainElement(ContainSubstring("test/c")))
+			Expect(cerr.Reasons).ToNot(BeEmpty())
+		})
+
+		It("Reports the reasons when the steps are exhausted on the minimal set search", func() {
+			cfg.GetSolverOptions().BacktrackMaxSteps = 4
+			s.Searcher = newTestSearcher(
+				newTestPackage("lib", "1.0"),
+				newTestPackage("lib", "2.0"),
+				newTestPackage("a", "1.0", sel("lib", ">=2.0")),
+				newTestPackage("b", "1.0", sel("lib", "<2.0")),
+				newTestPackage("c", "1.0"),
+			)
+
+			_, _, err := s.Install(&[]*pkg.DefaultPackage{
+				sel("a", ">=0"), sel("c", ">=0"), sel("b", ">=0"),
+			})
+			Expect(err).To(HaveOccurred())
+
+			cerr, ok := err.(*ConflictError)
+			Expect(ok).To(BeTrue())
+			// The search is stopped before the reduction of the set.
+			Expect(cerr.Packages).To(HaveLen(3))
+			Expect(cerr.Reasons).ToNot(BeEmpty())
+		})
+	})
+
+	Context("Upgrade", func() {
+
+		It("Selects an older version when the latest version is not installable", func() {
+			Expect(db.CreatePackage(newTestPackage("lib", "1.0"))).ToNot(BeNil())
+			Expect(db.CreatePackage(newTestPackage("app", "1.0", sel("lib", "<2.0")))).ToNot(BeNil())
+
+			// app-2.0 requires a missing package.
+			s.Searcher = newTestSearcher(
+				newTestPackage("lib", "2.0"),
+				newTestPackage("app", "1.5", sel("lib", ">=2.0")),
+				newTestPackage("app", "2.0", sel("lib", ">=2.0"), sel("ghost", ">=0")),
+			)
+
+			_, update, _, err := s.Upgrade()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(artifactsNames(update.Artifacts)).To(ConsistOf(
+				"test/lib-2.0", "test/app-1.5",
+			))
+		})
+
+		It("Keeps an installed package when the new versions are not valid together", func() {
+			Expect(db.CreatePackage(newTestPackage("lib", "1.0"))).ToNot(BeNil())
+			Expect(db.CreatePackage(newTestPackage("app", "1.0", sel("lib", ">=1.0")))).ToNot(BeNil())
+
+			s.Searcher = newTestSearcher(
+				newTestPackage("lib", "2.0"),
+				newTestPackage("app", "2.0", sel("lib", ">=1.0"), sel("ghost", ">=0")),
+			)
+
+			_, update, _, err := s.Upgrade()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(artifactsNames(update.Artifacts)).To(ConsistOf("test/lib-2.0"))
+		})
+	})
+})
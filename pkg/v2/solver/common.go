@@ -26,7 +26,10 @@ type SolverOpts struct {
 	Force           bool
 	NoDeps          bool
 	IgnoreMasks     bool
+	IgnoreHolds     bool
 	Deep            bool
+	// Trace the decisions of the solver.
+	Explain bool
 }
 
 type Operation struct {
@@ -39,6 +42,7 @@ func NewSolverOpts() *SolverOpts {
 		IgnoreConflicts: false,
 		NoDeps:          false,
 		IgnoreMasks:     false,
+		IgnoreHolds:     false,
 		Force:           false,
 		Deep:            false,
 	}
@@ -51,6 +55,9 @@ type PackageSolver interface {
 	SetDatabase(pkg.PackageDatabase)
 	OrderOperations(p2i, p2u, p2r *artifact.ArtifactsPack) (*[]*Operation, error)
 	Orphans() (*[]*pkg.DefaultPackage, error)
+	UnneededDeps() (*[]*pkg.DefaultPackage, error)
+	GetHoldReport() *HoldReport
+	GetExplain() *SolverExplain
 }
 
 func NewOperation(action string, art *artifact.PackageArtifact) *Operation {
@@ -0,0 +1,162 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package solver
+
+import (
+	"fmt"
+	"strings"
+
+	pkg "github.com/geaaru/luet/pkg/package"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+)
+
+const (
+	// Versions available of the package analyzed.
+	ExplainCandidates = "candidates"
+	ExplainSelected   = "selected"
+	ExplainMasked     = "masked"
+	ExplainConflict   = "conflict"
+	ExplainProvides   = "provides"
+	ExplainSelector   = "selector"
+	ExplainHold       = "hold"
+	ExplainNotFound   = "notfound"
+	ExplainBacktrack  = "backtrack"
+)
+
+// ExplainEntry is a decision of the solver about a package.
+type ExplainEntry struct {
+	Package    string `yaml:"package" json:"package"`
+	Artifact   string `yaml:"artifact,omitempty" json:"artifact,omitempty"`
+	Decision   string `yaml:"decision" json:"decision"`
+	Reason     string `yaml:"reason,omitempty" json:"reason,omitempty"`
+	RequiredBy string `yaml:"required_by,omitempty" json:"required_by,omitempty"`
+}
+
+// SolverExplain is the decisions trace of the solver in
+// the order of the analysis.
+type SolverExplain struct {
+	Entries []*ExplainEntry `yaml:"entries" json:"entries"`
+}
+
+func NewSolverExplain() *SolverExplain {
+	return &SolverExplain{
+		Entries: []*ExplainEntry{},
+	}
+}
+
+// GetPackageEntries returns the decisions related to a package.
+func (e *SolverExplain) GetPackageEntries(pkgname string) []*ExplainEntry {
+	ans := []*ExplainEntry{}
+	for _, entry := range e.Entries {
+		if entry.Package == pkgname {
+			ans = append(ans, entry)
+		}
+	}
+	return ans
+}
+
+// GetPackages returns the packages analyzed in the order
+// of the analysis.
+func (e *SolverExplain) GetPackages() []string {
+	ans := []string{}
+	m := make(map[string]bool, 0)
+	for _, entry := range e.Entries {
+		if _, present := m[entry.Package]; !present {
+			m[entry.Package] = true
+			ans = append(ans, entry.Package)
+		}
+	}
+	return ans
+}
+
+func (s *Solver) GetExplain() *SolverExplain { return s.explainTrace }
+
+func (s *Solver) explain(pkgname string, art *artifact.PackageArtifact,
+	decision, reason string, stack []string) {
+	if !s.Opts.Explain {
+		return
+	}
+
+	entry := &ExplainEntry{
+		Package:  pkgname,
+		Decision: decision,
+		Reason:   reason,
+	}
+	if art != nil {
+		entry.Artifact = art.GetPackage().HumanReadableString()
+		if art.GetRepository() != "" {
+			entry.Artifact += "::" + art.GetRepository()
+		}
+	}
+	if len(stack) > 0 {
+		entry.RequiredBy = stack[len(stack)-1]
+	}
+
+	s.mutex.Lock()
+	s.explainTrace.Entries = append(s.explainTrace.Entries, entry)
+	s.mutex.Unlock()
+}
+
+func (s *Solver) explainCandidates(pkgname string, arts []*artifact.PackageArtifact, stack []string) {
+	if !s.Opts.Explain {
+		return
+	}
+
+	versions := []string{}
+	for _, a := range arts {
+		v := a.GetPackage().HumanReadableString()
+		if a.GetRepository() != "" {
+			v += "::" + a.GetRepository()
+		}
+		versions = append(versions, v)
+	}
+
+	s.explain(pkgname, nil, ExplainCandidates, strings.Join(versions, ", "), stack)
+}
+
+// searchArtifacts executes the search of the artifacts. In explain
+// mode the masks are applied here to trace the artifacts masked.
+func (s *Solver) searchArtifacts(opts *wagon.StonesSearchOpts) (*[]*artifact.PackageArtifact, error) {
+	if !s.Opts.Explain || opts.IgnoreMasks || s.maskManager == nil {
+		return s.Searcher.SearchArtifacts(opts)
+	}
+
+	o := *opts
+	o.IgnoreMasks = true
+	arts, err := s.Searcher.SearchArtifacts(&o)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := []*artifact.PackageArtifact{}
+	for _, a := range *arts {
+		gp, err := a.GetPackage().ToGentooPackage()
+		if err != nil {
+			return nil, err
+		}
+		masked, err := s.maskManager.IsMasked(a.GetRepository(), gp)
+		if err != nil {
+			return nil, err
+		}
+		if masked {
+			s.explain(a.GetPackage().PackageName(), a, ExplainMasked,
+				fmt.Sprintf("masked on repository %s", a.GetRepository()), nil)
+			continue
+		}
+		ans = append(ans, a)
+	}
+
+	return &ans, nil
+}
+
+// selectorString returns the package selector in the
+// format used by the cli.
+func selectorString(p *pkg.DefaultPackage) string {
+	if p.GetVersion() == "" || p.GetVersion() == ">=0" {
+		return p.PackageName()
+	}
+	return p.PackageName() + "@" + p.GetVersion()
+}
@@ -0,0 +1,133 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package solver_test
+
+import (
+	"github.com/geaaru/luet/pkg/config"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/v2/repository/mask"
+	. "github.com/geaaru/luet/pkg/v2/solver"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Explain", func() {
+	var s *Solver
+	var db pkg.PackageDatabase
+
+	sel := func(name, version string) *pkg.DefaultPackage {
+		return &pkg.DefaultPackage{Category: "test", Name: name, Version: version}
+	}
+
+	decisions := func(decision string) []*ExplainEntry {
+		ans := []*ExplainEntry{}
+		for _, e := range s.GetExplain().Entries {
+			if e.Decision == decision {
+				ans = append(ans, e)
+			}
+		}
+		return ans
+	}
+
+	BeforeEach(func() {
+		cfg := config.LuetCfg
+		cfg.GetGeneral().Concurrency = 1
+		cfg.SystemRepositories = []config.LuetRepository{}
+		cfg.HoldsFile = ""
+		cfg.GetSolverOptions().BacktrackMaxSteps = 0
+
+		db = pkg.NewInMemoryDatabase(false)
+		opts := NewSolverOpts()
+		opts.Explain = true
+		s = NewSolver(cfg, opts)
+		s.SetDatabase(db)
+	})
+
+	It("Traces the versions masked", func() {
+		s.Searcher = newTestSearcher(
+			newTestPackage("app", "1.0"),
+			newTestPackage("app", "2.0"),
+		)
+		f, err := mask.NewPackageMaskFileFromData("test.yml",
+			[]byte("enabled: true\nrules:\n- =test/app-2.0\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.BuildMap()).ToNot(HaveOccurred())
+		m := mask.NewPackagesMaskManager(config.LuetCfg)
+		m.Files = append(m.Files, f)
+		s.SetMaskManager(m)
+
+		toInstall, _, err := s.Install(&[]*pkg.DefaultPackage{sel("app", ">=0")})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(artifactsNames(toInstall.Artifacts)).To(ConsistOf("test/app-1.0"))
+
+		entries := decisions(ExplainMasked)
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Package).To(Equal("test/app"))
+		Expect(entries[0].Artifact).To(Equal("test/app-2.0::test"))
+		Expect(entries[0].Reason).To(Equal("masked on repository test"))
+	})
+
+	It("Traces the versions in conflict with the installed packages", func() {
+		app := newTestPackage("app", "2.0")
+		app.PackageConflicts = []*pkg.DefaultPackage{sel("old", ">=0")}
+		s.Searcher = newTestSearcher(newTestPackage("app", "1.0"), app)
+		_, err := db.CreatePackage(sel("old", "1.0"))
+		Expect(err).ToNot(HaveOccurred())
+
+		toInstall, _, err := s.Install(&[]*pkg.DefaultPackage{sel("app", ">=0")})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(artifactsNames(toInstall.Artifacts)).To(ConsistOf("test/app-1.0"))
+
+		entries := decisions(ExplainConflict)
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Package).To(Equal("test/app"))
+		Expect(entries[0].Artifact).To(Equal("test/app-2.0::test"))
+		Expect(entries[0].Reason).To(Equal("conflicts with the installed test/old-1.0"))
+	})
+
+	It("Traces the dependencies resolved through the provides", func() {
+		impl := newTestPackage("impl", "1.0")
+		impl.Provides = []*pkg.DefaultPackage{sel("virtual", "1.0")}
+		s.Searcher = newTestSearcher(
+			impl,
+			newTestPackage("app", "1.0", sel("virtual", ">=0")),
+		)
+
+		toInstall, _, err := s.Install(&[]*pkg.DefaultPackage{sel("app", ">=0")})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(artifactsNames(toInstall.Artifacts)).To(ConsistOf(
+			"test/app-1.0", "test/impl-1.0",
+		))
+
+		entries := decisions(ExplainProvides)
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Package).To(Equal("test/virtual"))
+		Expect(entries[0].Reason).To(Equal("provided by test/impl"))
+		Expect(entries[0].RequiredBy).To(Equal("test/app"))
+	})
+
+	It("Traces the versions with dependencies not admitted", func() {
+		s.Searcher = newTestSearcher(
+			newTestPackage("lib", "2.0"),
+			newTestPackage("app", "1.0", sel("lib", ">=1.0")),
+			newTestPackage("app", "2.0", sel("lib", ">=2.0")),
+		)
+		lib := sel("lib", "1.0")
+		lib.SetInstallReason(pkg.InstallReasonDep)
+		_, err := db.CreatePackage(lib)
+		Expect(err).ToNot(HaveOccurred())
+
+		toInstall, _, err := s.Install(&[]*pkg.DefaultPackage{sel("app", ">=0")})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(artifactsNames(toInstall.Artifacts)).To(ConsistOf("test/app-1.0"))
+
+		entries := decisions(ExplainSelector)
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Package).To(Equal("test/app"))
+		Expect(entries[0].Artifact).To(Equal("test/app-2.0::test"))
+		Expect(entries[0].Reason).To(Equal("requires test/lib@>=2.0 but is installed test/lib-1.0"))
+	})
+})
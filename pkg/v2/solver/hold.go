@@ -0,0 +1,201 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package solver
+
+import (
+	"fmt"
+
+	. "github.com/geaaru/luet/pkg/logger"
+	pkg "github.com/geaaru/luet/pkg/package"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	"github.com/geaaru/luet/pkg/v2/hold"
+)
+
+// HoldSkipped describes an update of a held package not applied.
+type HoldSkipped struct {
+	Package   string `yaml:"package" json:"package"`
+	Available string `yaml:"available" json:"available"`
+	Hold      string `yaml:"hold" json:"hold"`
+}
+
+// HoldBlocked describes a candidate not selected because
+// it requires to change a held package.
+type HoldBlocked struct {
+	Candidate string `yaml:"candidate" json:"candidate"`
+	Package   string `yaml:"package" json:"package"`
+	Hold      string `yaml:"hold" json:"hold"`
+}
+
+type HoldReport struct {
+	Skipped []*HoldSkipped `yaml:"skipped,omitempty" json:"skipped,omitempty"`
+	Blocked []*HoldBlocked `yaml:"blocked,omitempty" json:"blocked,omitempty"`
+}
+
+func NewHoldReport() *HoldReport {
+	return &HoldReport{
+		Skipped: []*HoldSkipped{},
+		Blocked: []*HoldBlocked{},
+	}
+}
+
+func (r *HoldReport) IsEmpty() bool {
+	return len(r.Skipped) == 0 && len(r.Blocked) == 0
+}
+
+func (s *Solver) GetHoldReport() *HoldReport { return s.holdReport }
+
+func (s *Solver) prepareHolds() error {
+	s.holdReport = NewHoldReport()
+	if s.Opts.IgnoreHolds || s.holds != nil {
+		return nil
+	}
+
+	s.holds = hold.NewPackagesHoldManager(s.Config)
+	return s.holds.Load()
+}
+
+func (s *Solver) getHold(pkgname string) *hold.PackageHold {
+	if s.holds == nil {
+		return nil
+	}
+	return s.holds.GetHold(pkgname)
+}
+
+func (s *Solver) addHoldSkipped(p *pkg.DefaultPackage, available string, h *hold.PackageHold) {
+	s.explain(p.PackageName(), nil, ExplainHold,
+		fmt.Sprintf("update to %s skipped by the hold %s", available, h.String()), nil)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	Debug(fmt.Sprintf(":pushpin:[%s] Update to %s skipped by hold %s.",
+		p.HumanReadableString(), available, h.String()))
+
+	s.holdReport.Skipped = append(s.holdReport.Skipped, &HoldSkipped{
+		Package:   p.HumanReadableString(),
+		Available: available,
+		Hold:      h.String(),
+	})
+}
+
+func (s *Solver) addHoldBlocked(candidate, p *pkg.DefaultPackage, h *hold.PackageHold) {
+	s.explain(candidate.PackageName(), nil, ExplainHold,
+		fmt.Sprintf("%s requires a change of %s blocked by the hold %s",
+			candidate.HumanReadableString(), p.HumanReadableString(), h.String()), nil)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, b := range s.holdReport.Blocked {
+		if b.Candidate == candidate.HumanReadableString() && b.Package == p.HumanReadableString() {
+			return
+		}
+	}
+
+	Debug(fmt.Sprintf(":pushpin:[%s] Blocked by the hold %s of %s.",
+		candidate.HumanReadableString(), h.String(), p.HumanReadableString()))
+
+	s.holdReport.Blocked = append(s.holdReport.Blocked, &HoldBlocked{
+		Candidate: candidate.HumanReadableString(),
+		Package:   p.HumanReadableString(),
+		Hold:      h.String(),
+	})
+}
+
+// artefactAdmitByHold checks if the artefact could replace the
+// installed package pkgname. The artefact is not admitted
+// if the installed package is held and the hold doesn't admit the
+// version or if the artefact provides the held package.
+func (s *Solver) artefactAdmitByHold(pkgname string, art *artifact.PackageArtifact) (bool, error) {
+	h := s.getHold(pkgname)
+	if h == nil {
+		return true, nil
+	}
+
+	if art.GetPackage().PackageName() != pkgname {
+		// The replace of a held package through provides
+		// is never admitted.
+		return false, nil
+	}
+
+	return h.Admit(art.GetPackage())
+}
+
+// artefactReplacesHold returns the installed and held package
+// that the artefact replaces through provides.
+func (s *Solver) artefactReplacesHold(art *artifact.PackageArtifact) (*pkg.DefaultPackage, *hold.PackageHold) {
+	if s.holds == nil || !art.GetPackage().HasProvides() {
+		return nil, nil
+	}
+
+	for _, prov := range art.GetPackage().GetProvides() {
+		h := s.getHold(prov.PackageName())
+		if h == nil {
+			continue
+		}
+		if val, present := s.systemMap.Packages[prov.PackageName()]; present {
+			return val[0], h
+		}
+	}
+
+	return nil, nil
+}
+
+// filterHeldArtifacts drops the artefacts not admitted by the hold
+// of the installed package and registers the first update skipped.
+func (s *Solver) filterHeldArtifacts(p *pkg.DefaultPackage, h *hold.PackageHold,
+	arts *[]*artifact.PackageArtifact) (*[]*artifact.PackageArtifact, error) {
+
+	gpI, err := p.ToGentooPackage()
+	if err != nil {
+		return nil, err
+	}
+	pHash := p.GetComparitionHash()
+
+	ans := []*artifact.PackageArtifact{}
+	skipped := false
+	for _, a := range *arts {
+		admit, err := s.artefactAdmitByHold(p.PackageName(), a)
+		if err != nil {
+			return nil, err
+		}
+		if admit {
+			ans = append(ans, a)
+			continue
+		}
+
+		if skipped {
+			continue
+		}
+
+		ap := a.GetPackage()
+		if !ap.AtomMatches(p) {
+			prov := ap.GetProvidePackage(p.PackageName())
+			if prov == nil {
+				continue
+			}
+			ap = prov
+		}
+
+		gpR, err := ap.ToGentooPackage()
+		if err != nil {
+			return nil, err
+		}
+
+		newer, _ := gpR.GreaterThan(gpI)
+		if !newer {
+			if equal, _ := gpR.Equal(gpI); equal && a.GetPackage().GetComparitionHash() != pHash {
+				newer = true
+			}
+		}
+
+		if newer {
+			s.addHoldSkipped(p, a.GetPackage().HumanReadableString(), h)
+			skipped = true
+		}
+	}
+
+	return &ans, nil
+}
@@ -146,3 +146,235 @@ func (s *Solver) Orphans() (*[]*pkg.DefaultPackage, error) {
 
 	return &ans, nil
 }
+
+// installedDepsMap maps the installed packages by name and by
+// provides and creates the map of the reverse dependencies.
+type installedDepsMap struct {
+	packages map[string]*pkg.DefaultPackage
+	rdeps    map[string][]*pkg.DefaultPackage
+}
+
+func newInstalledDepsMap(systemPkgs *pkg.Packages) *installedDepsMap {
+	ans := &installedDepsMap{
+		packages: make(map[string]*pkg.DefaultPackage, 0),
+		rdeps:    make(map[string][]*pkg.DefaultPackage, 0),
+	}
+
+	for _, p := range *systemPkgs {
+		dp := p.(*pkg.DefaultPackage)
+		ans.packages[dp.PackageName()] = dp
+	}
+	for _, p := range *systemPkgs {
+		dp := p.(*pkg.DefaultPackage)
+		if dp.HasProvides() {
+			for _, prov := range dp.GetProvides() {
+				if _, present := ans.packages[prov.PackageName()]; !present {
+					ans.packages[prov.PackageName()] = dp
+				}
+			}
+		}
+	}
+
+	for _, p := range *systemPkgs {
+		dp := p.(*pkg.DefaultPackage)
+		for _, d := range ans.deps(dp) {
+			ans.rdeps[d.PackageName()] = append(ans.rdeps[d.PackageName()], dp)
+		}
+	}
+
+	return ans
+}
+
+// deps returns the installed packages required by the package.
+func (m *installedDepsMap) deps(p *pkg.DefaultPackage) []*pkg.DefaultPackage {
+	ans := []*pkg.DefaultPackage{}
+	for _, r := range p.GetRequires() {
+		if d, ok := m.packages[r.PackageName()]; ok && d.PackageName() != p.PackageName() {
+			ans = append(ans, d)
+		}
+	}
+	return ans
+}
+
+// isWorld returns true if the package is a package selected by the
+// user. The packages installed without the install reason are
+// considered selected by the user: they are never removed until
+// the install reason is set with luet database mark.
+func (m *installedDepsMap) isWorld(p *pkg.DefaultPackage) bool {
+	return !p.HasInstallReason() || p.IsExplicit()
+}
+
+// warnUnknownReasons warns about the installed packages without
+// the install reason that are ignored by the orphans research.
+func (m *installedDepsMap) warnUnknownReasons() {
+	n := 0
+	for name, p := range m.packages {
+		if name == p.PackageName() && !p.HasInstallReason() {
+			n++
+		}
+	}
+
+	if n > 0 {
+		Warning(fmt.Sprintf(
+			"%d installed packages are without the install reason and are considered selected by the user.", n))
+		Warning("Use luet database mark --dep to mark the dependencies.")
+	}
+}
+
+// UnneededDeps returns the installed packages not selected by the
+// user that are not required by other packages.
+func (s *Solver) UnneededDeps() (*[]*pkg.DefaultPackage, error) {
+	if s.Database == nil {
+		return nil, errors.New("Solver UnneededDeps requires Database")
+	}
+
+	systemPkgs := s.Database.World()
+	dmap := newInstalledDepsMap(&systemPkgs)
+	dmap.warnUnknownReasons()
+
+	ans := []*pkg.DefaultPackage{}
+	for _, p := range systemPkgs {
+		dp := p.(*pkg.DefaultPackage)
+		if !dmap.isWorld(dp) && len(dmap.rdeps[dp.PackageName()]) == 0 {
+			ans = append(ans, dp)
+		}
+	}
+
+	if len(ans) > 0 {
+		others, err := s.orphansAfterRemove(dmap, ans)
+		if err != nil {
+			return nil, err
+		}
+		ans = append(ans, others...)
+	}
+
+	return &ans, nil
+}
+
+// OrphansAfterRemove returns the installed packages that are
+// dependencies of the packages to remove and that will be without
+// reverse dependencies after the remove. The packages selected by
+// the user are never returned.
+func (s *Solver) OrphansAfterRemove(pkgs []*pkg.DefaultPackage) ([]*pkg.DefaultPackage, error) {
+	if s.Database == nil {
+		return nil, errors.New("Solver OrphansAfterRemove requires Database")
+	}
+
+	systemPkgs := s.Database.World()
+	dmap := newInstalledDepsMap(&systemPkgs)
+	dmap.warnUnknownReasons()
+
+	return s.orphansAfterRemove(dmap, pkgs)
+}
+
+func (s *Solver) orphansAfterRemove(dmap *installedDepsMap,
+	pkgs []*pkg.DefaultPackage) ([]*pkg.DefaultPackage, error) {
+	ans := []*pkg.DefaultPackage{}
+
+	removed := make(map[string]bool, 0)
+	queue := []*pkg.DefaultPackage{}
+	for _, p := range pkgs {
+		removed[p.PackageName()] = true
+		queue = append(queue, p)
+	}
+
+	// The candidates are the dependencies of the removed packages.
+	// A candidate rejected could be accepted later when all its
+	// reverse dependencies are removed.
+	candidates := []*pkg.DefaultPackage{}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		for _, d := range dmap.deps(p) {
+			if removed[d.PackageName()] || dmap.isWorld(d) {
+				continue
+			}
+			candidates = append(candidates, d)
+		}
+
+		for _, c := range candidates {
+			if removed[c.PackageName()] {
+				continue
+			}
+
+			orphan := true
+			for _, r := range dmap.rdeps[c.PackageName()] {
+				if !removed[r.PackageName()] {
+					orphan = false
+					break
+				}
+			}
+
+			if orphan {
+				Debug(fmt.Sprintf("[%s] Orphan after the remove.",
+					c.HumanReadableString()))
+				removed[c.PackageName()] = true
+				ans = append(ans, c)
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	return ans, nil
+}
+
+// SortPackages2Remove sorts the packages to remove in dependency
+// order: a package is removed before the packages that it requires.
+func (s *Solver) SortPackages2Remove(pkgs []*pkg.DefaultPackage) ([]*pkg.DefaultPackage, error) {
+	if s.Database == nil {
+		return nil, errors.New("Solver SortPackages2Remove requires Database")
+	}
+
+	systemPkgs := s.Database.World()
+	dmap := newInstalledDepsMap(&systemPkgs)
+
+	inList := make(map[string]bool, 0)
+	for _, p := range pkgs {
+		inList[p.PackageName()] = true
+	}
+
+	// Count the reverse dependencies inside the list.
+	nrdeps := make(map[string]int, 0)
+	for _, p := range pkgs {
+		for _, d := range dmap.deps(p) {
+			if inList[d.PackageName()] {
+				nrdeps[d.PackageName()]++
+			}
+		}
+	}
+
+	ans := []*pkg.DefaultPackage{}
+	done := make(map[string]bool, 0)
+	for len(ans) < len(pkgs) {
+		found := false
+		for _, p := range pkgs {
+			if done[p.PackageName()] || nrdeps[p.PackageName()] > 0 {
+				continue
+			}
+			found = true
+			done[p.PackageName()] = true
+			ans = append(ans, p)
+			for _, d := range dmap.deps(p) {
+				if inList[d.PackageName()] {
+					nrdeps[d.PackageName()]--
+				}
+			}
+		}
+
+		if !found {
+			// POST: cycle between the packages. I append the
+			//       remaining packages in the original order.
+			for _, p := range pkgs {
+				if !done[p.PackageName()] {
+					Debug(fmt.Sprintf("[%s] Found in a dependency cycle.",
+						p.HumanReadableString()))
+					done[p.PackageName()] = true
+					ans = append(ans, p)
+				}
+			}
+		}
+	}
+
+	return ans, nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package solver_test
+
+import (
+	"github.com/geaaru/luet/pkg/config"
+	pkg "github.com/geaaru/luet/pkg/package"
+	. "github.com/geaaru/luet/pkg/v2/solver"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Orphans", func() {
+	var s *Solver
+	var db pkg.PackageDatabase
+
+	newPackage := func(name, reason string, requires ...*pkg.DefaultPackage) *pkg.DefaultPackage {
+		p := &pkg.DefaultPackage{
+			Category: "test", Name: name, Version: "1.0",
+			PackageRequires: requires,
+		}
+		if reason != "" {
+			p.SetInstallReason(reason)
+		}
+		_, err := db.CreatePackage(p)
+		Expect(err).ToNot(HaveOccurred())
+		return p
+	}
+
+	names := func(pkgs []*pkg.DefaultPackage) []string {
+		ans := []string{}
+		for _, p := range pkgs {
+			ans = append(ans, p.GetName())
+		}
+		return ans
+	}
+
+	sel := func(name string) *pkg.DefaultPackage {
+		return &pkg.DefaultPackage{Category: "test", Name: name, Version: ">=0"}
+	}
+
+	BeforeEach(func() {
+		db = pkg.NewInMemoryDatabase(false)
+		s = NewSolver(config.LuetCfg, NewSolverOpts())
+		s.SetDatabase(db)
+	})
+
+	Context("OrphansAfterRemove", func() {
+
+		It("Returns the dependencies without other reverse dependencies", func() {
+			// app -> lib -> base
+			// other -> shared <- app
+			newPackage("base", pkg.InstallReasonDep)
+			newPackage("lib", pkg.InstallReasonDep, sel("base"))
+			newPackage("shared", pkg.InstallReasonDep)
+			app := newPackage("app", pkg.InstallReasonExplicit, sel("lib"), sel("shared"))
+			newPackage("other", pkg.InstallReasonExplicit, sel("shared"))
+
+			ans, err := s.OrphansAfterRemove([]*pkg.DefaultPackage{app})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(names(ans)).To(ConsistOf("lib", "base"))
+		})
+
+		It("Never returns the packages selected by the user", func() {
+			newPackage("lib", pkg.InstallReasonExplicit)
+			app := newPackage("app", pkg.InstallReasonExplicit, sel("lib"))
+
+			ans, err := s.OrphansAfterRemove([]*pkg.DefaultPackage{app})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ans).To(BeEmpty())
+		})
+
+		It("Accepts a dependency when all its reverse dependencies are removed", func() {
+			// app -> a -> c and app -> b -> c
+			newPackage("c", pkg.InstallReasonDep)
+			newPackage("a", pkg.InstallReasonDep, sel("c"))
+			newPackage("b", pkg.InstallReasonDep, sel("c"))
+			app := newPackage("app", pkg.InstallReasonExplicit, sel("a"), sel("b"))
+
+			ans, err := s.OrphansAfterRemove([]*pkg.DefaultPackage{app})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(names(ans)).To(ConsistOf("a", "b", "c"))
+		})
+
+		It("Considers world the packages without install reason", func() {
+			// lib is installed by an older release: it could be
+			// installed explicitly by the user.
+			newPackage("base", pkg.InstallReasonDep)
+			newPackage("lib", "", sel("base"))
+			newPackage("tool", "")
+			app := newPackage("app", pkg.InstallReasonExplicit, sel("lib"))
+
+			ans, err := s.OrphansAfterRemove([]*pkg.DefaultPackage{app})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ans).To(BeEmpty())
+		})
+	})
+
+	Context("UnneededDeps", func() {
+
+		It("Ignores the packages without install reason", func() {
+			newPackage("base", pkg.InstallReasonDep)
+			newPackage("lib", pkg.InstallReasonDep, sel("base"))
+			newPackage("old", "")
+			newPackage("app", pkg.InstallReasonExplicit)
+
+			ans, err := s.UnneededDeps()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(names(*ans)).To(ConsistOf("lib", "base"))
+		})
+	})
+
+	Context("SortPackages2Remove", func() {
+
+		It("Removes a package before its dependencies", func() {
+			base := newPackage("base", pkg.InstallReasonDep)
+			lib := newPackage("lib", pkg.InstallReasonDep, sel("base"))
+			app := newPackage("app", pkg.InstallReasonExplicit, sel("lib"), sel("base"))
+			tool := newPackage("tool", pkg.InstallReasonExplicit)
+
+			ans, err := s.SortPackages2Remove([]*pkg.DefaultPackage{base, tool, lib, app})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(names(ans)).To(Equal([]string{"tool", "app", "lib", "base"}))
+		})
+
+		It("Appends the packages of a dependency cycle", func() {
+			a := newPackage("a", pkg.InstallReasonDep, sel("b"))
+			b := newPackage("b", pkg.InstallReasonDep, sel("a"))
+			c := newPackage("c", pkg.InstallReasonExplicit)
+
+			ans, err := s.SortPackages2Remove([]*pkg.DefaultPackage{a, b, c})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(names(ans)).To(Equal([]string{"c", "a", "b"}))
+		})
+
+		It("Requires the database", func() {
+			s.SetDatabase(nil)
+			_, err := s.SortPackages2Remove([]*pkg.DefaultPackage{})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
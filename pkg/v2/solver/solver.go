@@ -16,7 +16,9 @@ import (
 	. "github.com/geaaru/luet/pkg/logger"
 	pkg "github.com/geaaru/luet/pkg/package"
 	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	"github.com/geaaru/luet/pkg/v2/hold"
 	wagon "github.com/geaaru/luet/pkg/v2/repository"
+	"github.com/geaaru/luet/pkg/v2/repository/mask"
 )
 
 type Solver struct {
@@ -34,6 +36,13 @@ type Solver struct {
 	availableArtsMap *artifact.ArtifactsMap `yaml:"-" json:"-"`
 	candidatesMap    *artifact.ArtifactsMap `yaml:"-" json:"-"`
 
+	holds      *hold.PackagesHoldManager `yaml:"-" json:"-"`
+	holdReport *HoldReport               `yaml:"-" json:"-"`
+
+	maskManager  *mask.PackagesMaskManager `yaml:"-" json:"-"`
+	explainTrace *SolverExplain            `yaml:"-" json:"-"`
+	journal      *solverJournal            `yaml:"-" json:"-"`
+
 	mutex *sync.Mutex `yaml:"-" json:"-'`
 }
 
@@ -44,12 +53,24 @@ func NewSolver(cfg *config.LuetConfig, opts *SolverOpts) *Solver {
 		Database:      nil,
 		MapRepos:      nil,
 		candidatesMap: artifact.NewArtifactsMap(),
+		holdReport:    NewHoldReport(),
+		explainTrace:  NewSolverExplain(),
+		journal:       &solverJournal{},
 		mutex:         &sync.Mutex{},
 	}
 }
 
 func (s *Solver) SetDatabase(d pkg.PackageDatabase) { s.Database = d }
 
+// SetMaskManager sets the masks of the packages to use
+// when the searcher is already configured.
+func (s *Solver) SetMaskManager(m *mask.PackagesMaskManager) {
+	s.maskManager = m
+	if s.Searcher != nil {
+		s.Searcher.SetMaskManager(m)
+	}
+}
+
 func (s *Solver) createThinPkgsPlist(p2i *artifact.ArtifactsPack, p2imap *artifact.ArtifactsMap) []*pkg.PackageThin {
 
 	// Instead to check if a dependency is already installed
@@ -528,7 +549,7 @@ func (s *Solver) Install(pkgsref *[]*pkg.DefaultPackage) (*artifact.ArtifactsPac
 
 	// For every package in list retrieve all available candidates
 	// and store the result on ArtifactsMap
-	reposArtifacts, err := s.Searcher.SearchArtifacts(searchOpts)
+	reposArtifacts, err := s.searchArtifacts(searchOpts)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -627,8 +648,11 @@ func (s *Solver) Install(pkgsref *[]*pkg.DefaultPackage) (*artifact.ArtifactsPac
 						k, installedPkg))
 
 					// Delete all packages because is already installed.
-					for k, _ := range m {
-						delete(s.availableArtsMap.Artifacts, k)
+					for pname, _ := range m {
+						s.explain(pname, nil, ExplainProvides,
+							fmt.Sprintf("%s is already provided by the installed %s",
+								k, installedPkg), nil)
+						delete(s.availableArtsMap.Artifacts, pname)
 					}
 				} else {
 					// Sort packages for requires and repos
@@ -641,9 +665,12 @@ func (s *Solver) Install(pkgsref *[]*pkg.DefaultPackage) (*artifact.ArtifactsPac
 
 					// Delete the packages related with the same provides
 					// loser.
-					for k, _ := range m {
-						if k != arts[0].GetPackage().PackageName() {
-							delete(s.availableArtsMap.Artifacts, k)
+					for pname, _ := range m {
+						if pname != arts[0].GetPackage().PackageName() {
+							s.explain(pname, nil, ExplainProvides,
+								fmt.Sprintf("%s is provided by %s",
+									k, arts[0].GetPackage().PackageName()), nil)
+							delete(s.availableArtsMap.Artifacts, pname)
 						}
 					}
 
@@ -661,9 +688,27 @@ func (s *Solver) Install(pkgsref *[]*pkg.DefaultPackage) (*artifact.ArtifactsPac
 		pList = append(pList, pname)
 	}
 
+	var greedyErr error
 	for _, pname := range pList {
 		err := s.resolvePackage(pname, []string{})
 		if !s.Opts.Force && err != nil {
+			greedyErr = err
+			break
+		}
+	}
+
+	if greedyErr == nil && !s.Opts.Force {
+		greedyErr = s.checkCandidatesConsistency()
+	}
+
+	if greedyErr != nil {
+		// POST: the latest versions are not valid together. I try
+		//       to find a solution with older versions.
+		Debug(fmt.Sprintf(":brain:Resolution of the latest versions failed: %s",
+			greedyErr.Error()))
+
+		err = s.backtrackInstall(pkgs)
+		if err != nil {
 			return nil, nil, err
 		}
 	}
@@ -719,6 +764,7 @@ func (s *Solver) resolvePackage(pkgstr string, stack []string) error {
 	if err != nil {
 		return err
 	}
+	s.explainCandidates(pkgstr, selectedArts, stack)
 
 	foundMatched := false
 
@@ -755,14 +801,19 @@ func (s *Solver) resolvePackage(pkgstr string, stack []string) error {
 			continue
 		}
 
+		cp := s.checkpoint()
 		ss := append(stack, art.GetPackage().PackageName())
 		// Check and in queue all package dependencies
 		admittedDeps, err := s.processArtefactDeps(art, ss)
 		if err != nil {
+			s.release()
 			return err
 		}
 
 		if !admittedDeps {
+			// Drop the dependencies selected for the artefact.
+			s.rollback(cp)
+			bannedVersion[art.GetPackage().GetVersion()] = true
 			// POST: Not all packages dependencies are admit by
 			//       the current system.
 			Debug(fmt.Sprintf(
@@ -770,7 +821,9 @@ func (s *Solver) resolvePackage(pkgstr string, stack []string) error {
 				art.GetPackage().HumanReadableString()))
 			continue
 		}
+		s.release()
 
+		s.explain(pkgstr, art, ExplainSelected, "", stack)
 		foundMatched = true
 		break
 	}
@@ -785,6 +838,7 @@ func (s *Solver) resolvePackage(pkgstr string, stack []string) error {
 				"No valid candidate or valid dependencies found for %s", pkgstr)
 		}
 		Debug(str)
+		s.explain(pkgstr, nil, ExplainNotFound, str, stack)
 		return errors.New(str)
 	}
 
@@ -801,7 +855,7 @@ func (s *Solver) resolvePackage(pkgstr string, stack []string) error {
 			p := art.GetPackage()
 			if len(p.Provides) > 0 {
 				for _, prov := range p.Provides {
-					s.providesMap.Add(prov.PackageName(), p)
+					s.addProvides(prov.PackageName(), p)
 				}
 			}
 			firstValid = true
@@ -809,7 +863,7 @@ func (s *Solver) resolvePackage(pkgstr string, stack []string) error {
 		validArts = append(validArts, art)
 	}
 
-	s.candidatesMap.Artifacts[pkgstr] = validArts
+	s.setCandidates(pkgstr, validArts)
 
 	return nil
 }
@@ -831,6 +885,9 @@ func (s *Solver) processArtefactDeps(art *artifact.PackageArtifact, stack []stri
 			if err != nil {
 				return false, err
 			} else if !admit {
+				s.explain(candidate.PackageName(), art, ExplainSelector,
+					fmt.Sprintf("requires %s but is installed %s",
+						selectorString(p), val[0].HumanReadableString()), nil)
 				return false, nil
 			}
 
@@ -852,6 +909,8 @@ func (s *Solver) processArtefactDeps(art *artifact.PackageArtifact, stack []stri
 					}
 				}
 				if provMatched {
+					s.explain(p.PackageName(), nil, ExplainProvides,
+						fmt.Sprintf("provided by %s", prov.HumanReadableString()), stack)
 					break
 				}
 			}
@@ -927,7 +986,7 @@ func (s *Solver) processArtefactDeps(art *artifact.PackageArtifact, stack []stri
 			}
 			Debug(fmt.Sprintf("[%30s] Searching for dependency %s...",
 				candidate.PackageName(), searchOpts.Packages[0].PackageName()))
-			reposArtifacts, err = s.Searcher.SearchArtifacts(searchOpts)
+			reposArtifacts, err = s.searchArtifacts(searchOpts)
 			if err != nil {
 				return false, err
 			}
@@ -948,18 +1007,23 @@ func (s *Solver) processArtefactDeps(art *artifact.PackageArtifact, stack []stri
 					provStr = depArt.GetPackage().PackageName()
 				}
 
-				s.availableArtsMap.Add(depArt)
+				s.addAvailable(depArt)
 			}
 
 		}
 
 		if provStr != "" {
+			s.explain(p.PackageName(), nil, ExplainProvides,
+				fmt.Sprintf("provided by %s", provStr), stack)
 			err = s.resolvePackage(provStr, stack)
 		} else {
 			err = s.resolvePackage(p.PackageName(), stack)
 		}
 		if err != nil {
 			if strings.HasPrefix(err.Error(), "No valid") {
+				s.explain(candidate.PackageName(), art, ExplainSelector,
+					fmt.Sprintf("no valid candidate for the dependency %s",
+						selectorString(p)), nil)
 				return false, nil
 			}
 			return false, err
@@ -985,6 +1049,9 @@ func (s *Solver) artefactAdmitByQueue(art *artifact.PackageArtifact) (bool, erro
 				Debug(fmt.Sprintf("%s NOT admits %s...",
 					artInQueue.GetPackage().HumanReadableString(),
 					art.GetPackage().HumanReadableString()))
+				s.explain(art.GetPackage().PackageName(), art, ExplainSelector,
+					fmt.Sprintf("not admitted by %s",
+						artInQueue.GetPackage().HumanReadableString()), nil)
 				return admit, err
 			}
 		}
@@ -1001,6 +1068,9 @@ func (s *Solver) artefactIsInConflict(art *artifact.PackageArtifact) bool {
 			// Check if propagate error
 			valid, _ := c.Admit(p)
 			if !valid {
+				s.explain(p.PackageName(), art, ExplainConflict,
+					fmt.Sprintf("the installed %s conflicts with it",
+						c.HumanReadableString()), nil)
 				return true
 			}
 		}
@@ -1018,6 +1088,9 @@ func (s *Solver) artefactIsInConflict(art *artifact.PackageArtifact) bool {
 						"[%s] conflict with %s but is provided. Ignoring it.",
 						p.HumanReadableString(), val[0].HumanReadableString()))
 				} else {
+					s.explain(p.PackageName(), art, ExplainConflict,
+						fmt.Sprintf("conflicts with the installed %s",
+							val[0].HumanReadableString()), nil)
 					return true
 				}
 			}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package solver_test
+
+import (
+	"testing"
+
+	pkg "github.com/geaaru/luet/pkg/package"
+	artifact "github.com/geaaru/luet/pkg/v2/compiler/types/artifact"
+	wagon "github.com/geaaru/luet/pkg/v2/repository"
+	"github.com/geaaru/luet/pkg/v2/repository/mask"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestSolver(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Solver Suite")
+}
+
+// testSearcher is a Searcher over a static list of artifacts.
+type testSearcher struct {
+	Artifacts []*artifact.PackageArtifact
+}
+
+func newTestSearcher(packages ...*pkg.DefaultPackage) *testSearcher {
+	ans := &testSearcher{Artifacts: []*artifact.PackageArtifact{}}
+	for _, p := range packages {
+		a := artifact.NewPackageArtifact(
+			p.GetName() + "-" + p.GetCategory() + "-" + p.GetVersion() + ".package.tar")
+		a.Runtime = p
+		ans.Artifacts = append(ans.Artifacts, a)
+	}
+	return ans
+}
+
+func (s *testSearcher) SearchArtifacts(opts *wagon.StonesSearchOpts) (*[]*artifact.PackageArtifact, error) {
+	ans := []*artifact.PackageArtifact{}
+	for _, a := range s.Artifacts {
+		for _, sel := range opts.Packages {
+			match, err := s.match(a.GetPackage(), sel)
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				ans = append(ans, a)
+				break
+			}
+		}
+	}
+	return &ans, nil
+}
+
+func (s *testSearcher) match(p, sel *pkg.DefaultPackage) (bool, error) {
+	if p.PackageName() == sel.PackageName() {
+		return p.VersionMatchSelector(sel.GetVersion(), nil)
+	}
+	for _, prov := range p.Provides {
+		if prov.PackageName() == sel.PackageName() {
+			return prov.VersionMatchSelector(sel.GetVersion(), nil)
+		}
+	}
+	return false, nil
+}
+
+func (s *testSearcher) SearchStones(opts *wagon.StonesSearchOpts) (*[]*wagon.Stone, error) {
+	return &[]*wagon.Stone{}, nil
+}
+
+func (s *testSearcher) SearchInstalled(opts *wagon.StonesSearchOpts) (*[]*wagon.Stone, error) {
+	return &[]*wagon.Stone{}, nil
+}
+
+func (s *testSearcher) SearchArtifactsOnRepo(name string, opts *wagon.StonesSearchOpts) (*[]*artifact.PackageArtifact, error) {
+	return s.SearchArtifacts(opts)
+}
+
+func (s *testSearcher) SetMaskManager(m *mask.PackagesMaskManager) {}
+
+// newTestPackage returns a package of the test category.
+func newTestPackage(name, version string, requires ...*pkg.DefaultPackage) *pkg.DefaultPackage {
+	return &pkg.DefaultPackage{
+		Category:        "test",
+		Name:            name,
+		Version:         version,
+		Repository:      "test",
+		PackageRequires: requires,
+	}
+}
+
+// artifactsNames returns the packages of the artifacts.
+func artifactsNames(arts []*artifact.PackageArtifact) []string {
+	ans := []string{}
+	for _, a := range arts {
+		ans = append(ans, a.GetPackage().HumanReadableString())
+	}
+	return ans
+}
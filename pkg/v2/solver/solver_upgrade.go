@@ -54,11 +54,17 @@ func (s *Solver) Upgrade() (*artifact.ArtifactsPack, *artifact.ArtifactsPack, *a
 		s.MapRepos[repo.Name] = wr
 	}
 
+	// Load the packages held.
+	err := s.prepareHolds()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	Debug(":brain:Starting preliminary analysis...")
 	start := time.Now()
 	// 1. Search for all packages with new versions excluding
 	//    his dependencies or with changes on requires/conflicts/provides
-	err := s.analyzeInstalledPackages()
+	err = s.analyzeInstalledPackages()
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -103,8 +109,29 @@ func (s *Solver) Upgrade() (*artifact.ArtifactsPack, *artifact.ArtifactsPack, *a
 	mapArt = nil
 	artsSortList = nil
 
+	var greedyErr error
 	for _, pname := range pList {
-		err := s.checkCandidate2Upgrade(pname, []string{})
+		greedyErr = s.checkCandidate2Upgrade(pname, []string{})
+		if greedyErr != nil {
+			break
+		}
+	}
+
+	if greedyErr == nil && !s.Opts.NoDeps {
+		greedyErr = s.checkCandidatesConsistency()
+	}
+
+	if greedyErr != nil {
+		if s.Opts.NoDeps {
+			return nil, nil, nil, greedyErr
+		}
+
+		// POST: the latest versions are not valid together. I try
+		//       to find a solution exploring also the installed versions.
+		Debug(fmt.Sprintf(":brain:Resolution of the latest versions failed: %s",
+			greedyErr.Error()))
+
+		err = s.backtrackUpgrade(pList)
 		if err != nil {
 			return nil, nil, nil, err
 		}
@@ -308,6 +335,7 @@ func (s *Solver) checkCandidate2Upgrade(pkgstr string, stack []string) error {
 			return err
 		}
 	}
+	s.explainCandidates(pkgstr, selectedArts, stack)
 	//pkg2replace = pkgstr
 
 	// Retrieve the DefaultPackage of the installed package.
@@ -357,6 +385,27 @@ func (s *Solver) checkCandidate2Upgrade(pkgstr string, stack []string) error {
 			continue
 		}
 
+		// The held packages are immovable except for the
+		// versions admitted by the hold selector.
+		admitByHold, err := s.artefactAdmitByHold(pkgstr, art)
+		if err != nil {
+			return err
+		}
+		if !admitByHold {
+			s.explain(pkgstr, art, ExplainHold,
+				fmt.Sprintf("not admitted by the hold %s", s.getHold(pkgstr).String()),
+				stack)
+			bannedVersion[candidate.GetVersion()] = true
+			continue
+		}
+
+		// Check if the artefact replaces a held package through provides.
+		if held, h := s.artefactReplacesHold(art); held != nil && held.PackageName() != pkgstr {
+			s.addHoldBlocked(art.GetPackage(), held, h)
+			bannedVersion[candidate.GetVersion()] = true
+			continue
+		}
+
 		val, err := gpS.GreaterThan(gpI)
 		if err != nil {
 			return err
@@ -396,11 +445,25 @@ func (s *Solver) checkCandidate2Upgrade(pkgstr string, stack []string) error {
 							}
 
 							if !newUserVersionAdmit {
+								if h := s.getHold(user.PackageName()); h != nil {
+									s.addHoldBlocked(candidate, user, h)
+								} else {
+									s.explain(candidate.PackageName(), art, ExplainSelector,
+										fmt.Sprintf("not admitted by the installed %s",
+											user.HumanReadableString()), nil)
+								}
 								usersAdmitNew = false
 								break
 							}
 
 						} else {
+							if h := s.getHold(user.PackageName()); h != nil {
+								s.addHoldBlocked(candidate, user, h)
+							} else {
+								s.explain(candidate.PackageName(), art, ExplainSelector,
+									fmt.Sprintf("not admitted by the installed %s",
+										user.HumanReadableString()), nil)
+							}
 							usersAdmitNew = false
 							break
 						}
@@ -437,19 +500,26 @@ func (s *Solver) checkCandidate2Upgrade(pkgstr string, stack []string) error {
 				continue
 			}
 
+			cp := s.checkpoint()
 			ss := append(stack, art.GetPackage().PackageName())
 			// Check and in queue all package dependencies
 			admittedDeps, err := s.processArtefactDeps4Upgrade(art, ss)
 			if err != nil {
+				s.release()
 				return err
 			}
 
 			if !admittedDeps {
+				s.rollback(cp)
 				// POST: Not all packages dependencies are admit by
 				//       the current system.
 				continue
 			}
+			s.release()
 
+			s.explain(pkgstr, art, ExplainSelected,
+				fmt.Sprintf("upgrade of the installed %s", dp[0].HumanReadableString()),
+				stack)
 			foundMatched = true
 			break
 
@@ -465,18 +535,25 @@ func (s *Solver) checkCandidate2Upgrade(pkgstr string, stack []string) error {
 				//       greather then the installed. I could just
 				//       validate the new requires.
 
+				cp := s.checkpoint()
 				ss := append(stack, art.GetPackage().PackageName())
 				// Check the package dependencies
 				admittedDeps, err := s.processArtefactDeps4Upgrade(art, ss)
 				if err != nil {
+					s.release()
 					return err
 				}
 
 				if !admittedDeps {
+					s.rollback(cp)
 					bannedVersion[candidate.GetVersion()] = true
 					continue
 				}
+				s.release()
 
+				s.explain(pkgstr, art, ExplainSelected,
+					fmt.Sprintf("rebuild of the installed %s", dp[0].HumanReadableString()),
+					stack)
 				foundMatched = true
 				break
 
@@ -515,20 +592,27 @@ func (s *Solver) checkCandidate2Upgrade(pkgstr string, stack []string) error {
 				continue
 			}
 
+			cp := s.checkpoint()
 			ss := append(stack, art.GetPackage().PackageName())
 			// Check and in queue all package dependencies
 			admittedDeps, err := s.processArtefactDeps4Upgrade(art, ss)
 			if err != nil {
+				s.release()
 				return err
 			}
 
 			if !admittedDeps {
+				s.rollback(cp)
 				// POST: Not all packages dependencies are admit by
 				//       the current system.
 				bannedVersion[candidate.GetVersion()] = true
 				continue
 			}
+			s.release()
 
+			s.explain(pkgstr, art, ExplainSelected,
+				fmt.Sprintf("downgrade of the installed %s", dp[0].HumanReadableString()),
+				stack)
 			foundMatched = true
 			break
 
@@ -556,7 +640,7 @@ func (s *Solver) checkCandidate2Upgrade(pkgstr string, stack []string) error {
 				p := art.GetPackage()
 				if len(p.Provides) > 0 {
 					for _, prov := range p.Provides {
-						s.providesMap.Add(prov.PackageName(), p)
+						s.addProvides(prov.PackageName(), p)
 					}
 				}
 				firstValid = true
@@ -564,7 +648,7 @@ func (s *Solver) checkCandidate2Upgrade(pkgstr string, stack []string) error {
 			validArts = append(validArts, art)
 		}
 
-		s.candidatesMap.Artifacts[pkgstr] = validArts
+		s.setCandidates(pkgstr, validArts)
 
 	} // else ignoring package to update.
 
@@ -599,7 +683,8 @@ func (s *Solver) processArtefactDeps4Upgrade(art *artifact.PackageArtifact, stac
 				if ok {
 					admit = false
 					for _, dv := range newDepsVersions {
-						a, _ := dv.GetPackage().Admit(candidate)
+						// The requires of the candidate must admit the new version.
+						a, _ := candidate.Admit(dv.GetPackage())
 						if a {
 							admit = true
 							break
@@ -608,6 +693,13 @@ func (s *Solver) processArtefactDeps4Upgrade(art *artifact.PackageArtifact, stac
 				}
 
 				if !admit {
+					if h := s.getHold(p.PackageName()); h != nil {
+						s.addHoldBlocked(candidate, val[0], h)
+					} else {
+						s.explain(candidate.PackageName(), art, ExplainSelector,
+							fmt.Sprintf("requires %s but is installed %s",
+								selectorString(p), val[0].HumanReadableString()), nil)
+					}
 					return false, nil
 				}
 			}
@@ -631,6 +723,8 @@ func (s *Solver) processArtefactDeps4Upgrade(art *artifact.PackageArtifact, stac
 					}
 				}
 				if provMatched {
+					s.explain(p.PackageName(), nil, ExplainProvides,
+						fmt.Sprintf("provided by %s", prov.HumanReadableString()), stack)
 					break
 				}
 			}
@@ -678,7 +772,7 @@ func (s *Solver) processArtefactDeps4Upgrade(art *artifact.PackageArtifact, stac
 		}
 		Debug(fmt.Sprintf("[%30s] Searching for dependency %s...",
 			candidate.PackageName(), searchOpts.Packages[0].PackageName()))
-		reposArtifacts, err := s.Searcher.SearchArtifacts(searchOpts)
+		reposArtifacts, err := s.searchArtifacts(searchOpts)
 		if err != nil {
 			return false, err
 		}
@@ -694,10 +788,12 @@ func (s *Solver) processArtefactDeps4Upgrade(art *artifact.PackageArtifact, stac
 				provStr = depArt.GetPackage().PackageName()
 			}
 
-			s.availableArtsMap.Add(depArt)
+			s.addAvailable(depArt)
 		}
 
 		if provStr != "" {
+			s.explain(p.PackageName(), nil, ExplainProvides,
+				fmt.Sprintf("provided by %s", provStr), stack)
 			err = s.resolvePackage(provStr, stack)
 		} else {
 			err = s.resolvePackage(p.PackageName(), stack)
@@ -774,7 +870,7 @@ func (s *Solver) checkInstalledPackage(p *pkg.DefaultPackage) error {
 	start := time.Now()
 
 	// Retrieve all new candidates from repositories.
-	reposArtifacts, err := s.Searcher.SearchArtifacts(searchOpts)
+	reposArtifacts, err := s.searchArtifacts(searchOpts)
 	if err != nil {
 		return err
 	}
@@ -808,6 +904,17 @@ func (s *Solver) checkInstalledPackage(p *pkg.DefaultPackage) error {
 	wagon.SortArtifactList4VersionAndRepos(
 		reposArtifacts, &s.MapRepos, true)
 
+	// Drop the versions not admitted by the hold of the package.
+	if h := s.getHold(p.PackageName()); h != nil {
+		reposArtifacts, err = s.filterHeldArtifacts(p, h, reposArtifacts)
+		if err != nil {
+			return err
+		}
+		if len(*reposArtifacts) == 0 {
+			return nil
+		}
+	}
+
 	// To avoid continue reinstall of the same package version
 	// with different hash on different repository. For a specific
 	// version I consider only the first.
@@ -935,6 +1042,7 @@ func (s *Solver) prepareSearcher() error {
 				return err
 			}
 			s.Searcher.SetMaskManager(maskManager)
+			s.maskManager = maskManager
 		}
 	}
 	return nil
@@ -0,0 +1,168 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package solver_test
+
+import (
+	"os"
+
+	"github.com/geaaru/luet/pkg/config"
+	pkg "github.com/geaaru/luet/pkg/package"
+	"github.com/geaaru/luet/pkg/v2/hold"
+	. "github.com/geaaru/luet/pkg/v2/solver"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Upgrade", func() {
+	var s *Solver
+	var db pkg.PackageDatabase
+	var cfg *config.LuetConfig
+
+	install := func(packages ...*pkg.DefaultPackage) {
+		for _, p := range packages {
+			_, err := db.CreatePackage(p)
+			Expect(err).ToNot(HaveOccurred())
+		}
+	}
+
+	sel := func(name, version string) *pkg.DefaultPackage {
+		return &pkg.DefaultPackage{Category: "test", Name: name, Version: version}
+	}
+
+	BeforeEach(func() {
+		cfg = config.LuetCfg
+		cfg.GetGeneral().Concurrency = 1
+		cfg.SystemRepositories = []config.LuetRepository{}
+		cfg.HoldsFile = ""
+
+		db = pkg.NewInMemoryDatabase(false)
+		s = NewSolver(cfg, NewSolverOpts())
+		s.SetDatabase(db)
+	})
+
+	It("Upgrades a package and the new dependency version", func() {
+		install(
+			newTestPackage("lib", "1.0"),
+			newTestPackage("app", "1.0", sel("lib", ">=1.0")),
+		)
+		s.Searcher = newTestSearcher(
+			newTestPackage("lib", "2.0"),
+			newTestPackage("app", "2.0", sel("lib", ">=2.0")),
+		)
+
+		_, update, _, err := s.Upgrade()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(artifactsNames(update.Artifacts)).To(ConsistOf(
+			"test/lib-2.0", "test/app-2.0",
+		))
+	})
+
+	It("Doesn't upgrade a package whose requires aren't admitted by the new dependency versions", func() {
+		// The new lib version has no requires and so it admits
+		// everything: the check must use the requires of the candidate.
+		install(
+			newTestPackage("lib", "1.0"),
+			newTestPackage("app", "1.0", sel("lib", ">=1.0")),
+		)
+		s.Searcher = newTestSearcher(
+			newTestPackage("lib", "2.0"),
+			newTestPackage("app", "2.0", sel("lib", ">=3.0")),
+		)
+
+		_, update, _, err := s.Upgrade()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(artifactsNames(update.Artifacts)).To(ConsistOf("test/lib-2.0"))
+	})
+
+	Context("Holds", func() {
+		var tmpdir string
+
+		addHold := func(h *hold.PackageHold) {
+			m := hold.NewPackagesHoldManager(cfg)
+			Expect(m.Load()).ToNot(HaveOccurred())
+			m.Add(h)
+			Expect(m.Save()).ToNot(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			var err error
+			tmpdir, err = os.MkdirTemp("", "holds")
+			Expect(err).ToNot(HaveOccurred())
+			cfg.ConfigFromHost = false
+			cfg.GetSystem().Rootfs = tmpdir
+			cfg.HoldsFile = "/etc/luet/holds.yaml"
+		})
+
+		AfterEach(func() {
+			cfg.GetSystem().Rootfs = "/"
+			cfg.HoldsFile = ""
+			os.RemoveAll(tmpdir)
+		})
+
+		It("Skips the update of a held package", func() {
+			install(newTestPackage("lib", "1.0"), newTestPackage("app", "1.0"))
+			s.Searcher = newTestSearcher(
+				newTestPackage("lib", "2.0"),
+				newTestPackage("app", "2.0"),
+			)
+			addHold(&hold.PackageHold{Package: "test/lib"})
+
+			_, update, _, err := s.Upgrade()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(artifactsNames(update.Artifacts)).To(ConsistOf("test/app-2.0"))
+
+			report := s.GetHoldReport()
+			Expect(report.Skipped).To(HaveLen(1))
+			Expect(report.Skipped[0].Package).To(Equal("test/lib-1.0"))
+			Expect(report.Skipped[0].Hold).To(Equal("test/lib"))
+		})
+
+		It("Upgrades a held package only to the versions of the selector", func() {
+			install(newTestPackage("lib", "1.0"))
+			s.Searcher = newTestSearcher(
+				newTestPackage("lib", "1.1"),
+				newTestPackage("lib", "2.0"),
+			)
+			addHold(&hold.PackageHold{Package: "test/lib", Selector: "<2.0"})
+
+			_, update, _, err := s.Upgrade()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(artifactsNames(update.Artifacts)).To(ConsistOf("test/lib-1.1"))
+		})
+
+		It("Blocks the candidate that requires a change of a held package", func() {
+			install(
+				newTestPackage("lib", "1.0"),
+				newTestPackage("app", "1.0", sel("lib", ">=1.0")),
+			)
+			s.Searcher = newTestSearcher(
+				newTestPackage("lib", "2.0"),
+				newTestPackage("app", "2.0", sel("lib", ">=2.0")),
+			)
+			addHold(&hold.PackageHold{Package: "test/lib"})
+
+			_, update, _, err := s.Upgrade()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(update.Artifacts).To(BeEmpty())
+
+			report := s.GetHoldReport()
+			Expect(report.Blocked).To(HaveLen(1))
+			Expect(report.Blocked[0].Candidate).To(Equal("test/app-2.0"))
+			Expect(report.Blocked[0].Package).To(Equal("test/lib-1.0"))
+		})
+
+		It("Ignores the holds with IgnoreHolds", func() {
+			install(newTestPackage("lib", "1.0"))
+			s.Searcher = newTestSearcher(newTestPackage("lib", "2.0"))
+			addHold(&hold.PackageHold{Package: "test/lib"})
+			s.Opts.IgnoreHolds = true
+
+			_, update, _, err := s.Upgrade()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(artifactsNames(update.Artifacts)).To(ConsistOf("test/lib-2.0"))
+		})
+	})
+})
@@ -0,0 +1,65 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package solver
+
+import (
+	"errors"
+	"fmt"
+
+	pkg "github.com/geaaru/luet/pkg/package"
+)
+
+// Why returns the chains of the installed packages that require the
+// installed package in input. Every chain starts with a package
+// selected by the user and ends with the package in input.
+// For every package selected by the user is returned the
+// shortest chain.
+func (s *Solver) Why(p *pkg.DefaultPackage) ([][]*pkg.DefaultPackage, error) {
+	if s.Database == nil {
+		return nil, errors.New("Solver Why requires Database")
+	}
+
+	systemPkgs := s.Database.World()
+	dmap := newInstalledDepsMap(&systemPkgs)
+
+	target, ok := dmap.packages[p.PackageName()]
+	if !ok {
+		return nil, fmt.Errorf("Package %s is not installed", p.PackageName())
+	}
+
+	ans := [][]*pkg.DefaultPackage{}
+	if dmap.isWorld(target) {
+		ans = append(ans, []*pkg.DefaultPackage{target})
+	}
+
+	// Visit the reverse dependencies in breadth-first order
+	// storing the chain from the target.
+	visited := map[string]bool{target.PackageName(): true}
+	queue := [][]*pkg.DefaultPackage{{target}}
+	for len(queue) > 0 {
+		chain := queue[0]
+		queue = queue[1:]
+
+		for _, r := range dmap.rdeps[chain[len(chain)-1].PackageName()] {
+			if visited[r.PackageName()] {
+				continue
+			}
+			visited[r.PackageName()] = true
+
+			rchain := append(append([]*pkg.DefaultPackage{}, chain...), r)
+			if dmap.isWorld(r) {
+				// Reverse the chain to start with the package selected.
+				c := make([]*pkg.DefaultPackage, len(rchain))
+				for i := range rchain {
+					c[len(rchain)-1-i] = rchain[i]
+				}
+				ans = append(ans, c)
+			}
+			queue = append(queue, rchain)
+		}
+	}
+
+	return ans, nil
+}
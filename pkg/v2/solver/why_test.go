@@ -0,0 +1,85 @@
+/*
+Copyright © 2023 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package solver_test
+
+import (
+	"github.com/geaaru/luet/pkg/config"
+	pkg "github.com/geaaru/luet/pkg/package"
+	. "github.com/geaaru/luet/pkg/v2/solver"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Why", func() {
+	var s *Solver
+	var db pkg.PackageDatabase
+
+	newPackage := func(name, reason string, requires ...*pkg.DefaultPackage) *pkg.DefaultPackage {
+		p := &pkg.DefaultPackage{
+			Category: "test", Name: name, Version: "1.0",
+			PackageRequires: requires,
+		}
+		if reason != "" {
+			p.SetInstallReason(reason)
+		}
+		_, err := db.CreatePackage(p)
+		Expect(err).ToNot(HaveOccurred())
+		return p
+	}
+
+	sel := func(name string) *pkg.DefaultPackage {
+		return &pkg.DefaultPackage{Category: "test", Name: name, Version: ">=0"}
+	}
+
+	chains := func(ans [][]*pkg.DefaultPackage) [][]string {
+		ret := [][]string{}
+		for _, chain := range ans {
+			names := []string{}
+			for _, p := range chain {
+				names = append(names, p.GetName())
+			}
+			ret = append(ret, names)
+		}
+		return ret
+	}
+
+	BeforeEach(func() {
+		db = pkg.NewInMemoryDatabase(false)
+		s = NewSolver(config.LuetCfg, NewSolverOpts())
+		s.SetDatabase(db)
+	})
+
+	It("Returns the chain from the package selected by the user", func() {
+		// app -> lib -> base
+		newPackage("base", pkg.InstallReasonDep)
+		newPackage("lib", pkg.InstallReasonDep, sel("base"))
+		newPackage("app", pkg.InstallReasonExplicit, sel("lib"))
+
+		ans, err := s.Why(sel("base"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(chains(ans)).To(Equal([][]string{{"app", "lib", "base"}}))
+	})
+
+	It("Returns the chain from a package without install reason", func() {
+		// legacy -> base <- lib
+		newPackage("base", pkg.InstallReasonDep)
+		newPackage("lib", pkg.InstallReasonDep, sel("base"))
+		newPackage("legacy", "", sel("base"))
+
+		ans, err := s.Why(sel("base"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(chains(ans)).To(Equal([][]string{{"legacy", "base"}}))
+		Expect(ans[0][0].HasInstallReason()).To(BeFalse())
+	})
+
+	It("Returns the package selected by the user", func() {
+		newPackage("app", pkg.InstallReasonExplicit)
+
+		ans, err := s.Why(sel("app"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(chains(ans)).To(Equal([][]string{{"app"}}))
+	})
+})
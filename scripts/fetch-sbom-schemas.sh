@@ -0,0 +1,21 @@
+#!/usr/bin/env bash
+# Copyright © 2023 Macaroni OS Linux
+# See AUTHORS and LICENSE for the license details and contributors.
+#
+# Downloads the official JSON schemas used by the tests of pkg/sbom
+# to validate the SPDX 2.3 and the CycloneDX 1.4 documents.
+# The files are stored unmodified.
+
+set -euo pipefail
+
+dir=${1:-$(dirname "$0")/../pkg/sbom/testdata}
+mkdir -p "${dir}"
+
+spdx=https://raw.githubusercontent.com/spdx/spdx-spec/v2.3/schemas
+cdx=https://raw.githubusercontent.com/CycloneDX/specification/1.4/schema
+
+curl -fsSL -o "${dir}/spdx-2.3.schema.json" "${spdx}/spdx-schema.json"
+# The CycloneDX schema references the SPDX licenses and the JSF schemas.
+curl -fsSL -o "${dir}/bom-1.4.schema.json" "${cdx}/bom-1.4.schema.json"
+curl -fsSL -o "${dir}/spdx.schema.json" "${cdx}/spdx.schema.json"
+curl -fsSL -o "${dir}/jsf-0.82.schema.json" "${cdx}/jsf-0.82.schema.json"